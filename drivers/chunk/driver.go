@@ -16,6 +16,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/fs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
 	"github.com/OpenListTeam/OpenList/v4/internal/sign"
 	"github.com/OpenListTeam/OpenList/v4/internal/stream"
 	"github.com/OpenListTeam/OpenList/v4/pkg/errgroup"
@@ -205,7 +206,7 @@ func (d *Chunk) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 					if !d.Thumbnail {
 						result[resultIdx] = &objRes
 					} else {
-						thumbPath := stdpath.Join(args.ReqPath, ".thumbnails", name+".webp")
+						thumbPath := stdpath.Join(args.ReqPath, setting.ThumbnailDirName(), name+".webp")
 						thumb := fmt.Sprintf("%s/d%s?sign=%s",
 							common.GetApiUrl(ctx),
 							utils.EncodePath(thumbPath, true),
@@ -415,7 +416,7 @@ func (d *Chunk) Put(ctx context.Context, dstDir model.Obj, file model.FileStream
 	if err != nil {
 		return err
 	}
-	if (d.Thumbnail && dstDir.GetName() == ".thumbnails") || (d.ChunkLargeFileOnly && file.GetSize() <= d.PartSize) {
+	if (d.Thumbnail && dstDir.GetName() == setting.ThumbnailDirName()) || (d.ChunkLargeFileOnly && file.GetSize() <= d.PartSize) {
 		return op.Put(ctx, remoteStorage, stdpath.Join(remoteActualPath, dstDir.GetPath()), file, up)
 	}
 	upReader := &driver.ReaderUpdatingProgress{
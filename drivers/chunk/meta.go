@@ -14,7 +14,7 @@ type Addition struct {
 	StoreHash          bool   `json:"store_hash" type:"bool" default:"true"`
 	NumListWorkers     int    `json:"num_list_workers" required:"true" type:"number" default:"5"`
 
-	Thumbnail  bool `json:"thumbnail" required:"true" default:"false" help:"enable thumbnail which pre-generated under .thumbnails folder"`
+	Thumbnail  bool `json:"thumbnail" required:"true" default:"false" help:"enable thumbnail which pre-generated under the thumbnail_dir_name folder"`
 	ShowHidden bool `json:"show_hidden"  default:"true" required:"false" help:"show hidden directories and files"`
 }
 
@@ -16,6 +16,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/fs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
 	"github.com/OpenListTeam/OpenList/v4/internal/sign"
 	"github.com/OpenListTeam/OpenList/v4/internal/stream"
 	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
@@ -150,7 +151,7 @@ func (d *Crypt) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 			result = append(result, objRes)
 			continue
 		}
-		thumbPath := stdpath.Join(args.ReqPath, ".thumbnails", name+".webp")
+		thumbPath := stdpath.Join(args.ReqPath, setting.ThumbnailDirName(), name+".webp")
 		thumb := fmt.Sprintf("%s/d%s?sign=%s",
 			common.GetApiUrl(ctx),
 			utils.EncodePath(thumbPath, true),
@@ -443,4 +443,9 @@ func (d *Local) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
 	}, nil
 }
 
+func (d *Local) DeclareCapabilities(caps *driver.StorageCapabilities) {
+	caps.Mtime = true
+	caps.LocalFiles = true
+}
+
 var _ driver.Driver = (*Local)(nil)
@@ -121,7 +121,7 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.TextTypes, Value: "txt,htm,html,xml,java,properties,sql,js,md,json,conf,ini,vue,php,py,bat,gitignore,yml,go,sh,c,cpp,h,hpp,tsx,vtt,srt,ass,rs,lrc,strm", Type: conf.TypeText, Group: model.PREVIEW, Flag: model.PRIVATE},
 		{Key: conf.AudioTypes, Value: "mp3,flac,ogg,m4a,wav,opus,wma", Type: conf.TypeText, Group: model.PREVIEW, Flag: model.PRIVATE},
 		{Key: conf.VideoTypes, Value: "mp4,mkv,avi,mov,rmvb,webm,flv,m3u8", Type: conf.TypeText, Group: model.PREVIEW, Flag: model.PRIVATE},
-		{Key: conf.ImageTypes, Value: "jpg,tiff,jpeg,png,gif,bmp,svg,ico,swf,webp,avif", Type: conf.TypeText, Group: model.PREVIEW, Flag: model.PRIVATE},
+		{Key: conf.ImageTypes, Value: "jpg,tiff,jpeg,png,gif,bmp,svg,ico,swf,webp,avif,heic,heif", Type: conf.TypeText, Group: model.PREVIEW, Flag: model.PRIVATE},
 		//{Key: conf.OfficeTypes, Value: "doc,docx,xls,xlsx,ppt,pptx", Type: conf.TypeText, Group: model.PREVIEW, Flag: model.PRIVATE},
 		{Key: conf.ProxyTypes, Value: "m3u8,url", Type: conf.TypeText, Group: model.PREVIEW, Flag: model.PRIVATE},
 		{Key: conf.ProxyIgnoreHeaders, Value: "authorization,referer", Type: conf.TypeText, Group: model.PREVIEW, Flag: model.PRIVATE},
@@ -180,9 +180,11 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.HandleHookAfterWriting, Value: "false", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE},
 		{Key: conf.HandleHookRateLimit, Value: "0", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE},
 		{Key: conf.IgnoreSystemFiles, Value: "false", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `When enabled, ignores common system files during upload (.DS_Store, desktop.ini, Thumbs.db, and files starting with ._)`},
+		{Key: conf.HidePathErrors, Value: "true", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `When enabled, non-admin users get a generic "access denied" for a rejected path, the detailed error is only logged`},
 
 		// single settings
 		{Key: conf.Token, Value: token, Type: conf.TypeString, Group: model.SINGLE, Flag: model.PRIVATE},
+		{Key: conf.SignSecret, Value: random.Token(), Type: conf.TypeString, Group: model.SINGLE, Flag: model.PRIVATE, Help: `secret of signed links, changing it invalidates all of them`},
 		{Key: conf.SearchIndex, Value: "none", Type: conf.TypeSelect, Options: "database,database_non_full_text,bleve,meilisearch,none", Group: model.INDEX},
 		{Key: conf.AutoUpdateIndex, Value: "false", Type: conf.TypeBool, Group: model.INDEX},
 		{Key: conf.IgnorePaths, Value: "", Type: conf.TypeText, Group: model.INDEX, Flag: model.PRIVATE, Help: `one path per line`},
@@ -242,6 +244,79 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.StreamMaxClientUploadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
 		{Key: conf.StreamMaxServerDownloadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
 		{Key: conf.StreamMaxServerUploadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
+
+		// upload settings
+		{Key: conf.UploadUniformResponse, Value: "false", Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `When enabled, upload responses always use the uniform shape, same as sending "Accept-Version: 2"`},
+		{Key: conf.UploadContentTypeRoutes, Value: "{}", Type: conf.TypeText, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `JSON object mapping a MIME family or type to a directory, e.g. {"video":"/media/videos","image":"/media/images"}. Applied to uploads sent with "Auto-Route: true", the file name is preserved`},
+		{Key: conf.DateOrganizeTemplate, Value: "YYYY/MM/DD", Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Path template beneath the target dir for uploads sent with "Date-Organize: true", YYYY, MM and DD are replaced by the capture date`},
+		{Key: conf.MaxUploadSize, Value: "0", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Maximum size in bytes of a tus upload, advertised as Tus-Max-Size, and of an archive built by /api/fs/archive/upload. 0 means no limit`},
+		{Key: conf.DefaultOverwrite, Value: "true", Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Whether uploads replace existing files when the client sends no Overwrite header. An explicit header always wins`},
+		{Key: conf.UploadQueueTimeout, Value: "0", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Seconds an upload waits for a slot when its storage reached max_concurrent_uploads, 0 rejects it right away with 429`},
+		{Key: conf.IgnoreInvalidHash, Value: "false", Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `When enabled, a malformed X-File-Md5/Sha1/Sha256 header is dropped with a warning instead of failing the upload with 400`},
+		{Key: conf.MaxConcurrentUploadsPerIP, Value: "0", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Maximum simultaneous uploads from a single client IP, further ones get 429. 0 means no limit`},
+		{Key: conf.UploadTrustedProxies, Value: "", Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Comma separated IPs or CIDRs of reverse proxies whose X-Forwarded-For is used to find the client IP of uploads, e.g. 127.0.0.1,10.0.0.0/8`},
+		{Key: conf.ProgressCallbackSecret, Value: random.Token(), Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `HMAC-SHA256 key of the X-Callback-Signature sent with Progress-Callback-Url requests`},
+		{Key: conf.RejectEmptyUploads, Value: "false", Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `When enabled, zero-byte uploads through /api/fs/put and /api/fs/form fail with 400 instead of creating an empty file`},
+		{Key: conf.TusChunkSize, Value: "8388608", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Chunk size in bytes the server prefers for tus uploads, returned as Upload-Chunk-Size when an upload is created. Every PATCH but the last must carry exactly the negotiated size. 0 lets clients send chunks of any size`},
+		{Key: conf.TusUploadTTL, Value: "86400", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Seconds a tus upload is kept after its last chunk or keepalive, sent as Upload-Expires. Expired uploads are purged with their partial data. 0 keeps them forever`},
+		{Key: conf.MirrorUploadsAllMustSucceed, Value: "false", Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Fail an upload when putting it into any of its Mirror-Paths fails. Otherwise failed mirrors are only reported in the response. Nothing is rolled back either way, the file at File-Path and the mirrors already written are kept`},
+		{Key: conf.UploadDeadLetterTTL, Value: "0", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Hours the data of a failed As-Task upload is kept, so it can be retried from /api/fs/dead_letter without uploading it again. 0 disables keeping failed uploads`},
+		{Key: conf.UploadDeadLetterMaxSize, Value: "1073741824", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Maximum total bytes of kept failed uploads, the oldest are removed first. 0 means no limit`},
+		{Key: conf.UploadModerationURL, Value: "", Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `When set, images and videos uploaded by /api/fs/put, /api/fs/form and tus, or retried from /api/fs/dead_letter, are put into .quarantine next to their destination and POSTed here as {id, path, mimetype, size, username, download_url}. Only admins and the signed download_url reach them until then. They go live once approved, by answering {"decision": "approve"} or through /api/admin/quarantine`},
+		{Key: conf.UploadModerationSecret, Value: random.Token(), Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Key of the HMAC-SHA256 X-Callback-Signature of moderation requests`},
+		{Key: conf.KeepPreviousVersions, Value: "false", Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Rename a file overwritten by an upload by version_name_template instead of replacing it`},
+		{Key: conf.VersionNameTemplate, Value: "{name}{ext}.bak.{seq}", Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Name of a kept previous version, with {name} (without extension), {ext} (with the dot), {timestamp} (20060102150405) and {seq}, the lowest unused number. {seq} is required`},
+		{Key: conf.UploadTempBufferThreshold, Value: "0", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Bytes of an /api/fs/put or /api/fs/form upload buffered in memory when it must be cached whole (As-Task, unknown size, drivers needing a seekable file), larger ones spill to a temp file. An upload of unknown size spills once it reaches the threshold. Drivers uploading a stream of known size in parts ignore it, their part buffers keep max_buffer_limitMB of the config. 0 uses max_buffer_limitMB of the config`},
+		{Key: conf.StripExifOnUpload, Value: "false", Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Remove EXIF (GPS, device), XMP, IPTC and comments from uploaded JPEG images without re-encoding them, the orientation is kept. The stored bytes change, so hashes sent with the upload are replaced by those of the stripped image. The Strip-Exif header overrides it per upload`},
+		{Key: conf.MinFreeSpace, Value: "", Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Free space left on the storage after an upload, bytes or a percentage of the total space like 5%. Uploads which would go below are refused with 507. Only applies to storages reporting their space. Empty disables it`},
+		{Key: conf.ConflictRenameTemplate, Value: "{name} ({seq}){ext}", Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Name picked by an upload with "Overwrite: rename" when the file exists, with {name} (without extension), {ext} (with the dot), {timestamp} (20060102150405) and {seq}, the lowest unused number. {seq} is required`},
+		{Key: conf.UploadFileMode, Value: "", Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Mode of files uploaded to storages on the local disk, in octal, e.g. 0644, or "inherit" for the mode of their directory without the execute bits. Empty keeps the mode the storage created them with. Other storages are unaffected`},
+		{Key: conf.UploadTaskRetention, Value: "0", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Seconds finished upload tasks are kept in the task list before being removed, checked every 10 minutes. Running and pending tasks are never removed. 0 keeps them until cleared`},
+		{Key: conf.UploadResponseFormat, Value: "json", Type: conf.TypeSelect, Options: "json,text,empty", Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Body of successful upload responses for clients which don't ask for one with Accept: json, text (the path of the uploaded file as text/plain) or empty (204, or 202 for buffered uploads). Clients sending "Accept: text/plain" or "Accept: application/json" get that format`},
+		{Key: conf.UploadsPaused, Value: "false", Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Reject every upload but the ones of admins with 503 and a Retry-After of 5 minutes, e.g. during maintenance. Covers the upload API, tus, archive uploads, direct uploads and WebDAV PUT`},
+		{Key: conf.UploadsPausedMessage, Value: "", Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Message of the uploads rejected by uploads_paused, empty for a generic one`},
+		{Key: conf.PathUnicodeNormalization, Value: "none", Type: conf.TypeSelect, Options: "none,nfc,nfd", Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Unicode normalization of the paths of uploads, so names sent composed (nfc) by most clients and decomposed (nfd) by macOS ones are the same file for overwrite and existence checks`},
+		{Key: conf.UploadStagingTTL, Value: "86400", Type: conf.TypeNumber, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Seconds an upload sent with "Staged: true" is kept when it's neither committed nor aborted, 0 keeps it until then`},
+		{Key: conf.ExistenceProbeConsistency, Value: "weak", Type: conf.TypeSelect, Options: "weak,strong", Group: model.UPLOAD, Flag: model.PRIVATE, Help: `How uploads check whether their path exists before deciding to overwrite. weak trusts the cached listing and a single probe. strong drops the cached listing and probes again after 100, 200 then 400ms until two probes agree, for eventually consistent storages where a file deleted just before may still be listed. strong adds at least 100ms, up to 700ms, to every upload`},
+		{Key: conf.VerifyUploadHash, Value: "false", Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE, Help: `Check the content of stream and form uploads against their X-File-Md5, X-File-Sha1 and X-File-Sha256 headers, a mismatch is rejected with 400 naming the header and the written file is removed. Algorithms without header aren't checked. The Verify-Hash header (true or false) overrides it per upload`},
+
+		// thumbnail settings
+		{Key: conf.ExtractSubtitles, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `When enabled, embedded text subtitles of uploaded videos are extracted to sidecar .srt files`},
+		{Key: conf.ImageThumbnails, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `When enabled, thumbnails are also generated for uploaded images, turned upright by their EXIF orientation`},
+		{Key: conf.FFprobeTimeout, Value: "30", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Seconds before a ffprobe run is killed, 0 means no limit`},
+		{Key: conf.FFmpegTimeout, Value: "300", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Seconds before a ffmpeg run is killed together with its children, 0 means no limit`},
+		{Key: conf.ThumbnailSchedule, Value: "", Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Daily windows allowed to generate thumbnails, e.g. 01:00-06:00,22:00-23:30. Thumbnails of uploads outside the windows are queued until a window opens. Empty means any time`},
+		{Key: conf.ThumbnailStoreMode, Value: "folder", Type: conf.TypeSelect, Options: "folder,central,storage", Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `folder: .thumbnails next to the file; central: a directory named by the path hash; storage: mirrored tree in a dedicated storage`},
+		{Key: conf.ThumbnailStorePath, Value: "/.thumbnails", Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Directory of the central mode, or mount path of the dedicated storage`},
+		{Key: conf.InlineThumbnailMaxSize, Value: "16384", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Largest thumbnail in bytes embedded into listings requested with inline_thumbnail, at most 65536. 0 disables inlining`},
+		{Key: conf.LazyThumbnails, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `When enabled, missing thumbnails of the images and videos in a listed folder are generated in the background`},
+		{Key: conf.LazyThumbnailRate, Value: "60", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Maximum thumbnails queued per minute by lazy_thumbnails, so browsing doesn't flood the generator`},
+		{Key: conf.ThumbnailCacheControl, Value: "private, no-cache", Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Cache-Control of served thumbnails, e.g. "public, max-age=86400" to let a CDN cache them. A max-age also sets Expires. Empty sends no cache headers besides ETag`},
+		{Key: conf.ThumbnailFrames, Value: "cover,3", Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Positions tried in order for video thumbnails, "cover" for the embedded cover or a percentage of the duration, e.g. cover,10,50,3`},
+		{Key: conf.ThumbnailBlackThreshold, Value: "16", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Frames with a mean luminance (0-255) below this are black and the next position of thumbnail_frames is tried. 0 disables the check`},
+		{Key: conf.ThumbnailFrameCacheTTL, Value: "60", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Seconds extracted full resolution video frames are kept for reuse after their last use. 0 keeps them only while a thumbnail is being generated`},
+		{Key: conf.ThumbnailFrameCacheSize, Value: "67108864", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Maximum total bytes of cached video frames, the least recently used are evicted first`},
+		{Key: conf.ThumbnailToneMapping, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Tone-map frames of HDR videos (PQ or HLG) to SDR before they are scaled. Requires ffmpeg built with zimg (the zscale filter), otherwise frames are extracted as is`},
+		{Key: conf.DefaultThumbnailPath, Value: "", Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Path of a placeholder image served for files without thumbnail, with X-Thumbnail-State generating, failed, unsupported or missing. Empty answers 404`},
+		{Key: conf.ThumbnailConcurrency, Value: "2", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Maximum thumbnails generated at the same time, the others wait for a free slot. 0 is unlimited`},
+		{Key: conf.RegenerateThumbnailOnOverwrite, Value: "true", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Record the modified time, size and hashes of the source in the sidecar, and generate the thumbnail again once the file was overwritten`},
+		{Key: conf.ThumbnailAttachments, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Generate a thumbnail of every cover attached to a video, e.g. .thumbnails/<base>_front.webp, and use the front cover (or the first) as the thumbnail of the video instead of a frame`},
+		{Key: conf.StripGPSFromResponse, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Leave the GPS position out of /api/fs/media/exif, the files themselves are unchanged`},
+		{Key: conf.ThumbnailRemoteFetchSize, Value: "0", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Bytes downloaded from the head of videos on storages without local files to generate their thumbnail, the whole video is downloaded only when ffmpeg fails on them, e.g. to seek past the downloaded part. 0 generates no thumbnails on such storages`},
+		{Key: conf.ThumbnailPHash, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Compute a perceptual hash of every generated thumbnail, so /api/fs/similar finds visually duplicate images and videos whose bytes differ, e.g. re-encoded`},
+		{Key: conf.EnableBlurhash, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Compute a blurhash of every generated thumbnail, returned as blurhash in listings, /api/fs/get and /api/fs/thumbnail/status for clients to draw a blurred placeholder while the thumbnail loads. Kept in the database, thumbnails generated while disabled have none until regenerated`},
+		{Key: conf.FFmpegPath, Value: "", Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Path of the ffmpeg executable, ffprobe is taken from the same directory. Empty looks both up in PATH. Without ffmpeg no thumbnail is generated`},
+		{Key: conf.FFmpegMissingWarning, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Add a warning to the response of image and video uploads when ffmpeg is unavailable, so clients know no thumbnail is coming`},
+		{Key: conf.FolderThumbnails, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Give directories a thumbnail in listings: .thumbnails/folder.webp or a poster image (poster.webp, poster.jpg, poster.png, folder.jpg) in the directory, otherwise the thumbnail of its first video. Listed directories always get the /api/fs/thumbnail URL, which looks for the thumbnail when requested and answers 404 when there is none`},
+		{Key: conf.ThumbnailFormats, Value: "", Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Comma separated formats (webp, jpeg, png) every thumbnail is also encoded in from the same frame, e.g. webp,jpeg. /api/fs/thumbnail serves the one the Accept header prefers. Formats ffmpeg can't encode are skipped with a warning`},
+		{Key: conf.EnableDominantColor, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Record the dominant color (#rrggbb) of every generated thumbnail, returned as dominant_color in listings, /api/fs/get and /api/fs/thumbnail/status, e.g. to tint cards while the thumbnail loads. Kept in the database, thumbnails generated while disabled have none until regenerated`},
+		{Key: conf.ThumbnailVerify, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Extract a small frame again after generating the thumbnail of a video and compare their perceptual hashes, generating the thumbnail once more without the frame cache when they differ. Costs an extra extraction, meant for debugging`},
+		{Key: conf.ThumbnailFilmstripFrames, Value: "100", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Frames of the filmstrip sprite of videos, evenly spaced and tiled 10 per row at 160x90, at most 300. The filmstrip is generated with the thumbnail of videos whose .thumbnail.json or Thumbnail-Filmstrip header enables it, and served with its WebVTT by /api/fs/thumbnail/filmstrip`},
+		{Key: conf.LivePhotos, Value: "false", Type: conf.TypeBool, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Pair the still (.heic, .heif, .jpg, .jpeg) and the motion (.mov) of live photos sharing their directory and name, e.g. IMG_0001.HEIC and IMG_0001.MOV. The pair is recorded in the sidecars and returned as live_photo in listings, and the motion uses the thumbnail of the still. HEIC stills need image_thumbnails and an ffmpeg able to decode HEIF, e.g. 7.0 or later`},
+		{Key: conf.ThumbnailCacheMaxBytes, Value: "0", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Maximum total bytes of the local disk cache of served thumbnails, kept in the data dir. /api/fs/thumbnail reads a cached thumbnail instead of the thumbnail store, the least recently served are evicted first. Hit rate and evictions are reported by /api/admin/thumbnail/cache. 0 disables the cache and deletes what it holds`},
+		{Key: conf.ThumbnailWidth, Value: "320", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Width in pixels thumbnails are scaled to, keeping the aspect ratio, from 16 to 4096. Thumbnail-Width headers and .thumbnail.json take precedence`},
+		{Key: conf.ThumbnailQuality, Value: "80", Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Quality of webp thumbnails from 0 to 100, higher is larger and sharper`},
+		{Key: conf.ThumbnailDirName, Value: ".thumbnails", Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE, Help: `Name of the directory next to the files holding their thumbnails and sidecars in the folder mode, and their sidecars in every mode. Existing thumbnails aren't moved when it changes, the crypt and chunk drivers look for thumbnails in it as well`},
 	}
 	additionalSettingItems := tool.Tools.Items()
 	// 固定顺序
@@ -59,6 +59,7 @@ const (
 	HandleHookAfterWriting  = "handle_hook_after_writing"
 	HandleHookRateLimit     = "handle_hook_rate_limit"
 	IgnoreSystemFiles       = "ignore_system_files"
+	HidePathErrors          = "hide_path_errors"
 
 	// index
 	SearchIndex     = "search_index"
@@ -97,6 +98,7 @@ const (
 
 	// single
 	Token         = "token"
+	SignSecret    = "sign_secret"
 	IndexProgress = "index_progress"
 
 	// SSO
@@ -161,6 +163,79 @@ const (
 	StreamMaxClientUploadSpeed            = "max_client_upload_speed"
 	StreamMaxServerDownloadSpeed          = "max_server_download_speed"
 	StreamMaxServerUploadSpeed            = "max_server_upload_speed"
+
+	// upload
+	UploadUniformResponse       = "upload_uniform_response"
+	UploadContentTypeRoutes     = "upload_content_type_routes"
+	DateOrganizeTemplate        = "date_organize_template"
+	MaxUploadSize               = "max_upload_size"
+	DefaultOverwrite            = "default_overwrite"
+	UploadQueueTimeout          = "upload_queue_timeout"
+	IgnoreInvalidHash           = "ignore_invalid_hash"
+	MaxConcurrentUploadsPerIP   = "max_concurrent_uploads_per_ip"
+	UploadTrustedProxies        = "upload_trusted_proxies"
+	ProgressCallbackSecret      = "progress_callback_secret"
+	RejectEmptyUploads          = "reject_empty_uploads"
+	TusChunkSize                = "tus_chunk_size"
+	TusUploadTTL                = "tus_upload_ttl"
+	MirrorUploadsAllMustSucceed = "mirror_uploads_all_must_succeed"
+	UploadDeadLetterTTL         = "upload_dead_letter_ttl"
+	UploadDeadLetterMaxSize     = "upload_dead_letter_max_size"
+	UploadModerationURL         = "upload_moderation_url"
+	UploadModerationSecret      = "upload_moderation_secret"
+	KeepPreviousVersions        = "keep_previous_versions"
+	VersionNameTemplate         = "version_name_template"
+	UploadTempBufferThreshold   = "upload_temp_buffer_threshold"
+	StripExifOnUpload           = "strip_exif_on_upload"
+	MinFreeSpace                = "min_free_space"
+	ConflictRenameTemplate      = "conflict_rename_template"
+	UploadFileMode              = "upload_file_mode"
+	UploadTaskRetention         = "upload_task_retention"
+	UploadResponseFormat        = "upload_response_format"
+	UploadsPaused               = "uploads_paused"
+	UploadsPausedMessage        = "uploads_paused_message"
+	PathUnicodeNormalization    = "path_unicode_normalization"
+	UploadStagingTTL            = "upload_staging_ttl"
+	ExistenceProbeConsistency   = "existence_probe_consistency"
+	VerifyUploadHash            = "verify_upload_hash"
+
+	// thumbnail
+	ExtractSubtitles               = "extract_subtitles"
+	ImageThumbnails                = "image_thumbnails"
+	FFprobeTimeout                 = "ffprobe_timeout"
+	FFmpegTimeout                  = "ffmpeg_timeout"
+	ThumbnailSchedule              = "thumbnail_schedule"
+	ThumbnailStoreMode             = "thumbnail_store_mode"
+	ThumbnailStorePath             = "thumbnail_store_path"
+	InlineThumbnailMaxSize         = "inline_thumbnail_max_size"
+	LazyThumbnails                 = "lazy_thumbnails"
+	LazyThumbnailRate              = "lazy_thumbnail_rate"
+	ThumbnailCacheControl          = "thumbnail_cache_control"
+	ThumbnailFrames                = "thumbnail_frames"
+	ThumbnailBlackThreshold        = "thumbnail_black_threshold"
+	ThumbnailFrameCacheTTL         = "thumbnail_frame_cache_ttl"
+	ThumbnailFrameCacheSize        = "thumbnail_frame_cache_size"
+	ThumbnailToneMapping           = "thumbnail_tone_mapping"
+	DefaultThumbnailPath           = "default_thumbnail_path"
+	ThumbnailConcurrency           = "thumbnail_concurrency"
+	RegenerateThumbnailOnOverwrite = "regenerate_thumbnail_on_overwrite"
+	ThumbnailAttachments           = "thumbnail_attachments"
+	StripGPSFromResponse           = "strip_gps_from_response"
+	ThumbnailRemoteFetchSize       = "thumbnail_remote_fetch_size"
+	ThumbnailPHash                 = "thumbnail_phash"
+	EnableBlurhash                 = "enable_blurhash"
+	FFmpegPath                     = "ffmpeg_path"
+	FFmpegMissingWarning           = "ffmpeg_missing_warning"
+	FolderThumbnails               = "folder_thumbnails"
+	ThumbnailFormats               = "thumbnail_formats"
+	EnableDominantColor            = "enable_dominant_color"
+	ThumbnailVerify                = "thumbnail_verify"
+	ThumbnailFilmstripFrames       = "thumbnail_filmstrip_frames"
+	LivePhotos                     = "live_photos"
+	ThumbnailCacheMaxBytes         = "thumbnail_cache_max_bytes"
+	ThumbnailWidth                 = "thumbnail_width"
+	ThumbnailQuality               = "thumbnail_quality"
+	ThumbnailDirName               = "thumbnail_dir_name"
 )
 
 const (
@@ -191,4 +266,7 @@ const (
 	PathKey
 	SharingIDKey
 	SkipHookKey
+	TargetStorageKey
+	// VerifyHashKey makes an upload task check its cached body against the hashes of the stream
+	VerifyHashKey
 )
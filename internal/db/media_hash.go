@@ -0,0 +1,35 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+// SaveMediaHash replaces the hash recorded for h.Path
+func SaveMediaHash(h *model.MediaHash) error {
+	if old, err := GetMediaHash(h.Path); err == nil {
+		h.ID = old.ID
+	}
+	return errors.WithStack(db.Save(h).Error)
+}
+
+func GetMediaHash(path string) (*model.MediaHash, error) {
+	var h model.MediaHash
+	if err := db.Where(fmt.Sprintf("%s = ?", columnName("path")), path).First(&h).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get media hash")
+	}
+	return &h, nil
+}
+
+func GetMediaHashes() (hashes []model.MediaHash, err error) {
+	if err := db.Find(&hashes).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get media hashes")
+	}
+	return hashes, nil
+}
+
+func DeleteMediaHash(path string) error {
+	return errors.WithStack(db.Where(fmt.Sprintf("%s = ?", columnName("path")), path).Delete(&model.MediaHash{}).Error)
+}
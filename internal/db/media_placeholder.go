@@ -0,0 +1,32 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+// SaveMediaPlaceholder replaces the placeholder recorded for p.Path
+func SaveMediaPlaceholder(p *model.MediaPlaceholder) error {
+	if old, err := GetMediaPlaceholder(p.Path); err == nil {
+		p.ID = old.ID
+	}
+	return errors.WithStack(db.Save(p).Error)
+}
+
+func GetMediaPlaceholder(path string) (*model.MediaPlaceholder, error) {
+	var p model.MediaPlaceholder
+	if err := db.Where(fmt.Sprintf("%s = ?", columnName("path")), path).First(&p).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get media placeholder")
+	}
+	return &p, nil
+}
+
+// GetMediaPlaceholdersByParent returns the placeholders of the files directly in parent
+func GetMediaPlaceholdersByParent(parent string) (placeholders []model.MediaPlaceholder, err error) {
+	if err := db.Where(fmt.Sprintf("%s = ?", columnName("parent")), parent).Find(&placeholders).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get media placeholders")
+	}
+	return placeholders, nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 )
 
 func GetSettingItems() ([]model.SettingItem, error) {
@@ -67,3 +68,14 @@ func SaveSettingItem(item *model.SettingItem) error {
 func DeleteSettingItemByKey(key string) error {
 	return errors.WithStack(db.Delete(&model.SettingItem{Key: key}).Error)
 }
+
+// DeleteSettingItemsByKeys deletes the settings in one transaction and returns how many existed
+func DeleteSettingItemsByKeys(keys []string) (int64, error) {
+	var deleted int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where(fmt.Sprintf("%s in ?", columnName("key")), keys).Delete(&model.SettingItem{})
+		deleted = res.RowsAffected
+		return res.Error
+	})
+	return deleted, errors.WithStack(err)
+}
@@ -0,0 +1,73 @@
+package driver
+
+// StorageCapabilities describes what a driver supports, so callers branch on it
+// instead of asserting the optional interfaces themselves
+type StorageCapabilities struct {
+	Upload    bool `json:"upload"`
+	Overwrite bool `json:"overwrite"`
+	Mkdir     bool `json:"mkdir"`
+	Rename    bool `json:"rename"`
+	Move      bool `json:"move"`
+	Copy      bool `json:"copy"`
+	Remove    bool `json:"remove"`
+	PutURL    bool `json:"put_url"`
+	// Presign is a direct upload from the client to the storage, see DirectUploader
+	Presign   bool `json:"presign"`
+	FreeSpace bool `json:"free_space"`
+	Archive   bool `json:"archive"`
+
+	// the following can't be told from the interfaces, drivers declare them with CapabilitiesDeclarer
+
+	// Mtime is whether uploads keep the modified time of the stream
+	Mtime bool `json:"mtime"`
+	// ConditionalPut is whether an upload can be refused by the storage when the file changed
+	ConditionalPut bool `json:"conditional_put"`
+	// Metadata is whether the storage keeps custom metadata along the file
+	Metadata bool `json:"metadata"`
+	// LocalFiles is whether the objects are files on the local disk, their path being GetPath
+	LocalFiles bool `json:"local_files"`
+}
+
+type CapabilitiesDeclarer interface {
+	// DeclareCapabilities sets the capabilities which GetCapabilities can't detect
+	DeclareCapabilities(caps *StorageCapabilities)
+}
+
+// GetCapabilities returns the capabilities of the driver
+func GetCapabilities(d Driver) StorageCapabilities {
+	config := d.Config()
+	caps := StorageCapabilities{}
+	switch d.(type) {
+	case Put, PutResult:
+		caps.Upload = !config.NoUpload
+	}
+	caps.Overwrite = caps.Upload && !config.NoOverwriteUpload
+	switch d.(type) {
+	case Mkdir, MkdirResult:
+		caps.Mkdir = true
+	}
+	switch d.(type) {
+	case Rename, RenameResult:
+		caps.Rename = true
+	}
+	switch d.(type) {
+	case Move, MoveResult:
+		caps.Move = true
+	}
+	switch d.(type) {
+	case Copy, CopyResult:
+		caps.Copy = true
+	}
+	_, caps.Remove = d.(Remove)
+	switch d.(type) {
+	case PutURL, PutURLResult:
+		caps.PutURL = true
+	}
+	_, caps.Presign = d.(DirectUploader)
+	_, caps.FreeSpace = d.(WithDetails)
+	_, caps.Archive = d.(ArchiveReader)
+	if declarer, ok := d.(CapabilitiesDeclarer); ok {
+		declarer.DeclareCapabilities(&caps)
+	}
+	return caps
+}
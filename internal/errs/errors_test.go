@@ -3,6 +3,7 @@ package errs
 import (
 	"errors"
 	pkgerr "github.com/pkg/errors"
+	"os"
 	"testing"
 )
 
@@ -25,3 +26,18 @@ func TestErrs(t *testing.T) {
 		t.Errorf("failed, expect %s is %s", err2, StorageNotFound)
 	}
 }
+
+func TestIsObjectAlreadyExists(t *testing.T) {
+	for _, err := range []error{
+		ObjectAlreadyExists,
+		pkgerr.WithStack(ObjectAlreadyExists),
+		pkgerr.WithMessage(&os.PathError{Op: "mkdir", Path: "/a", Err: os.ErrExist}, "failed make dir"),
+	} {
+		if !IsObjectAlreadyExists(err) {
+			t.Errorf("expect %s to be an already exists error", err)
+		}
+	}
+	if IsObjectAlreadyExists(pkgerr.WithStack(ObjectNotFound)) {
+		t.Errorf("expect %s not to be an already exists error", ObjectNotFound)
+	}
+}
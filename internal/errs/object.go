@@ -2,6 +2,7 @@ package errs
 
 import (
 	"errors"
+	"os"
 
 	pkgerr "github.com/pkg/errors"
 )
@@ -17,3 +18,8 @@ var (
 func IsObjectNotFound(err error) bool {
 	return errors.Is(pkgerr.Cause(err), ObjectNotFound)
 }
+
+// IsObjectAlreadyExists reports errors of drivers about an existing object
+func IsObjectAlreadyExists(err error) bool {
+	return errors.Is(err, ObjectAlreadyExists) || errors.Is(err, os.ErrExist)
+}
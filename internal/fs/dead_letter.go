@@ -0,0 +1,307 @@
+package fs
+
+import (
+	"context"
+	"io"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/cron"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeadLetter is an upload task which failed after its data was buffered to temp,
+// the data is kept for conf.UploadDeadLetterTTL so it can be retried without a new transfer
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	DstDir   string    `json:"dst_dir"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Mimetype string    `json:"mimetype"`
+	Modified time.Time `json:"modified"`
+	Hash     string    `json:"hash"`
+	Creator  string    `json:"creator"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// deadLetterLock serializes changes of the dead letter dir
+var deadLetterLock sync.Mutex
+
+func deadLetterDir() string {
+	return filepath.Join(conf.Conf.TempDir, "dead_letter")
+}
+
+func deadLetterDataPath(id string) string {
+	return filepath.Join(deadLetterDir(), id+".part")
+}
+
+func deadLetterInfoPath(id string) string {
+	return filepath.Join(deadLetterDir(), id+".json")
+}
+
+func deadLetterTTL() time.Duration {
+	return time.Duration(setting.GetInt(conf.UploadDeadLetterTTL, 0)) * time.Hour
+}
+
+// keepDeadLetterData links or copies the buffered data of the task before it's put,
+// putting closes the stream and removes its temp file
+func keepDeadLetterData(t *UploadTask) {
+	if deadLetterTTL() <= 0 {
+		return
+	}
+	cache := t.file.GetFile()
+	if cache == nil {
+		return
+	}
+	path := deadLetterDataPath(t.GetID())
+	if _, err := os.Stat(path); err == nil {
+		// kept by a previous attempt
+		return
+	}
+	if err := os.MkdirAll(deadLetterDir(), 0o700); err != nil {
+		log.Warnf("failed to create dead letter dir: %+v", err)
+		return
+	}
+	if f, ok := cache.(interface{ Name() string }); ok && os.Link(f.Name(), path) == nil {
+		return
+	}
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Warnf("failed to keep dead letter data of %s: %+v", t.GetID(), err)
+		return
+	}
+	_, err = utils.CopyWithBuffer(out, io.NewSectionReader(cache, 0, t.file.GetSize()))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Warnf("failed to keep dead letter data of %s: %+v", t.GetID(), err)
+		_ = os.Remove(path)
+	}
+}
+
+// addDeadLetter records the failed task, its data must have been kept by keepDeadLetterData
+func addDeadLetter(t *UploadTask) {
+	if _, err := os.Stat(deadLetterDataPath(t.GetID())); err != nil {
+		return
+	}
+	letter := DeadLetter{
+		ID:       t.GetID(),
+		DstDir:   stdpath.Join(t.storage.GetStorage().MountPath, t.dstDirActualPath),
+		Name:     t.file.GetName(),
+		Size:     t.file.GetSize(),
+		Mimetype: t.file.GetMimetype(),
+		Modified: t.file.ModTime(),
+		Hash:     t.file.GetHash().String(),
+		FailedAt: time.Now(),
+	}
+	if t.Creator != nil {
+		letter.Creator = t.Creator.Username
+	}
+	if err := t.GetErr(); err != nil {
+		letter.Error = err.Error()
+	}
+	data, err := utils.Json.Marshal(letter)
+	if err == nil {
+		err = os.WriteFile(deadLetterInfoPath(letter.ID), data, 0o600)
+	}
+	if err != nil {
+		log.Warnf("failed to save dead letter %s: %+v", letter.ID, err)
+		_ = os.Remove(deadLetterDataPath(letter.ID))
+		return
+	}
+	sweepDeadLetters()
+}
+
+func removeDeadLetter(id string) {
+	_ = os.Remove(deadLetterDataPath(id))
+	_ = os.Remove(deadLetterInfoPath(id))
+}
+
+// ListDeadLetters returns the dead letters of the user, or all of them when the user is nil
+func ListDeadLetters(user *model.User) ([]DeadLetter, error) {
+	entries, err := os.ReadDir(deadLetterDir())
+	if os.IsNotExist(err) {
+		return []DeadLetter{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]DeadLetter, 0)
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		letter, err := getDeadLetter(id)
+		if err != nil {
+			continue
+		}
+		if user == nil || letter.Creator == user.Username {
+			letters = append(letters, *letter)
+		}
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.After(letters[j].FailedAt)
+	})
+	return letters, nil
+}
+
+func getDeadLetter(id string) (*DeadLetter, error) {
+	// ids are task ids, never paths
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, errors.WithStack(errs.ObjectNotFound)
+	}
+	data, err := os.ReadFile(deadLetterInfoPath(id))
+	if os.IsNotExist(err) {
+		return nil, errors.WithStack(errs.ObjectNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var letter DeadLetter
+	if err = utils.Json.Unmarshal(data, &letter); err != nil {
+		return nil, err
+	}
+	return &letter, nil
+}
+
+// GetDeadLetter returns the dead letter if it belongs to the user, any of them when the user is nil
+func GetDeadLetter(id string, user *model.User) (*DeadLetter, error) {
+	letter, err := getDeadLetter(id)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil && letter.Creator != user.Username {
+		return nil, errors.WithStack(errs.ObjectNotFound)
+	}
+	return letter, nil
+}
+
+// RetryDeadLetter puts the kept data as a new upload task, the dead letter is removed
+// and a new one is added if the task fails again
+func RetryDeadLetter(ctx context.Context, letter *DeadLetter) (*UploadTask, error) {
+	deadLetterLock.Lock()
+	f, err := os.CreateTemp(conf.Conf.TempDir, "file-*")
+	if err == nil {
+		_ = f.Close()
+		err = os.Rename(deadLetterDataPath(letter.ID), f.Name())
+	}
+	if err == nil {
+		_ = os.Remove(deadLetterInfoPath(letter.ID))
+	}
+	deadLetterLock.Unlock()
+	if err != nil {
+		if f != nil {
+			_ = os.Remove(f.Name())
+		}
+		return nil, errors.WithMessage(err, "failed to take dead letter data")
+	}
+	data, err := os.Open(f.Name())
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	s := &stream.FileStream{
+		Obj: &model.Object{
+			Name:     letter.Name,
+			Size:     letter.Size,
+			Modified: letter.Modified,
+			HashInfo: utils.FromString(letter.Hash),
+		},
+		Reader:       data,
+		Mimetype:     letter.Mimetype,
+		WebPutAsTask: true,
+		Closers: utils.NewClosers(data, utils.CloseFunc(func() error {
+			return os.Remove(data.Name())
+		})),
+	}
+	t, err := putAsTask(ctx, letter.DstDir, s)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return t.(*UploadTask), nil
+}
+
+// DiscardDeadLetter removes the dead letter and its data
+func DiscardDeadLetter(letter *DeadLetter) {
+	deadLetterLock.Lock()
+	defer deadLetterLock.Unlock()
+	removeDeadLetter(letter.ID)
+}
+
+// sweepDeadLetters removes expired dead letters and data left by interrupted tasks,
+// then the oldest ones until they fit into conf.UploadDeadLetterMaxSize
+func sweepDeadLetters() {
+	deadLetterLock.Lock()
+	defer deadLetterLock.Unlock()
+	entries, err := os.ReadDir(deadLetterDir())
+	if err != nil {
+		return
+	}
+	ttl := deadLetterTTL()
+	var letters []DeadLetter
+	var total int64
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".part")
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		letter, err := getDeadLetter(id)
+		if err != nil {
+			// data of a running task, unless it's left over for longer than a task may run
+			if ttl <= 0 || time.Since(info.ModTime()) > max(ttl, 24*time.Hour) {
+				_ = os.Remove(deadLetterDataPath(id))
+			}
+			continue
+		}
+		if ttl <= 0 || time.Since(letter.FailedAt) > ttl {
+			removeDeadLetter(id)
+			continue
+		}
+		letters = append(letters, *letter)
+		total += info.Size()
+	}
+	limit := int64(setting.GetInt(conf.UploadDeadLetterMaxSize, 0))
+	if limit <= 0 || total <= limit {
+		return
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+	for _, letter := range letters {
+		if total <= limit {
+			break
+		}
+		log.Infof("dead letter %s of %s removed to fit %s", letter.ID, letter.Name, conf.UploadDeadLetterMaxSize)
+		removeDeadLetter(letter.ID)
+		total -= letter.Size
+	}
+}
+
+var deadLetterSweeperStart sync.Once
+
+// InitDeadLetterSweeper expires dead letters periodically
+func InitDeadLetterSweeper() {
+	deadLetterSweeperStart.Do(func() {
+		cron.NewCron(10 * time.Minute).Do(sweepDeadLetters)
+	})
+}
@@ -21,6 +21,9 @@ func makeDir(ctx context.Context, path string) error {
 }
 
 func rename(ctx context.Context, srcPath, dstName string, skipHook ...bool) error {
+	if err := checkQuarantine(ctx, srcPath); err != nil {
+		return err
+	}
 	storage, srcActualPath, err := op.GetStorageAndActualPath(srcPath)
 	if err != nil {
 		return errors.WithMessage(err, "failed get storage")
@@ -40,6 +43,9 @@ func remove(ctx context.Context, path string) error {
 }
 
 func other(ctx context.Context, args model.FsOtherArgs) (interface{}, error) {
+	if err := checkQuarantine(ctx, args.Path); err != nil {
+		return nil, err
+	}
 	storage, actualPath, err := op.GetStorageAndActualPath(args.Path)
 	if err != nil {
 		return nil, errors.WithMessage(err, "failed get storage")
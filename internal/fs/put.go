@@ -3,6 +3,8 @@ package fs
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	stdpath "path"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
 	"github.com/OpenListTeam/OpenList/v4/internal/task"
 	"github.com/OpenListTeam/OpenList/v4/internal/task_group"
 	"github.com/OpenListTeam/tache"
@@ -25,6 +28,7 @@ type UploadTask struct {
 	storage          driver.Driver
 	dstDirActualPath string
 	file             model.FileStreamer
+	verifyHash       bool
 }
 
 func (t *UploadTask) GetName() string {
@@ -39,14 +43,22 @@ func (t *UploadTask) Run() error {
 	t.ClearEndTime()
 	t.SetStartTime(time.Now())
 	defer func() { t.SetEndTime(time.Now()) }()
+	keepDeadLetterData(t)
+	if t.verifyHash {
+		if err := verifyUploadCache(t.file); err != nil {
+			return err
+		}
+	}
 	return op.Put(context.WithValue(t.Ctx(), conf.SkipHookKey, struct{}{}), t.storage, t.dstDirActualPath, t.file, t.SetProgress)
 }
 
 func (t *UploadTask) OnSucceeded() {
+	_ = os.Remove(deadLetterDataPath(t.GetID()))
 	task_group.TransferCoordinator.Done(context.WithoutCancel(t.Ctx()), stdpath.Join(t.storage.GetStorage().MountPath, t.dstDirActualPath), true)
 }
 
 func (t *UploadTask) OnFailed() {
+	addDeadLetter(t)
 	task_group.TransferCoordinator.Done(context.WithoutCancel(t.Ctx()), stdpath.Join(t.storage.GetStorage().MountPath, t.dstDirActualPath), false)
 }
 
@@ -60,9 +72,56 @@ func (t *UploadTask) SetRetry(retry int, maxRetry int) {
 
 var UploadTaskManager *tache.Manager[*UploadTask]
 
+// verifyUploadCache checks the cached body of file against its hashes before it's put,
+// and replaces them by the computed ones. A mismatch isn't retried
+func verifyUploadCache(file model.FileStreamer) error {
+	cache := file.GetFile()
+	if cache == nil {
+		return errors.New("the upload isn't cached")
+	}
+	want := file.GetHash().Export()
+	types := make([]*utils.HashType, 0, len(want))
+	for ht := range want {
+		types = append(types, ht)
+	}
+	hasher := utils.NewMultiHasher(types)
+	if _, err := cache.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := utils.CopyWithBuffer(hasher, cache); err != nil {
+		return err
+	}
+	if _, err := cache.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := hasher.Verify(want); err != nil {
+		return tache.Unrecoverable(err)
+	}
+	if s, ok := file.(*stream.FileStream); ok {
+		if obj, ok := s.Obj.(*model.Object); ok {
+			obj.HashInfo = *hasher.GetHashInfo()
+		}
+	}
+	return nil
+}
+
+// getPutStorageAndActualPath honors the storage id forced by conf.TargetStorageKey
+func getPutStorageAndActualPath(ctx context.Context, dstDirPath string) (driver.Driver, string, error) {
+	if id, ok := ctx.Value(conf.TargetStorageKey).(uint); ok {
+		return op.GetStorageAndActualPathByID(dstDirPath, id)
+	}
+	return op.GetStorageAndActualPath(dstDirPath)
+}
+
+// GetPutStorage returns the storage an upload into dstDirPath is put into
+func GetPutStorage(ctx context.Context, dstDirPath string) (driver.Driver, error) {
+	storage, _, err := getPutStorageAndActualPath(ctx, dstDirPath)
+	return storage, err
+}
+
 // putAsTask add as a put task and return immediately
 func putAsTask(ctx context.Context, dstDirPath string, file model.FileStreamer) (task.TaskExtensionInfo, error) {
-	storage, dstDirActualPath, err := op.GetStorageAndActualPath(dstDirPath)
+	storage, dstDirActualPath, err := getPutStorageAndActualPath(ctx, dstDirPath)
 	if err != nil {
 		return nil, errors.WithMessage(err, "failed get storage")
 	}
@@ -87,6 +146,7 @@ func putAsTask(ctx context.Context, dstDirPath string, file model.FileStreamer)
 		dstDirActualPath: dstDirActualPath,
 		file:             file,
 	}
+	t.verifyHash, _ = ctx.Value(conf.VerifyHashKey).(bool)
 	t.SetTotalBytes(file.GetSize())
 	task_group.TransferCoordinator.AddTask(stdpath.Join(storage.GetStorage().MountPath, dstDirActualPath), nil)
 	UploadTaskManager.Add(t)
@@ -95,7 +155,7 @@ func putAsTask(ctx context.Context, dstDirPath string, file model.FileStreamer)
 
 // putDirect put the file and return after finish
 func putDirectly(ctx context.Context, dstDirPath string, file model.FileStreamer, skipHook ...bool) error {
-	storage, dstDirActualPath, err := op.GetStorageAndActualPath(dstDirPath)
+	storage, dstDirActualPath, err := getPutStorageAndActualPath(ctx, dstDirPath)
 	if err != nil {
 		_ = file.Close()
 		return errors.WithMessage(err, "failed get storage")
@@ -0,0 +1,41 @@
+package fs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+// QuarantineDirName holds the uploads awaiting moderation next to their destination,
+// so approving them is a move within the storage
+const QuarantineDirName = ".quarantine"
+
+// InQuarantine reports whether path is in a quarantine dir
+func InQuarantine(path string) bool {
+	for _, name := range strings.Split(utils.FixAndCleanPath(path), "/") {
+		if name == QuarantineDirName {
+			return true
+		}
+	}
+	return false
+}
+
+// checkQuarantine keeps the content of uploads awaiting moderation from the non admin user of ctx,
+// so it isn't live before it's approved. Calls without a user are made by the server itself,
+// the download links of /d and /p always require a sign for these paths
+func checkQuarantine(ctx context.Context, paths ...string) error {
+	user, _ := ctx.Value(conf.UserKey).(*model.User)
+	if user == nil || user.IsAdmin() {
+		return nil
+	}
+	for _, path := range paths {
+		if InQuarantine(path) {
+			return errs.PermissionDenied
+		}
+	}
+	return nil
+}
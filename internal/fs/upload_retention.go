@@ -0,0 +1,61 @@
+package fs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/cron"
+	"github.com/OpenListTeam/tache"
+	log "github.com/sirupsen/logrus"
+)
+
+var uploadTaskPrunerStart sync.Once
+
+// uploadTaskFinished reports whether the task won't run again unless retried by hand
+func uploadTaskFinished(t *UploadTask) bool {
+	switch t.GetState() {
+	case tache.StateSucceeded, tache.StateFailed, tache.StateCanceled:
+		return true
+	}
+	return false
+}
+
+// PruneUploadTasks removes the finished upload tasks which ended before the given time.
+// The state is checked while the manager removes them, so a task retried meanwhile is kept.
+func PruneUploadTasks(before time.Time) int {
+	if UploadTaskManager == nil {
+		return 0
+	}
+	removed := 0
+	UploadTaskManager.RemoveByCondition(func(t *UploadTask) bool {
+		if !uploadTaskFinished(t) {
+			return false
+		}
+		if end := t.GetEndTime(); end == nil || end.After(before) {
+			return false
+		}
+		removed++
+		return true
+	})
+	return removed
+}
+
+// pruneExpiredUploadTasks applies conf.UploadTaskRetention, 0 keeps the tasks until cleared by hand
+func pruneExpiredUploadTasks() {
+	retention := time.Duration(setting.GetInt(conf.UploadTaskRetention, 0)) * time.Second
+	if retention <= 0 {
+		return
+	}
+	if removed := PruneUploadTasks(time.Now().Add(-retention)); removed > 0 {
+		log.Infof("%d finished upload tasks pruned", removed)
+	}
+}
+
+// InitUploadTaskPruner prunes the finished upload tasks periodically
+func InitUploadTaskPruner() {
+	uploadTaskPrunerStart.Do(func() {
+		cron.NewCron(10 * time.Minute).Do(pruneExpiredUploadTasks)
+	})
+}
@@ -0,0 +1,9 @@
+package model
+
+// MediaHash is the perceptual hash of the thumbnail of a file, see utils.PHash
+type MediaHash struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Path string `json:"path" gorm:"unique"`
+	// PHash is stored signed since some databases don't support uint64 with the high bit set
+	PHash int64 `json:"phash"`
+}
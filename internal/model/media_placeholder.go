@@ -0,0 +1,11 @@
+package model
+
+// MediaPlaceholder is the blurhash and the dominant color of the thumbnail of a file,
+// indexed by its parent so a listing reads them in a single query
+type MediaPlaceholder struct {
+	ID            uint   `json:"id" gorm:"primaryKey"`
+	Path          string `json:"path" gorm:"unique"`
+	Parent        string `json:"parent" gorm:"index"`
+	Blurhash      string `json:"blurhash"`
+	DominantColor string `json:"dominant_color"`
+}
@@ -14,8 +14,36 @@ const (
 	FTP
 	TRAFFIC
 	WEBDAV // 添加WebDAV设置组
+	UPLOAD
+	THUMBNAIL
 )
 
+// SettingGroup describes a setting group for the frontend, Name is stable and safe to key on
+type SettingGroup struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
+
+// SettingGroups lists every group above, keep it in sync when adding one
+var SettingGroups = []SettingGroup{
+	{ID: SINGLE, Name: "single", Label: "Single"},
+	{ID: SITE, Name: "site", Label: "Site"},
+	{ID: STYLE, Name: "style", Label: "Style"},
+	{ID: PREVIEW, Name: "preview", Label: "Preview"},
+	{ID: GLOBAL, Name: "global", Label: "Global"},
+	{ID: OFFLINE_DOWNLOAD, Name: "offline_download", Label: "Offline Download"},
+	{ID: INDEX, Name: "index", Label: "Index"},
+	{ID: SSO, Name: "sso", Label: "SSO"},
+	{ID: LDAP, Name: "ldap", Label: "LDAP"},
+	{ID: S3, Name: "s3", Label: "S3"},
+	{ID: FTP, Name: "ftp", Label: "FTP"},
+	{ID: TRAFFIC, Name: "traffic", Label: "Traffic"},
+	{ID: WEBDAV, Name: "webdav", Label: "WebDAV"},
+	{ID: UPLOAD, Name: "upload", Label: "Upload"},
+	{ID: THUMBNAIL, Name: "thumbnail", Label: "Thumbnail"},
+}
+
 const (
 	PUBLIC = iota
 	PRIVATE
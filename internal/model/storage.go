@@ -19,6 +19,8 @@ type Storage struct {
 	Disabled            bool      `json:"disabled"` // if disabled
 	DisableIndex        bool      `json:"disable_index"`
 	EnableSign          bool      `json:"enable_sign"`
+	// MaxConcurrentUploads bounds the simultaneous uploads through the web api, 0 means no limit
+	MaxConcurrentUploads int `json:"max_concurrent_uploads"`
 	Sort
 	Proxy
 }
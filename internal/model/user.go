@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
@@ -60,6 +61,8 @@ type User struct {
 	SsoID      string `json:"sso_id"` // unique by sso platform
 	Authn      string `gorm:"type:text" json:"-"`
 	AllowLdap  bool   `json:"allow_ldap" gorm:"default:true"`
+	// where uploads with a relative File-Path go, within the base path
+	DefaultUploadDir string `json:"default_upload_dir"`
 }
 
 func (u *User) IsGuest() bool {
@@ -215,6 +218,28 @@ func (u *User) JoinPath(reqPath string) (string, error) {
 	return utils.JoinBasePath(u.BasePath, reqPath)
 }
 
+// JoinUploadPath is JoinPath for the File-Path of an upload: a relative path
+// is resolved under DefaultUploadDir when the user has one
+func (u *User) JoinUploadPath(reqPath string) (string, error) {
+	if u.DefaultUploadDir == "" || strings.HasPrefix(reqPath, "/") {
+		return u.JoinPath(reqPath)
+	}
+	return utils.JoinBasePath(u.DefaultUploadDir, reqPath)
+}
+
+// FixPaths cleans the base path and the default upload dir, which must be within the base path
+func (u *User) FixPaths() error {
+	u.BasePath = utils.FixAndCleanPath(u.BasePath)
+	if u.DefaultUploadDir == "" {
+		return nil
+	}
+	u.DefaultUploadDir = utils.FixAndCleanPath(u.DefaultUploadDir)
+	if !utils.IsSubPath(u.BasePath, u.DefaultUploadDir) {
+		return errors.WithStack(errs.DefaultUploadDirOutsideBasePath)
+	}
+	return nil
+}
+
 func StaticHash(password string) string {
 	return utils.HashData(utils.SHA256, []byte(fmt.Sprintf("%s-%s", password, StaticHashSalt)))
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
@@ -83,6 +84,24 @@ var settingItemHooks = map[string]SettingItemHook{
 		conf.SlicesMap[conf.IgnoreDirectLinkParams] = strings.Split(item.Value, ",")
 		return nil
 	},
+	conf.DateOrganizeTemplate: func(item *model.SettingItem) error {
+		_, err := utils.FormatDatePath(item.Value, time.Now())
+		return err
+	},
+	conf.ThumbnailSchedule: func(item *model.SettingItem) error {
+		_, err := utils.ParseTimeWindows(item.Value)
+		return err
+	},
+	conf.ThumbnailFrames: func(item *model.SettingItem) error {
+		_, err := utils.ParseFramePositions(item.Value)
+		return err
+	},
+	conf.VersionNameTemplate: func(item *model.SettingItem) error {
+		return utils.ValidateVersionNameTemplate(item.Value)
+	},
+	conf.ConflictRenameTemplate: func(item *model.SettingItem) error {
+		return utils.ValidateVersionNameTemplate(item.Value)
+	},
 }
 
 func RegisterSettingItemHook(key string, hook SettingItemHook) {
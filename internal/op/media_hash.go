@@ -0,0 +1,26 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func SaveMediaHash(path string, phash uint64) error {
+	return db.SaveMediaHash(&model.MediaHash{Path: path, PHash: int64(phash)})
+}
+
+func GetMediaHash(path string) (uint64, error) {
+	h, err := db.GetMediaHash(path)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(h.PHash), nil
+}
+
+func GetMediaHashes() ([]model.MediaHash, error) {
+	return db.GetMediaHashes()
+}
+
+func DeleteMediaHash(path string) error {
+	return db.DeleteMediaHash(path)
+}
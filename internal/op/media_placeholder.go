@@ -0,0 +1,34 @@
+package op
+
+import (
+	stdpath "path"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func SaveMediaPlaceholder(path, blurhash, dominantColor string) error {
+	return db.SaveMediaPlaceholder(&model.MediaPlaceholder{
+		Path:          path,
+		Parent:        stdpath.Dir(path),
+		Blurhash:      blurhash,
+		DominantColor: dominantColor,
+	})
+}
+
+func GetMediaPlaceholder(path string) (*model.MediaPlaceholder, error) {
+	return db.GetMediaPlaceholder(path)
+}
+
+// GetMediaPlaceholders returns the placeholders of the files directly in parent by name
+func GetMediaPlaceholders(parent string) (map[string]model.MediaPlaceholder, error) {
+	placeholders, err := db.GetMediaPlaceholdersByParent(parent)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]model.MediaPlaceholder, len(placeholders))
+	for _, p := range placeholders {
+		byName[stdpath.Base(p.Path)] = p
+	}
+	return byName, nil
+}
@@ -30,6 +30,20 @@ func GetStorageAndActualPath(rawPath string) (storage driver.Driver, actualPath
 	return
 }
 
+// GetStorageAndActualPathByID is GetStorageAndActualPath forced to the storage with id, which must
+// be one of the storages (balanced ones included) serving rawPath
+func GetStorageAndActualPathByID(rawPath string, id uint) (storage driver.Driver, actualPath string, err error) {
+	rawPath = utils.FixAndCleanPath(rawPath)
+	for _, s := range getStoragesByPath(rawPath) {
+		if s.GetStorage().ID == id {
+			mountPath := utils.GetActualMountPath(s.GetStorage().MountPath)
+			return s, utils.FixAndCleanPath(strings.TrimPrefix(rawPath, mountPath)), nil
+		}
+	}
+	err = errs.NewErr(errs.StorageNotFound, "storage %d doesn't serve %s", id, rawPath)
+	return
+}
+
 // urlTreeSplitLineFormPath 分割path中分割真实路径和UrlTree定义字符串
 func urlTreeSplitLineFormPath(path string) (pp string, file string) {
 	// url.PathUnescape 会移除 // ，手动加回去
@@ -2,14 +2,19 @@ package op
 
 import (
 	"fmt"
+	"maps"
+	"path"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/db"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/pkg/singleflight"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 )
 
 var settingG singleflight.Group[*model.SettingItem]
@@ -44,6 +49,21 @@ func GetPublicSettingsMap() map[string]string {
 	return pSettings
 }
 
+// PreviewPublicSettingsMap returns GetPublicSettingsMap as if the value of the setting were changed,
+// nothing is persisted. The bool reports whether the setting is public at all
+func PreviewPublicSettingsMap(key, value string) (map[string]string, bool, error) {
+	item, err := GetSettingItemByKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	pSettings := maps.Clone(GetPublicSettingsMap())
+	public := item.Flag == model.PUBLIC
+	if public {
+		pSettings[key] = value
+	}
+	return pSettings, public, nil
+}
+
 func GetSettingsMap() map[string]string {
 	items, _ := GetSettingItems()
 	settings := make(map[string]string)
@@ -198,6 +218,116 @@ func DeleteSettingItemByKey(key string) error {
 	return db.DeleteSettingItemByKey(key)
 }
 
+// protectedSettingGroups hold settings the server can't run without, e.g. the token in SINGLE
+var protectedSettingGroups = []int{model.SINGLE}
+
+// DeleteSettingItemsByKeys deletes the settings in one transaction and returns those deleted.
+// Like DeleteSettingItemByKey only deprecated settings are deleted, unless force is set.
+// The hook of every deleted setting is fired with an empty value, as the setting now reads
+func DeleteSettingItemsByKeys(keys []string, force bool) ([]model.SettingItem, error) {
+	items := make([]model.SettingItem, 0, len(keys))
+	for _, key := range keys {
+		item, err := db.GetSettingItemByKey(key)
+		if err != nil {
+			continue
+		}
+		if !force && !item.IsDeprecated() {
+			return nil, errors.Errorf("setting [%s] is not deprecated, force is required", key)
+		}
+		items = append(items, *item)
+	}
+	if len(items) == 0 {
+		return items, nil
+	}
+	if _, err := db.DeleteSettingItemsByKeys(keys); err != nil {
+		return nil, err
+	}
+	SettingCacheUpdate()
+	for _, item := range items {
+		removed := item
+		removed.Value = ""
+		if _, err := HandleSettingItemHook(&removed); err != nil {
+			log.Warnf("failed to execute hook on deleted setting %s: %+v", item.Key, err)
+		}
+	}
+	return items, nil
+}
+
+// DeleteSettingItemsByGroup deletes all settings of the group, see DeleteSettingItemsByKeys.
+// The settings of protectedSettingGroups are only deleted with force even when deprecated
+func DeleteSettingItemsByGroup(group int, force bool) ([]model.SettingItem, error) {
+	if !force && slices.Contains(protectedSettingGroups, group) {
+		return nil, errors.Errorf("setting group [%d] is protected, force is required", group)
+	}
+	items, err := db.GetSettingItemsByGroup(group)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	return DeleteSettingItemsByKeys(keys, force)
+}
+
+// coerceSettingValue checks value against the type of the setting and returns it in canonical form
+func coerceSettingValue(item *model.SettingItem, value string) (string, error) {
+	switch item.Type {
+	case conf.TypeBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", errors.Errorf("setting [%s] expects a bool, got %q", item.Key, value)
+		}
+		return strconv.FormatBool(b), nil
+	case conf.TypeNumber:
+		value = strings.TrimSpace(value)
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", errors.Errorf("setting [%s] expects a number, got %q", item.Key, value)
+		}
+		return value, nil
+	case conf.TypeSelect:
+		if item.Options != "" && !slices.Contains(strings.Split(item.Options, ","), value) {
+			return "", errors.Errorf("setting [%s] expects one of %s, got %q", item.Key, item.Options, value)
+		}
+	}
+	return value, nil
+}
+
+// BulkSetSettingItems sets value to every setting whose key matches the glob pattern, as path.Match does,
+// and returns their keys. Every value is checked before any is saved, and they're saved in one statement.
+// Read-only settings and those of protectedSettingGroups, e.g. the token, can't be matched
+func BulkSetSettingItems(pattern, value string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, errors.Wrapf(err, "invalid pattern %q", pattern)
+	}
+	items, err := db.GetSettingItems()
+	if err != nil {
+		return nil, err
+	}
+	var updated []model.SettingItem
+	keys := make([]string, 0)
+	for _, item := range items {
+		if ok, _ := path.Match(pattern, item.Key); !ok {
+			continue
+		}
+		if item.Flag == model.READONLY || slices.Contains(protectedSettingGroups, item.Group) {
+			return nil, errors.Errorf("setting [%s] is protected and can't be set in bulk", item.Key)
+		}
+		if item.Value, err = coerceSettingValue(&item, value); err != nil {
+			return nil, err
+		}
+		updated = append(updated, item)
+		keys = append(keys, item.Key)
+	}
+	if len(updated) == 0 {
+		return keys, nil
+	}
+	if err = SaveSettingItems(updated); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
 type MigrationValueItem struct {
 	MigrationValue, Value string
 }
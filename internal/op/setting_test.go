@@ -0,0 +1,49 @@
+package op_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestBulkSetSettingItems(t *testing.T) {
+	items := []model.SettingItem{
+		{Key: "bulk_a_enabled", Value: "false", Type: conf.TypeBool, Group: model.GLOBAL},
+		{Key: "bulk_b_enabled", Value: "false", Type: conf.TypeBool, Group: model.GLOBAL},
+		{Key: "bulk_c_limit", Value: "1", Type: conf.TypeNumber, Group: model.GLOBAL},
+		{Key: "bulk_token", Value: "secret", Type: conf.TypeString, Group: model.SINGLE},
+	}
+	if err := op.SaveSettingItems(items); err != nil {
+		t.Fatalf("failed to save settings: %+v", err)
+	}
+
+	keys, err := op.BulkSetSettingItems("bulk_*_enabled", "1")
+	if err != nil {
+		t.Fatalf("bulk set failed: %+v", err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"bulk_a_enabled", "bulk_b_enabled"}) {
+		t.Errorf("keys = %v", keys)
+	}
+	for _, key := range keys {
+		if item, _ := op.GetSettingItemByKey(key); item == nil || item.Value != "true" {
+			t.Errorf("%s = %+v, want true", key, item)
+		}
+	}
+
+	if _, err = op.BulkSetSettingItems("bulk_*", "true"); err == nil {
+		t.Errorf("pattern matching a protected setting was accepted")
+	}
+	if _, err = op.BulkSetSettingItems("bulk_c_*", "many"); err == nil {
+		t.Errorf("non-numeric value of a number setting was accepted")
+	}
+	if item, _ := op.GetSettingItemByKey("bulk_c_limit"); item == nil || item.Value != "1" {
+		t.Errorf("bulk_c_limit = %+v, want unchanged", item)
+	}
+	if _, err = op.BulkSetSettingItems("bulk_[", "true"); err == nil {
+		t.Errorf("malformed pattern was accepted")
+	}
+}
@@ -71,6 +71,26 @@ func TestGetBalancedStorage(t *testing.T) {
 	}
 }
 
+func TestGetStorageAndActualPathByID(t *testing.T) {
+	balance, err := op.GetStorageByMountPath("/a/d/e1.balance")
+	if err != nil {
+		t.Fatalf("failed to get storage: %+v", err)
+	}
+	id := balance.GetStorage().ID
+	for i := 0; i < 3; i++ {
+		storage, actualPath, err := op.GetStorageAndActualPathByID("/a/d/e1/f", id)
+		if err != nil {
+			t.Fatalf("failed to get storage by id: %+v", err)
+		}
+		if storage.GetStorage().MountPath != "/a/d/e1.balance" || actualPath != "/f" {
+			t.Errorf("expected: /a/d/e1.balance /f, got: %s %s", storage.GetStorage().MountPath, actualPath)
+		}
+	}
+	if _, _, err = op.GetStorageAndActualPathByID("/a/c/f", id); err == nil {
+		t.Errorf("expected an error for a path the storage doesn't serve")
+	}
+}
+
 func setupStorages(t *testing.T) {
 	var storages = []model.Storage{
 		{Driver: "Local", MountPath: "/a/b", Order: 0, Addition: `{"root_folder_path":"."}`},
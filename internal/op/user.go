@@ -5,7 +5,6 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/pkg/singleflight"
-	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/pkg/errors"
 )
 
@@ -66,7 +65,9 @@ func GetUsers(pageIndex, pageSize int) (users []model.User, count int64, err err
 }
 
 func CreateUser(u *model.User) error {
-	u.BasePath = utils.FixAndCleanPath(u.BasePath)
+	if err := u.FixPaths(); err != nil {
+		return err
+	}
 	return db.CreateUser(u)
 }
 
@@ -90,6 +91,9 @@ func UpdateUser(u *model.User) error {
 	if err != nil {
 		return err
 	}
+	if err := u.FixPaths(); err != nil {
+		return err
+	}
 	if u.IsAdmin() {
 		adminUser = nil
 	}
@@ -97,7 +101,6 @@ func UpdateUser(u *model.User) error {
 		guestUser = nil
 	}
 	Cache.DeleteUser(old.Username)
-	u.BasePath = utils.FixAndCleanPath(u.BasePath)
 	return db.UpdateUser(u)
 }
 
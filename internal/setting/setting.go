@@ -2,7 +2,9 @@ package setting
 
 import (
 	"strconv"
+	"strings"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 )
 
@@ -36,3 +38,12 @@ func GetFloat(key string, defaultVal float64) float64 {
 	}
 	return f
 }
+
+// ThumbnailDirName is conf.ThumbnailDirName, a name which isn't a single path element falls back to .thumbnails
+func ThumbnailDirName() string {
+	name := GetStr(conf.ThumbnailDirName, ".thumbnails")
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return ".thumbnails"
+	}
+	return name
+}
@@ -37,5 +37,5 @@ func VerifyArchive(data string, sign string) error {
 }
 
 func InstanceArchive() {
-	instanceArchive = sign.NewHMACSign([]byte(setting.GetStr(conf.Token) + "-archive"))
+	instanceArchive = sign.NewHMACSign([]byte(secret() + "-archive"))
 }
@@ -5,8 +5,11 @@ import (
 	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/setting"
 	"github.com/OpenListTeam/OpenList/v4/pkg/sign"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils/random"
 )
 
 var once sync.Once
@@ -36,6 +39,23 @@ func Verify(data string, sign string) error {
 	return instance.Verify(data, sign)
 }
 
+func secret() string {
+	return setting.GetStr(conf.SignSecret)
+}
+
 func Instance() {
-	instance = sign.NewHMACSign([]byte(setting.GetStr(conf.Token)))
+	instance = sign.NewHMACSign([]byte(secret()))
+}
+
+func init() {
+	// a new secret replaces the keys right away, so links signed with the previous one stop verifying.
+	// An empty secret is replaced by a random one instead of signing with an empty key
+	op.RegisterSettingItemHook(conf.SignSecret, func(item *model.SettingItem) error {
+		if item.Value == "" {
+			item.Value = random.Token()
+		}
+		instance = sign.NewHMACSign([]byte(item.Value))
+		instanceArchive = sign.NewHMACSign([]byte(item.Value + "-archive"))
+		return nil
+	})
 }
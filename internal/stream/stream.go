@@ -18,6 +18,9 @@ import (
 	"go4.org/readerutil"
 )
 
+// peekChunkSize is the most a stream of unknown size kept in memory grows by at once
+const peekChunkSize = 1 << 20
+
 type FileStream struct {
 	Ctx context.Context
 	model.Obj
@@ -26,6 +29,10 @@ type FileStream struct {
 	WebPutAsTask      bool
 	ForceStreamUpload bool
 	Exist             model.Obj //the file existed in the destination, we can reuse some info since we wil overwrite it
+	// BufferThreshold is the size up to which caching the whole stream (CacheFullAndWriter,
+	// RangeRead) keeps the data in memory, larger data spills to a temp file. 0 means
+	// conf.MaxBufferLimit. The part buffers of NewStreamSectionReader ignore it
+	BufferThreshold int
 	utils.Closers
 	size      int64
 	peekBuff  *buffer.Reader
@@ -39,6 +46,13 @@ func (f *FileStream) GetSize() int64 {
 	return f.Obj.GetSize()
 }
 
+func (f *FileStream) bufferLimit() int {
+	if f.BufferThreshold > 0 {
+		return f.BufferThreshold
+	}
+	return conf.MaxBufferLimit
+}
+
 func (f *FileStream) GetMimetype() string {
 	return f.Mimetype
 }
@@ -132,23 +146,39 @@ func (f *FileStream) CacheFullAndWriter(up *model.UpdateProgress, writer io.Writ
 		} else if err != nil {
 			return nil, err
 		}
-		if conf.MaxBufferLimit-n > conf.MmapThreshold && conf.MmapThreshold > 0 {
-			m, err := mmap.Alloc(conf.MaxBufferLimit - n)
-			if err == nil {
+		var m []byte
+		limit := f.bufferLimit() - n
+		if limit > conf.MmapThreshold && conf.MmapThreshold > 0 {
+			if m, err = mmap.Alloc(limit); err == nil {
 				f.Add(utils.CloseFunc(func() error {
 					return mmap.Free(m)
 				}))
-				n, err = io.ReadFull(reader, m)
-				if n > 0 {
-					f.peekBuff.Append(m[:n])
-				}
-				if err == io.ErrUnexpectedEOF {
-					f.size = f.peekBuff.Size()
-					f.Reader = f.peekBuff
-					return f.peekBuff, nil
-				} else if err != nil {
-					return nil, err
-				}
+			} else {
+				m = nil
+			}
+		}
+		if m != nil {
+			n, err = io.ReadFull(reader, m)
+			if n > 0 {
+				f.peekBuff.Append(m[:n])
+			}
+			if err == io.ErrUnexpectedEOF {
+				f.size = f.peekBuff.Size()
+				f.Reader = f.peekBuff
+				return f.peekBuff, nil
+			} else if err != nil {
+				return nil, err
+			}
+		} else if f.BufferThreshold > 0 && limit > 0 {
+			// an explicit threshold without mmap is kept in memory, allocated as the data arrives
+			ended, err := f.peekChunks(reader, limit)
+			if err != nil {
+				return nil, err
+			}
+			if ended {
+				f.size = f.peekBuff.Size()
+				f.Reader = f.peekBuff
+				return f.peekBuff, nil
 			}
 		}
 		tmpF, err := utils.CreateTempFile(reader, 0)
@@ -193,6 +223,25 @@ func (f *FileStream) CacheFullAndWriter(up *model.UpdateProgress, writer io.Writ
 	return f.cache(f.GetSize())
 }
 
+// peekChunks reads up to limit bytes of reader into peekBuff in chunks of at most
+// peekChunkSize, so only what the stream holds is allocated. It reports whether reader ended
+func (f *FileStream) peekChunks(reader io.Reader, limit int) (bool, error) {
+	for limit > 0 {
+		buf := make([]byte, min(limit, peekChunkSize))
+		n, err := io.ReadFull(reader, buf)
+		if n > 0 {
+			f.peekBuff.Append(buf[:n])
+			limit -= n
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
 func (f *FileStream) GetFile() model.File {
 	if file, ok := f.Reader.(model.File); ok {
 		return file
@@ -225,7 +274,7 @@ func (f *FileStream) RangeRead(httpRange http_range.Range) (io.Reader, error) {
 
 // 确保指定大小的数据被缓存
 func (f *FileStream) cache(maxCacheSize int64) (model.File, error) {
-	if maxCacheSize > int64(conf.MaxBufferLimit) {
+	if maxCacheSize > int64(f.bufferLimit()) {
 		size := f.GetSize()
 		reader := f.Reader
 		if f.peekBuff != nil {
@@ -7,7 +7,9 @@ import (
 	"io"
 	"testing"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/buffer"
 	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 )
@@ -107,3 +109,49 @@ func TestFileStream_With_PreHash(t *testing.T) {
 		t.Errorf("fullHash and fileFullHash should match: fullHash=%s fileFullHash=%s", fullHash, fileFullHash)
 	}
 }
+
+func TestFileStream_BufferThreshold(t *testing.T) {
+	if conf.Conf == nil {
+		conf.Conf = &conf.Config{}
+	}
+	conf.Conf.TempDir = t.TempDir()
+	const threshold = 8
+	tests := []struct {
+		name     string
+		size     int
+		unknown  bool
+		inMemory bool
+	}{
+		{"known size at threshold", threshold, false, true},
+		{"known size over threshold", threshold + 1, false, false},
+		{"unknown size below threshold", threshold - 1, true, true},
+		{"unknown size at threshold", threshold, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte("a"), tt.size)
+			size := int64(tt.size)
+			if tt.unknown {
+				size = -1
+			}
+			f := &FileStream{
+				Obj:             &model.Object{Size: size},
+				Reader:          io.NopCloser(bytes.NewReader(data)),
+				BufferThreshold: threshold,
+			}
+			defer f.Close()
+			cache, err := f.CacheFullAndWriter(nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, inMemory := cache.(*buffer.Reader)
+			if inMemory != tt.inMemory {
+				t.Errorf("cached in memory = %v, want %v (%T)", inMemory, tt.inMemory, cache)
+			}
+			got, err := io.ReadAll(io.NewSectionReader(cache, 0, int64(tt.size)))
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("cached data = %q, %v", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlurhash(t *testing.T) {
+	solid := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			solid.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	hash, err := Blurhash(solid, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// L is 4x3 components, TI:j the pure red DC
+	if len(hash) != 28 || hash[0] != 'L' || hash[2:6] != "TI:j" {
+		t.Errorf("unexpected hash of a red image %s", hash)
+	}
+
+	gradient := pattern(64, 48, false)
+	hash, err = Blurhash(gradient, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hash) != 28 || hash[0] != 'L' {
+		t.Errorf("unexpected hash %s", hash)
+	}
+
+	if _, err = Blurhash(solid, 0, 3); err == nil {
+		t.Error("expected error for 0 components")
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+)
+
+const (
+	dominantColorSamples = 64 // side of the grid of pixels sampled
+	dominantColorBits    = 4  // bits kept per channel when grouping similar colors
+)
+
+// DominantColor returns the most common color of img as #rrggbb: the sampled pixels are grouped
+// by their quantized color and the mean of the largest group is returned, so a small but vivid
+// area doesn't tint the result the way a plain average would. Empty for an empty image
+func DominantColor(img image.Image) string {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return ""
+	}
+	type group struct {
+		count   int
+		r, g, b int
+	}
+	groups := make(map[int]*group)
+	var best *group
+	shift := 8 - dominantColorBits
+	gw, gh := min(w, dominantColorSamples), min(h, dominantColorSamples)
+	for y := 0; y < gh; y++ {
+		sy := bounds.Min.Y + y*h/gh
+		for x := 0; x < gw; x++ {
+			sx := bounds.Min.X + x*w/gw
+			r, g, b, a := img.At(sx, sy).RGBA()
+			if a == 0 {
+				continue
+			}
+			r, g, b = r>>8, g>>8, b>>8
+			key := int(r>>shift)<<(2*dominantColorBits) | int(g>>shift)<<dominantColorBits | int(b>>shift)
+			gr, ok := groups[key]
+			if !ok {
+				gr = &group{}
+				groups[key] = gr
+			}
+			gr.count++
+			gr.r += int(r)
+			gr.g += int(g)
+			gr.b += int(b)
+			if best == nil || gr.count > best.count {
+				best = gr
+			}
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.count, best.g/best.count, best.b/best.count)
+}
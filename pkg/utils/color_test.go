@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDominantColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 100; x++ {
+			c := color.RGBA{R: 200, G: 40, B: 30, A: 255}
+			// a smaller blue area mustn't win nor tint the result
+			if x < 30 {
+				c = color.RGBA{R: 10, G: 20, B: 230, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	if got := DominantColor(img); got != "#c8281e" {
+		t.Errorf("got %s, want #c8281e", got)
+	}
+	if got := DominantColor(image.NewRGBA(image.Rect(0, 0, 0, 0))); got != "" {
+		t.Errorf("empty image gave %s", got)
+	}
+}
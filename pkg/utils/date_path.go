@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var datePathTokens = []string{"YYYY", "MM", "DD"}
+
+var datePathLayouts = map[string]string{"YYYY": "2006", "MM": "01", "DD": "02"}
+
+// FormatDatePath expands a date path template like "YYYY/MM/DD" with t.
+// The template must use at least one of YYYY, MM and DD and expand to a
+// relative path without empty, "." or ".." segments.
+func FormatDatePath(template string, t time.Time) (string, error) {
+	template = strings.Trim(strings.TrimSpace(template), "/")
+	if !strings.Contains(template, "YYYY") && !strings.Contains(template, "MM") && !strings.Contains(template, "DD") {
+		return "", errors.New("date path template must contain YYYY, MM or DD")
+	}
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsAny(segment, `\`) {
+			return "", fmt.Errorf("invalid segment %q in date path template", segment)
+		}
+		// only the tokens are expanded, other characters are kept as they are
+		var b strings.Builder
+		for _, part := range splitDateTokens(segment) {
+			if layout, ok := datePathLayouts[part]; ok {
+				b.WriteString(t.Format(layout))
+			} else {
+				b.WriteString(part)
+			}
+		}
+		segments[i] = b.String()
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// splitDateTokens splits s into the date tokens and the text between them
+func splitDateTokens(s string) []string {
+	var parts []string
+	for len(s) > 0 {
+		idx, token := -1, ""
+		for _, tk := range datePathTokens {
+			if i := strings.Index(s, tk); i >= 0 && (idx < 0 || i < idx) {
+				idx, token = i, tk
+			}
+		}
+		if idx < 0 {
+			parts = append(parts, s)
+			break
+		}
+		if idx > 0 {
+			parts = append(parts, s[:idx])
+		}
+		parts = append(parts, token)
+		s = s[idx+len(token):]
+	}
+	return parts
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDatePath(t *testing.T) {
+	date := time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC)
+	testCases := map[string]string{
+		"YYYY/MM/DD":      "2024/03/07",
+		"/YYYY/MM/":       "2024/03",
+		"YYYY-MM/DD":      "2024-03/07",
+		"photos/YYYY/MM":  "photos/2024/03",
+		"YYYY/Trip MM-DD": "2024/Trip 03-07",
+		" YYYY/MMDD ":     "2024/0307",
+	}
+	for template, expected := range testCases {
+		got, err := FormatDatePath(template, date)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", template, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("%q: expected %q, got %q", template, expected, got)
+		}
+	}
+}
+
+func TestFormatDatePathInvalid(t *testing.T) {
+	date := time.Now()
+	for _, template := range []string{"", "photos", "YYYY//MM", "YYYY/../MM", "YYYY/./DD", `YYYY\MM`} {
+		if _, err := FormatDatePath(template, date); err == nil {
+			t.Errorf("%q: expected error", template)
+		}
+	}
+}
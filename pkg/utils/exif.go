@@ -0,0 +1,424 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	exifTagMake              = 0x010F
+	exifTagModel             = 0x0110
+	exifTagOrientation       = 0x0112
+	exifTagDateTime          = 0x0132
+	exifTagExifIFD           = 0x8769
+	exifTagGPSIFD            = 0x8825
+	exifTagDateTimeOriginal  = 0x9003
+	exifTagDateTimeDigitized = 0x9004
+	exifTagPixelXDimension   = 0xA002
+	exifTagPixelYDimension   = 0xA003
+	gpsTagLatitudeRef        = 0x0001
+	gpsTagLatitude           = 0x0002
+	gpsTagLongitudeRef       = 0x0003
+	gpsTagLongitude          = 0x0004
+	exifTypeASCII            = 2
+	exifTypeShort            = 3
+	exifTypeLong             = 4
+	exifTypeRational         = 5
+	exifDateLayout           = "2006:01:02 15:04:05"
+)
+
+var (
+	ErrExifDateNotFound        = errors.New("exif date not found")
+	ErrExifOrientationNotFound = errors.New("exif orientation not found")
+)
+
+// ReadExifDate returns the capture date stored in the EXIF of a JPEG or TIFF,
+// preferring DateTimeOriginal over DateTimeDigitized over DateTime.
+// The date has no zone in EXIF, so it's interpreted in the local time zone.
+// Only the head of the file is needed, the EXIF segment precedes the image data.
+func ReadExifDate(r io.Reader) (time.Time, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	tiff, err := findExifTIFF(data)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return readTIFFDate(tiff)
+}
+
+// findExifTIFF returns the TIFF structure holding the EXIF
+func findExifTIFF(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")) {
+		return data, nil
+	}
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a jpeg or tiff")
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil, errors.New("invalid jpeg marker")
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			// fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			// start of scan or end of image, no more metadata
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 {
+			return nil, errors.New("invalid jpeg segment length")
+		}
+		start, end := i+4, i+2+length
+		if end > len(data) {
+			end = len(data)
+		}
+		if marker == 0xE1 && bytes.HasPrefix(data[start:end], []byte("Exif\x00\x00")) {
+			return data[start+6 : end], nil
+		}
+		i += 2 + length
+	}
+	return nil, ErrExifDateNotFound
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func (t *tiffReader) u16(off int) (uint16, bool) {
+	if off < 0 || off+2 > len(t.data) {
+		return 0, false
+	}
+	return t.order.Uint16(t.data[off:]), true
+}
+
+func (t *tiffReader) u32(off int) (uint32, bool) {
+	if off < 0 || off+4 > len(t.data) {
+		return 0, false
+	}
+	return t.order.Uint32(t.data[off:]), true
+}
+
+// ifdEntries returns the entries of the IFD at off indexed by tag,
+// the value is the offset of the 4 byte value/offset field
+func (t *tiffReader) ifdEntries(off int) map[uint16]int {
+	entries := make(map[uint16]int)
+	count, ok := t.u16(off)
+	if !ok {
+		return entries
+	}
+	for i := 0; i < int(count); i++ {
+		entry := off + 2 + i*12
+		tag, ok := t.u16(entry)
+		if !ok {
+			break
+		}
+		entries[tag] = entry
+	}
+	return entries
+}
+
+func (t *tiffReader) date(entry int) (time.Time, bool) {
+	typ, _ := t.u16(entry + 2)
+	count, ok := t.u32(entry + 4)
+	if !ok || typ != exifTypeASCII || count < uint32(len(exifDateLayout)) {
+		return time.Time{}, false
+	}
+	off, _ := t.u32(entry + 8)
+	start, end := int(off), int(off)+len(exifDateLayout)
+	if end > len(t.data) {
+		return time.Time{}, false
+	}
+	value := strings.TrimSpace(string(t.data[start:end]))
+	date, err := time.ParseInLocation(exifDateLayout, value, time.Local)
+	if err != nil || date.Year() <= 1 {
+		// unset dates are often stored as "0000:00:00 00:00:00"
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// ifd0 returns the reader of the TIFF and the entries of its first IFD
+func ifd0(data []byte) (*tiffReader, map[uint16]int, error) {
+	t := &tiffReader{data: data}
+	switch {
+	case bytes.HasPrefix(data, []byte("II")):
+		t.order = binary.LittleEndian
+	case bytes.HasPrefix(data, []byte("MM")):
+		t.order = binary.BigEndian
+	default:
+		return nil, nil, errors.New("invalid tiff byte order")
+	}
+	off, ok := t.u32(4)
+	if !ok {
+		return nil, nil, errors.New("invalid tiff header")
+	}
+	return t, t.ifdEntries(int(off)), nil
+}
+
+func readTIFFDate(data []byte) (time.Time, error) {
+	t, entries, err := ifd0(data)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if entry, ok := entries[exifTagExifIFD]; ok {
+		typ, _ := t.u16(entry + 2)
+		if off, ok := t.u32(entry + 8); ok && typ == exifTypeLong {
+			exifEntries := t.ifdEntries(int(off))
+			for _, tag := range []uint16{exifTagDateTimeOriginal, exifTagDateTimeDigitized} {
+				if e, ok := exifEntries[tag]; ok {
+					if date, ok := t.date(e); ok {
+						return date, nil
+					}
+				}
+			}
+		}
+	}
+	if entry, ok := entries[exifTagDateTime]; ok {
+		if date, ok := t.date(entry); ok {
+			return date, nil
+		}
+	}
+	return time.Time{}, ErrExifDateNotFound
+}
+
+// ReadExifOrientation returns the EXIF orientation (1-8) of a JPEG or TIFF,
+// 1 is upright, 6 needs a clockwise rotation by 90 degrees to display upright
+func ReadExifOrientation(r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	tiff, err := findExifTIFF(data)
+	if err != nil {
+		return 0, err
+	}
+	t, entries, err := ifd0(tiff)
+	if err != nil {
+		return 0, err
+	}
+	entry, ok := entries[exifTagOrientation]
+	if !ok {
+		return 0, ErrExifOrientationNotFound
+	}
+	typ, _ := t.u16(entry + 2)
+	value, ok := t.u16(entry + 8)
+	if !ok || typ != exifTypeShort || value < 1 || value > 8 {
+		return 0, ErrExifOrientationNotFound
+	}
+	return int(value), nil
+}
+
+// ExifInfo is the metadata of an image read by ReadExif, missing values are zero
+type ExifInfo struct {
+	Date        time.Time
+	Make        string
+	Model       string
+	Width       int
+	Height      int
+	Orientation int
+	// HasGPS tells whether Latitude and Longitude are set, in degrees, negative south and west
+	HasGPS    bool
+	Latitude  float64
+	Longitude float64
+}
+
+// ReadExif reads the capture date, camera, dimensions, orientation and GPS position
+// stored in the EXIF of a JPEG or TIFF, like ReadExifDate only the head of the file is needed
+func ReadExif(r io.Reader) (*ExifInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tiff, err := findExifTIFF(data)
+	if err != nil {
+		return nil, err
+	}
+	t, entries, err := ifd0(tiff)
+	if err != nil {
+		return nil, err
+	}
+	info := &ExifInfo{}
+	info.Date, _ = readTIFFDate(tiff)
+	if entry, ok := entries[exifTagMake]; ok {
+		info.Make = t.ascii(entry)
+	}
+	if entry, ok := entries[exifTagModel]; ok {
+		info.Model = t.ascii(entry)
+	}
+	if entry, ok := entries[exifTagOrientation]; ok {
+		info.Orientation = t.uint(entry)
+	}
+	if exifEntries, ok := t.subIFD(entries, exifTagExifIFD); ok {
+		if entry, ok := exifEntries[exifTagPixelXDimension]; ok {
+			info.Width = t.uint(entry)
+		}
+		if entry, ok := exifEntries[exifTagPixelYDimension]; ok {
+			info.Height = t.uint(entry)
+		}
+	}
+	if gpsEntries, ok := t.subIFD(entries, exifTagGPSIFD); ok {
+		lat, latOK := t.coordinate(gpsEntries, gpsTagLatitude, gpsTagLatitudeRef, "S")
+		lon, lonOK := t.coordinate(gpsEntries, gpsTagLongitude, gpsTagLongitudeRef, "W")
+		if latOK && lonOK {
+			info.HasGPS, info.Latitude, info.Longitude = true, lat, lon
+		}
+	}
+	return info, nil
+}
+
+// subIFD returns the entries of the IFD pointed to by tag
+func (t *tiffReader) subIFD(entries map[uint16]int, tag uint16) (map[uint16]int, bool) {
+	entry, ok := entries[tag]
+	if !ok {
+		return nil, false
+	}
+	off, ok := t.u32(entry + 8)
+	if !ok {
+		return nil, false
+	}
+	return t.ifdEntries(int(off)), true
+}
+
+// ascii returns the ASCII value of the entry, values up to 4 bytes are stored in the entry itself
+func (t *tiffReader) ascii(entry int) string {
+	typ, _ := t.u16(entry + 2)
+	count, ok := t.u32(entry + 4)
+	if !ok || typ != exifTypeASCII {
+		return ""
+	}
+	start := entry + 8
+	if count > 4 {
+		off, _ := t.u32(entry + 8)
+		start = int(off)
+	}
+	end := start + int(count)
+	if start < 0 || end > len(t.data) || end < start {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(t.data[start:end]), "\x00"))
+}
+
+// uint returns the SHORT or LONG value of the entry, 0 for other types
+func (t *tiffReader) uint(entry int) int {
+	typ, _ := t.u16(entry + 2)
+	switch typ {
+	case exifTypeShort:
+		v, _ := t.u16(entry + 8)
+		return int(v)
+	case exifTypeLong:
+		v, _ := t.u32(entry + 8)
+		return int(v)
+	}
+	return 0
+}
+
+// coordinate returns the degrees of a GPS latitude or longitude stored as degrees, minutes
+// and seconds rationals, negative when its ref is negativeRef
+func (t *tiffReader) coordinate(entries map[uint16]int, tag, refTag uint16, negativeRef string) (float64, bool) {
+	entry, ok := entries[tag]
+	if !ok {
+		return 0, false
+	}
+	typ, _ := t.u16(entry + 2)
+	count, _ := t.u32(entry + 4)
+	off, ok := t.u32(entry + 8)
+	if !ok || typ != exifTypeRational || count != 3 {
+		return 0, false
+	}
+	value := 0.0
+	for i, unit := range []float64{1, 60, 3600} {
+		num, ok1 := t.u32(int(off) + i*8)
+		den, ok2 := t.u32(int(off) + i*8 + 4)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		if den != 0 {
+			value += float64(num) / float64(den) / unit
+		}
+	}
+	if ref, ok := entries[refTag]; ok && t.ascii(ref) == negativeRef {
+		value = -value
+	}
+	return value, true
+}
+
+// StripJPEGMetadata removes the EXIF, XMP, IPTC and comment segments of a JPEG without
+// re-encoding it. The ICC profile (APP2) and Adobe (APP14) segments are kept since they
+// affect the colors, and an orientation other than 1 is kept in a minimal EXIF segment so
+// the image still displays upright
+func StripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a jpeg")
+	}
+	orientation, _ := ReadExifOrientation(bytes.NewReader(data))
+	var head, rest bytes.Buffer
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, errors.New("invalid jpeg marker")
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errors.New("invalid jpeg segment length")
+		}
+		switch {
+		case marker == 0xE0 && rest.Len() == 0:
+			// APP0 has to stay right after SOI
+			head.Write(data[i:end])
+		case marker == 0xE2 || marker == 0xEE || marker < 0xE0:
+			rest.Write(data[i:end])
+		case marker == 0xFE || marker >= 0xE0 && marker <= 0xEF:
+			// comment and the other application segments, EXIF and XMP are APP1
+		default:
+			rest.Write(data[i:end])
+		}
+		i = end
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	out.Write(head.Bytes())
+	if orientation > 1 {
+		out.Write(orientationSegment(uint16(orientation)))
+	}
+	out.Write(rest.Bytes())
+	out.Write(data[i:])
+	return out.Bytes(), nil
+}
+
+// orientationSegment returns an APP1 EXIF segment holding only the orientation
+func orientationSegment(orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("Exif\x00\x00MM")
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(42))
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(8))
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(1))
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(exifTagOrientation))
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(exifTypeShort))
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(1))
+	_ = binary.Write(&tiff, binary.BigEndian, orientation)
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(0))
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(0))
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(tiff.Len()+2))
+	return append(segment, tiff.Bytes()...)
+}
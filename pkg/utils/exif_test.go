@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+type testIFDEntry struct {
+	tag   uint16
+	typ   uint16
+	value []byte // ASCII payload, or the 4 byte value
+}
+
+// buildTIFF builds a TIFF with IFD0 holding DateTime and an Exif IFD holding DateTimeOriginal
+func buildTIFF(order binary.ByteOrder, dateTime, dateTimeOriginal string) []byte {
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	_ = binary.Write(&buf, order, uint16(42))
+	_ = binary.Write(&buf, order, uint32(8))
+
+	const ifd0Size = 2 + 2*12 + 4
+	const exifIFDSize = 2 + 12 + 4
+	exifIFDOffset := uint32(8 + ifd0Size)
+	dataOffset := exifIFDOffset + exifIFDSize
+	dateTimeValue := append([]byte(dateTime), 0)
+	originalValue := append([]byte(dateTimeOriginal), 0)
+
+	writeEntry := func(tag, typ uint16, count, value uint32) {
+		_ = binary.Write(&buf, order, tag)
+		_ = binary.Write(&buf, order, typ)
+		_ = binary.Write(&buf, order, count)
+		_ = binary.Write(&buf, order, value)
+	}
+	// IFD0
+	_ = binary.Write(&buf, order, uint16(2))
+	writeEntry(exifTagDateTime, exifTypeASCII, uint32(len(dateTimeValue)), dataOffset)
+	writeEntry(exifTagExifIFD, exifTypeLong, 1, exifIFDOffset)
+	_ = binary.Write(&buf, order, uint32(0))
+	// Exif IFD
+	_ = binary.Write(&buf, order, uint16(1))
+	writeEntry(exifTagDateTimeOriginal, exifTypeASCII, uint32(len(originalValue)), dataOffset+uint32(len(dateTimeValue)))
+	_ = binary.Write(&buf, order, uint32(0))
+
+	buf.Write(dateTimeValue)
+	buf.Write(originalValue)
+	return buf.Bytes()
+}
+
+func buildJPEG(tiff []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xFF, 0xD8})
+	// APP0 JFIF
+	buf.Write([]byte{0xFF, 0xE0, 0x00, 0x07})
+	buf.WriteString("JFIF\x00")
+	// APP1 Exif
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	buf.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&buf, binary.BigEndian, uint16(len(payload)+2))
+	buf.Write(payload)
+	// SOS, the image data isn't needed
+	buf.Write([]byte{0xFF, 0xDA, 0x00, 0x02})
+	return buf.Bytes()
+}
+
+func TestReadExifDate(t *testing.T) {
+	expected := time.Date(2023, 8, 15, 18, 30, 5, 0, time.Local)
+	testCases := map[string][]byte{
+		"jpeg little endian": buildJPEG(buildTIFF(binary.LittleEndian, "2024:01:01 00:00:00", "2023:08:15 18:30:05")),
+		"jpeg big endian":    buildJPEG(buildTIFF(binary.BigEndian, "2024:01:01 00:00:00", "2023:08:15 18:30:05")),
+		"tiff":               buildTIFF(binary.LittleEndian, "2024:01:01 00:00:00", "2023:08:15 18:30:05"),
+		"fallback":           buildJPEG(buildTIFF(binary.BigEndian, "2023:08:15 18:30:05", "0000:00:00 00:00:00")),
+	}
+	for name, data := range testCases {
+		date, err := ReadExifDate(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if !date.Equal(expected) {
+			t.Errorf("%s: expected %s, got %s", name, expected, date)
+		}
+	}
+}
+
+func TestReadExifDateInvalid(t *testing.T) {
+	full := buildJPEG(buildTIFF(binary.LittleEndian, "2024:01:01 00:00:00", "2023:08:15 18:30:05"))
+	testCases := map[string][]byte{
+		"empty":     {},
+		"png":       []byte("\x89PNG\r\n\x1a\n"),
+		"no exif":   {0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02},
+		"truncated": full[:40],
+	}
+	for name, data := range testCases {
+		if _, err := ReadExifDate(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func buildOrientationTIFF(order binary.ByteOrder, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	_ = binary.Write(&buf, order, uint16(42))
+	_ = binary.Write(&buf, order, uint32(8))
+	_ = binary.Write(&buf, order, uint16(1))
+	_ = binary.Write(&buf, order, uint16(exifTagOrientation))
+	_ = binary.Write(&buf, order, uint16(exifTypeShort))
+	_ = binary.Write(&buf, order, uint32(1))
+	// a SHORT is left aligned in the 4 byte value field
+	_ = binary.Write(&buf, order, orientation)
+	_ = binary.Write(&buf, order, uint16(0))
+	_ = binary.Write(&buf, order, uint32(0))
+	return buf.Bytes()
+}
+
+func TestReadExifOrientation(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, orientation := range []uint16{1, 3, 6, 8} {
+			got, err := ReadExifOrientation(bytes.NewReader(buildJPEG(buildOrientationTIFF(order, orientation))))
+			if err != nil {
+				t.Errorf("%s %d: unexpected error: %v", order, orientation, err)
+				continue
+			}
+			if got != int(orientation) {
+				t.Errorf("%s: expected %d, got %d", order, orientation, got)
+			}
+		}
+	}
+	if _, err := ReadExifOrientation(bytes.NewReader(buildJPEG(buildOrientationTIFF(binary.LittleEndian, 9)))); err == nil {
+		t.Error("expected error for orientation out of range")
+	}
+	if _, err := ReadExifOrientation(bytes.NewReader(buildJPEG(buildTIFF(binary.LittleEndian, "2024:01:01 00:00:00", "2024:01:01 00:00:00")))); err == nil {
+		t.Error("expected error without orientation")
+	}
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	dated := buildJPEG(buildTIFF(binary.LittleEndian, "2024:01:01 00:00:00", "2023:08:15 18:30:05"))
+	stripped, err := StripJPEGMetadata(dated)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = ReadExifDate(bytes.NewReader(stripped)); err == nil {
+		t.Error("exif date kept")
+	}
+	if !bytes.HasPrefix(stripped, []byte{0xFF, 0xD8, 0xFF, 0xE0}) || !bytes.HasSuffix(stripped, []byte{0xFF, 0xDA, 0x00, 0x02}) {
+		t.Errorf("SOI, APP0 or the image data not kept: %x", stripped)
+	}
+
+	for _, orientation := range []uint16{1, 6} {
+		stripped, err = StripJPEGMetadata(buildJPEG(buildOrientationTIFF(binary.LittleEndian, orientation)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := ReadExifOrientation(bytes.NewReader(stripped))
+		if orientation == 1 {
+			if err == nil {
+				t.Errorf("exif kept for orientation 1")
+			}
+			continue
+		}
+		if err != nil || got != int(orientation) {
+			t.Errorf("expected orientation %d, got %d, %v", orientation, got, err)
+		}
+	}
+
+	if _, err = StripJPEGMetadata([]byte("\x89PNG\r\n\x1a\n")); err == nil {
+		t.Error("expected error for a png")
+	}
+}
+
+// buildGPSTIFF builds a TIFF with Make, Model and Orientation in IFD0 and a GPS IFD
+func buildGPSTIFF(order binary.ByteOrder) []byte {
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	_ = binary.Write(&buf, order, uint16(42))
+	_ = binary.Write(&buf, order, uint32(8))
+
+	const ifd0Size = 2 + 4*12 + 4
+	const gpsIFDSize = 2 + 4*12 + 4
+	gpsIFDOffset := uint32(8 + ifd0Size)
+	makeOffset := gpsIFDOffset + gpsIFDSize
+	latOffset := makeOffset + 8
+	lonOffset := latOffset + 24
+
+	entry := func(tag, typ uint16, count uint32, value uint32) {
+		_ = binary.Write(&buf, order, tag)
+		_ = binary.Write(&buf, order, typ)
+		_ = binary.Write(&buf, order, count)
+		_ = binary.Write(&buf, order, value)
+	}
+	inline := func(tag uint16, value string) {
+		_ = binary.Write(&buf, order, tag)
+		_ = binary.Write(&buf, order, uint16(exifTypeASCII))
+		_ = binary.Write(&buf, order, uint32(len(value)))
+		buf.Write(append([]byte(value), make([]byte, 4-len(value))...))
+	}
+
+	_ = binary.Write(&buf, order, uint16(4))
+	entry(exifTagMake, exifTypeASCII, 8, makeOffset)
+	inline(exifTagModel, "X1\x00")
+	_ = binary.Write(&buf, order, uint16(exifTagOrientation))
+	_ = binary.Write(&buf, order, uint16(exifTypeShort))
+	_ = binary.Write(&buf, order, uint32(1))
+	_ = binary.Write(&buf, order, uint16(6))
+	_ = binary.Write(&buf, order, uint16(0))
+	entry(exifTagGPSIFD, exifTypeLong, 1, gpsIFDOffset)
+	_ = binary.Write(&buf, order, uint32(0))
+
+	_ = binary.Write(&buf, order, uint16(4))
+	inline(gpsTagLatitudeRef, "S\x00")
+	entry(gpsTagLatitude, exifTypeRational, 3, latOffset)
+	inline(gpsTagLongitudeRef, "E\x00")
+	entry(gpsTagLongitude, exifTypeRational, 3, lonOffset)
+	_ = binary.Write(&buf, order, uint32(0))
+
+	buf.WriteString("Canon\x00\x00\x00")
+	// 33°52'12.6" S, 151°12'30" E
+	for _, v := range []uint32{33, 1, 52, 1, 126, 10, 151, 1, 12, 1, 30, 1} {
+		_ = binary.Write(&buf, order, v)
+	}
+	return buf.Bytes()
+}
+
+func TestReadExif(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		info, err := ReadExif(bytes.NewReader(buildJPEG(buildGPSTIFF(order))))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", order, err)
+		}
+		if info.Make != "Canon" || info.Model != "X1" || info.Orientation != 6 {
+			t.Errorf("%s: unexpected camera %+v", order, info)
+		}
+		if !info.HasGPS {
+			t.Fatalf("%s: expected GPS", order)
+		}
+		if lat := -(33 + 52.0/60 + 12.6/3600); math.Abs(info.Latitude-lat) > 1e-9 {
+			t.Errorf("%s: expected latitude %f, got %f", order, lat, info.Latitude)
+		}
+		if lon := 151 + 12.0/60 + 30.0/3600; math.Abs(info.Longitude-lon) > 1e-9 {
+			t.Errorf("%s: expected longitude %f, got %f", order, lon, info.Longitude)
+		}
+	}
+	info, err := ReadExif(bytes.NewReader(buildJPEG(buildTIFF(binary.LittleEndian, "2024:01:01 00:00:00", "2023:08:15 18:30:05"))))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2023, 8, 15, 18, 30, 5, 0, time.Local); !info.Date.Equal(want) || info.HasGPS {
+		t.Errorf("unexpected info %+v", info)
+	}
+}
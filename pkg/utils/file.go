@@ -164,12 +164,14 @@ func GetObjType(filename string, isDir bool) int {
 }
 
 var extraMimeTypes = map[string]string{
-	".apk": "application/vnd.android.package-archive",
+	".apk":  "application/vnd.android.package-archive",
+	".heic": "image/heic",
+	".heif": "image/heif",
 }
 
 func GetMimeType(name string) string {
 	ext := path.Ext(name)
-	if m, ok := extraMimeTypes[ext]; ok {
+	if m, ok := extraMimeTypes[strings.ToLower(ext)]; ok {
 		return m
 	}
 	m := mime.TypeByExtension(ext)
@@ -41,3 +41,16 @@ func TestIsSystemFile(t *testing.T) {
 		})
 	}
 }
+
+func TestGetMimeTypeHEIC(t *testing.T) {
+	for name, want := range map[string]string{
+		"IMG_0001.HEIC": "image/heic",
+		"photo.heic":    "image/heic",
+		"photo.heif":    "image/heif",
+		"app.apk":       "application/vnd.android.package-archive",
+	} {
+		if got := GetMimeType(name); got != want {
+			t.Errorf("GetMimeType(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
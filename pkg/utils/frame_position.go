@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FramePosition is where a video thumbnail is taken, the embedded cover or a percentage of the duration
+type FramePosition struct {
+	Cover   bool
+	Percent float64
+}
+
+func (p FramePosition) String() string {
+	if p.Cover {
+		return "cover"
+	}
+	return strconv.FormatFloat(p.Percent, 'f', -1, 64) + "%"
+}
+
+// ParseFramePositions parses an ordered comma separated list like "cover,10,50,3",
+// every entry is "cover" or a percentage in [0, 100)
+func ParseFramePositions(s string) ([]FramePosition, error) {
+	var positions []FramePosition
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.EqualFold(part, "cover") {
+			positions = append(positions, FramePosition{Cover: true})
+			continue
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(part, "%"), 64)
+		if err != nil || percent < 0 || percent >= 100 {
+			return nil, fmt.Errorf("invalid frame position %q, expected cover or a percentage in [0, 100)", part)
+		}
+		positions = append(positions, FramePosition{Percent: percent})
+	}
+	if len(positions) == 0 {
+		return nil, fmt.Errorf("no frame position")
+	}
+	return positions, nil
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFramePositions(t *testing.T) {
+	positions, err := ParseFramePositions(" cover, 10,50%,3.5 ")
+	require.NoError(t, err)
+	assert.Equal(t, []FramePosition{{Cover: true}, {Percent: 10}, {Percent: 50}, {Percent: 3.5}}, positions)
+
+	for _, s := range []string{"", " , ", "cover,abc", "100", "-1", "cover,10,,x"} {
+		_, err := ParseFramePositions(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"hash"
 	"io"
 	"iter"
+	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	log "github.com/sirupsen/logrus"
@@ -92,6 +94,20 @@ var (
 	SHA256 = RegisterHash("sha256", "SHA-256", 64, sha256.New)
 )
 
+// ErrInvalidHash is returned by NormalizeHash for a value which can't be a digest of the hash type
+var ErrInvalidHash = errors.New("invalid hash")
+
+// NormalizeHash checks that str is a hex digest of ht, i.e. Width hex chars, and returns it in lowercase
+func NormalizeHash(ht *HashType, str string) (string, error) {
+	if len(str) != ht.Width {
+		return "", fmt.Errorf("%w: %s must be %d hex chars, got %d", ErrInvalidHash, ht.Name, ht.Width, len(str))
+	}
+	if _, err := hex.DecodeString(str); err != nil {
+		return "", fmt.Errorf("%w: %s is not hex", ErrInvalidHash, ht.Name)
+	}
+	return strings.ToLower(str), nil
+}
+
 // HashData get hash of one hashType
 func HashData(hashType *HashType, data []byte, params ...any) string {
 	h := hashType.NewFunc(params...)
@@ -185,6 +201,35 @@ func (m *MultiHasher) Size() int64 {
 	return m.size
 }
 
+// HashMismatchError is returned by MultiHasher.Verify for data not hashing to the expected sum
+type HashMismatchError struct {
+	Type      *HashType
+	Got, Want string
+}
+
+func (e *HashMismatchError) Error() string {
+	return fmt.Sprintf("%s mismatch: the data hashes to %s, %s expected", e.Type.Name, e.Got, e.Want)
+}
+
+// Verify checks the sums of the data written against want, in the order of Supported.
+// A hash type of want the MultiHasher doesn't compute is an ErrUnsupported
+func (m *MultiHasher) Verify(want map[*HashType]string) error {
+	for _, ht := range Supported {
+		expected, ok := want[ht]
+		if !ok {
+			continue
+		}
+		sum, err := m.Sum(ht)
+		if err != nil {
+			return err
+		}
+		if got := hex.EncodeToString(sum); got != strings.ToLower(expected) {
+			return &HashMismatchError{Type: ht, Got: got, Want: expected}
+		}
+	}
+	return nil
+}
+
 // A HashInfo contains hash string for one or more hashType
 type HashInfo struct {
 	h map[*HashType]string `json:"hashInfo"`
@@ -64,3 +64,52 @@ func TestMultiHasher(t *testing.T) {
 
 	}
 }
+
+func TestNormalizeHash(t *testing.T) {
+	tests := []struct {
+		name  string
+		ht    *HashType
+		input string
+		want  string
+		valid bool
+	}{
+		{"md5", MD5, "bf13fc19e5151ac57d4252e0e0f87abe", "bf13fc19e5151ac57d4252e0e0f87abe", true},
+		{"md5 uppercase", MD5, "BF13FC19E5151AC57D4252E0E0F87ABE", "bf13fc19e5151ac57d4252e0e0f87abe", true},
+		{"sha1", SHA1, "3ab6543c08a75f292a5ecedac87ec41642d12166", "3ab6543c08a75f292a5ecedac87ec41642d12166", true},
+		{"sha256", SHA256, "C839E57675862AF5C21BD0A15413C3EC579E0D5522DAB600BC6C3489B05B8F54", "c839e57675862af5c21bd0a15413c3ec579e0d5522dab600bc6c3489b05b8f54", true},
+		{"md5 too short", MD5, "bf13fc19e5151ac57d4252e0e0f87ab", "", false},
+		{"md5 too long", MD5, "bf13fc19e5151ac57d4252e0e0f87abe0", "", false},
+		{"sha1 given md5", SHA1, "bf13fc19e5151ac57d4252e0e0f87abe", "", false},
+		{"sha256 given sha1", SHA256, "3ab6543c08a75f292a5ecedac87ec41642d12166", "", false},
+		{"md5 not hex", MD5, "zf13fc19e5151ac57d4252e0e0f87abe", "", false},
+		{"sha1 with spaces", SHA1, " 3ab6543c08a75f292a5ecedac87ec41642d1216", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeHash(tt.ht, tt.input)
+			if !tt.valid {
+				assert.ErrorIs(t, err, ErrInvalidHash)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMultiHasherVerify(t *testing.T) {
+	test := hashTestSet[0]
+	mh := NewMultiHasher([]*HashType{MD5, SHA1})
+	_, err := CopyWithBuffer(mh, bytes.NewBuffer(test.input))
+	require.NoError(t, err)
+	assert.NoError(t, mh.Verify(map[*HashType]string{MD5: test.output[MD5], SHA1: test.output[SHA1]}))
+	assert.NoError(t, mh.Verify(map[*HashType]string{MD5: "BF13FC19E5151AC57D4252E0E0F87ABE"}))
+
+	var mismatch *HashMismatchError
+	err = mh.Verify(map[*HashType]string{MD5: test.output[MD5], SHA1: hashTestSet[1].output[SHA1]})
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, SHA1, mismatch.Type)
+	assert.Equal(t, test.output[SHA1], mismatch.Got)
+
+	assert.ErrorIs(t, mh.Verify(map[*HashType]string{SHA256: test.output[SHA256]}), ErrUnsupported)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"golang.org/x/text/unicode/norm"
 )
 
 // FixAndCleanPath
@@ -43,6 +44,19 @@ func IsSubPath(path string, subPath string) bool {
 	return path == subPath || strings.HasPrefix(subPath, PathAddSeparatorSuffix(path))
 }
 
+// NormalizeUnicodePath converts path to the unicode normalization form "nfc" or "nfd",
+// other forms leave it unchanged. macOS clients send decomposed (NFD) names where
+// others send composed (NFC) ones, so the same-looking names differ in bytes
+func NormalizeUnicodePath(path, form string) string {
+	switch strings.ToLower(form) {
+	case "nfc":
+		return norm.NFC.String(path)
+	case "nfd":
+		return norm.NFD.String(path)
+	}
+	return path
+}
+
 func Ext(path string) string {
 	return strings.ToLower(SourceExt(path))
 }
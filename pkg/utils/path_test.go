@@ -51,3 +51,23 @@ func TestGetPathHierarchy(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeUnicodePath(t *testing.T) {
+	composed := "/photos/caf\u00e9/r\u00e9sum\u00e9.jpg"
+	decomposed := "/photos/cafe\u0301/re\u0301sume\u0301.jpg"
+	for _, form := range []string{"nfc", "nfd", "NFC"} {
+		c, d := NormalizeUnicodePath(composed, form), NormalizeUnicodePath(decomposed, form)
+		if c != d {
+			t.Errorf("%s: %q and %q normalize to %q and %q", form, composed, decomposed, c, d)
+		}
+	}
+	if got := NormalizeUnicodePath(decomposed, "nfc"); got != composed {
+		t.Errorf("nfc of %q = %q, want %q", decomposed, got, composed)
+	}
+	if got := NormalizeUnicodePath(composed, "nfd"); got != decomposed {
+		t.Errorf("nfd of %q = %q, want %q", composed, got, decomposed)
+	}
+	if got := NormalizeUnicodePath(decomposed, "none"); got != decomposed {
+		t.Errorf("none changed %q to %q", decomposed, got)
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	phashSize    = 32 // side of the grayscale image the DCT is computed on
+	phashLowFreq = 8  // side of the low frequencies kept in the hash
+)
+
+// PHash returns the DCT based perceptual hash of img: similar looking images,
+// e.g. re-encoded or resized, have hashes a small HammingDistance apart
+func PHash(img image.Image) uint64 {
+	pixels := grayscaleResize(img, phashSize)
+	var coefs [phashLowFreq * phashLowFreq]float64
+	for u := 0; u < phashLowFreq; u++ {
+		for v := 0; v < phashLowFreq; v++ {
+			sum := 0.0
+			for y := 0; y < phashSize; y++ {
+				for x := 0; x < phashSize; x++ {
+					sum += pixels[y*phashSize+x] *
+						math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*phashSize)) *
+						math.Cos(float64(2*y+1)*float64(v)*math.Pi/(2*phashSize))
+				}
+			}
+			coefs[v*phashLowFreq+u] = sum
+		}
+	}
+	// the DC coefficient is the mean brightness, it would skew the median
+	sorted := append([]float64(nil), coefs[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	var hash uint64
+	for i, c := range coefs {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns the number of bits differing between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// grayscaleResize returns the luminance of img scaled to size x size, each pixel
+// being the mean of the source pixels it covers
+func grayscaleResize(img image.Image, size int) []float64 {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pixels := make([]float64, size*size)
+	if w == 0 || h == 0 {
+		return pixels
+	}
+	for y := 0; y < size; y++ {
+		y0, y1 := bounds.Min.Y+y*h/size, bounds.Min.Y+(y+1)*h/size
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < size; x++ {
+			x0, x1 := bounds.Min.X+x*w/size, bounds.Min.X+(x+1)*w/size
+			if x1 == x0 {
+				x1++
+			}
+			sum := 0.0
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			pixels[y*size+x] = sum / float64((y1-y0)*(x1-x0)) / 257
+		}
+	}
+	return pixels
+}
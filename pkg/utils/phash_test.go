@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// pattern draws a few shapes over a wavy background, scaled to w x h
+func pattern(w, h int, invert bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := float64(x)/float64(w), float64(y)/float64(h)
+			v := uint8(100 + 60*math.Sin(7*fx+2*fy) + 40*math.Cos(3*fy*fy+5*fx))
+			if fx > 0.2 && fx < 0.5 && fy > 0.3 && fy < 0.7 {
+				v = 250
+			}
+			if (fx-0.75)*(fx-0.75)+(fy-0.2)*(fy-0.2) < 0.02 {
+				v = 10
+			}
+			if invert {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestPHash(t *testing.T) {
+	original := PHash(pattern(320, 180, false))
+	if d := HammingDistance(original, PHash(pattern(160, 90, false))); d > 4 {
+		t.Errorf("expected a resized copy to be close, distance %d", d)
+	}
+	if d := HammingDistance(original, PHash(pattern(320, 180, true))); d < 20 {
+		t.Errorf("expected an inverted copy to be far, distance %d", d)
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	if d := HammingDistance(0b1011, 0b0110); d != 3 {
+		t.Errorf("expected 3, got %d", d)
+	}
+}
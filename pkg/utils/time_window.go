@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily window of time, End may be earlier than Start
+// for a window crossing midnight
+type TimeWindow struct {
+	Start time.Duration // since midnight
+	End   time.Duration
+}
+
+func (w TimeWindow) Contains(t time.Time) bool {
+	d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.Start <= w.End {
+		return d >= w.Start && d < w.End
+	}
+	return d >= w.Start || d < w.End
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ParseTimeWindows parses comma separated daily windows like "01:00-06:00,22:30-23:30",
+// an empty string means no restriction and returns no windows
+func ParseTimeWindows(s string) ([]TimeWindow, error) {
+	var windows []TimeWindow
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		start, end, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", part)
+		}
+		var w TimeWindow
+		var err error
+		if w.Start, err = parseClock(start); err != nil {
+			return nil, err
+		}
+		if w.End, err = parseClock(end); err != nil {
+			return nil, err
+		}
+		if w.Start == w.End {
+			return nil, fmt.Errorf("empty time window %q", part)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// InTimeWindows reports whether t falls in any of the windows,
+// which is always true when there are no windows
+func InTimeWindows(windows []TimeWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInTimeWindows(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2024, 1, 1, hour, min, 0, 0, time.Local)
+	}
+	testCases := []struct {
+		windows  string
+		t        time.Time
+		expected bool
+	}{
+		{"", at(12, 0), true},
+		{"01:00-06:00", at(3, 0), true},
+		{"01:00-06:00", at(6, 0), false},
+		{"01:00-06:00", at(0, 59), false},
+		{"22:00-02:00", at(23, 30), true},
+		{"22:00-02:00", at(1, 0), true},
+		{"22:00-02:00", at(12, 0), false},
+		{"01:00-02:00, 13:00-14:00", at(13, 15), true},
+	}
+	for _, tc := range testCases {
+		windows, err := ParseTimeWindows(tc.windows)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.windows, err)
+			continue
+		}
+		if got := InTimeWindows(windows, tc.t); got != tc.expected {
+			t.Errorf("%q at %s: expected %v, got %v", tc.windows, tc.t.Format("15:04"), tc.expected, got)
+		}
+	}
+}
+
+func TestParseTimeWindowsInvalid(t *testing.T) {
+	for _, s := range []string{"01:00", "1-2", "25:00-26:00", "01:00-01:00", "01:00-06:00,x"} {
+		if _, err := ParseTimeWindows(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"fmt"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxVersionSeq bounds the search for an unused version name
+const maxVersionSeq = 10000
+
+// ValidateVersionNameTemplate checks a template of {name}, {ext}, {timestamp} and {seq} placeholders,
+// {seq} is required so that every version gets a unique name
+func ValidateVersionNameTemplate(template string) error {
+	if !strings.Contains(template, "{seq}") {
+		return fmt.Errorf("version name template %q must contain {seq}", template)
+	}
+	if strings.ContainsAny(template, `/\`) {
+		return fmt.Errorf("version name template %q must not contain path separators", template)
+	}
+	// a template rendering the file name itself would overwrite the live file
+	if FormatVersionName(template, "file.txt", time.Unix(0, 0), 1) == "file.txt" {
+		return fmt.Errorf("version name template %q collides with the live file", template)
+	}
+	return nil
+}
+
+// FormatVersionName renders the template for the file name, {name} is the name without {ext},
+// which includes the dot, e.g. "{name}{ext}.bak.{seq}" renders "a.txt.bak.1"
+func FormatVersionName(template, filename string, t time.Time, seq int) string {
+	ext := stdpath.Ext(filename)
+	return strings.NewReplacer(
+		"{name}", strings.TrimSuffix(filename, ext),
+		"{ext}", ext,
+		"{timestamp}", t.Format("20060102150405"),
+		"{seq}", strconv.Itoa(seq),
+	).Replace(template)
+}
+
+// NextVersionName returns the first version name of the file with a {seq} from 1 that doesn't exist yet
+func NextVersionName(template, filename string, t time.Time, exists func(name string) bool) (string, error) {
+	if err := ValidateVersionNameTemplate(template); err != nil {
+		return "", err
+	}
+	for seq := 1; seq <= maxVersionSeq; seq++ {
+		name := FormatVersionName(template, filename, t, seq)
+		if name != filename && !exists(name) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no free version name of %s after %d versions", filename, maxVersionSeq)
+}
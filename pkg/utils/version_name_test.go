@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextVersionNameSequentialOverwrites(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for _, template := range []string{"{name}{ext}.bak.{seq}", "{name}.v{seq}{ext}", "{name}-{timestamp}-{seq}{ext}"} {
+		existing := map[string]bool{"report.pdf": true}
+		for i := 0; i < 5; i++ {
+			name, err := NextVersionName(template, "report.pdf", now, func(name string) bool {
+				return existing[name]
+			})
+			if err != nil {
+				t.Fatalf("%s: %v", template, err)
+			}
+			if existing[name] {
+				t.Fatalf("%s: version name %s is not unique", template, name)
+			}
+			existing[name] = true
+		}
+	}
+}
+
+func TestFormatVersionName(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		template, want string
+	}{
+		{"{name}{ext}.bak.{seq}", "a.txt.bak.2"},
+		{"{name}.{timestamp}.{seq}{ext}", "a.20240506070809.2.txt"},
+	}
+	for _, tt := range tests {
+		if got := FormatVersionName(tt.template, "a.txt", now, 2); got != tt.want {
+			t.Errorf("FormatVersionName(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestValidateVersionNameTemplate(t *testing.T) {
+	for _, template := range []string{"{name}{ext}", "{name}{ext}.{timestamp}", "bak/{name}{ext}.{seq}"} {
+		if err := ValidateVersionNameTemplate(template); err == nil {
+			t.Errorf("template %q should be rejected", template)
+		}
+	}
+	if err := ValidateVersionNameTemplate("{name}{ext}.bak.{seq}"); err != nil {
+		t.Error(err)
+	}
+}
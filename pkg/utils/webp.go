@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WebPHeaderSize is the number of bytes needed by CheckWebPHeader
+const WebPHeaderSize = 16
+
+// CheckWebPHeader validates the RIFF/WEBP container signature of a WebP file,
+// so that it doesn't depend on a registered image decoder.
+// size is the total size of the file, or -1 if unknown.
+func CheckWebPHeader(header []byte, size int64) error {
+	if len(header) < WebPHeaderSize {
+		return errors.New("file too small to be a webp")
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return errors.New("missing RIFF/WEBP signature")
+	}
+	switch chunk := string(header[12:16]); chunk {
+	case "VP8 ", "VP8L", "VP8X":
+	default:
+		return fmt.Errorf("unknown webp chunk %q", chunk)
+	}
+	riffSize := int64(binary.LittleEndian.Uint32(header[4:8]))
+	if size >= 0 && riffSize+8 > size {
+		return fmt.Errorf("webp truncated: riff size %d, file size %d", riffSize+8, size)
+	}
+	return nil
+}
+
+// ReadWebPHeader reads the header from r and validates it with CheckWebPHeader
+func ReadWebPHeader(r io.Reader, size int64) error {
+	header := make([]byte, WebPHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("failed to read webp header: %w", err)
+	}
+	return CheckWebPHeader(header, size)
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+// 1x1 images encoded by libwebp (cwebp)
+const (
+	libwebpLossless = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+	libwebpLossy    = "UklGRiIAAABXRUJQVlA4IBYAAAAwAQCdASoBAAEADsD+JaQAA3AAAAAA"
+)
+
+func TestReadWebPHeader(t *testing.T) {
+	for name, b64 := range map[string]string{"lossless": libwebpLossless, "lossy": libwebpLossy} {
+		data, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ReadWebPHeader(bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+		if err = ReadWebPHeader(bytes.NewReader(data), -1); err != nil {
+			t.Errorf("%s with unknown size: unexpected error: %v", name, err)
+		}
+	}
+}
+
+func TestReadWebPHeaderInvalid(t *testing.T) {
+	valid, _ := base64.StdEncoding.DecodeString(libwebpLossy)
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00")
+	wav := append([]byte("RIFF"), valid[4:8]...)
+	wav = append(wav, []byte("WAVEfmt ")...)
+	testCases := map[string][]byte{
+		"empty":     {},
+		"png":       png,
+		"wav":       wav,
+		"truncated": valid[:len(valid)-4],
+	}
+	for name, data := range testCases {
+		if err := ReadWebPHeader(bytes.NewReader(data), int64(len(data))); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 )
@@ -41,6 +43,19 @@ func ErrorResp(c *gin.Context, err error, code int, l ...bool) {
 	//c.Abort()
 }
 
+// PathErrorResp is used when user.JoinPath rejects a requested path.
+// With conf.HidePathErrors only admins get the detailed error, the others a generic one,
+// so the message can't reveal the layout beneath their base path. The detail is always logged.
+func PathErrorResp(c *gin.Context, err error, code int) {
+	user, _ := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if user != nil && !user.IsAdmin() && setting.GetBool(conf.HidePathErrors) {
+		log.Warnf("path of user %s denied: %+v", user.Username, err)
+		ErrorStrResp(c, "access denied", code)
+		return
+	}
+	ErrorResp(c, err, code)
+}
+
 // ErrorPage is used to return error page HTML.
 // It also returns standard HTTP status code.
 // @param l: if true, log error
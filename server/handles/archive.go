@@ -97,7 +97,7 @@ func FsArchiveMeta(c *gin.Context, req *ArchiveMetaReq, user *model.User) {
 	}
 	reqPath, err := user.JoinPath(req.Path)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	meta, err := op.GetNearestMeta(reqPath)
@@ -182,7 +182,7 @@ func FsArchiveList(c *gin.Context, req *ArchiveListReq, user *model.User) {
 	}
 	reqPath, err := user.JoinPath(req.Path)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	meta, err := op.GetNearestMeta(reqPath)
@@ -254,14 +254,14 @@ func FsArchiveDecompress(c *gin.Context) {
 	for _, name := range req.Name {
 		srcPath, err := user.JoinPath(stdpath.Join(req.SrcDir, name))
 		if err != nil {
-			common.ErrorResp(c, err, 403)
+			common.PathErrorResp(c, err, 403)
 			return
 		}
 		srcPaths = append(srcPaths, srcPath)
 	}
 	dstDir, err := user.JoinPath(req.DstDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	tasks := make([]task.TaskExtensionInfo, 0, len(srcPaths))
@@ -0,0 +1,158 @@
+package handles
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap/data"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ConfigSourceFile    = "file"
+	ConfigSourceDB      = "db"
+	ConfigSourceDefault = "default"
+
+	redactedValue = "******"
+)
+
+// configSettingOverrides maps config.json keys to the setting items which
+// take precedence over them once stored in the database.
+var configSettingOverrides = map[string]string{
+	"webdav.enable": "webdav_enabled",
+	"webdav.listen": "webdav_listen",
+}
+
+var secretKeywords = []string{"secret", "password", "token", "api_key", "dsn"}
+
+type EffectiveConfigItem struct {
+	Key     string `json:"key"`
+	Value   any    `json:"value"`
+	Source  string `json:"source"`
+	Setting string `json:"setting,omitempty"`
+}
+
+type EffectiveSettingItem struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Group  int    `json:"group"`
+	Source string `json:"source"`
+}
+
+type EffectiveConfigResp struct {
+	Config   []EffectiveConfigItem  `json:"config"`
+	Settings []EffectiveSettingItem `json:"settings"`
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range secretKeywords {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenConfig walks a config struct and returns its leaf values keyed by
+// the dotted json path, e.g. "scheme.http_port".
+func flattenConfig(prefix string, v reflect.Value, out map[string]any) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			flattenConfig(name, fv, out)
+			continue
+		}
+		out[name] = fv.Interface()
+	}
+}
+
+func GetEffectiveConfig(c *gin.Context) {
+	current := make(map[string]any)
+	flattenConfig("", reflect.ValueOf(conf.Conf), current)
+	defaults := make(map[string]any)
+	flattenConfig("", reflect.ValueOf(conf.DefaultConfig(flags.DataDir)), defaults)
+
+	stored := op.GetSettingsMap()
+	resp := EffectiveConfigResp{
+		Config:   make([]EffectiveConfigItem, 0, len(current)),
+		Settings: make([]EffectiveSettingItem, 0, len(stored)),
+	}
+	for key, value := range current {
+		item := EffectiveConfigItem{Key: key, Value: value, Source: ConfigSourceFile}
+		if settingKey, ok := configSettingOverrides[key]; ok {
+			if _, ok := stored[settingKey]; ok {
+				item.Source = ConfigSourceDB
+				item.Setting = settingKey
+			}
+		}
+		if item.Source == ConfigSourceFile && reflect.DeepEqual(value, defaults[key]) {
+			item.Source = ConfigSourceDefault
+		}
+		if isSecretKey(key) {
+			item.Value = redactedValue
+		}
+		resp.Config = append(resp.Config, item)
+	}
+
+	initial := make(map[string]model.SettingItem)
+	for _, item := range data.InitialSettings() {
+		initial[item.Key] = item
+	}
+	items, err := op.GetSettingItems()
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	for _, item := range items {
+		if item.IsDeprecated() {
+			continue
+		}
+		source := ConfigSourceDB
+		if def, ok := initial[item.Key]; ok && def.Value == item.Value {
+			source = ConfigSourceDefault
+		}
+		// 私有设置的值一律隐藏,只保留来源
+		value := item.Value
+		if item.Flag == model.PRIVATE {
+			value = redactedValue
+		}
+		resp.Settings = append(resp.Settings, EffectiveSettingItem{
+			Key:    item.Key,
+			Value:  value,
+			Group:  item.Group,
+			Source: source,
+		})
+	}
+	sort.Slice(resp.Config, func(i, j int) bool {
+		return resp.Config[i].Key < resp.Config[j].Key
+	})
+	common.SuccessResp(c, resp)
+}
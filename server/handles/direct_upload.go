@@ -35,7 +35,7 @@ func FsGetDirectUploadInfo(c *gin.Context) {
 	user := c.Request.Context().Value(conf.UserKey).(*model.User)
 	path, err = user.JoinPath(path)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	overwrite := c.GetHeader("Overwrite") != "false"
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	stdpath "path"
 	"strconv"
+	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
@@ -71,6 +72,9 @@ func Proxy(c *gin.Context) {
 			common.ErrorPage(c, err, 500)
 			return
 		}
+		if strings.HasPrefix(utils.GetMimeType(filename), "text/") && !renderMarkdown(c, file) {
+			serveStoredCharset(c, rawPath)
+		}
 		proxy(c, link, file, storage.GetStorage().ProxyRange)
 	} else {
 		common.ErrorPage(c, errors.New("proxy not allowed"), 403)
@@ -115,8 +119,7 @@ func proxy(c *gin.Context, link *model.Link, file model.Obj, proxyRange bool) {
 		link = common.ProxyRange(c, link, file.GetSize())
 	}
 	Writer := &common.WrittenResponseWriter{ResponseWriter: c.Writer}
-	raw, _ := strconv.ParseBool(c.DefaultQuery("raw", "false"))
-	if utils.Ext(file.GetName()) == "md" && setting.GetBool(conf.FilterReadMeScripts) && !raw {
+	if renderMarkdown(c, file) {
 		buf := bytes.NewBuffer(make([]byte, 0, file.GetSize()))
 		w := &common.InterceptResponseWriter{ResponseWriter: Writer, Writer: buf}
 		err = common.Proxy(w, c.Request, link, file)
@@ -156,6 +159,12 @@ func proxy(c *gin.Context, link *model.Link, file model.Obj, proxyRange bool) {
 	}
 }
 
+// renderMarkdown reports whether proxy serves the markdown file as sanitized html
+func renderMarkdown(c *gin.Context, file model.Obj) bool {
+	raw, _ := strconv.ParseBool(c.DefaultQuery("raw", "false"))
+	return utils.Ext(file.GetName()) == "md" && setting.GetBool(conf.FilterReadMeScripts) && !raw
+}
+
 // TODO need optimize
 // when can be proxy?
 // 1. text file
@@ -0,0 +1,110 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/sirupsen/logrus"
+)
+
+var errCommandTimeout = errors.New("command timed out")
+
+// commandWaitDelay bounds how long Wait blocks on output pipes held open by
+// orphaned children after the process group has been killed
+const commandWaitDelay = 5 * time.Second
+
+func ffprobeTimeout() time.Duration {
+	return time.Duration(setting.GetInt(conf.FFprobeTimeout, 30)) * time.Second
+}
+
+func ffmpegTimeout() time.Duration {
+	return time.Duration(setting.GetInt(conf.FFmpegTimeout, 300)) * time.Second
+}
+
+// runCommand runs name with a hard timeout (0 means no limit), killing the
+// whole process group on expiry. A timeout is reported as errCommandTimeout.
+func runCommand(ctx context.Context, timeout time.Duration, combined bool, name string, args ...string) ([]byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = commandWaitDelay
+	var output []byte
+	var err error
+	if combined {
+		output, err = cmd.CombinedOutput()
+	} else {
+		output, err = cmd.Output()
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logrus.Warnf("%s执行超时(%s)，已终止进程组", name, timeout)
+		return output, fmt.Errorf("%w: %s after %s", errCommandTimeout, name, timeout)
+	}
+	return output, err
+}
+
+// ffmpegBinary returns the ffmpeg executable of conf.FFmpegPath, ffmpeg from PATH when unset
+func ffmpegBinary() string {
+	if path := setting.GetStr(conf.FFmpegPath); path != "" {
+		return path
+	}
+	return "ffmpeg"
+}
+
+// ffprobeBinary returns the ffprobe next to the configured ffmpeg, ffprobe from PATH when unset
+func ffprobeBinary() string {
+	path := setting.GetStr(conf.FFmpegPath)
+	if path == "" {
+		return "ffprobe"
+	}
+	dir, name := filepath.Split(path)
+	return filepath.Join(dir, strings.Replace(name, "ffmpeg", "ffprobe", 1))
+}
+
+// ffmpegCheck caches whether the ffmpeg of conf.FFmpegPath exists, checked again once the setting changes
+var ffmpegCheck struct {
+	sync.Mutex
+	path      string
+	available bool
+}
+
+// ffmpegAvailable reports whether ffmpeg can be run. A missing ffmpeg is logged once per configured
+// path instead of every thumbnail failing with its own error
+func ffmpegAvailable() bool {
+	path := ffmpegBinary()
+	ffmpegCheck.Lock()
+	defer ffmpegCheck.Unlock()
+	if ffmpegCheck.path == path {
+		return ffmpegCheck.available
+	}
+	_, err := exec.LookPath(path)
+	ffmpegCheck.path, ffmpegCheck.available = path, err == nil
+	if err != nil {
+		logrus.Warnf("未找到ffmpeg(%s)，不生成缩略图: %v", path, err)
+	}
+	return ffmpegCheck.available
+}
+
+// runFFprobe returns the stdout of ffprobe
+func runFFprobe(ctx context.Context, args ...string) ([]byte, error) {
+	return runCommand(ctx, ffprobeTimeout(), false, ffprobeBinary(), args...)
+}
+
+// runFFmpeg returns the combined output of ffmpeg
+// and records it to the thumbnail attempt of ctx
+func runFFmpeg(ctx context.Context, args ...string) ([]byte, error) {
+	output, err := runCommand(ctx, ffmpegTimeout(), true, ffmpegBinary(), args...)
+	recordFFmpegOutput(ctx, args, output)
+	return output, err
+}
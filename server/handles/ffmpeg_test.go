@@ -0,0 +1,31 @@
+package handles
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestFFmpegAvailable(t *testing.T) {
+	setPath := func(value string) {
+		if err := op.SaveSettingItem(&model.SettingItem{Key: conf.FFmpegPath, Value: value, Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := filepath.Join(t.TempDir(), "bin", "ffmpeg")
+	setPath(missing)
+	t.Cleanup(func() { setPath("") })
+
+	if ffmpegAvailable() {
+		t.Errorf("expected %s to be unavailable", missing)
+	}
+	if want := filepath.Join(filepath.Dir(missing), "ffprobe"); ffprobeBinary() != want {
+		t.Errorf("expected ffprobe %s, got %s", want, ffprobeBinary())
+	}
+	if thumbnailState("/videos/a.mp4") != thumbnailStateUnsupported {
+		t.Error("expected thumbnails to be unsupported without ffmpeg")
+	}
+}
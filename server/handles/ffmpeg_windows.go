@@ -0,0 +1,16 @@
+//go:build windows
+
+package handles
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup kills the process tree on cancellation
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		_ = exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+		return cmd.Process.Kill()
+	}
+}
@@ -36,12 +36,12 @@ func FsRecursiveMove(c *gin.Context) {
 	}
 	srcDir, err := user.JoinPath(req.SrcDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	dstDir, err := user.JoinPath(req.DstDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 
@@ -157,7 +157,7 @@ func FsBatchRename(c *gin.Context) {
 
 	reqPath, err := user.JoinPath(req.SrcDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 
@@ -207,7 +207,7 @@ func FsRegexRename(c *gin.Context) {
 
 	reqPath, err := user.JoinPath(req.SrcDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 
@@ -33,7 +33,7 @@ func FsMkdir(c *gin.Context) {
 	user := c.Request.Context().Value(conf.UserKey).(*model.User)
 	reqPath, err := user.JoinPath(req.Path)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	if !user.CanWrite() {
@@ -82,12 +82,12 @@ func FsMove(c *gin.Context) {
 	}
 	srcDir, err := user.JoinPath(req.SrcDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	dstDir, err := user.JoinPath(req.DstDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 
@@ -149,12 +149,12 @@ func FsCopy(c *gin.Context) {
 	}
 	srcDir, err := user.JoinPath(req.SrcDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	dstDir, err := user.JoinPath(req.DstDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 
@@ -278,7 +278,7 @@ func FsRemove(c *gin.Context) {
 	}
 	reqDir, err := user.JoinPath(req.Dir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	for _, name := range req.Names {
@@ -310,7 +310,7 @@ func FsRemoveEmptyDirectory(c *gin.Context) {
 	}
 	srcDir, err := user.JoinPath(req.SrcDir)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 
@@ -1,6 +1,7 @@
 package handles
 
 import (
+	"encoding/json"
 	"fmt"
 	stdpath "path"
 	"strings"
@@ -24,6 +25,8 @@ type ListReq struct {
 	Path     string `json:"path" form:"path"`
 	Password string `json:"password" form:"password"`
 	Refresh  bool   `json:"refresh"`
+	// InlineThumbnail embeds small existing thumbnails into the listing
+	InlineThumbnail bool `json:"inline_thumbnail" form:"inline_thumbnail"`
 }
 
 type DirReq struct {
@@ -44,6 +47,12 @@ type ObjResp struct {
 	HashInfoStr  string                     `json:"hashinfo"`
 	HashInfo     map[*utils.HashType]string `json:"hash_info"`
 	MountDetails *model.StorageDetails      `json:"mount_details,omitempty"`
+	InlineThumb  string                     `json:"inline_thumb,omitempty"`
+	Blurhash     string                     `json:"blurhash,omitempty"`
+	// DominantColor is the #rrggbb color of the thumbnail, see conf.EnableDominantColor
+	DominantColor string `json:"dominant_color,omitempty"`
+	// LivePhoto pairs a still with its motion, see conf.LivePhotos
+	LivePhoto *LivePhoto `json:"live_photo,omitempty"`
 }
 
 type FsListResp struct {
@@ -79,7 +88,7 @@ func FsListSplit(c *gin.Context) {
 func FsList(c *gin.Context, req *ListReq, user *model.User) {
 	reqPath, err := user.JoinPath(req.Path)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	meta, err := op.GetNearestMeta(reqPath)
@@ -106,6 +115,9 @@ func FsList(c *gin.Context, req *ListReq, user *model.User) {
 		common.ErrorResp(c, err, 500)
 		return
 	}
+	if !user.IsAdmin() {
+		objs = hideQuarantine(objs)
+	}
 	total, objs := pagination(objs, &req.PageReq)
 	provider := "unknown"
 	var directUploadTools []string
@@ -114,8 +126,16 @@ func FsList(c *gin.Context, req *ListReq, user *model.User) {
 			directUploadTools = op.GetDirectUploadTools(storage)
 		}
 	}
+	content := toObjsResp(objs, reqPath, isEncrypt(meta, reqPath))
+	if req.InlineThumbnail {
+		inlineThumbnails(c.Request.Context(), reqPath, content)
+	}
+	placeholders(reqPath, content)
+	livePhotos(content)
+	folderThumbnails(c, req.Path, content)
+	lazyThumbnails(reqPath, content, user)
 	common.SuccessResp(c, FsListResp{
-		Content:           toObjsResp(objs, reqPath, isEncrypt(meta, reqPath)),
+		Content:           content,
 		Total:             int64(total),
 		Readme:            getReadme(meta, reqPath),
 		Header:            getHeader(meta, reqPath),
@@ -141,7 +161,7 @@ func FsDirs(c *gin.Context) {
 	} else {
 		tmp, err := user.JoinPath(req.Path)
 		if err != nil {
-			common.ErrorResp(c, err, 403)
+			common.PathErrorResp(c, err, 403)
 			return
 		}
 		reqPath = tmp
@@ -260,6 +280,8 @@ type FsGetResp struct {
 	Header   string    `json:"header"`
 	Provider string    `json:"provider"`
 	Related  []ObjResp `json:"related"`
+	// Metadata uploaded with the file in X-Metadata
+	Metadata json.RawMessage `json:"metadata,omitempty"`
 }
 
 func FsGetSplit(c *gin.Context) {
@@ -284,7 +306,7 @@ func FsGetSplit(c *gin.Context) {
 func FsGet(c *gin.Context, req *FsGetReq, user *model.User) {
 	reqPath, err := user.JoinPath(req.Path)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	meta, err := op.GetNearestMeta(reqPath)
@@ -359,25 +381,34 @@ func FsGet(c *gin.Context, req *FsGetReq, user *model.User) {
 	parentMeta, _ := op.GetNearestMeta(parentPath)
 	thumb, _ := model.GetThumb(obj)
 	mountDetails, _ := model.GetStorageDetails(obj)
+	var metadata json.RawMessage
+	var blurhash, dominantColor string
+	if !obj.IsDir() {
+		metadata = storedMetadata(c.Request.Context(), reqPath)
+		blurhash, dominantColor = storedPlaceholders(reqPath)
+	}
 	common.SuccessResp(c, FsGetResp{
 		ObjResp: ObjResp{
-			Name:         obj.GetName(),
-			Size:         obj.GetSize(),
-			IsDir:        obj.IsDir(),
-			Modified:     obj.ModTime(),
-			Created:      obj.CreateTime(),
-			HashInfoStr:  obj.GetHash().String(),
-			HashInfo:     obj.GetHash().Export(),
-			Sign:         common.Sign(obj, parentPath, isEncrypt(meta, reqPath)),
-			Type:         utils.GetFileType(obj.GetName()),
-			Thumb:        thumb,
-			MountDetails: mountDetails,
+			Name:          obj.GetName(),
+			Size:          obj.GetSize(),
+			IsDir:         obj.IsDir(),
+			Modified:      obj.ModTime(),
+			Created:       obj.CreateTime(),
+			HashInfoStr:   obj.GetHash().String(),
+			HashInfo:      obj.GetHash().Export(),
+			Sign:          common.Sign(obj, parentPath, isEncrypt(meta, reqPath)),
+			Type:          utils.GetFileType(obj.GetName()),
+			Thumb:         thumb,
+			MountDetails:  mountDetails,
+			Blurhash:      blurhash,
+			DominantColor: dominantColor,
 		},
 		RawURL:   rawURL,
 		Readme:   getReadme(meta, reqPath),
 		Header:   getHeader(meta, reqPath),
 		Provider: provider,
 		Related:  toObjsResp(related, parentPath, isEncrypt(parentMeta, parentPath)),
+		Metadata: metadata,
 	})
 }
 
@@ -410,7 +441,7 @@ func FsOther(c *gin.Context) {
 	var err error
 	req.Path, err = user.JoinPath(req.Path)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	meta, err := op.GetNearestMeta(req.Path)
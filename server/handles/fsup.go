@@ -1,13 +1,12 @@
 package handles
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"image"
 	"io"
 	"net/url"
 	"os"
-	"os/exec"
 	stdpath "path"
 	"strconv"
 	"strings"
@@ -17,6 +16,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/internal/fs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
@@ -61,6 +61,89 @@ func shouldIgnoreSystemFile(filename string) bool {
 	return false
 }
 
+// resolveOverwrite decides whether an upload replaces an existing file:
+// an explicit "true" or "false" from the client wins, then conf.DefaultOverwrite, then true
+func resolveOverwrite(value string) bool {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return setting.GetStr(conf.DefaultOverwrite, "true") != "false"
+}
+
+// uploadHashHeaders maps the hash headers of an upload to their hash types
+var uploadHashHeaders = []struct {
+	header string
+	ht     *utils.HashType
+}{
+	{"X-File-Md5", utils.MD5},
+	{"X-File-Sha1", utils.SHA1},
+	{"X-File-Sha256", utils.SHA256},
+}
+
+// uploadHashes reads the hash headers of an upload, lowercased. A malformed hash is an error,
+// or is dropped with a warning when conf.IgnoreInvalidHash is enabled
+func uploadHashes(c *gin.Context) (map[*utils.HashType]string, error) {
+	h := make(map[*utils.HashType]string)
+	for _, hh := range uploadHashHeaders {
+		value := c.GetHeader(hh.header)
+		if value == "" {
+			continue
+		}
+		normalized, err := utils.NormalizeHash(hh.ht, value)
+		if err != nil {
+			if setting.GetBool(conf.IgnoreInvalidHash) {
+				logrus.Warnf("ignore %s of upload: %v", hh.header, err)
+				continue
+			}
+			return nil, err
+		}
+		h[hh.ht] = normalized
+	}
+	return h, nil
+}
+
+const errEmptyUpload = "empty uploads are rejected"
+
+// rejectEmptyUploads reports whether zero-byte uploads fail instead of creating an empty file
+func rejectEmptyUploads() bool {
+	return setting.GetBool(conf.RejectEmptyUploads)
+}
+
+// streamBodyEmpty reads ahead one byte of a body of unknown size, the byte is put back
+func streamBodyEmpty(c *gin.Context) bool {
+	b := make([]byte, 1)
+	n, _ := io.ReadFull(c.Request.Body, b)
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b[:n]), c.Request.Body), c.Request.Body}
+	return n == 0
+}
+
+// uploadUser returns the user of the upload, responding 401 when no middleware set one,
+// e.g. a route registered without its auth middleware
+func uploadUser(c *gin.Context) (*model.User, bool) {
+	user, ok := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if !ok || user == nil {
+		common.ErrorStrResp(c, "no user in the request, login please", 401)
+		return nil, false
+	}
+	return user, true
+}
+
+// skipThumbnail reports whether the client asked not to generate thumbnails for this upload
+func skipThumbnail(c *gin.Context) bool {
+	return c.GetHeader("Skip-Thumbnail") == "true"
+}
+
+// normalizeUploadPath applies conf.PathUnicodeNormalization to the path of an upload as sent
+func normalizeUploadPath(path string) string {
+	return utils.NormalizeUnicodePath(path, setting.GetStr(conf.PathUnicodeNormalization))
+}
+
 func FsStream(c *gin.Context) {
 	defer func() {
 		if n, _ := io.ReadFull(c.Request.Body, []byte{0}); n == 1 {
@@ -77,20 +160,66 @@ func FsStream(c *gin.Context) {
 		return
 	}
 
-	asTask := c.GetHeader("As-Task") == "true"
-	overwrite := c.GetHeader("Overwrite") != "false"
-	user := c.Request.Context().Value(conf.UserKey).(*model.User)
-	path, err = user.JoinPath(path)
+	mode, err := resolveUploadMode(c.Request.Header)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	overwrite, asTask, durable := mode.overwrite, mode.asTask, mode.durable
+	callbackURL, err := progressCallbackURL(c)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	thumbnailOverride, err := thumbnailOptionsFromHeaders(c)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user, ok := uploadUser(c)
+	if !ok {
+		return
+	}
+	path, err = user.JoinUploadPath(normalizeUploadPath(path))
+	if err != nil {
+		common.PathErrorResp(c, err, 403)
+		return
+	}
+	path, err = applyAutoRoute(c, user, path, c.GetHeader("Content-Type"))
 	if err != nil {
 		common.ErrorResp(c, err, 403)
 		return
 	}
-
-	if !overwrite {
-		if res, _ := fs.Get(c.Request.Context(), path, &fs.GetArgs{NoLog: true}); res != nil {
-			common.ErrorStrResp(c, "file exists", 403)
+	path, ok = applyDateOrganize(c, user, path, false)
+	if !ok {
+		return
+	}
+	// 重名时自动改名，不覆盖已有文件，最终路径在File-Path响应头中返回
+	if mode.rename {
+		var release func()
+		path, release, err = renameOnConflict(c.Request.Context(), path)
+		if err != nil {
+			common.ErrorResp(c, err, 500)
 			return
 		}
+		defer release()
+		c.Header("File-Path", url.PathEscape(path))
+	}
+	putCtx, ok := uploadPutContext(c, path)
+	if !ok {
+		return
+	}
+
+	// 目标已是目录时无论是否覆盖都拒绝，否则驱动会返回难以理解的错误
+	exist := probeExistence(c.Request.Context(), path)
+	if exist != nil && exist.IsDir() {
+		common.ErrorStrResp(c, "destination is a directory", 409)
+		return
+	}
+	// 去重的上传先与已有文件比较内容
+	if !overwrite && !mode.dedupe && exist != nil {
+		common.ErrorStrResp(c, "file exists", 403)
+		return
 	}
 
 	// 解析文件信息
@@ -116,107 +245,317 @@ func FsStream(c *gin.Context) {
 			}
 		}
 	}
+	if rejectEmptyUploads() && (size == 0 || size < 0 && streamBodyEmpty(c)) {
+		common.ErrorStrResp(c, errEmptyUpload, 400)
+		return
+	}
 	// 处理文件哈希信息
-	h := make(map[*utils.HashType]string)
-	if md5 := c.GetHeader("X-File-Md5"); md5 != "" {
-		h[utils.MD5] = md5
+	h, err := uploadHashes(c)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	// 按模式跳过与已有文件相同或不比它新的上传
+	if uploadSkipped(mode, exist, size, h, getLastModified(c)) {
+		skippedUploadResp(c, path, exist)
+		return
 	}
-	if sha1 := c.GetHeader("X-File-Sha1"); sha1 != "" {
-		h[utils.SHA1] = sha1
+	if !overwrite && exist != nil {
+		common.ErrorStrResp(c, "file exists", 403)
+		return
 	}
-	if sha256 := c.GetHeader("X-File-Sha256"); sha256 != "" {
-		h[utils.SHA256] = sha256
+	metadata, ok := parseUploadMetadata(c, path, overwrite, false)
+	if !ok {
+		return
+	}
+	preserved, metadata, ok := contentOnlyUpload(c, mode, path, exist, metadata)
+	if !ok {
+		return
+	}
+	mirrors, ok := uploadMirrorPaths(c, user, overwrite)
+	if !ok {
+		return
 	}
-
 	// 设置MIME类型
 	mimetype := c.GetHeader("Content-Type")
 	if len(mimetype) == 0 {
 		mimetype = utils.GetMimeType(name)
 	}
+	// 统计实际收到的字节数，用于发现连接中断导致的截断上传
+	declaredSize := size
+	body := &countingReader{Reader: c.Request.Body}
+	var reader io.Reader = body
+	// 按请求边读边校验哈希，不符时读取失败，后台任务在任务中校验缓存
+	verifier := newHashVerifier(c, body, declaredSize, h, asTask)
+	if verifier != nil {
+		reader = verifier
+	}
+	// 客户端接受trailer时边上传边计算哈希
+	trailers := newTrailerHasher(c, reader)
+	if trailers != nil {
+		reader = trailers
+	}
+	// 去除JPEG的元数据，存储内容改变后哈希按去除后的内容重新计算
+	if stripExifRequested(c, mimetype) {
+		data, hashes, err := stripUploadExif(reader)
+		if err != nil {
+			common.ErrorResp(c, err, 400)
+			return
+		}
+		if err = truncatedUploadErr(declaredSize, body.n); err != nil {
+			common.ErrorResp(c, err, 400)
+			return
+		}
+		reader, size, h = bytes.NewReader(data), int64(len(data)), hashes
+	}
+	// 有镜像路径时先缓存到临时文件，以便多次读取
+	var spool *os.File
+	if len(mirrors) > 0 {
+		spool, size, err = spoolUploadBody(reader)
+		if err != nil {
+			common.ErrorResp(c, err, uploadErrCode(err, 500))
+			return
+		}
+		defer func() {
+			_ = spool.Close()
+			_ = os.Remove(spool.Name())
+		}()
+		if err = truncatedUploadErr(declaredSize, body.n); err != nil {
+			common.ErrorResp(c, err, 400)
+			return
+		}
+		reader = spool
+	}
+	// 缓冲后确认：请求体落盘并同步后即返回，由任务写入存储
+	var durableFile *os.File
+	durableQueued := false
+	if durable {
+		durableFile, size, err = bufferDurableUpload(reader)
+		if err != nil {
+			common.ErrorResp(c, err, uploadErrCode(err, 500))
+			return
+		}
+		// 任务创建后由任务负责清理
+		defer func() {
+			if !durableQueued {
+				_ = removeDurableUpload(durableFile)()
+			}
+		}()
+		if err = truncatedUploadErr(declaredSize, body.n); err != nil {
+			common.ErrorResp(c, err, 400)
+			return
+		}
+		reader = durableFile
+	}
+
+	// 暂存的上传校验后等待客户端提交
+	staged, ok := stageUpload(c, mode, path, mimetype, user, mirrors, metadata)
+	if !ok {
+		return
+	}
+	if staged != nil {
+		dir = stdpath.Dir(staged.StagingPath)
+	}
+	// 需要审核的上传先放入隔离区
+	quarantine, ok := quarantineUpload(c, path, mimetype, overwrite, user, mirrors)
+	if !ok {
+		return
+	}
+	if quarantine != nil {
+		dir = stdpath.Dir(quarantine.QuarantinePath)
+	}
+	held := quarantine != nil || staged != nil
 
 	// 创建文件流对象
+	obj := &model.Object{
+		Name:     name,
+		Size:     size,
+		Modified: getLastModified(c),
+		Ctime:    preserved.createTime(),
+		HashInfo: utils.NewHashInfoByMap(h),
+	}
 	s := &stream.FileStream{
-		Obj: &model.Object{
-			Name:     name,
-			Size:     size,
-			Modified: getLastModified(c),
-			HashInfo: utils.NewHashInfoByMap(h),
-		},
-		Reader:       c.Request.Body,
-		Mimetype:     mimetype,
-		WebPutAsTask: asTask,
+		Obj:             obj,
+		Reader:          reader,
+		Mimetype:        mimetype,
+		WebPutAsTask:    asTask,
+		BufferThreshold: setting.GetInt(conf.UploadTempBufferThreshold, 0),
+	}
+	if durableFile != nil {
+		s.Add(removeDurableUpload(durableFile))
 	}
 
 	// 执行文件上传
+	if storage, err := fs.GetPutStorage(putCtx, dir); err == nil && !checkFreeSpace(c, storage, s.GetSize()) {
+		if quarantine != nil {
+			discardQuarantineItem(quarantine)
+		}
+		discardStagedUpload(c.Request.Context(), staged)
+		return
+	}
+	release, ok := acquireUploadSlot(c, path)
+	if !ok {
+		return
+	}
+	defer release()
+	// 隔离或暂存中的上传在审核通过或提交时保留旧版本
+	var version string
+	if overwrite && !held {
+		if version, err = keepPreviousVersion(c.Request.Context(), path); err != nil {
+			common.ErrorResp(c, err, 500)
+			return
+		}
+	}
 	var t task.TaskExtensionInfo
 	if asTask {
-		t, err = fs.PutAsTask(c.Request.Context(), dir, s)
+		t, err = fs.PutAsTask(verifyUploadTask(c, putCtx, h), dir, s)
+		durableQueued = t != nil
 	} else {
-		err = fs.PutDirectly(c.Request.Context(), dir, s)
+		err = fs.PutDirectly(putCtx, dir, s)
 	}
 
+	// 校验失败时删除驱动可能已写入的内容，通过时记录计算出的哈希
+	if err == nil && verifier != nil {
+		if err = verifier.finish(); err == nil {
+			obj.HashInfo = *verifier.hasher.GetHashInfo()
+		}
+	}
 	if err != nil {
-		common.ErrorResp(c, err, 500)
+		if quarantine != nil {
+			discardQuarantineItem(quarantine)
+		}
+		discardStagedUpload(c.Request.Context(), staged)
+		// 驱动可能只返回了自己的错误
+		if verifier != nil && verifier.err != nil {
+			err = verifier.err
+		}
+		code := uploadErrCode(err, 500)
+		if code == 400 && verifier != nil && !held {
+			removeUnverifiedUpload(putCtx, path, version)
+		}
+		common.ErrorResp(c, err, code)
 		return
 	}
+	// 后台任务会先完整缓存请求体，长度不符时已在缓存时失败
+	if t == nil {
+		if err = removeTruncatedUpload(putCtx, stdpath.Join(dir, name), declaredSize, body); err != nil {
+			if quarantine != nil {
+				discardQuarantineItem(quarantine)
+			}
+			discardStagedUpload(c.Request.Context(), staged)
+			common.ErrorResp(c, err, 400)
+			return
+		}
+	}
+	if quarantine != nil {
+		startModeration(quarantine, s, t, common.GetApiUrl(c))
+	}
+	if staged != nil && !verifyStagedUpload(c, staged, size, h) {
+		return
+	}
+	if len(mirrors) > 0 && !putMirrors(c, mirrors, s, mimetype, func() (io.ReadCloser, error) {
+		return os.Open(spool.Name())
+	}) {
+		return
+	}
+	if t != nil && callbackURL != "" {
+		startProgressCallback(callbackURL, t)
+	}
+	// 后台任务完成前文件尚未写入
+	if !held && t == nil {
+		applyUploadFileMode(c.Request.Context(), path)
+	}
+	// 元数据与字符集写入同一个sidecar，先同步写入元数据
+	if !held {
+		if err = storeUploadMetadata(c.Request.Context(), path, metadata); err != nil {
+			logrus.Warnf("store metadata of %s error: %+v", path, err)
+		}
+	}
+	if strings.HasPrefix(mimetype, "text/") {
+		go storeCharset(context.Background(), path, mimetype)
+	}
 
-	// 异步处理视频缩略图
-	if strings.HasPrefix(mimetype, "video/") {
-		// 使用独立上下文，避免HTTP请求结束后取消任务
-		go generateVideoThumbnail(context.Background(), path, user)
+	// 实况照片的两部分先配对，视频据此跳过缩略图
+	if !held {
+		pairLivePhoto(c.Request.Context(), path)
+	}
+	// 异步处理视频缩略图，隔离或暂存中的上传在审核通过或提交后生成
+	if !held && (strings.HasPrefix(mimetype, "video/") || strings.HasPrefix(mimetype, "image/") && setting.GetBool(conf.ImageThumbnails)) && !skipThumbnail(c) {
+		if !ffmpegAvailable() && setting.GetBool(conf.FFmpegMissingWarning) {
+			addUploadWarning(c, "ffmpeg is unavailable, no thumbnail will be generated")
+		}
+		scheduleThumbnail(path, user, thumbnailOverride)
 	}
 
 	// 返回结果
-	if t == nil {
-		common.SuccessResp(c)
-		return
+	if trailers != nil {
+		trailers.declare(c)
+	}
+	if durable {
+		bufferedUploadResp(c, path, exist == nil, s, t)
+	} else {
+		uploadSuccessResp(c, path, exist == nil, s, t)
+	}
+	if trailers != nil {
+		trailers.send(c)
 	}
-
-	common.SuccessResp(c, gin.H{
-		"task": getTaskInfo(t),
-	})
 }
 
-// 生成视频缩略图（WebP格式）
+// 生成视频缩略图（WebP格式，不支持时见getThumbnailFormat）
 func generateVideoThumbnail(ctx context.Context, filePath string, user *model.User) {
 
 	// 获取视频文件绝对路径
 	fileObj, err := fs.Get(ctx, filePath, &fs.GetArgs{NoLog: true})
 	if err != nil {
 		logrus.Printf("获取视频文件信息失败: %v", err)
+		failThumbnail(ctx, thumbnailStageGet, err)
 		return
 	}
 
-	videoAbsPath := fileObj.GetPath()
-	if videoAbsPath == "" {
-		logrus.Printf("视频文件绝对路径为空")
+	// 实况照片的视频使用照片的缩略图
+	if _, ok := livePhotoStill(ctx, filePath); ok {
+		logrus.Printf("实况照片的视频不单独生成缩略图: %s", filePath)
+		skipThumbnailLog(ctx)
 		return
 	}
 
-	// 解析目标路径
-	dir, name := stdpath.Split(filePath)
-	targetThumbDir := stdpath.Join(dir, ".thumbnails")
-	baseName := strings.TrimSuffix(name, stdpath.Ext(name))
-	targetThumbName := baseName + ".webp"
-	targetThumbPath := stdpath.Join(targetThumbDir, targetThumbName)
+	// 检查目标缩略图是否已存在，源文件修改过时删除旧缩略图
+	store := getThumbnailStore()
+	removeStaleThumbnail(ctx, store, filePath, fileObj)
+	if !thumbnailNeeded(ctx, store, filePath) {
+		return
+	}
+	// 已确认不含视频流且未修改的文件不再探测
+	if noVideoStreamRecorded(ctx, filePath, fileObj) {
+		logrus.Infof("文件不含可解码的视频流，跳过生成缩略图: %s", filePath)
+		skipThumbnailLog(ctx)
+		return
+	}
 
-	// 新增：检查目标缩略图是否已存在
-	exists, err := checkFileExists(ctx, targetThumbPath)
+	// 本地存储直接读取视频，远程存储只下载文件开头
+	source, err := openVideoSource(ctx, filePath, fileObj)
 	if err != nil {
-		logrus.Printf("检查缩略图存在性失败: %v", err)
+		logrus.Printf("获取视频文件失败: %v", err)
+		failThumbnail(ctx, thumbnailStageGet, err)
 		return
 	}
-	if exists {
-		logrus.Printf("缩略图已存在，跳过生成: %s", targetThumbPath)
+	defer source.Close()
+	videoAbsPath := source.Path
+
+	// 只下载了开头的远程视频无法确认，交由后续提取处理
+	if !source.partial && !confirmVideoStream(ctx, filePath, videoAbsPath, fileObj) {
+		skipThumbnailLog(ctx)
 		return
 	}
-	targetThumbName = baseName + ".webp"
-	targetThumbPath = stdpath.Join(targetThumbDir, targetThumbName)
 
-	// 创建本地临时文件（修改：使用.webp扩展名）
-	tempFile, err := os.CreateTemp(os.TempDir(), "video_thumb_*.webp")
+	// 记录章节、字幕和封面信息到元数据文件
+	meta := probeAndStoreVideoMeta(ctx, filePath, videoAbsPath)
+
+	// 创建本地临时文件，扩展名决定FFmpeg的输出格式
+	tempFile, err := os.CreateTemp(os.TempDir(), "video_thumb_*"+thumbnailOptionsFrom(ctx).format().Ext)
 	if err != nil {
 		logrus.Printf("创建本地临时文件失败: %v", err)
+		failThumbnail(ctx, thumbnailStageTemp, err)
 		return
 	}
 
@@ -230,82 +569,101 @@ func generateVideoThumbnail(ctx context.Context, filePath string, user *model.Us
 		}
 	}()
 
-	// 尝试生成WebP格式缩略图
-
-	// 先尝试提取封面
-	if err := extractVideoCover(ctx, videoAbsPath, tempFilePath); err != nil {
-		logrus.Printf("提取封面失败，尝试生成3%%处缩略图: %v", err)
+	// thumbnail_formats中的其他格式使用同一帧编码
+	variants, cleanupVariants := newThumbnailVariants(ctx, "video_thumb_*")
+	defer cleanupVariants()
 
-		// 尝试生成3%处画面
-		if err := extractVideoFrameAtPercentage(ctx, videoAbsPath, tempFilePath, 3.0); err != nil {
-			logrus.Printf("生成3%%处缩略图失败: %v", err)
+	// 有附加封面时使用主封面，否则按配置的位置顺序尝试生成缩略图
+	if !extractVideoCovers(ctx, store, filePath, videoAbsPath, meta, tempFilePath) {
+		err := extractVideoThumbnail(ctx, videoAbsPath, tempFilePath, variants)
+		if err != nil && source.partial {
+			// 需要的帧不在已下载的部分中，下载完整文件后重试
+			logrus.Printf("从部分视频生成缩略图失败: %v", err)
+			if err = source.complete(ctx); err == nil {
+				videoAbsPath = source.Path
+				if meta == nil {
+					probeAndStoreVideoMeta(ctx, filePath, videoAbsPath)
+				}
+				err = extractVideoThumbnail(ctx, videoAbsPath, tempFilePath, variants)
+			}
+		}
+		if err != nil {
+			logrus.Printf("生成视频缩略图失败: %v", err)
+			failThumbnail(ctx, thumbnailStageExtract, err)
 			return
 		}
 	}
 
-	// 验证WebP文件有效性
-	if err := validateWebPFile(tempFilePath); err != nil {
-		logrus.Printf("生成的WebP图片无效: %v", err)
+	if err := uploadThumbnail(ctx, store, filePath, tempFilePath); err != nil {
+		logrus.Printf("%v", err)
+		failThumbnail(ctx, thumbnailStageUpload, err)
 		return
 	}
+	storeThumbnailVariants(ctx, store, filePath, variants)
+	recordThumbnailSource(ctx, filePath, fileObj)
+	recordThumbnailHashes(ctx, filePath, tempFilePath)
+	generateFilmstrip(ctx, store, filePath, source, meta)
 
-	// 确保目标缩略图目录存在
-	if err := MakeDir(ctx, targetThumbDir, true); err != nil {
-		logrus.Printf("创建目标缩略图目录失败: %v", err)
-		return
+	logrus.Printf("缩略图生成并上传成功: 临时文件=%s, 目标路径=%s", tempFilePath, store.PathFor(filePath))
+}
+
+// 检查缩略图是否需要生成
+func thumbnailNeeded(ctx context.Context, store ThumbnailStore, filePath string) bool {
+	exists, err := store.Exists(ctx, filePath)
+	if err != nil {
+		logrus.Printf("检查缩略图存在性失败: %v", err)
+		failThumbnail(ctx, thumbnailStageCheck, err)
+		return false
+	}
+	if exists {
+		logrus.Printf("缩略图已存在，跳过生成: %s", store.PathFor(filePath))
+		skipThumbnailLog(ctx)
+		return false
+	}
+	return true
+}
+
+// 校验并保存本地临时缩略图
+func uploadThumbnail(ctx context.Context, store ThumbnailStore, filePath, tempFilePath string) error {
+	// 验证缩略图文件有效性
+	if err := validateThumbnailFile(tempFilePath, thumbnailOptionsFrom(ctx).format()); err != nil {
+		return fmt.Errorf("生成的缩略图无效: %w", err)
 	}
 
 	// 打开临时文件准备上传
 	tempFileReader, err := os.Open(tempFilePath)
 	if err != nil {
-		logrus.Printf("打开临时文件失败: %v", err)
-		return
+		return fmt.Errorf("打开临时文件失败: %w", err)
 	}
 
 	defer tempFileReader.Close()
 
 	// 获取临时文件大小
 	fileSize := int64(0)
-	if info, err := os.Stat(tempFilePath); err == nil {
+	if info, err := tempFileReader.Stat(); err == nil {
 		fileSize = info.Size()
 	}
 
-	// 构造上传流（修改：Mimetype改为image/webp）
-	uploadStream := &stream.FileStream{
-		Obj: &model.Object{
-			Name:     targetThumbName,
-			Size:     fileSize,
-			Modified: time.Now(),
-		},
-		Reader:   tempFileReader,
-		Mimetype: "image/webp",
-	}
-
-	// 上传到目标目录
-	if err := fs.PutDirectly(ctx, targetThumbDir, uploadStream, true); err != nil {
-		logrus.Printf("上传缩略图到目标路径失败: %v", err)
-		return
+	// 保存到缩略图存储
+	if err := store.Put(ctx, filePath, tempFileReader, fileSize); err != nil {
+		return fmt.Errorf("上传缩略图到目标路径失败: %w", err)
 	}
 
-	logrus.Printf("缩略图生成并上传成功: 临时文件=%s, 目标路径=%s", tempFilePath, targetThumbPath)
+	return nil
 }
 
-// 提取视频封面（WebP格式）
-func extractVideoCover(ctx context.Context, videoPath, outputPath string) error {
-	// 使用libwebp编码器，优化WebP参数
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+// 提取视频封面（原始分辨率，格式由输出文件扩展名决定），filters为空时不使用滤镜
+func extractVideoCover(ctx context.Context, videoPath, outputPath string, filters ...string) error {
+	args := []string{
 		"-i", videoPath,
 		"-map", "0:v:0", // 选择第一个视频流
 		"-vframes", "1", // 只输出一帧
-		"-c:v", "libwebp", // 使用WebP编码器
-		"-q:v", "80", // 质量参数（0-100，默认75）
-		"-lossless", "0", // 非无损压缩（节省空间）
-		"-compression_level", "6", // 压缩级别（0-9，默认6）
-		"-preset", "default", // 预设：平衡质量和速度
+	}
+	args = append(args, filters...)
+	args = append(args,
 		"-y", // 覆盖现有文件
 		outputPath)
-
-	output, err := cmd.CombinedOutput()
+	output, err := runFFmpeg(ctx, args...)
 	if err != nil {
 		logrus.Printf("FFmpeg封面提取输出: %s", string(output))
 		return err
@@ -314,8 +672,8 @@ func extractVideoCover(ctx context.Context, videoPath, outputPath string) error
 	return nil
 }
 
-// 提取视频指定百分比位置的帧（WebP格式）
-func extractVideoFrameAtPercentage(ctx context.Context, videoPath, outputPath string, percentage float64) error {
+// 提取视频指定百分比位置的帧（原始分辨率，格式由输出文件扩展名决定），filters为空时不使用滤镜
+func extractVideoFrameAtPercentage(ctx context.Context, videoPath, outputPath string, percentage float64, filters ...string) error {
 	// 获取视频时长
 	duration, err := getVideoDuration(ctx, videoPath)
 	if err != nil {
@@ -326,22 +684,17 @@ func extractVideoFrameAtPercentage(ctx context.Context, videoPath, outputPath st
 	seekTime := duration * (percentage / 100.0)
 	seekTimeStr := formatTime(seekTime)
 
-	// 使用libwebp编码器
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	args := []string{
 		"-ss", seekTimeStr, // 跳转到指定时间点
 		"-i", videoPath,
 		"-vframes", "1", // 只输出一帧
-		"-vf", "scale=320:-1", // 缩放至320像素宽
-		"-c:v", "libwebp", // 使用WebP编码器
-		"-q:v", "80", // 质量参数
-		"-lossless", "0", // 非无损压缩
-		"-compression_level", "6", // 压缩级别
-		"-preset", "default", // 预设
+	}
+	args = append(args, filters...)
+	args = append(args,
 		"-update", "1", // 输出单个文件
 		"-y", // 覆盖现有文件
 		outputPath)
-
-	output, err := cmd.CombinedOutput()
+	output, err := runFFmpeg(ctx, args...)
 	if err != nil {
 		logrus.Printf("FFmpeg帧提取输出: %s", string(output))
 		return err
@@ -350,15 +703,33 @@ func extractVideoFrameAtPercentage(ctx context.Context, videoPath, outputPath st
 	return nil
 }
 
+// 将提取的帧编码为缩略图（优先WebP格式）
+func encodeThumbnail(ctx context.Context, framePath, outputPath string) error {
+	options := thumbnailOptionsFrom(ctx)
+	args := []string{
+		"-i", framePath,
+		"-vf", fmt.Sprintf("scale=%d:-1", options.width()), // 缩放至指定宽度，默认320像素
+	}
+	args = append(args, options.encoderArgs()...)
+	args = append(args,
+		"-y", // 覆盖现有文件
+		outputPath)
+	output, err := runFFmpeg(ctx, args...)
+	if err != nil {
+		logrus.Printf("FFmpeg缩略图编码输出: %s", string(output))
+		return err
+	}
+
+	return nil
+}
+
 // 获取视频时长
 func getVideoDuration(ctx context.Context, filePath string) (float64, error) {
-	cmd := exec.CommandContext(ctx, "ffprobe",
+	output, err := runFFprobe(ctx,
 		"-v", "error",
 		"-show_entries", "format=duration",
 		"-of", "default=noprint_wrappers=1:nokey=1",
 		filePath)
-
-	output, err := cmd.Output()
 	if err != nil {
 		return 0, err
 	}
@@ -397,24 +768,35 @@ func validateWebPFile(path string) error {
 		return fmt.Errorf("文件为空")
 	}
 
-	// 尝试解码WebP文件
-	_, _, err = image.Decode(file)
-	if err != nil {
-		return fmt.Errorf("WebP解码失败: %w", err)
+	// 校验RIFF/WEBP文件头，不依赖已注册的WebP解码器
+	if err = utils.ReadWebPHeader(file, stat.Size()); err != nil {
+		return fmt.Errorf("WebP文件头无效: %w", err)
 	}
 
 	return nil
 }
 
-// 创建目录（假设已有的函数）
+// 创建目录，目录已存在时视为成功，只记录真正的失败
 func MakeDir(ctx context.Context, path string, lazyCache ...bool) error {
 	err := fs.MakeDir(ctx, path)
-	if err != nil {
-		logrus.Errorf("failed make dir %s: %+v", path, err)
+	if err == nil || dirExists(ctx, path, err) {
+		return nil
 	}
+	logrus.Errorf("failed make dir %s: %+v", path, err)
 	return err
 }
 
+// 判断创建目录失败是否只是因为目录已存在
+func dirExists(ctx context.Context, path string, err error) bool {
+	obj, getErr := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+	if getErr == nil {
+		// 同名文件占用路径时仍是失败
+		return obj.IsDir()
+	}
+	// 缓存中可能还没有刚被其他请求创建的目录
+	return errs.IsObjectAlreadyExists(err)
+}
+
 func FsForm(c *gin.Context) {
 	defer func() {
 		if n, _ := io.ReadFull(c.Request.Body, []byte{0}); n == 1 {
@@ -428,26 +810,72 @@ func FsForm(c *gin.Context) {
 		common.ErrorResp(c, err, 400)
 		return
 	}
-	asTask := c.GetHeader("As-Task") == "true"
-	overwrite := c.GetHeader("Overwrite") != "false"
-	user := c.Request.Context().Value(conf.UserKey).(*model.User)
-	path, err = user.JoinPath(path)
+	mode, err := resolveUploadMode(c.Request.Header)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	overwrite, asTask, durable := mode.overwrite, mode.asTask, mode.durable
+	callbackURL, err := progressCallbackURL(c)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	thumbnailOverride, err := thumbnailOptionsFromHeaders(c)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user, ok := uploadUser(c)
+	if !ok {
+		return
+	}
+	path, err = user.JoinUploadPath(normalizeUploadPath(path))
+	if err != nil {
+		common.PathErrorResp(c, err, 403)
+		return
+	}
+	path, err = applyAutoRoute(c, user, path, "")
 	if err != nil {
 		common.ErrorResp(c, err, 403)
 		return
 	}
-	if !overwrite {
-		if res, _ := fs.Get(c.Request.Context(), path, &fs.GetArgs{NoLog: true}); res != nil {
-			common.ErrorStrResp(c, "file exists", 403)
+	path, ok = applyDateOrganize(c, user, path, true)
+	if !ok {
+		return
+	}
+	// 重名时自动改名，不覆盖已有文件，最终路径在File-Path响应头中返回
+	if mode.rename {
+		var release func()
+		path, release, err = renameOnConflict(c.Request.Context(), path)
+		if err != nil {
+			common.ErrorResp(c, err, 500)
 			return
 		}
+		defer release()
+		c.Header("File-Path", url.PathEscape(path))
+	}
+	putCtx, ok := uploadPutContext(c, path)
+	if !ok {
+		return
+	}
+	// 目标已是目录时无论是否覆盖都拒绝，否则驱动会返回难以理解的错误
+	exist := probeExistence(c.Request.Context(), path)
+	if exist != nil && exist.IsDir() {
+		common.ErrorStrResp(c, "destination is a directory", 409)
+		return
+	}
+	// 去重的上传先与已有文件比较内容
+	if !overwrite && !mode.dedupe && exist != nil {
+		common.ErrorStrResp(c, "file exists", 403)
+		return
 	}
 	storage, err := fs.GetStorage(path, &fs.GetStoragesArgs{})
 	if err != nil {
 		common.ErrorResp(c, err, 400)
 		return
 	}
-	if storage.Config().NoUpload {
+	if !driver.GetCapabilities(storage).Upload {
 		common.ErrorStrResp(c, "Current storage doesn't support upload", 405)
 		return
 	}
@@ -462,55 +890,197 @@ func FsForm(c *gin.Context) {
 		return
 	}
 	defer f.Close()
+	if file.Size == 0 && rejectEmptyUploads() {
+		common.ErrorStrResp(c, errEmptyUpload, 400)
+		return
+	}
 	dir, name := stdpath.Split(path)
 	// Check if system file should be ignored
 	if shouldIgnoreSystemFile(name) {
 		common.ErrorStrResp(c, errs.IgnoredSystemFile.Error(), 403)
 		return
 	}
-	h := make(map[*utils.HashType]string)
-	if md5 := c.GetHeader("X-File-Md5"); md5 != "" {
-		h[utils.MD5] = md5
+	h, err := uploadHashes(c)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if uploadSkipped(mode, exist, file.Size, h, getLastModified(c)) {
+		skippedUploadResp(c, path, exist)
+		return
 	}
-	if sha1 := c.GetHeader("X-File-Sha1"); sha1 != "" {
-		h[utils.SHA1] = sha1
+	if !overwrite && exist != nil {
+		common.ErrorStrResp(c, "file exists", 403)
+		return
 	}
-	if sha256 := c.GetHeader("X-File-Sha256"); sha256 != "" {
-		h[utils.SHA256] = sha256
+	// 按请求在写入前校验哈希，记录计算出的哈希，后台任务在任务中校验
+	if !asTask {
+		if h, err = verifyUploadFile(c, f, h); err != nil {
+			common.ErrorResp(c, err, uploadErrCode(err, 500))
+			return
+		}
+	}
+	metadata, ok := parseUploadMetadata(c, path, overwrite, true)
+	if !ok {
+		return
+	}
+	preserved, metadata, ok := contentOnlyUpload(c, mode, path, exist, metadata)
+	if !ok {
+		return
+	}
+	mirrors, ok := uploadMirrorPaths(c, user, overwrite)
+	if !ok {
+		return
+	}
+	poster, ok := uploadPoster(c)
+	if !ok {
+		return
 	}
 	mimetype := file.Header.Get("Content-Type")
 	if len(mimetype) == 0 {
 		mimetype = utils.GetMimeType(name)
 	}
+	var reader io.Reader = f
+	size := file.Size
+	// 去除JPEG的元数据，镜像路径也使用去除后的内容
+	var stripped []byte
+	if stripExifRequested(c, mimetype) {
+		stripped, h, err = stripUploadExif(f)
+		if err != nil {
+			common.ErrorResp(c, err, 400)
+			return
+		}
+		reader, size = bytes.NewReader(stripped), int64(len(stripped))
+	}
+	var durableFile *os.File
+	durableQueued := false
+	if durable {
+		durableFile, size, err = bufferDurableUpload(reader)
+		if err != nil {
+			common.ErrorResp(c, err, 500)
+			return
+		}
+		defer func() {
+			if !durableQueued {
+				_ = removeDurableUpload(durableFile)()
+			}
+		}()
+		reader = durableFile
+	}
+	// 暂存的上传校验后等待客户端提交
+	staged, ok := stageUpload(c, mode, path, mimetype, user, mirrors, metadata)
+	if !ok {
+		return
+	}
+	if staged != nil {
+		dir = stdpath.Dir(staged.StagingPath)
+	}
+	quarantine, ok := quarantineUpload(c, path, mimetype, overwrite, user, mirrors)
+	if !ok {
+		return
+	}
+	if quarantine != nil {
+		dir = stdpath.Dir(quarantine.QuarantinePath)
+	}
+	held := quarantine != nil || staged != nil
 	s := &stream.FileStream{
 		Obj: &model.Object{
 			Name:     name,
-			Size:     file.Size,
+			Size:     size,
 			Modified: getLastModified(c),
+			Ctime:    preserved.createTime(),
 			HashInfo: utils.NewHashInfoByMap(h),
 		},
-		Reader:       f,
-		Mimetype:     mimetype,
-		WebPutAsTask: asTask,
+		Reader:          reader,
+		Mimetype:        mimetype,
+		WebPutAsTask:    asTask,
+		BufferThreshold: setting.GetInt(conf.UploadTempBufferThreshold, 0),
+	}
+	if durableFile != nil {
+		s.Add(removeDurableUpload(durableFile))
+	}
+	if storage, err := fs.GetPutStorage(putCtx, dir); err == nil && !checkFreeSpace(c, storage, s.GetSize()) {
+		if quarantine != nil {
+			discardQuarantineItem(quarantine)
+		}
+		discardStagedUpload(c.Request.Context(), staged)
+		return
+	}
+	release, ok := acquireUploadSlot(c, path)
+	if !ok {
+		return
+	}
+	defer release()
+	// 隔离或暂存中的上传在审核通过或提交时保留旧版本
+	if overwrite && !held {
+		if _, err = keepPreviousVersion(c.Request.Context(), path); err != nil {
+			common.ErrorResp(c, err, 500)
+			return
+		}
 	}
 	var t task.TaskExtensionInfo
 	if asTask {
-		s.Reader = struct {
-			io.Reader
-		}{f}
-		t, err = fs.PutAsTask(c.Request.Context(), dir, s)
+		// 已落盘的缓冲文件直接作为任务的缓存
+		if durableFile == nil {
+			s.Reader = struct {
+				io.Reader
+			}{reader}
+		}
+		t, err = fs.PutAsTask(verifyUploadTask(c, putCtx, h), dir, s)
+		durableQueued = t != nil
 	} else {
-		err = fs.PutDirectly(c.Request.Context(), dir, s)
+		err = fs.PutDirectly(putCtx, dir, s)
 	}
 	if err != nil {
+		if quarantine != nil {
+			discardQuarantineItem(quarantine)
+		}
+		discardStagedUpload(c.Request.Context(), staged)
 		common.ErrorResp(c, err, 500)
 		return
 	}
-	if t == nil {
-		common.SuccessResp(c)
+	if quarantine != nil {
+		startModeration(quarantine, s, t, common.GetApiUrl(c))
+	}
+	if staged != nil && !verifyStagedUpload(c, staged, size, h) {
 		return
 	}
-	common.SuccessResp(c, gin.H{
-		"task": getTaskInfo(t),
-	})
+	if len(mirrors) > 0 && !putMirrors(c, mirrors, s, mimetype, func() (io.ReadCloser, error) {
+		if stripped != nil {
+			return io.NopCloser(bytes.NewReader(stripped)), nil
+		}
+		return file.Open()
+	}) {
+		return
+	}
+	if t != nil && callbackURL != "" {
+		startProgressCallback(callbackURL, t)
+	}
+	// 后台任务完成前文件尚未写入
+	if !held && t == nil {
+		applyUploadFileMode(c.Request.Context(), path)
+	}
+	// 元数据与字符集写入同一个sidecar，先同步写入元数据
+	if !held {
+		if err = storeUploadMetadata(c.Request.Context(), path, metadata); err != nil {
+			logrus.Warnf("store metadata of %s error: %+v", path, err)
+		}
+		pairLivePhoto(c.Request.Context(), path)
+	}
+	if strings.HasPrefix(mimetype, "text/") {
+		go storeCharset(context.Background(), path, mimetype)
+	}
+	// 客户端提供的封面直接作为缩略图，否则与FsStream一样生成；隔离或暂存中的上传在审核通过或提交后生成
+	if !held && !skipThumbnail(c) {
+		if poster != nil {
+			go storePosterThumbnail(withThumbnailOverride(context.Background(), thumbnailOverride), path, poster)
+		} else if strings.HasPrefix(mimetype, "video/") || strings.HasPrefix(mimetype, "image/") && setting.GetBool(conf.ImageThumbnails) {
+			scheduleThumbnail(path, user, thumbnailOverride)
+		}
+	}
+	if durable {
+		bufferedUploadResp(c, path, exist == nil, s, t)
+	} else {
+		uploadSuccessResp(c, path, exist == nil, s, t)
+	}
 }
@@ -0,0 +1,395 @@
+package handles
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// archiveSessionIdle is how long an unfinished archive upload is kept without requests
+const archiveSessionIdle = time.Hour
+
+// archiveSession builds a tar or zip in a temp file from the files uploaded into it,
+// entries are streamed, so memory use doesn't depend on their size
+type archiveSession struct {
+	sync.Mutex
+	id       string
+	format   string
+	username string
+	file     *os.File
+	tw       *tar.Writer
+	zw       *zip.Writer
+	names    map[string]struct{}
+	lastUsed time.Time
+	// closed is set once the archive is completed by a finalize, no entries are added then
+	closed  bool
+	removed bool
+}
+
+var (
+	archiveSessionsLock sync.Mutex
+	archiveSessions     = make(map[string]*archiveSession)
+)
+
+func archiveSessionDir() string {
+	return filepath.Join(conf.Conf.TempDir, "archive_upload")
+}
+
+func (s *archiveSession) size() int64 {
+	info, err := s.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// close completes the archive, it's kept on disk until the session is removed
+func (s *archiveSession) close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	var err error
+	if s.tw != nil {
+		err = s.tw.Close()
+	} else {
+		err = s.zw.Close()
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *archiveSession) remove() {
+	if s.removed {
+		return
+	}
+	s.removed = true
+	_ = s.close()
+	_ = os.Remove(s.file.Name())
+}
+
+// add streams an entry into the archive, size is -1 when unknown.
+// A tar header needs the size up front, so such entries are spooled to a temp file first.
+func (s *archiveSession) add(name string, modified time.Time, r io.Reader, size int64) error {
+	if s.zw != nil {
+		w, err := s.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		_, err = utils.CopyWithBuffer(w, r)
+		return err
+	}
+	if size < 0 {
+		spool, err := os.CreateTemp(conf.Conf.TempDir, "archive_entry_*")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = spool.Close()
+			_ = os.Remove(spool.Name())
+		}()
+		if size, err = utils.CopyWithBuffer(spool, r); err != nil {
+			return err
+		}
+		if _, err = spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = spool
+	}
+	if err := s.tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: modified, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	_, err := io.CopyN(s.tw, r, size)
+	return err
+}
+
+// archiveEntryName turns a client supplied name into a relative slash separated path,
+// names escaping the archive root are rejected
+func archiveEntryName(raw string) (string, error) {
+	name, err := url.PathUnescape(raw)
+	if err != nil {
+		return "", err
+	}
+	name = strings.TrimPrefix(stdpath.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+	if name == "" || name == "." {
+		return "", errors.New("entry name is required")
+	}
+	return name, nil
+}
+
+func cleanIdleArchiveSessions() {
+	archiveSessionsLock.Lock()
+	defer archiveSessionsLock.Unlock()
+	for id, s := range archiveSessions {
+		if !s.TryLock() {
+			continue
+		}
+		if time.Since(s.lastUsed) > archiveSessionIdle {
+			s.remove()
+			delete(archiveSessions, id)
+		}
+		s.Unlock()
+	}
+}
+
+// getArchiveSession returns the locked session of the id param owned by the user
+func getArchiveSession(c *gin.Context) (*archiveSession, bool) {
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	archiveSessionsLock.Lock()
+	s, ok := archiveSessions[c.Param("id")]
+	archiveSessionsLock.Unlock()
+	if !ok || s.username != user.Username {
+		common.ErrorStrResp(c, "archive session not found", 404)
+		return nil, false
+	}
+	s.Lock()
+	if s.removed {
+		// finalized or aborted while waiting for the lock
+		s.Unlock()
+		common.ErrorStrResp(c, "archive session not found", 404)
+		return nil, false
+	}
+	s.lastUsed = time.Now()
+	return s, true
+}
+
+func takeArchiveSession(id string) {
+	archiveSessionsLock.Lock()
+	delete(archiveSessions, id)
+	archiveSessionsLock.Unlock()
+}
+
+type ArchiveUploadOpenReq struct {
+	Format string `json:"format" form:"format"`
+	// Path is where the archive will be put, it's checked for users who may only write where a meta allows
+	Path string `json:"path" form:"path"`
+}
+
+// FsArchiveUploadOpen starts an archive upload session, format is tar or zip
+func FsArchiveUploadOpen(c *gin.Context) {
+	var req ArchiveUploadOpenReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if req.Format != "tar" && req.Format != "zip" {
+		common.ErrorStrResp(c, "format must be tar or zip", 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	// 会话数据写入临时目录，没有写入权限的用户不能创建
+	if !user.CanWrite() {
+		path, err := user.JoinPath(req.Path)
+		if err != nil {
+			common.PathErrorResp(c, err, 403)
+			return
+		}
+		if err = checkUploadPermission(c, user, path); err != nil {
+			common.ErrorResp(c, err, 403)
+			return
+		}
+	}
+	cleanIdleArchiveSessions()
+	if err := os.MkdirAll(archiveSessionDir(), 0o700); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	s := &archiveSession{
+		id:       uuid.NewString(),
+		format:   req.Format,
+		username: user.Username,
+		names:    make(map[string]struct{}),
+		lastUsed: time.Now(),
+	}
+	f, err := os.OpenFile(filepath.Join(archiveSessionDir(), s.id+"."+s.format), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	s.file = f
+	if s.format == "tar" {
+		s.tw = tar.NewWriter(f)
+	} else {
+		s.zw = zip.NewWriter(f)
+	}
+	archiveSessionsLock.Lock()
+	archiveSessions[s.id] = s
+	archiveSessionsLock.Unlock()
+	common.SuccessResp(c, gin.H{"id": s.id})
+}
+
+// FsArchiveUploadAppend streams the body into the archive as the entry named by File-Path
+func FsArchiveUploadAppend(c *gin.Context) {
+	defer func() {
+		_, _ = utils.CopyWithBuffer(io.Discard, c.Request.Body)
+		_ = c.Request.Body.Close()
+	}()
+	name, err := archiveEntryName(c.GetHeader("File-Path"))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	s, ok := getArchiveSession(c)
+	if !ok {
+		return
+	}
+	defer s.Unlock()
+	if s.closed {
+		common.ErrorStrResp(c, "archive already finalized", 409)
+		return
+	}
+	if _, exists := s.names[name]; exists {
+		common.ErrorStrResp(c, fmt.Sprintf("entry %s already in the archive", name), 409)
+		return
+	}
+	size := c.Request.ContentLength
+	if max := tusMaxSize(); max > 0 && size >= 0 && s.size()+size > max {
+		common.ErrorStrResp(c, "archive exceeds max_upload_size", 413)
+		return
+	}
+	var body io.Reader = c.Request.Body
+	if max := tusMaxSize(); max > 0 {
+		// also bounds bodies of unknown size, the archive is broken then and discarded
+		body = io.LimitReader(body, max-s.size()+1)
+	}
+	if err = s.add(name, getLastModified(c), body, size); err != nil {
+		log.Warnf("archive upload %s failed at %s: %+v", s.id, name, err)
+		takeArchiveSession(s.id)
+		s.remove()
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if max := tusMaxSize(); max > 0 && s.size() > max {
+		takeArchiveSession(s.id)
+		s.remove()
+		common.ErrorStrResp(c, "archive exceeds max_upload_size", 413)
+		return
+	}
+	s.names[name] = struct{}{}
+	common.SuccessResp(c, gin.H{"entries": len(s.names)})
+}
+
+type ArchiveUploadFinalizeReq struct {
+	Path      string `json:"path" form:"path"`
+	Overwrite string `json:"overwrite" form:"overwrite"`
+}
+
+// FsArchiveUploadFinalize completes the archive and puts it to path
+func FsArchiveUploadFinalize(c *gin.Context) {
+	var req ArchiveUploadFinalizeReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	path, err := user.JoinPath(req.Path)
+	if err != nil {
+		common.PathErrorResp(c, err, 403)
+		return
+	}
+	if err = checkUploadPermission(c, user, path); err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	dir, name := stdpath.Split(path)
+	if shouldIgnoreSystemFile(name) {
+		common.ErrorStrResp(c, errs.IgnoredSystemFile.Error(), 403)
+		return
+	}
+	exist := probeExistence(c.Request.Context(), path)
+	if exist != nil && !resolveOverwrite(req.Overwrite) {
+		common.ErrorStrResp(c, "file exists", 403)
+		return
+	}
+	s, ok := getArchiveSession(c)
+	if !ok {
+		return
+	}
+	defer s.Unlock()
+	// 会话在写入存储成功后才结束，失败时可以再次完成
+	if err = s.close(); err != nil {
+		takeArchiveSession(s.id)
+		s.remove()
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	f, err := os.Open(s.file.Name())
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	mimetype := "application/x-tar"
+	if s.format == "zip" {
+		mimetype = "application/zip"
+	}
+	fileStream := &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     info.Size(),
+			Modified: time.Now(),
+		},
+		Reader:   f,
+		Mimetype: mimetype,
+	}
+	quarantine, err := holdUpload(c, path, mimetype, resolveOverwrite(req.Overwrite), user.Username)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if quarantine != nil {
+		dir = stdpath.Dir(quarantine.QuarantinePath)
+	}
+	if err = fs.PutDirectly(c.Request.Context(), dir, fileStream); err != nil {
+		if quarantine != nil {
+			discardQuarantineItem(quarantine)
+		}
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	takeArchiveSession(s.id)
+	s.remove()
+	if quarantine != nil {
+		startModeration(quarantine, fileStream, nil, common.GetApiUrl(c))
+	}
+	uploadSuccessResp(c, path, exist == nil, fileStream, nil)
+}
+
+// FsArchiveUploadAbort discards the archive upload session
+func FsArchiveUploadAbort(c *gin.Context) {
+	s, ok := getArchiveSession(c)
+	if !ok {
+		return
+	}
+	takeArchiveSession(s.id)
+	s.remove()
+	s.Unlock()
+	common.SuccessResp(c)
+}
@@ -0,0 +1,34 @@
+package handles
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFsArchiveUploadOpenRequiresWrite(t *testing.T) {
+	tempDir := conf.Conf.TempDir
+	conf.Conf.TempDir = t.TempDir()
+	t.Cleanup(func() { conf.Conf.TempDir = tempDir })
+	open := func(user *model.User) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		req := httptest.NewRequest(http.MethodPost, "/api/fs/archive/upload", strings.NewReader(`{"format":"zip","path":"/a.zip"}`))
+		req.Header.Set("Content-Type", "application/json")
+		c.Request = req.WithContext(context.WithValue(req.Context(), conf.UserKey, user))
+		FsArchiveUploadOpen(c)
+		return respCode(t, w)
+	}
+	if code := open(&model.User{Username: "reader", BasePath: "/", Role: model.GENERAL}); code != 403 {
+		t.Errorf("got code %d, want 403 for a user who can't write", code)
+	}
+	if code := open(&model.User{Username: "writer", BasePath: "/", Role: model.GENERAL, Permission: 1 << 3}); code != 200 {
+		t.Errorf("got code %d, want 200", code)
+	}
+}
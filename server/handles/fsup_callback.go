@@ -0,0 +1,134 @@
+package handles
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/net"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/task"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/tache"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// progressCallbackInterval is the minimum time between two callbacks of a task
+	progressCallbackInterval = time.Second
+	// progressCallbackStep is the progress in percent a task must advance before the next callback,
+	// state changes are always reported
+	progressCallbackStep    = 5
+	progressCallbackTimeout = 10 * time.Second
+)
+
+var progressCallbackClient = net.NewHttpClient()
+
+// ProgressCallback is POSTed to the Progress-Callback-Url of an As-Task upload
+type ProgressCallback struct {
+	TaskID  string      `json:"task_id"`
+	Bytes   int64       `json:"bytes"`
+	Percent float64     `json:"percent"`
+	State   tache.State `json:"state"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// progressCallbackURL returns the validated Progress-Callback-Url header, empty when absent
+func progressCallbackURL(c *gin.Context) (string, error) {
+	raw := c.GetHeader("Progress-Callback-Url")
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid Progress-Callback-Url: %s", raw)
+	}
+	return u.String(), nil
+}
+
+// signCallback returns the hex HMAC-SHA256 of "timestamp.body" keyed by secret
+func signCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newSignedCallback returns a POST of the JSON payload signed with the secret setting
+func newSignedCallback(callbackURL string, payload any, secretKey string) (*http.Request, error) {
+	body, err := utils.Json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Callback-Timestamp", timestamp)
+	req.Header.Set("X-Callback-Signature", "sha256="+signCallback(setting.GetStr(secretKey), timestamp, body))
+	return req, nil
+}
+
+func postProgressCallback(callbackURL string, payload ProgressCallback) error {
+	req, err := newSignedCallback(callbackURL, payload, conf.ProgressCallbackSecret)
+	if err != nil {
+		return err
+	}
+	client := *progressCallbackClient
+	client.Timeout = progressCallbackTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("callback responded %s", resp.Status)
+	}
+	return nil
+}
+
+func taskFinished(state tache.State) bool {
+	return state == tache.StateSucceeded || state == tache.StateFailed || state == tache.StateCanceled
+}
+
+// startProgressCallback reports the progress of the upload task to callbackURL until it finishes,
+// at most once per progressCallbackInterval and only on a state change or a progressCallbackStep advance
+func startProgressCallback(callbackURL string, t task.TaskExtensionInfo) {
+	go func() {
+		ticker := time.NewTicker(progressCallbackInterval)
+		defer ticker.Stop()
+		lastPercent := -float64(progressCallbackStep)
+		lastState := tache.State(-1)
+		for range ticker.C {
+			info := getTaskInfo(t)
+			finished := taskFinished(info.State)
+			if !finished && info.State == lastState && info.Progress-lastPercent < progressCallbackStep {
+				continue
+			}
+			payload := ProgressCallback{
+				TaskID:  info.ID,
+				Bytes:   int64(float64(info.TotalBytes) * info.Progress / 100),
+				Percent: info.Progress,
+				State:   info.State,
+				Error:   info.Error,
+			}
+			if err := postProgressCallback(callbackURL, payload); err != nil {
+				log.Warnf("progress callback of task %s error: %+v", info.ID, err)
+			}
+			lastPercent, lastState = info.Progress, info.State
+			if finished {
+				return
+			}
+		}
+	}()
+}
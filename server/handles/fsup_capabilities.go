@@ -0,0 +1,55 @@
+package handles
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+type UploadHeaderDesc struct {
+	Name        string   `json:"name"`
+	Values      []string `json:"values,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	Description string   `json:"description"`
+}
+
+type UploadCapabilitiesResp struct {
+	Headers []UploadHeaderDesc `json:"headers"`
+}
+
+// uploadHeaders describes the request headers honored by FsStream and FsForm
+var uploadHeaders = []UploadHeaderDesc{
+	{Name: "File-Path", Description: "url-encoded destination path of the uploaded file"},
+	{Name: "As-Task", Values: []string{"true", "false"}, Default: "false", Description: "upload in background as a task"},
+	{Name: "Durability", Values: []string{"immediate", "buffered"}, Default: "immediate", Description: "buffered returns 202 with a task once the body is synced to a temp file, the task puts it into the storage with retry and keeps it as a dead letter on failure. Implies As-Task, so As-Task false is refused"},
+	{Name: "Overwrite", Values: []string{"true", "false", "rename", "content-only", "if-newer", "if-changed"}, Default: "true", Description: "overwrite the destination if it already exists, when omitted the default_overwrite setting applies, other values are refused. rename uploads to a free name by conflict_rename_template instead, returned url-encoded in the File-Path response header, it can't be used with As-Task. content-only overwrites the bytes but keeps the creation time and the metadata of the existing file, so X-Metadata is refused with it. if-newer overwrites only a file older than Last-Modified, if-changed only one differing in size or in an X-File-* hash the storage reports, or without such a hash in modification time. A skipped upload leaves the file as is and returns it with the X-Upload-Skipped: true response header"},
+	{Name: "Dedupe", Values: []string{"true", "false"}, Default: "false", Description: "skip the upload like Overwrite if-changed when the destination has the same content, Overwrite then applies to a different one. Refused with Overwrite false and Staged"},
+	{Name: "Last-Modified", Description: "modification time of the file in unix milliseconds"},
+	{Name: "X-File-Size", Description: "size of the file when Content-Length is absent"},
+	{Name: "X-File-Md5", Description: "md5 of the file, 32 hex chars"},
+	{Name: "X-File-Sha1", Description: "sha1 of the file, 40 hex chars"},
+	{Name: "X-File-Sha256", Description: "sha256 of the file, 64 hex chars"},
+	{Name: "Verify-Hash", Values: []string{"true", "false"}, Description: "check the content against the X-File-* hashes sent, a mismatch is refused with 400 naming the hash, nothing is left at File-Path and an overwritten file is restored from its kept version. With As-Task the task fails instead. When omitted the verify_upload_hash setting applies"},
+	{Name: "Password", Description: "password of the destination directory if required by meta"},
+	{Name: "Skip-Thumbnail", Values: []string{"true", "false"}, Default: "false", Description: "don't generate a thumbnail for this upload. Form uploads may instead send a poster field, an image of at most 5MiB and 8192x8192 encoded as the thumbnail in place of an extracted frame"},
+	{Name: "Thumbnail-Width", Default: "320", Description: "width of the thumbnail in pixels, 16 to 4096, overrides the .thumbnail.json of the directories"},
+	{Name: "Thumbnail-Frames", Description: "video frame positions tried in order, same syntax as the thumbnail_frames setting"},
+	{Name: "Thumbnail-Format", Values: []string{"webp", "jpeg", "png"}, Description: "encoding of the thumbnail, falls back to the detected format when ffmpeg can't encode it"},
+	{Name: "Thumbnail-Preset", Values: []string{"default", "photo", "picture", "drawing", "icon", "text"}, Default: "default", Description: "libwebp -preset of a webp thumbnail: photo for outdoor photographs, picture for indoor or portraits, drawing for high contrast details, icon for small colorful images, text for text-like content. Unknown values are ignored"},
+	{Name: "Thumbnail-Filmstrip", Values: []string{"true", "false"}, Description: "generate the filmstrip sprite of a video with its thumbnail, served by /api/fs/thumbnail/filmstrip, overrides the .thumbnail.json of the directories. Dropped from signed uploads"},
+	{Name: "Accept-Version", Values: []string{"1", "2"}, Default: "1", Description: "2 returns the uniform response {created, path, size, modified, hashes, task}"},
+	{Name: "Auto-Route", Values: []string{"true", "false"}, Default: "false", Description: "move the upload into the directory configured for its MIME type in upload_content_type_routes"},
+	{Name: "Date-Organize", Values: []string{"true", "false"}, Default: "false", Description: "file images and videos under date_organize_template by their capture date (EXIF, creation_time, then Last-Modified)"},
+	{Name: "TE", Values: []string{"trailers"}, Description: "receive the md5, sha1 and sha256 computed while streaming as the trailers X-Computed-Md5, X-Computed-Sha1 and X-Computed-Sha256 of /api/fs/put"},
+	{Name: "Strip-Exif", Values: []string{"true", "false"}, Description: "remove the EXIF, XMP, IPTC and comments of a JPEG upload keeping its orientation, the hashes sent are replaced by those of the stored bytes. When omitted the strip_exif_on_upload setting applies"},
+	{Name: "X-Metadata", Description: "JSON object of at most 64KiB stored with the file and returned as metadata by /api/fs/get, form uploads may send it as the metadata field. Without Overwrite, existing metadata of the path isn't replaced"},
+	{Name: "Target-Storage", Description: "id of the storage the file is put into when File-Path is served by several balanced storages, 400 when it doesn't serve the path"},
+	{Name: "Mirror-Paths", Description: "comma separated url-encoded paths the file is also put into, resolved like File-Path, results are returned as mirrors [{path, error}]. When mirror_uploads_all_must_succeed fails the upload, the file and the mirrors put are kept. Refused with As-Task or Durability buffered"},
+	{Name: "Staged", Values: []string{"true", "false"}, Default: "false", Description: "put the upload in a hidden staging path, verified against the size and the X-File-* hashes, and only move it to File-Path when /api/fs/staged/commit is called with the id of the X-Upload-Staged-Id response header. Uncommitted uploads expire after upload_staging_ttl. Refused with As-Task, Durability buffered, Overwrite rename, Mirror-Paths and uploads requiring moderation"},
+	{Name: "Progress-Callback-Url", Description: "with As-Task, an http(s) url receiving signed POSTs of the task progress {task_id, bytes, percent, state}"},
+}
+
+func FsUploadCapabilities(c *gin.Context) {
+	common.SuccessResp(c, UploadCapabilitiesResp{
+		Headers: uploadHeaders,
+	})
+}
@@ -0,0 +1,101 @@
+package handles
+
+import (
+	"context"
+	"mime"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// charsetRegexp matches the names registered for charsets, anything else isn't worth echoing in a header
+var charsetRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:+-]{0,39}$`)
+
+// contentCharset returns the lowercased charset parameter of a Content-Type, empty when absent or malformed
+func contentCharset(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	charset := strings.ToLower(params["charset"])
+	if !charsetRegexp.MatchString(charset) {
+		return ""
+	}
+	return charset
+}
+
+// storeCharset records the charset declared by the upload of filePath in its sidecar,
+// so downloads can serve it again. Without a declared charset nothing is stored or guessed.
+func storeCharset(ctx context.Context, filePath, contentType string) {
+	charset := contentCharset(contentType)
+	if charset == "" {
+		return
+	}
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil {
+		sidecar = &MediaSidecar{}
+	}
+	if sidecar.Charset == charset {
+		return
+	}
+	sidecar.Charset = charset
+	if err = writeMediaSidecar(ctx, filePath, sidecar); err != nil {
+		log.Warnf("store charset of %s error: %+v", filePath, err)
+	}
+}
+
+// storedCharset returns the charset recorded for a text file, empty when none was declared
+func storedCharset(ctx context.Context, filePath string) string {
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil {
+		return ""
+	}
+	return sidecar.Charset
+}
+
+// charsetResponseWriter sets the charset parameter of the Content-Type before the header is written
+type charsetResponseWriter struct {
+	gin.ResponseWriter
+	charset string
+}
+
+func (w *charsetResponseWriter) setCharset() {
+	if w.Written() {
+		return
+	}
+	mediatype, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil {
+		return
+	}
+	params["charset"] = w.charset
+	w.Header().Set("Content-Type", mime.FormatMediaType(mediatype, params))
+}
+
+func (w *charsetResponseWriter) WriteHeader(code int) {
+	w.setCharset()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *charsetResponseWriter) WriteHeaderNow() {
+	w.setCharset()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *charsetResponseWriter) Write(data []byte) (int, error) {
+	w.setCharset()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *charsetResponseWriter) WriteString(s string) (int, error) {
+	w.setCharset()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// serveStoredCharset makes the proxied response of a text file carry the charset declared at upload
+func serveStoredCharset(c *gin.Context, filePath string) {
+	if charset := storedCharset(c.Request.Context(), filePath); charset != "" {
+		c.Writer = &charsetResponseWriter{ResponseWriter: c.Writer, charset: charset}
+	}
+}
@@ -0,0 +1,35 @@
+package handles
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestContentCharset(t *testing.T) {
+	tests := map[string]string{
+		"text/plain; charset=GBK":         "gbk",
+		`text/csv; charset="Shift_JIS"`:   "shift_jis",
+		"text/plain":                      "",
+		"text/plain; charset=":            "",
+		`text/plain; charset="a\r\nb: c"`: "",
+		"not a media type;;":              "",
+	}
+	for contentType, want := range tests {
+		if got := contentCharset(contentType); got != want {
+			t.Errorf("contentCharset(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+}
+
+func TestCharsetResponseWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Writer = &charsetResponseWriter{ResponseWriter: c.Writer, charset: "gbk"}
+	c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = c.Writer.Write([]byte("x"))
+	if got := w.Header().Get("Content-Type"); got != "text/plain; charset=gbk" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
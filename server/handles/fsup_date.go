@@ -0,0 +1,146 @@
+package handles
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// dateProbeSize is how much of the upload is buffered to read the capture date,
+// EXIF and a front moov atom normally fit in it
+const dateProbeSize = 256 * 1024
+
+func dateOrganize(c *gin.Context) bool {
+	return c.GetHeader("Date-Organize") == "true"
+}
+
+// captureDate returns the capture date from the head of the file,
+// falling back to the Last-Modified header and then to the upload time
+func captureDate(c *gin.Context, mimetype string, head []byte) time.Time {
+	switch {
+	case strings.HasPrefix(mimetype, "image/"):
+		if date, err := utils.ReadExifDate(bytes.NewReader(head)); err == nil {
+			return date
+		}
+	case strings.HasPrefix(mimetype, "video/"):
+		if date, err := probeCreationTime(c.Request.Context(), head); err == nil {
+			return date
+		}
+	}
+	if ms, err := strconv.ParseInt(c.GetHeader("Last-Modified"), 10, 64); err == nil {
+		return time.UnixMilli(ms)
+	}
+	return time.Now()
+}
+
+// probeCreationTime reads the creation_time tag with ffprobe from the head of a video
+func probeCreationTime(ctx context.Context, head []byte) (time.Time, error) {
+	tempFile, err := os.CreateTemp(os.TempDir(), "video_head_*")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(head)
+	_ = tempFile.Close()
+	if err != nil {
+		return time.Time{}, err
+	}
+	output, err := runFFprobe(ctx,
+		"-v", "error",
+		"-show_entries", "format_tags=creation_time",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		tempFile.Name())
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(string(output)))
+}
+
+// applyDateOrganize files an image or video upload into the date path template
+// beneath its target dir when the client sends "Date-Organize: true",
+// the missing dirs are created by the put. It writes the error response on failure.
+func applyDateOrganize(c *gin.Context, user *model.User, path string, form bool) (string, bool) {
+	if !dateOrganize(c) {
+		return path, true
+	}
+	var head []byte
+	var mimetype string
+	var err error
+	if form {
+		head, mimetype, err = peekFormHead(c)
+	} else {
+		head, err = peekStreamHead(c)
+		mimetype = c.GetHeader("Content-Type")
+	}
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return "", false
+	}
+	if mimetype == "" || strings.HasPrefix(mimetype, "application/octet-stream") {
+		mimetype = utils.GetMimeType(stdpath.Base(path))
+	}
+	if !strings.HasPrefix(mimetype, "image/") && !strings.HasPrefix(mimetype, "video/") {
+		return path, true
+	}
+	date := captureDate(c, mimetype, head)
+	datePath, err := utils.FormatDatePath(setting.GetStr(conf.DateOrganizeTemplate, "YYYY/MM/DD"), date)
+	if err != nil {
+		logrus.Warnf("invalid %s: %+v", conf.DateOrganizeTemplate, err)
+		common.ErrorResp(c, err, 500)
+		return "", false
+	}
+	dir, name := stdpath.Split(path)
+	dst := stdpath.Join(dir, datePath, name)
+	if err = checkUploadPermission(c, user, dst); err != nil {
+		common.ErrorResp(c, err, 403)
+		return "", false
+	}
+	return dst, true
+}
+
+// peekStreamHead reads the head of the request body for applyDateOrganize,
+// the body is replaced so the upload still gets the complete content
+func peekStreamHead(c *gin.Context) ([]byte, error) {
+	head := make([]byte, dateProbeSize)
+	n, err := io.ReadFull(c.Request.Body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	return head, nil
+}
+
+// peekFormHead reads the head of the "file" form field for applyDateOrganize
+func peekFormHead(c *gin.Context) ([]byte, string, error) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	head, err := io.ReadAll(io.LimitReader(f, dateProbeSize))
+	if err != nil {
+		return nil, "", err
+	}
+	return head, file.Header.Get("Content-Type"), nil
+}
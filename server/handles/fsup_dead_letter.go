@@ -0,0 +1,106 @@
+package handles
+
+import (
+	stdpath "path"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+type DeadLetterReq struct {
+	ID string `json:"id" form:"id" binding:"required"`
+}
+
+// deadLetterOwner limits non admins to their own dead letters
+func deadLetterOwner(user *model.User) *model.User {
+	if user.IsAdmin() {
+		return nil
+	}
+	return user
+}
+
+// FsDeadLetters lists the failed As-Task uploads kept for a retry
+func FsDeadLetters(c *gin.Context) {
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	letters, err := fs.ListDeadLetters(deadLetterOwner(user))
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c, letters)
+}
+
+func getDeadLetter(c *gin.Context) (*fs.DeadLetter, bool) {
+	var req DeadLetterReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return nil, false
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	letter, err := fs.GetDeadLetter(req.ID, deadLetterOwner(user))
+	if err != nil {
+		if errs.IsObjectNotFound(err) {
+			common.ErrorStrResp(c, "dead letter not found", 404)
+		} else {
+			common.ErrorResp(c, err, 500)
+		}
+		return nil, false
+	}
+	return letter, true
+}
+
+// FsDeadLetterRetry puts the kept data of a failed upload again as a new task
+func FsDeadLetterRetry(c *gin.Context) {
+	letter, ok := getDeadLetter(c)
+	if !ok {
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	// a failed upload held for moderation is moderated again, the item was discarded on failure
+	retry := *letter
+	if stdpath.Base(stdpath.Dir(retry.DstDir)) == fs.QuarantineDirName {
+		retry.DstDir = stdpath.Dir(stdpath.Dir(retry.DstDir))
+	}
+	path := stdpath.Join(retry.DstDir, retry.Name)
+	// the permission may have been revoked since the upload
+	if err := checkUploadPermission(c, user, path); err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	quarantine, err := holdUpload(c, path, retry.Mimetype, true, user.Username)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if quarantine != nil {
+		retry.DstDir = stdpath.Dir(quarantine.QuarantinePath)
+	}
+	t, err := fs.RetryDeadLetter(c.Request.Context(), &retry)
+	if err != nil {
+		if quarantine != nil {
+			discardQuarantineItem(quarantine)
+		}
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if quarantine != nil {
+		startModeration(quarantine, &model.Object{Size: retry.Size}, t, common.GetApiUrl(c))
+	}
+	common.SuccessResp(c, gin.H{
+		"task": getTaskInfo(t),
+	})
+}
+
+// FsDeadLetterDiscard removes a failed upload and its kept data
+func FsDeadLetterDiscard(c *gin.Context) {
+	letter, ok := getDeadLetter(c)
+	if !ok {
+		return
+	}
+	fs.DiscardDeadLetter(letter)
+	common.SuccessResp(c)
+}
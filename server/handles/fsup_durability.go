@@ -0,0 +1,74 @@
+package handles
+
+import (
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/task"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// values of the Durability header, a buffered upload is acknowledged once its body is synced
+// to a temp file and put into the storage by a task
+const (
+	durabilityImmediate = "immediate"
+	durabilityBuffered  = "buffered"
+)
+
+// bufferDurableUpload copies the body into a temp file synced to disk. The upload task owns
+// the file from then on, it's removed when the stream is closed or kept as a dead letter on failure
+func bufferDurableUpload(body io.Reader) (*os.File, int64, error) {
+	f, err := os.CreateTemp(conf.Conf.TempDir, "durable_upload_*")
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := utils.CopyWithBuffer(f, body)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, 0, err
+	}
+	return f, n, nil
+}
+
+// removeDurableUpload is the closer of the buffered file added to the stream
+func removeDurableUpload(f *os.File) utils.CloseFunc {
+	return func() error {
+		_ = f.Close()
+		return os.Remove(f.Name())
+	}
+}
+
+// bufferedUploadResp acknowledges a buffered upload with 202 and the task committing it
+func bufferedUploadResp(c *gin.Context, path string, created bool, obj model.Obj, t task.TaskExtensionInfo) {
+	setUploadLimitHeaders(c)
+	if writeLegacyUploadResp(c, http.StatusAccepted, path) {
+		return
+	}
+	legacy := gin.H{"task": getTaskInfo(t)}
+	if warnings := uploadWarnings(c); len(warnings) > 0 {
+		legacy["warnings"] = warnings
+	}
+	var data any = legacy
+	if useUniformUploadResp(c) {
+		resp := newUploadResp(path, created, obj, t)
+		resp.Warnings = uploadWarnings(c)
+		data = resp
+	}
+	c.JSON(http.StatusAccepted, common.Resp[any]{
+		Code:    http.StatusAccepted,
+		Message: "accepted",
+		Data:    data,
+	})
+}
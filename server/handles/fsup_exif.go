@@ -0,0 +1,51 @@
+package handles
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// maxStripExifSize bounds the images held in memory to be stripped
+const maxStripExifSize = 128 << 20
+
+// stripExifRequested reports whether the metadata of a JPEG upload is removed, the Strip-Exif
+// header overrides conf.StripExifOnUpload
+func stripExifRequested(c *gin.Context, mimetype string) bool {
+	if !strings.EqualFold(mimetype, "image/jpeg") {
+		return false
+	}
+	switch c.GetHeader("Strip-Exif") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return setting.GetBool(conf.StripExifOnUpload)
+}
+
+// stripUploadExif reads the JPEG and removes its metadata. The stored bytes differ from the
+// sent ones, so the hashes sent by the client are replaced by those of the stripped image
+func stripUploadExif(r io.Reader) ([]byte, map[*utils.HashType]string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxStripExifSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > maxStripExifSize {
+		return nil, nil, fmt.Errorf("image larger than %d bytes can't be stripped", maxStripExifSize)
+	}
+	stripped, err := utils.StripJPEGMetadata(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stripped, map[*utils.HashType]string{
+		utils.MD5:    utils.HashData(utils.MD5, stripped),
+		utils.SHA1:   utils.HashData(utils.SHA1, stripped),
+		utils.SHA256: utils.HashData(utils.SHA256, stripped),
+	}, nil
+}
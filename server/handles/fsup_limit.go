@@ -0,0 +1,241 @@
+package handles
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// uploadSemaphore bounds the concurrent uploads to a storage
+type uploadSemaphore struct {
+	limit int
+	slots chan struct{}
+}
+
+func (s *uploadSemaphore) acquire(ctx context.Context, wait time.Duration) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+var (
+	uploadSemaphoresLock sync.Mutex
+	// uploadSemaphores by storage id
+	uploadSemaphores = make(map[uint]*uploadSemaphore)
+	// uploadsInFlight by storage id, counted whether limited or not
+	uploadsInFlight sync.Map
+)
+
+// getUploadSemaphore returns the semaphore of the storage, a changed limit replaces it,
+// uploads holding a slot of the old one release it there
+func getUploadSemaphore(id uint, limit int) *uploadSemaphore {
+	uploadSemaphoresLock.Lock()
+	defer uploadSemaphoresLock.Unlock()
+	sem, ok := uploadSemaphores[id]
+	if !ok || sem.limit != limit {
+		sem = &uploadSemaphore{limit: limit, slots: make(chan struct{}, limit)}
+		uploadSemaphores[id] = sem
+	}
+	return sem
+}
+
+func getUploadsInFlight(id uint) *atomic.Int64 {
+	counter, _ := uploadsInFlight.LoadOrStore(id, &atomic.Int64{})
+	return counter.(*atomic.Int64)
+}
+
+// acquireUploadSlot takes an upload slot of the client IP and one of the storage of path,
+// it writes 429 when either is exhausted. The returned release must be deferred.
+func acquireUploadSlot(c *gin.Context, path string) (func(), bool) {
+	releaseIP, ok := acquireIPUploadSlot(c)
+	if !ok {
+		return nil, false
+	}
+	releaseStorage, ok := acquireStorageUploadSlot(c, path)
+	if !ok {
+		releaseIP()
+		return nil, false
+	}
+	return func() {
+		releaseStorage()
+		releaseIP()
+	}, true
+}
+
+// acquireStorageUploadSlot waits for a free upload slot of the storage of path, up to
+// conf.UploadQueueTimeout, and writes 429 when none frees up in time
+func acquireStorageUploadSlot(c *gin.Context, path string) (func(), bool) {
+	storage, err := fs.GetStorage(path, &fs.GetStoragesArgs{})
+	if err != nil {
+		// the put itself reports the missing storage
+		return func() {}, true
+	}
+	id := storage.GetStorage().ID
+	counter := getUploadsInFlight(id)
+	limit := storage.GetStorage().MaxConcurrentUploads
+	if limit <= 0 {
+		counter.Add(1)
+		return func() { counter.Add(-1) }, true
+	}
+	sem := getUploadSemaphore(id, limit)
+	wait := time.Duration(setting.GetInt(conf.UploadQueueTimeout, 0)) * time.Second
+	if !sem.acquire(c.Request.Context(), wait) {
+		c.Header("Retry-After", "1")
+		common.ErrorStrResp(c, "too many concurrent uploads to this storage", 429)
+		return nil, false
+	}
+	counter.Add(1)
+	return func() {
+		counter.Add(-1)
+		<-sem.slots
+	}, true
+}
+
+var (
+	ipUploadsLock sync.Mutex
+	// ipUploads counts the uploads in flight by client ip
+	ipUploads = make(map[string]int)
+)
+
+// acquireIPUploadSlot enforces conf.MaxConcurrentUploadsPerIP, it never waits
+func acquireIPUploadSlot(c *gin.Context) (func(), bool) {
+	limit := setting.GetInt(conf.MaxConcurrentUploadsPerIP, 0)
+	if limit <= 0 {
+		return func() {}, true
+	}
+	ip := uploadClientIP(c)
+	ipUploadsLock.Lock()
+	defer ipUploadsLock.Unlock()
+	if ipUploads[ip] >= limit {
+		c.Header("Retry-After", "1")
+		common.ErrorStrResp(c, "too many concurrent uploads from this ip", 429)
+		return nil, false
+	}
+	ipUploads[ip]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ipUploadsLock.Lock()
+			defer ipUploadsLock.Unlock()
+			if ipUploads[ip]--; ipUploads[ip] <= 0 {
+				delete(ipUploads, ip)
+			}
+		})
+	}, true
+}
+
+// parseTrustedProxies parses comma separated IPs and CIDRs, invalid entries are skipped
+func parseTrustedProxies(s string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		} else {
+			log.Warnf("invalid trusted proxy %q in %s", entry, conf.UploadTrustedProxies)
+		}
+	}
+	return prefixes
+}
+
+func isTrustedProxy(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the peer address, or when the peer is a trusted proxy, the rightmost
+// X-Forwarded-For address not added by a trusted proxy. Addresses left of it can be forged
+// by the client, so they are never used.
+func clientIP(remoteAddr string, forwardedFor []string, trusted []netip.Prefix) string {
+	ip, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		ip = remoteAddr
+	}
+	if !isTrustedProxy(ip, trusted) {
+		return ip
+	}
+	var hops []string
+	for _, header := range forwardedFor {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(hop, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+func uploadClientIP(c *gin.Context) string {
+	trusted := parseTrustedProxies(setting.GetStr(conf.UploadTrustedProxies))
+	return clientIP(c.Request.RemoteAddr, c.Request.Header.Values("X-Forwarded-For"), trusted)
+}
+
+type StorageUploadsResp struct {
+	ID        uint   `json:"id"`
+	MountPath string `json:"mount_path"`
+	InFlight  int64  `json:"in_flight"`
+	Limit     int    `json:"limit"`
+}
+
+// UploadsInFlight reports the current uploads of each storage
+func UploadsInFlight(c *gin.Context) {
+	storages := op.GetAllStorages()
+	resp := make([]StorageUploadsResp, 0, len(storages))
+	for _, storage := range storages {
+		s := storage.GetStorage()
+		resp = append(resp, StorageUploadsResp{
+			ID:        s.ID,
+			MountPath: s.MountPath,
+			InFlight:  getUploadsInFlight(s.ID).Load(),
+			Limit:     s.MaxConcurrentUploads,
+		})
+	}
+	common.SuccessResp(c, resp)
+}
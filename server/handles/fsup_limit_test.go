@@ -0,0 +1,56 @@
+package handles
+
+import (
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := parseTrustedProxies("127.0.0.1, 10.0.0.0/8, invalid")
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer ignores header", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "127.0.0.1:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"forged hop left of client", "127.0.0.1:5000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", "127.0.0.1:5000", []string{"198.51.100.1, 10.1.2.3"}, "198.51.100.1"},
+		{"multiple headers", "127.0.0.1:5000", []string{"198.51.100.1", "10.1.2.3"}, "198.51.100.1"},
+		{"garbage hop", "127.0.0.1:5000", []string{"198.51.100.1, nonsense"}, "127.0.0.1"},
+		{"all trusted", "127.0.0.1:5000", []string{"10.1.2.3"}, "10.1.2.3"},
+		{"ipv6 peer", "[2001:db8::1]:5000", []string{"198.51.100.1"}, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientIP(tt.remoteAddr, tt.forwardedFor, trusted); got != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMinFreeSpace(t *testing.T) {
+	valid := map[string]struct {
+		bytes   int64
+		percent float64
+	}{
+		"":            {},
+		"0":           {},
+		"10737418240": {bytes: 10737418240},
+		"5%":          {percent: 5},
+		" 2.5 % ":     {percent: 2.5},
+	}
+	for value, want := range valid {
+		bytes, percent, err := parseMinFreeSpace(value)
+		if err != nil || bytes != want.bytes || percent != want.percent {
+			t.Errorf("%q: got %d, %v, %v", value, bytes, percent, err)
+		}
+	}
+	for _, value := range []string{"-1", "100%", "ten", "5%%"} {
+		if _, _, err := parseMinFreeSpace(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
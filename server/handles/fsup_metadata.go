@@ -0,0 +1,118 @@
+package handles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadMetadataSize bounds the metadata sent with an upload
+const maxUploadMetadataSize = 64 << 10
+
+var errUploadMetadataExists = errors.New("metadata exists")
+
+// uploadMetadata returns the JSON object of the X-Metadata header, or of the metadata field of
+// a form upload, nil when none is sent
+func uploadMetadata(c *gin.Context, form bool) (json.RawMessage, error) {
+	value := c.GetHeader("X-Metadata")
+	if value == "" && form {
+		value = c.PostForm("metadata")
+	}
+	if value == "" {
+		return nil, nil
+	}
+	if len(value) > maxUploadMetadataSize {
+		return nil, fmt.Errorf("metadata larger than %d bytes", maxUploadMetadataSize)
+	}
+	data := bytes.TrimSpace([]byte(value))
+	if !json.Valid(data) || data[0] != '{' {
+		return nil, errors.New("metadata must be a JSON object")
+	}
+	return data, nil
+}
+
+// parseUploadMetadata reads and checks the metadata of the upload at path, writing the error
+func parseUploadMetadata(c *gin.Context, path string, overwrite, form bool) (json.RawMessage, bool) {
+	metadata, err := uploadMetadata(c, form)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return nil, false
+	}
+	if err = checkUploadMetadata(c.Request.Context(), path, overwrite, metadata); err != nil {
+		common.ErrorResp(c, err, 403)
+		return nil, false
+	}
+	return metadata, true
+}
+
+// checkUploadMetadata follows the Overwrite of the upload: without it, metadata already
+// recorded for path is kept and the upload is refused
+func checkUploadMetadata(ctx context.Context, path string, overwrite bool, metadata json.RawMessage) error {
+	if metadata == nil || overwrite {
+		return nil
+	}
+	if sidecar, err := readMediaSidecar(ctx, path); err == nil && sidecar.Metadata != nil {
+		return errUploadMetadataExists
+	}
+	return nil
+}
+
+// storeUploadMetadata records the metadata into the sidecar of the uploaded file
+func storeUploadMetadata(ctx context.Context, path string, metadata json.RawMessage) error {
+	if metadata == nil {
+		return nil
+	}
+	sidecar, err := readMediaSidecar(ctx, path)
+	if err != nil {
+		sidecar = &MediaSidecar{}
+	}
+	sidecar.Metadata = metadata
+	return writeMediaSidecar(ctx, path, sidecar)
+}
+
+// storedMetadata returns the metadata uploaded with the file, nil when there is none
+func storedMetadata(ctx context.Context, path string) json.RawMessage {
+	sidecar, err := readMediaSidecar(ctx, path)
+	if err != nil {
+		return nil
+	}
+	return sidecar.Metadata
+}
+
+// preservedFile is what an upload with "Overwrite: content-only" keeps of the file it replaces
+type preservedFile struct {
+	created time.Time
+}
+
+// createTime is the creation time of the new object, zero when nothing is preserved. Drivers
+// taking the creation time from the upload keep the one of the replaced file, the others ignore it
+func (p *preservedFile) createTime() time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	return p.created
+}
+
+// contentOnlyUpload reads what an upload with "Overwrite: content-only" keeps of exist, writing
+// the error. The metadata of exist is returned to be stored again once the new file is put, so
+// sending metadata with the upload is refused. Without exist or without the mode, nothing is kept.
+func contentOnlyUpload(c *gin.Context, mode uploadMode, path string, exist model.Obj, metadata json.RawMessage) (*preservedFile, json.RawMessage, bool) {
+	if !mode.contentOnly {
+		return nil, metadata, true
+	}
+	if metadata != nil {
+		common.ErrorStrResp(c, "metadata can't be sent with Overwrite content-only", 400)
+		return nil, nil, false
+	}
+	if exist == nil {
+		return nil, nil, true
+	}
+	return &preservedFile{created: exist.CreateTime()}, storedMetadata(c.Request.Context(), path), true
+}
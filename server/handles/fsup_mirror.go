@@ -0,0 +1,141 @@
+package handles
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	stdpath "path"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const mirrorResultsKey = "upload_mirror_results"
+
+// MirrorResult is the outcome of putting an upload into one of its Mirror-Paths
+type MirrorResult struct {
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"`
+}
+
+// uploadMirrorPaths parses the Mirror-Paths header, a comma separated list of url-encoded
+// destination paths. Every destination is resolved and checked like File-Path before the body
+// is read, resolveUploadMode already refused them for uploads put by a task
+func uploadMirrorPaths(c *gin.Context, user *model.User, overwrite bool) ([]string, bool) {
+	header := c.GetHeader("Mirror-Paths")
+	if header == "" {
+		return nil, true
+	}
+	var paths []string
+	for _, p := range strings.Split(header, ",") {
+		p, err := url.PathUnescape(strings.TrimSpace(p))
+		if err != nil {
+			common.ErrorResp(c, err, 400)
+			return nil, false
+		}
+		if p == "" {
+			continue
+		}
+		p, err = user.JoinUploadPath(normalizeUploadPath(p))
+		if err != nil {
+			common.PathErrorResp(c, err, 403)
+			return nil, false
+		}
+		if err = checkUploadPermission(c, user, p); err != nil {
+			common.ErrorResp(c, err, 403)
+			return nil, false
+		}
+		if shouldIgnoreSystemFile(stdpath.Base(p)) {
+			common.ErrorStrResp(c, errs.IgnoredSystemFile.Error(), 403)
+			return nil, false
+		}
+		if !overwrite {
+			if exist, _ := fs.Get(c.Request.Context(), p, &fs.GetArgs{NoLog: true}); exist != nil {
+				common.ErrorStrResp(c, "file exists: "+p, 403)
+				return nil, false
+			}
+		}
+		paths = append(paths, p)
+	}
+	return paths, true
+}
+
+// spoolUploadBody copies the body into a temp file, so it can be read once per destination
+func spoolUploadBody(body io.Reader) (*os.File, int64, error) {
+	f, err := os.CreateTemp(conf.Conf.TempDir, "mirror_upload_*")
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := utils.CopyWithBuffer(f, body)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, 0, err
+	}
+	return f, n, nil
+}
+
+// putMirrors puts the uploaded file into every mirror, open returns a new reader of its content.
+// With conf.MirrorUploadsAllMustSucceed a failed mirror fails the request, otherwise it's only
+// reported in the results of the response. Nothing is rolled back when the request fails: the
+// file at File-Path and the mirrors put may have replaced existing files, which are gone by then
+func putMirrors(c *gin.Context, mirrors []string, obj model.Obj, mimetype string, open func() (io.ReadCloser, error)) bool {
+	results := make([]MirrorResult, 0, len(mirrors))
+	var failed []string
+	for _, p := range mirrors {
+		err := putMirror(c, p, obj, mimetype, open)
+		result := MirrorResult{Path: p}
+		if err != nil {
+			log.Warnf("failed to put upload mirror %s: %+v", p, err)
+			result.Error = err.Error()
+			failed = append(failed, fmt.Sprintf("%s: %v", p, err))
+		}
+		results = append(results, result)
+	}
+	if len(failed) > 0 && setting.GetBool(conf.MirrorUploadsAllMustSucceed) {
+		// 主文件与已成功的镜像不回滚，错误信息中说明
+		common.ErrorStrResp(c, "failed to put mirrors, the file and the other mirrors were kept: "+strings.Join(failed, "; "), 500)
+		return false
+	}
+	c.Set(mirrorResultsKey, results)
+	return true
+}
+
+func putMirror(c *gin.Context, path string, obj model.Obj, mimetype string, open func() (io.ReadCloser, error)) error {
+	r, err := open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	dir, name := stdpath.Split(path)
+	s := &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     obj.GetSize(),
+			Modified: obj.ModTime(),
+			HashInfo: obj.GetHash(),
+		},
+		Reader:   r,
+		Mimetype: mimetype,
+	}
+	return fs.PutDirectly(c.Request.Context(), dir, s)
+}
+
+func uploadMirrorResults(c *gin.Context) []MirrorResult {
+	results, _ := c.Get(mirrorResultsKey)
+	mirrors, _ := results.([]MirrorResult)
+	return mirrors
+}
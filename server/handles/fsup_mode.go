@@ -0,0 +1,138 @@
+package handles
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+// uploadMode is how an upload treats an existing file and when it's put into the storage.
+// FsStream and FsForm resolve it from the headers with resolveUploadMode, in this order:
+//  1. Overwrite "true" or "false" from the client wins, then conf.DefaultOverwrite, then true.
+//     "rename" never overwrites, an existing file makes the upload pick a free name by
+//     conf.ConflictRenameTemplate. "content-only" overwrites the bytes only, the creation time
+//     and the metadata of the existing file are kept, see contentOnlyUpload. "if-newer" overwrites
+//     only a file older than the Last-Modified of the upload, "if-changed" only one whose content
+//     differs, see uploadSkipped. Any other value is refused
+//  2. Durability "buffered" implies As-Task, an explicit "As-Task: false" contradicts it
+//  3. Mirror-Paths are put synchronously, so they contradict As-Task and Durability "buffered".
+//     So does Overwrite "rename", the picked name is only reserved until the request ends
+//  4. "Staged: true" puts the upload aside until it's committed, see stageUpload. It's verified
+//     once put, so it contradicts As-Task and Durability "buffered", and Overwrite "rename"
+//  5. "Dedupe: true" skips the upload when the existing file has the same content, whatever
+//     Overwrite says about a different one. It contradicts an explicit "Overwrite: false",
+//     which refuses any existing file, and Staged, which would leave nothing to commit
+type uploadMode struct {
+	overwrite   bool
+	rename      bool
+	contentOnly bool
+	ifNewer     bool
+	ifChanged   bool
+	dedupe      bool
+	asTask      bool
+	durable     bool
+	staged      bool
+}
+
+func resolveUploadMode(header http.Header) (uploadMode, error) {
+	var mode uploadMode
+	switch overwrite := header.Get("Overwrite"); overwrite {
+	case "", "true", "false":
+		mode.overwrite = resolveOverwrite(overwrite)
+	case "rename":
+		mode.rename = true
+	case "content-only":
+		mode.overwrite, mode.contentOnly = true, true
+	case "if-newer":
+		mode.overwrite, mode.ifNewer = true, true
+	case "if-changed":
+		mode.overwrite, mode.ifChanged = true, true
+	default:
+		return mode, fmt.Errorf("invalid Overwrite %s", overwrite)
+	}
+	switch durability := header.Get("Durability"); durability {
+	case "", durabilityImmediate:
+	case durabilityBuffered:
+		mode.durable = true
+	default:
+		return mode, fmt.Errorf("invalid Durability %s", durability)
+	}
+	asTask := header.Get("As-Task")
+	if mode.durable && asTask == "false" {
+		return mode, errors.New("Durability buffered can't be used with As-Task false")
+	}
+	mode.asTask = asTask == "true" || mode.durable
+	if mode.rename && mode.asTask {
+		return mode, errors.New("Overwrite rename can't be used with As-Task or Durability buffered")
+	}
+	if header.Get("Mirror-Paths") != "" && mode.asTask {
+		if mode.durable {
+			return mode, errors.New("Mirror-Paths can't be used with Durability buffered")
+		}
+		return mode, errors.New("Mirror-Paths can't be used with As-Task")
+	}
+	switch staged := header.Get("Staged"); staged {
+	case "", "false":
+	case "true":
+		mode.staged = true
+	default:
+		return mode, fmt.Errorf("invalid Staged %s", staged)
+	}
+	if mode.staged && mode.asTask {
+		return mode, errors.New("Staged can't be used with As-Task or Durability buffered")
+	}
+	if mode.staged && mode.rename {
+		return mode, errors.New("Staged can't be used with Overwrite rename")
+	}
+	switch dedupe := header.Get("Dedupe"); dedupe {
+	case "", "false":
+	case "true":
+		mode.dedupe = true
+	default:
+		return mode, fmt.Errorf("invalid Dedupe %s", dedupe)
+	}
+	if mode.dedupe && header.Get("Overwrite") == "false" {
+		return mode, errors.New("Dedupe can't be used with Overwrite false")
+	}
+	if mode.dedupe && mode.staged {
+		return mode, errors.New("Dedupe can't be used with Staged")
+	}
+	return mode, nil
+}
+
+// uploadSkipped reports whether mode skips the upload, leaving the existing file as is:
+// Dedupe and Overwrite "if-changed" skip it when exist has the same content,
+// Overwrite "if-newer" when exist isn't older than the upload. Times are compared
+// in seconds, the precision of most storages
+func uploadSkipped(mode uploadMode, exist model.Obj, size int64, h map[*utils.HashType]string, modified time.Time) bool {
+	if exist == nil || exist.IsDir() {
+		return false
+	}
+	if (mode.dedupe || mode.ifChanged) && sameUploadContent(exist, size, h, modified) {
+		return true
+	}
+	return mode.ifNewer && !modified.Truncate(time.Second).After(exist.ModTime().Truncate(time.Second))
+}
+
+// sameUploadContent compares the upload to exist by the hashes sent which the storage reports,
+// or by the size and modification time when it reports none of them
+func sameUploadContent(exist model.Obj, size int64, h map[*utils.HashType]string, modified time.Time) bool {
+	if size < 0 || exist.GetSize() != size {
+		return false
+	}
+	compared := false
+	for ht, sum := range h {
+		if stored := exist.GetHash().GetHash(ht); stored != "" {
+			if !strings.EqualFold(stored, sum) {
+				return false
+			}
+			compared = true
+		}
+	}
+	return compared || modified.Truncate(time.Second).Equal(exist.ModTime().Truncate(time.Second))
+}
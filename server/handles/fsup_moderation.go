@@ -0,0 +1,385 @@
+package handles
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/internal/task"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/OpenListTeam/tache"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	QuarantineUploading = "uploading"
+	QuarantinePending   = "pending"
+	QuarantineFailed    = "failed"
+)
+
+// QuarantineItem is an upload held in quarantine until it's approved or rejected,
+// items are kept in the data dir so they survive restarts
+type QuarantineItem struct {
+	ID             string    `json:"id"`
+	Path           string    `json:"path"`
+	QuarantinePath string    `json:"quarantine_path"`
+	Mimetype       string    `json:"mimetype"`
+	Size           int64     `json:"size"`
+	Overwrite      bool      `json:"overwrite"`
+	Username       string    `json:"username"`
+	Status         string    `json:"status"`
+	Error          string    `json:"error,omitempty"`
+	Created        time.Time `json:"created"`
+}
+
+// ModerationRequest is POSTed to conf.UploadModerationURL, the service answers with
+// {"decision": "approve"|"reject"}, or later through /api/admin/quarantine
+type ModerationRequest struct {
+	ID          string `json:"id"`
+	Path        string `json:"path"`
+	Mimetype    string `json:"mimetype"`
+	Size        int64  `json:"size"`
+	Username    string `json:"username"`
+	DownloadURL string `json:"download_url"`
+}
+
+type ModerationResp struct {
+	Decision string `json:"decision"`
+}
+
+const moderationTimeout = 30 * time.Second
+
+var (
+	moderationClient = progressCallbackClient
+	// quarantineLock serializes changes of the quarantine items
+	quarantineLock sync.Mutex
+)
+
+func quarantineItemsDir() string {
+	return filepath.Join(flags.DataDir, "quarantine")
+}
+
+func quarantineItemPath(id string) string {
+	return filepath.Join(quarantineItemsDir(), id+".json")
+}
+
+// needModeration reports whether uploads of the mimetype go through quarantine
+func needModeration(mimetype string) bool {
+	if setting.GetStr(conf.UploadModerationURL) == "" {
+		return false
+	}
+	return strings.HasPrefix(mimetype, "image/") || strings.HasPrefix(mimetype, "video/")
+}
+
+// newQuarantineItem returns the item of the upload to path, nil when it needn't moderation
+func newQuarantineItem(path, mimetype string, overwrite bool, username string) *QuarantineItem {
+	if !needModeration(mimetype) {
+		return nil
+	}
+	id := uuid.NewString()
+	dir, name := stdpath.Split(path)
+	return &QuarantineItem{
+		ID:             id,
+		Path:           path,
+		QuarantinePath: stdpath.Join(dir, fs.QuarantineDirName, id, name),
+		Mimetype:       mimetype,
+		Overwrite:      overwrite,
+		Username:       username,
+		Status:         QuarantineUploading,
+		Created:        time.Now(),
+	}
+}
+
+// holdUpload records the quarantine item of the upload before it's put, nil when
+// the upload needn't moderation
+func holdUpload(c *gin.Context, path, mimetype string, overwrite bool, username string) (*QuarantineItem, error) {
+	item := newQuarantineItem(path, mimetype, overwrite, username)
+	if item == nil {
+		return nil, nil
+	}
+	quarantineLock.Lock()
+	err := saveQuarantineItem(item)
+	quarantineLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	c.Header("X-Upload-Quarantine-Id", item.ID)
+	return item, nil
+}
+
+// quarantineUpload is holdUpload for FsStream and FsForm, which respond the errors
+func quarantineUpload(c *gin.Context, path, mimetype string, overwrite bool, user *model.User, mirrors []string) (*QuarantineItem, bool) {
+	// mirrors would be live before the moderation
+	if len(mirrors) > 0 && needModeration(mimetype) {
+		common.ErrorStrResp(c, "Mirror-Paths can't be used with uploads requiring moderation", 400)
+		return nil, false
+	}
+	item, err := holdUpload(c, path, mimetype, overwrite, user.Username)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return nil, false
+	}
+	return item, true
+}
+
+func saveQuarantineItem(item *QuarantineItem) error {
+	if err := os.MkdirAll(quarantineItemsDir(), 0o700); err != nil {
+		return err
+	}
+	data, err := utils.Json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(quarantineItemPath(item.ID), data, 0o600)
+}
+
+func getQuarantineItem(id string) (*QuarantineItem, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("quarantine item %s not found", id)
+	}
+	data, err := os.ReadFile(quarantineItemPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("quarantine item %s not found", id)
+	}
+	var item QuarantineItem
+	if err = utils.Json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func listQuarantineItems() ([]QuarantineItem, error) {
+	entries, err := os.ReadDir(quarantineItemsDir())
+	if os.IsNotExist(err) {
+		return []QuarantineItem{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	items := make([]QuarantineItem, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if item, err := getQuarantineItem(id); err == nil {
+			items = append(items, *item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Created.Before(items[j].Created)
+	})
+	return items, nil
+}
+
+func updateQuarantineStatus(item *QuarantineItem, status string, err error) {
+	quarantineLock.Lock()
+	defer quarantineLock.Unlock()
+	// it may have been decided meanwhile
+	if _, getErr := getQuarantineItem(item.ID); getErr != nil {
+		return
+	}
+	item.Status, item.Error = status, ""
+	if err != nil {
+		item.Error = err.Error()
+	}
+	if err = saveQuarantineItem(item); err != nil {
+		log.Warnf("failed to save quarantine item %s: %+v", item.ID, err)
+	}
+}
+
+// startModeration submits the quarantined upload once it's put, t is the task of an As-Task upload
+func startModeration(item *QuarantineItem, obj model.Obj, t task.TaskExtensionInfo, apiURL string) {
+	item.Size = obj.GetSize()
+	go func() {
+		if t != nil {
+			ticker := time.NewTicker(time.Second)
+			for range ticker.C {
+				if taskFinished(getTaskInfo(t).State) {
+					break
+				}
+			}
+			ticker.Stop()
+			if getTaskInfo(t).State != tache.StateSucceeded {
+				// nothing was put, so there is nothing to moderate
+				discardQuarantineItem(item)
+				return
+			}
+		}
+		decision, err := submitModeration(item, apiURL)
+		if err != nil {
+			log.Warnf("failed to submit %s for moderation: %+v", item.Path, err)
+			updateQuarantineStatus(item, QuarantineFailed, err)
+			return
+		}
+		updateQuarantineStatus(item, QuarantinePending, nil)
+		switch decision {
+		case "approve":
+			err = approveQuarantineItem(context.Background(), item)
+		case "reject":
+			err = rejectQuarantineItem(context.Background(), item)
+		}
+		if err != nil {
+			log.Warnf("failed to apply moderation decision %s of %s: %+v", decision, item.Path, err)
+		}
+	}()
+}
+
+// submitModeration posts the item to conf.UploadModerationURL, it returns the decision
+// if the service made it right away
+func submitModeration(item *QuarantineItem, apiURL string) (string, error) {
+	payload := ModerationRequest{
+		ID:       item.ID,
+		Path:     item.Path,
+		Mimetype: item.Mimetype,
+		Size:     item.Size,
+		Username: item.Username,
+		DownloadURL: fmt.Sprintf("%s/p%s?sign=%s", apiURL,
+			utils.EncodePath(item.QuarantinePath, true), sign.Sign(item.QuarantinePath)),
+	}
+	req, err := newSignedCallback(setting.GetStr(conf.UploadModerationURL), payload, conf.UploadModerationSecret)
+	if err != nil {
+		return "", err
+	}
+	client := *moderationClient
+	client.Timeout = moderationTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("moderation service responded %s", resp.Status)
+	}
+	var result ModerationResp
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err == nil && len(body) > 0 {
+		_ = utils.Json.Unmarshal(body, &result)
+	}
+	return result.Decision, nil
+}
+
+func discardQuarantineItem(item *QuarantineItem) {
+	quarantineLock.Lock()
+	defer quarantineLock.Unlock()
+	_ = os.Remove(quarantineItemPath(item.ID))
+}
+
+// approveQuarantineItem moves the upload to its destination
+func approveQuarantineItem(ctx context.Context, item *QuarantineItem) error {
+	quarantineLock.Lock()
+	defer quarantineLock.Unlock()
+	if _, err := getQuarantineItem(item.ID); err != nil {
+		return err
+	}
+	if exist, _ := fs.Get(ctx, item.Path, &fs.GetArgs{NoLog: true}); exist != nil {
+		if !item.Overwrite {
+			return fmt.Errorf("%s already exists", item.Path)
+		}
+		kept, err := keepPreviousVersion(ctx, item.Path)
+		if err != nil {
+			return err
+		}
+		if kept == "" {
+			if err = fs.Remove(ctx, item.Path); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := fs.Move(ctx, item.QuarantinePath, stdpath.Dir(item.Path)); err != nil {
+		return err
+	}
+	_ = fs.Remove(ctx, stdpath.Dir(item.QuarantinePath))
+	_ = os.Remove(quarantineItemPath(item.ID))
+	if user, err := op.GetUserByName(item.Username); err == nil {
+		scheduleThumbnail(item.Path, user, nil)
+	}
+	return nil
+}
+
+// rejectQuarantineItem removes the upload
+func rejectQuarantineItem(ctx context.Context, item *QuarantineItem) error {
+	quarantineLock.Lock()
+	defer quarantineLock.Unlock()
+	if _, err := getQuarantineItem(item.ID); err != nil {
+		return err
+	}
+	if err := fs.Remove(ctx, stdpath.Dir(item.QuarantinePath)); err != nil {
+		return err
+	}
+	return os.Remove(quarantineItemPath(item.ID))
+}
+
+// hideQuarantine drops the quarantine and staging dirs from a listing
+func hideQuarantine(objs []model.Obj) []model.Obj {
+	filtered := objs[:0:0]
+	for _, obj := range objs {
+		if obj.IsDir() && (obj.GetName() == fs.QuarantineDirName || obj.GetName() == stagingDirName) {
+			continue
+		}
+		filtered = append(filtered, obj)
+	}
+	return filtered
+}
+
+type QuarantineReq struct {
+	ID string `json:"id" form:"id" binding:"required"`
+}
+
+func ListQuarantine(c *gin.Context) {
+	items, err := listQuarantineItems()
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c, items)
+}
+
+func quarantineDecision(c *gin.Context, decide func(context.Context, *QuarantineItem) error) {
+	var req QuarantineReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	item, err := getQuarantineItem(req.ID)
+	if err != nil {
+		common.ErrorResp(c, err, 404)
+		return
+	}
+	if item.Status == QuarantineUploading {
+		common.ErrorStrResp(c, "the upload hasn't finished yet", 409)
+		return
+	}
+	if err = decide(c.Request.Context(), item); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c)
+}
+
+// ApproveQuarantine moves a quarantined upload to its destination
+func ApproveQuarantine(c *gin.Context) {
+	quarantineDecision(c, approveQuarantineItem)
+}
+
+// RejectQuarantine deletes a quarantined upload
+func RejectQuarantine(c *gin.Context) {
+	quarantineDecision(c, rejectQuarantineItem)
+}
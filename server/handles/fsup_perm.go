@@ -0,0 +1,55 @@
+package handles
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	log "github.com/sirupsen/logrus"
+)
+
+// uploadFileModeInherit gives uploaded files the mode of their directory without the execute bits
+const uploadFileModeInherit = "inherit"
+
+// uploadFileMode resolves conf.UploadFileMode for the file at localPath
+func uploadFileMode(value, localPath string) (os.FileMode, error) {
+	if value == uploadFileModeInherit {
+		info, err := os.Stat(filepath.Dir(localPath))
+		if err != nil {
+			return 0, err
+		}
+		return info.Mode().Perm() &^ 0o111, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid %s %q", conf.UploadFileMode, value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// applyUploadFileMode sets the mode of conf.UploadFileMode on the uploaded file,
+// it does nothing on storages whose files aren't on the local disk
+func applyUploadFileMode(ctx context.Context, path string) {
+	value := strings.TrimSpace(setting.GetStr(conf.UploadFileMode))
+	if value == "" || !localFilesStorage(path) {
+		return
+	}
+	obj, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+	if err != nil || obj.GetPath() == "" {
+		log.Warnf("set mode of %s error: file not found: %v", path, err)
+		return
+	}
+	mode, err := uploadFileMode(value, obj.GetPath())
+	if err == nil {
+		err = os.Chmod(obj.GetPath(), mode)
+	}
+	if err != nil {
+		log.Warnf("set mode of %s error: %+v", path, err)
+	}
+}
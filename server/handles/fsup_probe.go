@@ -0,0 +1,53 @@
+package handles
+
+import (
+	"context"
+	stdpath "path"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+)
+
+const (
+	existenceProbeWeak   = "weak"
+	existenceProbeStrong = "strong"
+)
+
+// existenceProbeBackoff is the wait before each probe after the first one of a strong existence probe
+var existenceProbeBackoff = []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+
+// probeExistence returns the object at path an upload is about to overwrite, nil when there is none.
+// With conf.ExistenceProbeConsistency "strong" the cached listing is dropped before every probe and
+// the storage is asked again after a backoff until two probes agree, so an eventually consistent
+// storage doesn't answer with a file deleted just before. It costs at least existenceProbeBackoff[0]
+func probeExistence(ctx context.Context, path string) model.Obj {
+	if setting.GetStr(conf.ExistenceProbeConsistency, existenceProbeWeak) != existenceProbeStrong {
+		exist, _ := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+		return exist
+	}
+	probe := func() model.Obj {
+		if storage, actualPath, err := op.GetStorageAndActualPath(path); err == nil {
+			op.Cache.DeleteDirectory(storage, stdpath.Dir(actualPath))
+		}
+		exist, _ := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+		return exist
+	}
+	exist := probe()
+	for _, wait := range existenceProbeBackoff {
+		select {
+		case <-ctx.Done():
+			return exist
+		case <-time.After(wait):
+		}
+		again := probe()
+		if (again == nil) == (exist == nil) {
+			return again
+		}
+		exist = again
+	}
+	return exist
+}
@@ -0,0 +1,48 @@
+package handles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+func TestProbeExistence(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/probe", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setConsistency := func(value string) {
+		if err := op.SaveSettingItem(&model.SettingItem{Key: conf.ExistenceProbeConsistency, Value: value, Type: conf.TypeSelect, Group: model.UPLOAD, Flag: model.PRIVATE}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { setConsistency(existenceProbeWeak) })
+	for _, consistency := range []string{existenceProbeWeak, existenceProbeStrong} {
+		setConsistency(consistency)
+		if exist := probeExistence(ctx, "/probe/a.txt"); exist == nil || exist.GetName() != "a.txt" {
+			t.Errorf("%s: existing file probed as %v", consistency, exist)
+		}
+		if exist := probeExistence(ctx, "/probe/b.txt"); exist != nil {
+			t.Errorf("%s: missing file probed as %s", consistency, exist.GetName())
+		}
+	}
+
+	// a canceled request gets the first answer without waiting for the backoff
+	setConsistency(existenceProbeStrong)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if exist := probeExistence(canceled, "/probe/a.txt"); exist == nil {
+		t.Error("first probe of a canceled request lost")
+	}
+}
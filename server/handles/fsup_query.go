@@ -0,0 +1,45 @@
+package handles
+
+import (
+	"net/url"
+
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// uploadQueryHeaders maps the query parameters of a query upload to the headers FsStream reads
+var uploadQueryHeaders = []struct {
+	query  string
+	header string
+}{
+	{"overwrite", "Overwrite"},
+	{"as_task", "As-Task"},
+	{"md5", "X-File-Md5"},
+	{"sha1", "X-File-Sha1"},
+	{"sha256", "X-File-Sha256"},
+	{"last_modified", "Last-Modified"},
+	{"password", "Password"},
+}
+
+// UploadQueryHeaders lets clients which can't set headers upload the raw body with query parameters,
+// e.g. curl --data-binary @file ".../api/fs/upload?path=/x&sha256=...". path replaces File-Path and the
+// other parameters their header, the request then goes through FsUp and FsStream like any other
+func UploadQueryHeaders(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		common.ErrorStrResp(c, "path is required", 400)
+		c.Abort()
+		return
+	}
+	c.Request.Header.Set("File-Path", url.PathEscape(path))
+	for _, q := range uploadQueryHeaders {
+		if value, ok := c.GetQuery(q.query); ok {
+			c.Request.Header.Set(q.header, value)
+		}
+	}
+	// the default of curl --data-binary, the type is guessed from the name instead
+	if c.ContentType() == "application/x-www-form-urlencoded" {
+		c.Request.Header.Del("Content-Type")
+	}
+	c.Next()
+}
@@ -0,0 +1,47 @@
+package handles
+
+import (
+	"context"
+	stdpath "path"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+// defaultConflictRenameTemplate names uploads like "a (1).txt"
+const defaultConflictRenameTemplate = "{name} ({seq}){ext}"
+
+// reservedUploadPaths holds the paths picked for "Overwrite: rename" uploads until they end,
+// so concurrent uploads of the same name pick different ones
+var reservedUploadPaths sync.Map
+
+// renameOnConflict returns path when it's free, or else the first free name by
+// conf.ConflictRenameTemplate. The returned path is reserved until release is called
+func renameOnConflict(ctx context.Context, path string) (string, func(), error) {
+	dir, name := stdpath.Split(path)
+	taken := func(candidate string) bool {
+		p := stdpath.Join(dir, candidate)
+		if _, loaded := reservedUploadPaths.LoadOrStore(p, struct{}{}); loaded {
+			return true
+		}
+		if obj, _ := fs.Get(ctx, p, &fs.GetArgs{NoLog: true}); obj != nil {
+			reservedUploadPaths.Delete(p)
+			return true
+		}
+		return false
+	}
+	if !taken(name) {
+		return path, func() { reservedUploadPaths.Delete(path) }, nil
+	}
+	template := setting.GetStr(conf.ConflictRenameTemplate, defaultConflictRenameTemplate)
+	free, err := utils.NextVersionName(template, name, time.Now(), taken)
+	if err != nil {
+		return "", nil, err
+	}
+	path = stdpath.Join(dir, free)
+	return path, func() { reservedUploadPaths.Delete(path) }, nil
+}
@@ -0,0 +1,156 @@
+package handles
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/internal/task"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// UploadResp is the uniform response of FsStream and FsForm,
+// returned when the client sends "Accept-Version: 2" or conf.UploadUniformResponse is enabled
+type UploadResp struct {
+	Created  bool                       `json:"created"`
+	Path     string                     `json:"path"`
+	Size     int64                      `json:"size"`
+	Modified time.Time                  `json:"modified"`
+	Hashes   map[*utils.HashType]string `json:"hashes"`
+	Task     *TaskInfo                  `json:"task"`
+	Mirrors  []MirrorResult             `json:"mirrors,omitempty"`
+	Warnings []string                   `json:"warnings,omitempty"`
+}
+
+const uploadWarningsKey = "upload_warnings"
+
+// addUploadWarning reports a problem that didn't fail the upload in the warnings of its response
+func addUploadWarning(c *gin.Context, warning string) {
+	c.Set(uploadWarningsKey, append(uploadWarnings(c), warning))
+}
+
+func uploadWarnings(c *gin.Context) []string {
+	warnings, _ := c.Get(uploadWarningsKey)
+	w, _ := warnings.([]string)
+	return w
+}
+
+func useUniformUploadResp(c *gin.Context) bool {
+	return c.GetHeader("Accept-Version") == "2" || setting.GetBool(conf.UploadUniformResponse)
+}
+
+func newUploadResp(path string, created bool, obj model.Obj, t task.TaskExtensionInfo) UploadResp {
+	resp := UploadResp{
+		Created:  created,
+		Path:     path,
+		Size:     obj.GetSize(),
+		Modified: obj.ModTime(),
+		Hashes:   obj.GetHash().Export(),
+	}
+	if t != nil {
+		info := getTaskInfo(t)
+		resp.Task = &info
+	}
+	return resp
+}
+
+// setUploadLimitHeaders exposes the upload limiter state so clients can throttle themselves,
+// headers of disabled limits are omitted. There is no quota accounting yet, so the
+// X-Upload-Quota-Used and X-Upload-Quota-Limit headers are never sent.
+func setUploadLimitHeaders(c *gin.Context) {
+	if limiter := stream.ClientUploadLimit; limiter != nil && limiter.Limit() != rate.Inf {
+		remaining := int64(limiter.Tokens())
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-Upload-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	}
+}
+
+const (
+	uploadRespJSON  = "json"
+	uploadRespText  = "text"
+	uploadRespEmpty = "empty"
+)
+
+// uploadRespFormat returns the format of a successful upload response: the first of application/json
+// and text/plain the Accept header prefers, otherwise conf.UploadResponseFormat
+func uploadRespFormat(c *gin.Context) string {
+	for _, mimetype := range acceptedMimetypes(c.GetHeader("Accept")) {
+		switch mimetype {
+		case "application/json":
+			return uploadRespJSON
+		case "text/plain":
+			return uploadRespText
+		}
+	}
+	switch format := setting.GetStr(conf.UploadResponseFormat, uploadRespJSON); format {
+	case uploadRespText, uploadRespEmpty:
+		return format
+	}
+	return uploadRespJSON
+}
+
+// writeLegacyUploadResp writes the response of legacy clients which don't parse JSON, the path
+// as plain text or no body, and reports whether it did. status is the one of the JSON response,
+// an empty 200 becomes 204
+func writeLegacyUploadResp(c *gin.Context, status int, path string) bool {
+	switch uploadRespFormat(c) {
+	case uploadRespText:
+		c.String(status, path)
+	case uploadRespEmpty:
+		if status == http.StatusOK {
+			status = http.StatusNoContent
+		}
+		c.Status(status)
+	default:
+		return false
+	}
+	return true
+}
+
+// uploadSuccessResp writes the result of an upload in the shape requested by the client
+func uploadSuccessResp(c *gin.Context, path string, created bool, obj model.Obj, t task.TaskExtensionInfo) {
+	setUploadLimitHeaders(c)
+	if writeLegacyUploadResp(c, http.StatusOK, path) {
+		return
+	}
+	if useUniformUploadResp(c) {
+		resp := newUploadResp(path, created, obj, t)
+		resp.Mirrors = uploadMirrorResults(c)
+		resp.Warnings = uploadWarnings(c)
+		common.SuccessResp(c, resp)
+		return
+	}
+	var data gin.H
+	if mirrors := uploadMirrorResults(c); mirrors != nil {
+		data = gin.H{"mirrors": mirrors}
+	} else if t != nil {
+		data = gin.H{"task": getTaskInfo(t)}
+	}
+	if warnings := uploadWarnings(c); len(warnings) > 0 {
+		if data == nil {
+			data = gin.H{}
+		}
+		data["warnings"] = warnings
+	}
+	if data == nil {
+		common.SuccessResp(c)
+		return
+	}
+	common.SuccessResp(c, data)
+}
+
+// skippedUploadResp answers an upload its mode skipped, see uploadSkipped, with the existing file
+// and the X-Upload-Skipped header
+func skippedUploadResp(c *gin.Context, path string, exist model.Obj) {
+	c.Header("X-Upload-Skipped", "true")
+	uploadSuccessResp(c, path, false, exist, nil)
+}
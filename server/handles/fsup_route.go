@@ -0,0 +1,70 @@
+package handles
+
+import (
+	stdpath "path"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkUploadPermission does the same check as middlewares.FsUp for a path
+// which is only known inside the handler, e.g. a rewritten destination
+func checkUploadPermission(c *gin.Context, user *model.User, path string) error {
+	meta, err := op.GetNearestMeta(stdpath.Dir(path))
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		return err
+	}
+	if !(common.CanAccess(user, meta, path, c.GetHeader("Password")) && (user.CanWrite() || common.CanWrite(meta, stdpath.Dir(path)))) {
+		return errs.PermissionDenied
+	}
+	return nil
+}
+
+// getContentTypeRoute returns the directory configured for the mimetype,
+// an exact match like "video/mp4" takes precedence over the family like "video"
+func getContentTypeRoute(mimetype string) (string, bool) {
+	routes := make(map[string]string)
+	if err := utils.Json.UnmarshalFromString(setting.GetStr(conf.UploadContentTypeRoutes, "{}"), &routes); err != nil {
+		log.Warnf("invalid %s: %+v", conf.UploadContentTypeRoutes, err)
+		return "", false
+	}
+	mimetype = strings.ToLower(strings.TrimSpace(strings.Split(mimetype, ";")[0]))
+	if dir, ok := routes[mimetype]; ok {
+		return dir, true
+	}
+	family, _, _ := strings.Cut(mimetype, "/")
+	dir, ok := routes[family]
+	return dir, ok
+}
+
+// applyAutoRoute rewrites the destination directory of the upload by its mimetype
+// when the client sends "Auto-Route: true", the file name is preserved
+func applyAutoRoute(c *gin.Context, user *model.User, path, mimetype string) (string, error) {
+	if c.GetHeader("Auto-Route") != "true" {
+		return path, nil
+	}
+	if mimetype == "" || strings.HasPrefix(mimetype, "application/octet-stream") {
+		mimetype = utils.GetMimeType(stdpath.Base(path))
+	}
+	dir, ok := getContentTypeRoute(mimetype)
+	if !ok {
+		return path, nil
+	}
+	dst, err := user.JoinPath(stdpath.Join(dir, stdpath.Base(path)))
+	if err != nil {
+		return "", err
+	}
+	if err = checkUploadPermission(c, user, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
@@ -0,0 +1,123 @@
+package handles
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// signedUploadHeaders are the headers of uploadHeaders a signed upload honors, the others are dropped
+// so the upload keeps its signed path and the default mode, e.g. without Overwrite rename,
+// Mirror-Paths, Target-Storage, Staged or Durability
+var signedUploadHeaders = []string{
+	"Last-Modified", "X-File-Size", "X-File-Md5", "X-File-Sha1", "X-File-Sha256", "Verify-Hash", "Password",
+	"Skip-Thumbnail", "Thumbnail-Width", "Thumbnail-Frames", "Thumbnail-Format", "Thumbnail-Preset",
+	"Accept-Version", "TE", "Strip-Exif",
+}
+
+// uploadSignData is what a signed upload URL signs, prefixed so it can't be mistaken
+// for the path signed by a download link
+func uploadSignData(username, path string, maxSize int64) string {
+	return "upload?" + url.Values{
+		"user":     {username},
+		"path":     {path},
+		"max_size": {strconv.FormatInt(maxSize, 10)},
+	}.Encode()
+}
+
+type SignUploadReq struct {
+	Path string `json:"path" binding:"required"`
+	// Expires is the lifetime of the URL in seconds
+	Expires int64 `json:"expires" binding:"required"`
+	// MaxSize caps the upload in bytes, 0 keeps only max_upload_size
+	MaxSize int64 `json:"max_size"`
+}
+
+type SignUploadResp struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SignUpload returns a URL anyone can PUT the file at path to, as the calling admin,
+// until it expires
+func SignUpload(c *gin.Context) {
+	var req SignUploadReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if req.Expires <= 0 || req.MaxSize < 0 {
+		common.ErrorStrResp(c, "expires must be > 0 and max_size >= 0", 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if _, err := user.JoinPath(req.Path); err != nil {
+		common.PathErrorResp(c, err, 403)
+		return
+	}
+	d := time.Duration(req.Expires) * time.Second
+	query := url.Values{
+		"user":     {user.Username},
+		"path":     {req.Path},
+		"max_size": {strconv.FormatInt(req.MaxSize, 10)},
+		"sign":     {sign.WithDuration(uploadSignData(user.Username, req.Path, req.MaxSize), d)},
+	}
+	common.SuccessResp(c, SignUploadResp{
+		URL:       fmt.Sprintf("%s/api/public/upload?%s", common.GetApiUrl(c), query.Encode()),
+		ExpiresAt: time.Now().Add(d),
+	})
+}
+
+// SignedUploadAuth authenticates a PUT to a signed upload URL as the user who signed it, the
+// signed path and size cap replace File-Path and bound the body. The upload then goes
+// through the permission checks of FsUp and FsStream like any other
+func SignedUploadAuth(c *gin.Context) {
+	username, path := c.Query("user"), c.Query("path")
+	maxSize, err := strconv.ParseInt(c.Query("max_size"), 10, 64)
+	if err != nil {
+		common.ErrorStrResp(c, "invalid max_size", 400)
+		c.Abort()
+		return
+	}
+	if err = sign.Verify(uploadSignData(username, path, maxSize), c.Query("sign")); err != nil {
+		common.ErrorResp(c, err, 401)
+		c.Abort()
+		return
+	}
+	user, err := op.GetUserByName(username)
+	if err != nil || user.Disabled {
+		common.ErrorResp(c, errors.New("signer is missing or disabled"), 401)
+		c.Abort()
+		return
+	}
+	if maxSize > 0 {
+		if c.Request.ContentLength < 0 {
+			common.ErrorStrResp(c, "Content-Length is required by this upload URL", 411)
+			c.Abort()
+			return
+		}
+		if c.Request.ContentLength > maxSize {
+			common.ErrorStrResp(c, fmt.Sprintf("upload larger than %d bytes", maxSize), 413)
+			c.Abort()
+			return
+		}
+	}
+	for _, h := range uploadHeaders {
+		if !slices.Contains(signedUploadHeaders, h.Name) {
+			c.Request.Header.Del(h.Name)
+		}
+	}
+	c.Request.Header.Set("File-Path", url.PathEscape(path))
+	common.GinWithValue(c, conf.UserKey, user)
+	c.Next()
+}
@@ -0,0 +1,76 @@
+package handles
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// parseMinFreeSpace parses conf.MinFreeSpace, bytes or a percentage of the total space
+// like 5%. Empty and 0 disable the check
+func parseMinFreeSpace(value string) (bytes int64, percent float64, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, 0, nil
+	}
+	if p, ok := strings.CutSuffix(value, "%"); ok {
+		percent, err = strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || percent < 0 || percent >= 100 {
+			return 0, 0, fmt.Errorf("invalid %s: %s, expected a percentage in [0, 100)", conf.MinFreeSpace, value)
+		}
+		return 0, percent, nil
+	}
+	bytes, err = strconv.ParseInt(value, 10, 64)
+	if err != nil || bytes < 0 {
+		return 0, 0, fmt.Errorf("invalid %s: %s, expected bytes or a percentage", conf.MinFreeSpace, value)
+	}
+	return bytes, 0, nil
+}
+
+func init() {
+	op.RegisterSettingItemHook(conf.MinFreeSpace, func(item *model.SettingItem) error {
+		_, _, err := parseMinFreeSpace(item.Value)
+		return err
+	})
+}
+
+// checkFreeSpace refuses with 507 an upload of size bytes which would leave less than
+// conf.MinFreeSpace free on the storage. Storages without the FreeSpace capability
+// are never refused, the reported space may be cached briefly
+func checkFreeSpace(c *gin.Context, storage driver.Driver, size int64) bool {
+	if !driver.GetCapabilities(storage).FreeSpace {
+		return true
+	}
+	reserve, percent, err := parseMinFreeSpace(setting.GetStr(conf.MinFreeSpace))
+	if err != nil {
+		log.Warnf("%+v", err)
+		return true
+	}
+	if reserve == 0 && percent == 0 {
+		return true
+	}
+	details, err := op.GetStorageDetails(c.Request.Context(), storage)
+	if err != nil || details.TotalSpace <= 0 {
+		return true
+	}
+	if percent > 0 {
+		reserve = int64(float64(details.TotalSpace) * percent / 100)
+	}
+	if size < 0 {
+		size = 0
+	}
+	if free := details.FreeSpace(); free-size < reserve {
+		common.ErrorStrResp(c, fmt.Sprintf("insufficient storage: %d bytes free, %d needed with the reserve", free, size+reserve), 507)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,403 @@
+package handles
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/cron"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// stagingDirName holds the staged uploads next to their destination,
+	// so committing them is a move within the storage
+	stagingDirName = ".staging"
+
+	StagedUploading = "uploading"
+	StagedVerified  = "verified"
+)
+
+// StagedUpload is an upload put aside until the client commits or aborts it,
+// items are kept in the data dir so they survive restarts
+type StagedUpload struct {
+	ID          string          `json:"id"`
+	Path        string          `json:"path"`
+	StagingPath string          `json:"staging_path"`
+	Mimetype    string          `json:"mimetype"`
+	Size        int64           `json:"size"`
+	Overwrite   bool            `json:"overwrite"`
+	Username    string          `json:"username"`
+	Status      string          `json:"status"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	Created     time.Time       `json:"created"`
+}
+
+var (
+	// stagingLock serializes changes of the staged uploads
+	stagingLock         sync.Mutex
+	stagingCleanerStart sync.Once
+)
+
+func stagingItemsDir() string {
+	return filepath.Join(flags.DataDir, "staging")
+}
+
+func stagingItemPath(id string) string {
+	return filepath.Join(stagingItemsDir(), id+".json")
+}
+
+// stagingTTL is how long an uncommitted upload is kept, 0 keeps it until it's committed or aborted
+func stagingTTL() time.Duration {
+	return time.Duration(max(setting.GetInt(conf.UploadStagingTTL, 86400), 0)) * time.Second
+}
+
+// stageUpload records the staged upload before it's put, nil when the upload isn't staged
+func stageUpload(c *gin.Context, mode uploadMode, path, mimetype string, user *model.User, mirrors []string, metadata json.RawMessage) (*StagedUpload, bool) {
+	if !mode.staged {
+		return nil, true
+	}
+	// mirrors would be live before the commit
+	if len(mirrors) > 0 {
+		common.ErrorStrResp(c, "Mirror-Paths can't be used with Staged", 400)
+		return nil, false
+	}
+	if needModeration(mimetype) {
+		common.ErrorStrResp(c, "Staged can't be used with uploads requiring moderation", 400)
+		return nil, false
+	}
+	id := uuid.NewString()
+	dir, name := stdpath.Split(path)
+	item := &StagedUpload{
+		ID:          id,
+		Path:        path,
+		StagingPath: stdpath.Join(dir, stagingDirName, id, name),
+		Mimetype:    mimetype,
+		Overwrite:   mode.overwrite,
+		Username:    user.Username,
+		Status:      StagedUploading,
+		Metadata:    metadata,
+		Created:     time.Now(),
+	}
+	stagingLock.Lock()
+	err := saveStagedUpload(item)
+	stagingLock.Unlock()
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return nil, false
+	}
+	c.Header("X-Upload-Staged-Id", item.ID)
+	return item, true
+}
+
+func saveStagedUpload(item *StagedUpload) error {
+	if err := os.MkdirAll(stagingItemsDir(), 0o700); err != nil {
+		return err
+	}
+	data, err := utils.Json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stagingItemPath(item.ID), data, 0o600)
+}
+
+func getStagedUpload(id string) (*StagedUpload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("staged upload %s not found", id)
+	}
+	data, err := os.ReadFile(stagingItemPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("staged upload %s not found", id)
+	}
+	var item StagedUpload
+	if err = utils.Json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func listStagedUploads() ([]StagedUpload, error) {
+	entries, err := os.ReadDir(stagingItemsDir())
+	if os.IsNotExist(err) {
+		return []StagedUpload{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	items := make([]StagedUpload, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if item, err := getStagedUpload(id); err == nil {
+			items = append(items, *item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Created.Before(items[j].Created)
+	})
+	return items, nil
+}
+
+// discardStagedUpload removes the staged upload of a failed upload, item may be nil
+func discardStagedUpload(ctx context.Context, item *StagedUpload) {
+	if item == nil {
+		return
+	}
+	if err := abortStagedUpload(ctx, item); err != nil {
+		log.Warnf("failed to remove staged upload %s: %+v", item.ID, err)
+	}
+}
+
+// hashFileContent computes the hashes of the object at path by reading it through
+func hashFileContent(ctx context.Context, path string, types []*utils.HashType) (map[*utils.HashType]string, error) {
+	link, obj, err := fs.Link(ctx, path, model.LinkArgs{})
+	if err != nil {
+		return nil, err
+	}
+	ss, err := stream.NewSeekableStream(&stream.FileStream{Obj: obj, Ctx: ctx}, link)
+	if err != nil {
+		_ = link.Close()
+		return nil, err
+	}
+	defer ss.Close()
+	reader, err := ss.RangeRead(http_range.Range{Length: -1})
+	if err != nil {
+		return nil, err
+	}
+	hasher := utils.NewMultiHasher(types)
+	if _, err = utils.CopyWithBuffer(hasher, reader); err != nil {
+		return nil, err
+	}
+	hashes := make(map[*utils.HashType]string, len(types))
+	for _, ht := range types {
+		sum, err := hasher.Sum(ht)
+		if err != nil {
+			return nil, err
+		}
+		hashes[ht] = hex.EncodeToString(sum)
+	}
+	return hashes, nil
+}
+
+// checkStagedUpload compares the staged file with the size, unless it's unknown (-1),
+// and the hashes sent with the upload. Hashes the storage doesn't report are computed
+func checkStagedUpload(ctx context.Context, item *StagedUpload, size int64, h map[*utils.HashType]string) error {
+	obj, err := fs.Get(ctx, item.StagingPath, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		return err
+	}
+	if size >= 0 && obj.GetSize() != size {
+		return fmt.Errorf("staged upload is %d bytes, %d expected", obj.GetSize(), size)
+	}
+	item.Size = obj.GetSize()
+	stored := make(map[*utils.HashType]string, len(h))
+	var missing []*utils.HashType
+	for ht := range h {
+		if sum := obj.GetHash().GetHash(ht); sum != "" {
+			stored[ht] = strings.ToLower(sum)
+		} else {
+			missing = append(missing, ht)
+		}
+	}
+	if len(missing) > 0 {
+		computed, err := hashFileContent(ctx, item.StagingPath, missing)
+		if err != nil {
+			return err
+		}
+		for ht, sum := range computed {
+			stored[ht] = sum
+		}
+	}
+	for ht, want := range h {
+		if stored[ht] != want {
+			return fmt.Errorf("%s of the staged upload is %s, %s expected", ht.Name, stored[ht], want)
+		}
+	}
+	return nil
+}
+
+// verifyStagedUpload marks the staged upload verified once it's put, otherwise it's removed
+// and 422 is responded
+func verifyStagedUpload(c *gin.Context, item *StagedUpload, size int64, h map[*utils.HashType]string) bool {
+	ctx := c.Request.Context()
+	if err := checkStagedUpload(ctx, item, size, h); err != nil {
+		if abortErr := abortStagedUpload(ctx, item); abortErr != nil {
+			log.Warnf("failed to remove staged upload %s: %+v", item.ID, abortErr)
+		}
+		common.ErrorResp(c, err, 422)
+		return false
+	}
+	stagingLock.Lock()
+	item.Status = StagedVerified
+	err := saveStagedUpload(item)
+	stagingLock.Unlock()
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return false
+	}
+	return true
+}
+
+// commitStagedUpload moves the staged upload to its destination
+func commitStagedUpload(ctx context.Context, item *StagedUpload) error {
+	stagingLock.Lock()
+	defer stagingLock.Unlock()
+	if _, err := getStagedUpload(item.ID); err != nil {
+		return err
+	}
+	if exist, _ := fs.Get(ctx, item.Path, &fs.GetArgs{NoLog: true}); exist != nil {
+		if !item.Overwrite {
+			return fmt.Errorf("%s already exists", item.Path)
+		}
+		kept, err := keepPreviousVersion(ctx, item.Path)
+		if err != nil {
+			return err
+		}
+		if kept == "" {
+			if err = fs.Remove(ctx, item.Path); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := fs.Move(ctx, item.StagingPath, stdpath.Dir(item.Path)); err != nil {
+		return err
+	}
+	_ = fs.Remove(ctx, stdpath.Dir(item.StagingPath))
+	_ = os.Remove(stagingItemPath(item.ID))
+	applyUploadFileMode(ctx, item.Path)
+	if err := storeUploadMetadata(ctx, item.Path, item.Metadata); err != nil {
+		log.Warnf("store metadata of %s error: %+v", item.Path, err)
+	}
+	pairLivePhoto(ctx, item.Path)
+	if user, err := op.GetUserByName(item.Username); err == nil {
+		scheduleThumbnail(item.Path, user, nil)
+	}
+	return nil
+}
+
+// abortStagedUpload removes the staged upload
+func abortStagedUpload(ctx context.Context, item *StagedUpload) error {
+	stagingLock.Lock()
+	defer stagingLock.Unlock()
+	if _, err := getStagedUpload(item.ID); err != nil {
+		return err
+	}
+	// nothing was put when the upload failed
+	if exist, _ := fs.Get(ctx, stdpath.Dir(item.StagingPath), &fs.GetArgs{NoLog: true}); exist != nil {
+		if err := fs.Remove(ctx, stdpath.Dir(item.StagingPath)); err != nil {
+			return err
+		}
+	}
+	return os.Remove(stagingItemPath(item.ID))
+}
+
+// cleanExpiredStagedUploads aborts the uploads left uncommitted for longer than stagingTTL
+func cleanExpiredStagedUploads() {
+	ttl := stagingTTL()
+	if ttl == 0 {
+		return
+	}
+	items, err := listStagedUploads()
+	if err != nil {
+		log.Warnf("failed to list staged uploads: %+v", err)
+		return
+	}
+	for i := range items {
+		if time.Since(items[i].Created) < ttl {
+			continue
+		}
+		if err = abortStagedUpload(context.Background(), &items[i]); err != nil {
+			log.Warnf("failed to remove expired staged upload %s: %+v", items[i].ID, err)
+		}
+	}
+}
+
+// InitStagingCleaner starts purging the staged uploads which expired
+func InitStagingCleaner() {
+	stagingCleanerStart.Do(func() {
+		cron.NewCron(10 * time.Minute).Do(cleanExpiredStagedUploads)
+	})
+}
+
+type StagedUploadReq struct {
+	ID string `json:"id" form:"id" binding:"required"`
+}
+
+// ListStagedUploads lists the staged uploads of the user, admins see those of everyone
+func ListStagedUploads(c *gin.Context) {
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	items, err := listStagedUploads()
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if !user.IsAdmin() {
+		own := items[:0]
+		for _, item := range items {
+			if item.Username == user.Username {
+				own = append(own, item)
+			}
+		}
+		items = own
+	}
+	common.SuccessResp(c, items)
+}
+
+func stagedUploadDecision(c *gin.Context, decide func(context.Context, *StagedUpload) error) {
+	var req StagedUploadReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	item, err := getStagedUpload(req.ID)
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	// the uploads of others are as good as missing
+	if err == nil && item.Username != user.Username && !user.IsAdmin() {
+		err = fmt.Errorf("staged upload %s not found", req.ID)
+	}
+	if err != nil {
+		common.ErrorResp(c, err, 404)
+		return
+	}
+	if item.Status == StagedUploading {
+		common.ErrorStrResp(c, "the upload hasn't finished yet", 409)
+		return
+	}
+	if err = decide(c.Request.Context(), item); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c)
+}
+
+// CommitStagedUpload moves a verified staged upload to its destination
+func CommitStagedUpload(c *gin.Context) {
+	stagedUploadDecision(c, commitStagedUpload)
+}
+
+// AbortStagedUpload deletes a staged upload
+func AbortStagedUpload(c *gin.Context) {
+	stagedUploadDecision(c, abortStagedUpload)
+}
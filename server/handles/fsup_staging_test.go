@@ -0,0 +1,88 @@
+package handles
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestStagedUpload(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	dataDir := flags.DataDir
+	flags.DataDir = t.TempDir()
+	t.Cleanup(func() { flags.DataDir = dataDir })
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/staged", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	upload := func(md5 string) (*httptest.ResponseRecorder, string) {
+		c, w := newUploadContext(t, strings.NewReader("hello"), "text/plain")
+		c.Request.Header.Set("File-Path", "/staged/a.txt")
+		c.Request.Header.Set("Staged", "true")
+		c.Request.Header.Set("X-File-Md5", md5)
+		FsStream(c)
+		return w, w.Header().Get("X-Upload-Staged-Id")
+	}
+	decide := func(handler gin.HandlerFunc, id, username string) *httptest.ResponseRecorder {
+		c, w := newUploadContext(t, strings.NewReader(`{"id":"`+id+`"}`), "application/json")
+		user := &model.User{Username: username, BasePath: "/"}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), conf.UserKey, user))
+		handler(c)
+		return w
+	}
+
+	t.Run("hash mismatch", func(t *testing.T) {
+		w, id := upload("00000000000000000000000000000000")
+		if code := respCode(t, w); code != 422 {
+			t.Fatalf("got code %d, want 422: %s", code, w.Body.String())
+		}
+		if _, err := getStagedUpload(id); err == nil {
+			t.Error("staged upload kept after failing verification")
+		}
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		w, id := upload("5d41402abc4b2a76b9719d911017c592")
+		if code := respCode(t, w); code != 200 {
+			t.Fatalf("got code %d, want 200: %s", code, w.Body.String())
+		}
+		if _, err := os.Stat(filepath.Join(root, "a.txt")); !os.IsNotExist(err) {
+			t.Fatalf("staged upload visible before the commit: %v", err)
+		}
+		if code := respCode(t, decide(CommitStagedUpload, id, "guest")); code != 404 {
+			t.Errorf("commit by another user got code %d, want 404", code)
+		}
+		if code := respCode(t, decide(CommitStagedUpload, id, "admin")); code != 200 {
+			t.Fatalf("commit got code %d, want 200", code)
+		}
+		if data, err := os.ReadFile(filepath.Join(root, "a.txt")); err != nil || string(data) != "hello" {
+			t.Errorf("file not committed: %q, %v", data, err)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		_, id := upload("5d41402abc4b2a76b9719d911017c592")
+		item, err := getStagedUpload(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := respCode(t, decide(AbortStagedUpload, id, "admin")); code != 200 {
+			t.Fatalf("abort got code %d, want 200", code)
+		}
+		staging := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(item.StagingPath, "/staged")))
+		if _, err = os.Stat(staging); !os.IsNotExist(err) {
+			t.Errorf("staged file left after abort: %v", err)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package handles
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// uploadPutContext returns the context the upload at path is put with. With a Target-Storage
+// header naming a storage id, the storage must be one serving path and it's used instead of
+// the balanced one
+func uploadPutContext(c *gin.Context, path string) (context.Context, bool) {
+	header := c.GetHeader("Target-Storage")
+	if header == "" {
+		return c.Request.Context(), true
+	}
+	id, err := strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		common.ErrorStrResp(c, "invalid Target-Storage: "+header, 400)
+		return nil, false
+	}
+	if _, _, err = op.GetStorageAndActualPathByID(path, uint(id)); err != nil {
+		common.ErrorResp(c, err, 400)
+		return nil, false
+	}
+	return context.WithValue(c.Request.Context(), conf.TargetStorageKey, uint(id)), true
+}
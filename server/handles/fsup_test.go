@@ -0,0 +1,644 @@
+package handles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/OpenListTeam/OpenList/v4/drivers/local"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMakeDirIdempotent(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/mkdir", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	storage, err := op.GetStorageByMountPath("/mkdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := MakeDir(ctx, "/mkdir/a/.thumbnails", true); err != nil {
+			t.Fatalf("call %d: unexpected error: %+v", i+1, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(root, "a", ".thumbnails")); err != nil || !info.IsDir() {
+		t.Fatalf("dir not created: %v", err)
+	}
+
+	// a file in the way is still a failure
+	if err := os.WriteFile(filepath.Join(root, "a", "file"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	op.Cache.DeleteDirectory(storage, "/a")
+	if err := MakeDir(ctx, "/mkdir/a/file", true); err == nil {
+		t.Error("expected error when a file occupies the path")
+	}
+}
+
+func newUploadContext(t *testing.T, body io.Reader, contentType string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := httptest.NewRequest(http.MethodPut, "/api/fs/put", body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	user := &model.User{Username: "admin", BasePath: "/", Role: model.ADMIN}
+	c.Request = req.WithContext(context.WithValue(req.Context(), conf.UserKey, user))
+	return c, w
+}
+
+func respCode(t *testing.T, w *httptest.ResponseRecorder) int {
+	var resp struct {
+		Code int `json:"code"`
+	}
+	if err := utils.Json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+	}
+	return resp.Code
+}
+
+func TestRejectEmptyUploads(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/empty", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	setReject := func(value string) {
+		if err := op.SaveSettingItem(&model.SettingItem{Key: conf.RejectEmptyUploads, Value: value, Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setReject("true")
+	t.Cleanup(func() { setReject("false") })
+
+	t.Run("stream", func(t *testing.T) {
+		c, w := newUploadContext(t, http.NoBody, "")
+		c.Request.Header.Set("File-Path", "/empty/stream.txt")
+		FsStream(c)
+		if code := respCode(t, w); code != 400 {
+			t.Errorf("got code %d, want 400", code)
+		}
+		if _, err := os.Stat(filepath.Join(root, "stream.txt")); !os.IsNotExist(err) {
+			t.Errorf("empty file created: %v", err)
+		}
+	})
+
+	t.Run("stream of unknown size", func(t *testing.T) {
+		c, w := newUploadContext(t, strings.NewReader(""), "")
+		c.Request.ContentLength = -1
+		c.Request.Header.Set("File-Path", "/empty/unknown.txt")
+		FsStream(c)
+		if code := respCode(t, w); code != 400 {
+			t.Errorf("got code %d, want 400", code)
+		}
+	})
+
+	t.Run("stream with content", func(t *testing.T) {
+		c, w := newUploadContext(t, strings.NewReader("hello"), "")
+		c.Request.Header.Set("File-Path", "/empty/hello.txt")
+		FsStream(c)
+		if code := respCode(t, w); code != 200 {
+			t.Fatalf("got code %d, want 200: %s", code, w.Body.String())
+		}
+		if data, err := os.ReadFile(filepath.Join(root, "hello.txt")); err != nil || string(data) != "hello" {
+			t.Errorf("file not uploaded: %q, %v", data, err)
+		}
+	})
+
+	t.Run("form", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		if _, err := mw.CreateFormFile("file", "form.txt"); err != nil {
+			t.Fatal(err)
+		}
+		_ = mw.Close()
+		c, w := newUploadContext(t, body, mw.FormDataContentType())
+		c.Request.Header.Set("File-Path", "/empty/form.txt")
+		FsForm(c)
+		if code := respCode(t, w); code != 400 {
+			t.Errorf("got code %d, want 400", code)
+		}
+		if _, err := os.Stat(filepath.Join(root, "form.txt")); !os.IsNotExist(err) {
+			t.Errorf("empty file created: %v", err)
+		}
+	})
+}
+
+func TestTruncatedUpload(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/truncated", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+
+	t.Run("short body", func(t *testing.T) {
+		c, w := newUploadContext(t, strings.NewReader("hello"), "")
+		c.Request.ContentLength = 10
+		c.Request.Header.Set("File-Path", "/truncated/short.txt")
+		FsStream(c)
+		if code := respCode(t, w); code != 400 {
+			t.Errorf("got code %d, want 400: %s", code, w.Body.String())
+		}
+		if _, err := os.Stat(filepath.Join(root, "short.txt")); !os.IsNotExist(err) {
+			t.Errorf("truncated file kept: %v", err)
+		}
+	})
+
+	t.Run("complete body", func(t *testing.T) {
+		c, w := newUploadContext(t, strings.NewReader("hello"), "")
+		c.Request.Header.Set("File-Path", "/truncated/complete.txt")
+		FsStream(c)
+		if code := respCode(t, w); code != 200 {
+			t.Fatalf("got code %d, want 200: %s", code, w.Body.String())
+		}
+	})
+}
+
+func TestRenameOnConflict(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/rename", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	first, releaseFirst, err := renameOnConflict(ctx, "/rename/a.txt")
+	if err != nil || first != "/rename/a (1).txt" {
+		t.Fatalf("got %s, %v, want /rename/a (1).txt", first, err)
+	}
+	// the first name is reserved while its upload runs
+	second, releaseSecond, err := renameOnConflict(ctx, "/rename/a.txt")
+	if err != nil || second != "/rename/a (2).txt" {
+		t.Fatalf("got %s, %v, want /rename/a (2).txt", second, err)
+	}
+	releaseFirst()
+	releaseSecond()
+
+	free, release, err := renameOnConflict(ctx, "/rename/b.txt")
+	if err != nil || free != "/rename/b.txt" {
+		t.Fatalf("got %s, %v, want /rename/b.txt", free, err)
+	}
+	release()
+}
+
+func TestUploadToDirectory(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/todir", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, overwrite := range []string{"true", "false"} {
+		t.Run("stream overwrite "+overwrite, func(t *testing.T) {
+			c, w := newUploadContext(t, strings.NewReader("hello"), "")
+			c.Request.Header.Set("File-Path", "/todir/dir")
+			c.Request.Header.Set("Overwrite", overwrite)
+			FsStream(c)
+			if code := respCode(t, w); code != 409 {
+				t.Errorf("got code %d, want 409: %s", code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("form", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		part, err := mw.CreateFormFile("file", "dir")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = part.Write([]byte("hello"))
+		_ = mw.Close()
+		c, w := newUploadContext(t, body, mw.FormDataContentType())
+		c.Request.Header.Set("File-Path", "/todir/dir")
+		FsForm(c)
+		if code := respCode(t, w); code != 409 {
+			t.Errorf("got code %d, want 409: %s", code, w.Body.String())
+		}
+	})
+
+	if info, err := os.Stat(filepath.Join(root, "dir")); err != nil || !info.IsDir() {
+		t.Errorf("directory replaced: %v", err)
+	}
+}
+
+func TestUploadFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes aren't supported on windows")
+	}
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/mode", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "shared"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(root, "shared"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	setMode := func(value string) {
+		if err := op.SaveSettingItem(&model.SettingItem{Key: conf.UploadFileMode, Value: value, Type: conf.TypeString, Group: model.UPLOAD, Flag: model.PRIVATE}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { setMode("") })
+
+	tests := []struct {
+		value string
+		path  string
+		want  os.FileMode
+	}{
+		{"0604", "/mode/a.txt", 0o604},
+		{"inherit", "/mode/shared/b.txt", 0o640},
+	}
+	for _, tt := range tests {
+		setMode(tt.value)
+		c, w := newUploadContext(t, strings.NewReader("hello"), "")
+		c.Request.Header.Set("File-Path", tt.path)
+		FsStream(c)
+		if code := respCode(t, w); code != 200 {
+			t.Fatalf("upload %s failed: %s", tt.path, w.Body.String())
+		}
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(tt.path, "/mode/"))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != tt.want {
+			t.Errorf("%s: mode %o, want %o", tt.path, info.Mode().Perm(), tt.want)
+		}
+	}
+}
+
+func TestQueryUpload(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/query", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	sum := utils.HashData(utils.SHA256, []byte("hello"))
+	c, w := newUploadContext(t, strings.NewReader("hello"), "application/x-www-form-urlencoded")
+	c.Request.URL.RawQuery = url.Values{"path": {"/query/a b.txt"}, "sha256": {sum}}.Encode()
+	UploadQueryHeaders(c)
+	if c.IsAborted() {
+		t.Fatalf("query rejected: %s", w.Body.String())
+	}
+	FsStream(c)
+	if code := respCode(t, w); code != 200 {
+		t.Fatalf("upload failed: %s", w.Body.String())
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "a b.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	c, w = newUploadContext(t, strings.NewReader("hello"), "")
+	UploadQueryHeaders(c)
+	if !c.IsAborted() || respCode(t, w) != 400 {
+		t.Errorf("upload without path accepted: %s", w.Body.String())
+	}
+}
+
+func TestTruncatedUploadErr(t *testing.T) {
+	body := &countingReader{Reader: strings.NewReader("hello")}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := truncatedUploadErr(10, body.n); err == nil {
+		t.Error("expected an error for 5 of 10 bytes")
+	}
+	for _, declared := range []int64{-1, 0, 5} {
+		if err := truncatedUploadErr(declared, body.n); err != nil {
+			t.Errorf("declared %d: unexpected error %v", declared, err)
+		}
+	}
+}
+
+func TestUploadMetadata(t *testing.T) {
+	cases := []struct {
+		header  string
+		wantErr bool
+	}{
+		{header: ""},
+		{header: `{"tags":["a","b"],"description":"x"}`},
+		{header: `["not", "an", "object"]`, wantErr: true},
+		{header: `{"broken":`, wantErr: true},
+		{header: `{"big":"` + strings.Repeat("x", maxUploadMetadataSize) + `"}`, wantErr: true},
+	}
+	for _, tc := range cases {
+		c, _ := newUploadContext(t, http.NoBody, "")
+		c.Request.Header.Set("X-Metadata", tc.header)
+		metadata, err := uploadMetadata(c, false)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%.40s: got error %v, want error %v", tc.header, err, tc.wantErr)
+		}
+		if err == nil && tc.header != "" && string(metadata) != tc.header {
+			t.Errorf("got metadata %s, want %s", metadata, tc.header)
+		}
+	}
+}
+
+func TestContentOnlyUpload(t *testing.T) {
+	mode := uploadMode{overwrite: true, contentOnly: true}
+	c, w := newUploadContext(t, http.NoBody, "")
+	if _, _, ok := contentOnlyUpload(c, mode, "/a.txt", nil, json.RawMessage(`{"a":1}`)); ok {
+		t.Error("metadata sent with content-only should be refused")
+	} else if code := respCode(t, w); code != 400 {
+		t.Errorf("got code %d, want 400", code)
+	}
+	c, _ = newUploadContext(t, http.NoBody, "")
+	preserved, metadata, ok := contentOnlyUpload(c, mode, "/a.txt", nil, nil)
+	if !ok || preserved != nil || metadata != nil {
+		t.Errorf("without an existing file nothing should be kept, got %+v and %s", preserved, metadata)
+	}
+	if !preserved.createTime().IsZero() {
+		t.Error("nothing preserved should leave the creation time unset")
+	}
+	metadata = json.RawMessage(`{"a":1}`)
+	if _, kept, ok := contentOnlyUpload(c, uploadMode{overwrite: true}, "/a.txt", nil, metadata); !ok || string(kept) != string(metadata) {
+		t.Errorf("other modes should keep the sent metadata, got %s", kept)
+	}
+}
+
+func TestLegacyUploadResp(t *testing.T) {
+	cases := []struct {
+		accept     string
+		status     int
+		wantStatus int
+		wantBody   string
+		written    bool
+	}{
+		{accept: "", status: http.StatusOK},
+		{accept: "application/json, text/plain, */*", status: http.StatusOK},
+		{accept: "text/plain", status: http.StatusOK, wantStatus: http.StatusOK, wantBody: "/a.txt", written: true},
+		{accept: "application/json;q=0.5, text/plain", status: http.StatusAccepted, wantStatus: http.StatusAccepted, wantBody: "/a.txt", written: true},
+	}
+	for _, tc := range cases {
+		c, w := newUploadContext(t, http.NoBody, "")
+		c.Request.Header.Set("Accept", tc.accept)
+		if written := writeLegacyUploadResp(c, tc.status, "/a.txt"); written != tc.written {
+			t.Errorf("%q: got written %v, want %v", tc.accept, written, tc.written)
+			continue
+		}
+		if tc.written && (w.Code != tc.wantStatus || w.Body.String() != tc.wantBody) {
+			t.Errorf("%q: got %d %q, want %d %q", tc.accept, w.Code, w.Body.String(), tc.wantStatus, tc.wantBody)
+		}
+	}
+}
+
+func TestUploadWithoutUser(t *testing.T) {
+	for name, handler := range map[string]gin.HandlerFunc{"stream": FsStream, "form": FsForm} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/api/fs/put", strings.NewReader("data"))
+		c.Request.Header.Set("File-Path", "/a.txt")
+		handler(c)
+		if code := respCode(t, w); code != 401 {
+			t.Errorf("%s: got code %d, want 401", name, code)
+		}
+	}
+}
+
+func TestSignedUploadAuthRejects(t *testing.T) {
+	data := uploadSignData("admin", "/signed/a.txt", 10)
+	valid := sign.WithDuration(data, time.Minute)
+	cases := map[string]string{
+		"tampered path": "user=admin&path=/signed/b.txt&max_size=10&sign=" + url.QueryEscape(valid),
+		"raised size":   "user=admin&path=/signed/a.txt&max_size=100&sign=" + url.QueryEscape(valid),
+		"expired":       "user=admin&path=/signed/a.txt&max_size=10&sign=" + url.QueryEscape(sign.WithDuration(data, -time.Minute)),
+		"download sign": "user=admin&path=/signed/a.txt&max_size=10&sign=" + url.QueryEscape(sign.WithDuration("/signed/a.txt", time.Minute)),
+	}
+	for name, query := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/api/public/upload?"+query, strings.NewReader("hello"))
+		SignedUploadAuth(c)
+		if !c.IsAborted() {
+			t.Errorf("%s: request not rejected", name)
+		}
+		if code := respCode(t, w); code != 401 {
+			t.Errorf("%s: got code %d, want 401", name, code)
+		}
+	}
+}
+
+func TestSignedUploadAuthHeaders(t *testing.T) {
+	if err := op.CreateUser(&model.User{Username: "signer", Role: model.ADMIN, BasePath: "/"}); err != nil {
+		t.Fatal(err)
+	}
+	query := url.Values{
+		"user":     {"signer"},
+		"path":     {"/signed/a.txt"},
+		"max_size": {"0"},
+		"sign":     {sign.WithDuration(uploadSignData("signer", "/signed/a.txt", 0), time.Minute)},
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/public/upload?"+query.Encode(), strings.NewReader("hello"))
+	for name, value := range map[string]string{
+		"File-Path":      "/other.txt",
+		"Overwrite":      "rename",
+		"Target-Storage": "1",
+		"Staged":         "true",
+		"Durability":     "buffered",
+		"X-File-Md5":     "5d41402abc4b2a76b9719d911017c592",
+	} {
+		c.Request.Header.Set(name, value)
+	}
+	SignedUploadAuth(c)
+	if c.IsAborted() {
+		t.Fatalf("signed upload rejected: %s", w.Body.String())
+	}
+	if got := c.GetHeader("File-Path"); got != url.PathEscape("/signed/a.txt") {
+		t.Errorf("File-Path is %s, want the signed path", got)
+	}
+	for _, name := range []string{"Overwrite", "Target-Storage", "Staged", "Durability"} {
+		if got := c.GetHeader(name); got != "" {
+			t.Errorf("%s: %s kept, want it dropped", name, got)
+		}
+	}
+	if c.GetHeader("X-File-Md5") == "" {
+		t.Error("X-File-Md5 dropped")
+	}
+}
+
+func TestResolveUploadMode(t *testing.T) {
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    uploadMode
+		wantErr bool
+	}{
+		{name: "default", want: uploadMode{overwrite: true}},
+		{name: "no overwrite", headers: map[string]string{"Overwrite": "false"}, want: uploadMode{}},
+		{name: "invalid overwrite", headers: map[string]string{"Overwrite": "yes"}, wantErr: true},
+		{name: "rename", headers: map[string]string{"Overwrite": "rename"}, want: uploadMode{rename: true}},
+		{name: "rename task", headers: map[string]string{"Overwrite": "rename", "As-Task": "true"}, wantErr: true},
+		{name: "content only", headers: map[string]string{"Overwrite": "content-only"}, want: uploadMode{overwrite: true, contentOnly: true}},
+		{name: "content only task", headers: map[string]string{"Overwrite": "content-only", "As-Task": "true"}, want: uploadMode{overwrite: true, contentOnly: true, asTask: true}},
+		{name: "task", headers: map[string]string{"As-Task": "true", "Overwrite": "false"}, want: uploadMode{asTask: true}},
+		{name: "immediate", headers: map[string]string{"Durability": "immediate"}, want: uploadMode{overwrite: true}},
+		{name: "buffered", headers: map[string]string{"Durability": "buffered"}, want: uploadMode{overwrite: true, asTask: true, durable: true}},
+		{name: "buffered task", headers: map[string]string{"Durability": "buffered", "As-Task": "true"}, want: uploadMode{overwrite: true, asTask: true, durable: true}},
+		{name: "buffered not task", headers: map[string]string{"Durability": "buffered", "As-Task": "false"}, wantErr: true},
+		{name: "invalid durability", headers: map[string]string{"Durability": "fsync"}, wantErr: true},
+		{name: "mirrors", headers: map[string]string{"Mirror-Paths": "/a"}, want: uploadMode{overwrite: true}},
+		{name: "mirrors task", headers: map[string]string{"Mirror-Paths": "/a", "As-Task": "true"}, wantErr: true},
+		{name: "mirrors buffered", headers: map[string]string{"Mirror-Paths": "/a", "Durability": "buffered"}, wantErr: true},
+		{name: "staged", headers: map[string]string{"Staged": "true"}, want: uploadMode{overwrite: true, staged: true}},
+		{name: "staged task", headers: map[string]string{"Staged": "true", "As-Task": "true"}, wantErr: true},
+		{name: "staged rename", headers: map[string]string{"Staged": "true", "Overwrite": "rename"}, wantErr: true},
+		{name: "invalid staged", headers: map[string]string{"Staged": "yes"}, wantErr: true},
+		{name: "if newer", headers: map[string]string{"Overwrite": "if-newer"}, want: uploadMode{overwrite: true, ifNewer: true}},
+		{name: "if changed task", headers: map[string]string{"Overwrite": "if-changed", "As-Task": "true"}, want: uploadMode{overwrite: true, ifChanged: true, asTask: true}},
+		{name: "dedupe", headers: map[string]string{"Dedupe": "true"}, want: uploadMode{overwrite: true, dedupe: true}},
+		{name: "dedupe rename", headers: map[string]string{"Dedupe": "true", "Overwrite": "rename"}, want: uploadMode{rename: true, dedupe: true}},
+		{name: "dedupe no overwrite", headers: map[string]string{"Dedupe": "true", "Overwrite": "false"}, wantErr: true},
+		{name: "dedupe staged", headers: map[string]string{"Dedupe": "true", "Staged": "true"}, wantErr: true},
+		{name: "invalid dedupe", headers: map[string]string{"Dedupe": "yes"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		header := http.Header{}
+		for k, v := range tc.headers {
+			header.Set(k, v)
+		}
+		mode, err := resolveUploadMode(header)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if err == nil && mode != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, mode, tc.want)
+		}
+	}
+}
+
+func TestUploadUnicodeNormalization(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/unicode", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	setForm := func(value string) {
+		if err := op.SaveSettingItem(&model.SettingItem{Key: conf.PathUnicodeNormalization, Value: value, Type: conf.TypeSelect, Group: model.UPLOAD, Flag: model.PRIVATE}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setForm("nfc")
+	t.Cleanup(func() { setForm("none") })
+	upload := func(name, overwrite string) int {
+		c, w := newUploadContext(t, strings.NewReader("hello"), "text/plain")
+		c.Request.Header.Set("File-Path", url.PathEscape("/unicode/"+name))
+		c.Request.Header.Set("Overwrite", overwrite)
+		FsStream(c)
+		return respCode(t, w)
+	}
+
+	nfc, nfd := "caf\u00e9.txt", "cafe\u0301.txt"
+	if code := upload(nfd, "false"); code != 200 {
+		t.Fatalf("got code %d, want 200", code)
+	}
+	// the composed name is the file the decomposed one created
+	if code := upload(nfc, "false"); code != 403 {
+		t.Errorf("got code %d, want 403 for the existing file", code)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != nfc {
+		t.Errorf("got %v, want the single file %q", entries, nfc)
+	}
+}
+
+func TestUploadSkippedByMode(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/skip", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	reset := func() {
+		path := filepath.Join(root, "a.txt")
+		if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	upload := func(body string, lastModified time.Time, headers map[string]string) bool {
+		reset()
+		c, w := newUploadContext(t, strings.NewReader(body), "text/plain")
+		c.Request.Header.Set("File-Path", "/skip/a.txt")
+		c.Request.Header.Set("Last-Modified", strconv.FormatInt(lastModified.UnixMilli(), 10))
+		for k, v := range headers {
+			c.Request.Header.Set(k, v)
+		}
+		FsStream(c)
+		if code := respCode(t, w); code != 200 {
+			t.Fatalf("%v: got code %d: %s", headers, code, w.Body.String())
+		}
+		return w.Header().Get("X-Upload-Skipped") == "true"
+	}
+
+	cases := []struct {
+		name         string
+		body         string
+		lastModified time.Time
+		headers      map[string]string
+		skipped      bool
+	}{
+		{"if-newer older", "world", modified.Add(-time.Hour), map[string]string{"Overwrite": "if-newer"}, true},
+		{"if-newer same time", "world", modified, map[string]string{"Overwrite": "if-newer"}, true},
+		{"if-newer newer", "world", modified.Add(time.Hour), map[string]string{"Overwrite": "if-newer"}, false},
+		{"if-changed same", "hello", modified, map[string]string{"Overwrite": "if-changed"}, true},
+		{"if-changed other size", "hello!", modified, map[string]string{"Overwrite": "if-changed"}, false},
+		{"if-changed other time", "hello", modified.Add(time.Hour), map[string]string{"Overwrite": "if-changed"}, false},
+		{"dedupe same", "hello", modified, map[string]string{"Dedupe": "true"}, true},
+		{"dedupe different", "world!", modified, map[string]string{"Dedupe": "true"}, false},
+	}
+	for _, tc := range cases {
+		if skipped := upload(tc.body, tc.lastModified, tc.headers); skipped != tc.skipped {
+			t.Errorf("%s: got skipped %v, want %v", tc.name, skipped, tc.skipped)
+		}
+		data, _ := os.ReadFile(filepath.Join(root, "a.txt"))
+		if kept := string(data) == "hello"; kept != (tc.skipped || tc.body == "hello") {
+			t.Errorf("%s: file is %q", tc.name, data)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package handles
+
+import (
+	"bytes"
+	stdpath "path"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+type TouchReq struct {
+	Path string `json:"path" form:"path"`
+	// Overwrite truncates an existing file, empty means conf.DefaultOverwrite
+	Overwrite string `json:"overwrite" form:"overwrite"`
+	// CreateParents creates missing parent dirs, otherwise the parent must exist
+	CreateParents bool `json:"create_parents" form:"create_parents"`
+}
+
+// FsTouch creates an empty file, e.g. a placeholder or a lock file
+func FsTouch(c *gin.Context) {
+	var req TouchReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	path, err := user.JoinPath(req.Path)
+	if err != nil {
+		common.PathErrorResp(c, err, 403)
+		return
+	}
+	if err = checkUploadPermission(c, user, path); err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	dir, name := stdpath.Split(path)
+	if name == "" {
+		common.ErrorStrResp(c, "file name is required", 400)
+		return
+	}
+	if shouldIgnoreSystemFile(name) {
+		common.ErrorStrResp(c, errs.IgnoredSystemFile.Error(), 403)
+		return
+	}
+	exist := probeExistence(c.Request.Context(), path)
+	if exist != nil {
+		if exist.IsDir() {
+			common.ErrorStrResp(c, "a folder exists at the path", 403)
+			return
+		}
+		if !resolveOverwrite(req.Overwrite) {
+			common.ErrorStrResp(c, "file exists", 403)
+			return
+		}
+	}
+	if !req.CreateParents {
+		parent, err := fs.Get(c.Request.Context(), dir, &fs.GetArgs{NoLog: true})
+		if err != nil {
+			common.ErrorResp(c, err, 404)
+			return
+		}
+		if !parent.IsDir() {
+			common.ErrorResp(c, errs.NotFolder, 400)
+			return
+		}
+	}
+	s := &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     0,
+			Modified: time.Now(),
+		},
+		Reader:   bytes.NewReader(nil),
+		Mimetype: utils.GetMimeType(name),
+	}
+	if err = fs.PutDirectly(c.Request.Context(), dir, s); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	obj, err := fs.Get(c.Request.Context(), path, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		// the driver may list the new file only after a while
+		obj = s.Obj
+	}
+	meta, _ := op.GetNearestMeta(dir)
+	common.SuccessResp(c, toObjsResp([]model.Obj{obj}, dir, isEncrypt(meta, dir))[0])
+}
@@ -0,0 +1,69 @@
+package handles
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// hashTrailers are sent after the response body of FsStream when the client sends "TE: trailers"
+var hashTrailers = map[string]*utils.HashType{
+	"X-Computed-Md5":    utils.MD5,
+	"X-Computed-Sha1":   utils.SHA1,
+	"X-Computed-Sha256": utils.SHA256,
+}
+
+// trailerHasher hashes the body while it's streamed into the storage
+type trailerHasher struct {
+	r      io.Reader
+	hasher *utils.MultiHasher
+	eof    bool
+}
+
+// newTrailerHasher returns nil unless the client accepts trailers, clients which
+// don't can't tell the difference
+func newTrailerHasher(c *gin.Context, body io.Reader) *trailerHasher {
+	if !strings.Contains(strings.ToLower(c.GetHeader("TE")), "trailers") {
+		return nil
+	}
+	return &trailerHasher{
+		r:      body,
+		hasher: utils.NewMultiHasher([]*utils.HashType{utils.MD5, utils.SHA1, utils.SHA256}),
+	}
+}
+
+func (h *trailerHasher) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	_, _ = h.hasher.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		h.eof = true
+	}
+	return n, err
+}
+
+// declare announces the trailers, it must be called before the response is written
+func (h *trailerHasher) declare(c *gin.Context) {
+	names := make([]string, 0, len(hashTrailers))
+	for name := range hashTrailers {
+		names = append(names, name)
+	}
+	c.Header("Trailer", strings.Join(names, ", "))
+}
+
+// send sets the trailers after the response is written, a body the storage didn't read
+// to the end has no meaningful digest, so the declared trailers are left empty
+func (h *trailerHasher) send(c *gin.Context) {
+	if !h.eof {
+		return
+	}
+	for name, ht := range hashTrailers {
+		sum, err := h.hasher.Sum(ht)
+		if err == nil {
+			c.Writer.Header().Set(name, hex.EncodeToString(sum))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handles
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+// countingReader counts the bytes read from the request body
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// truncatedUploadErr reports a body shorter than the declared size, an unknown or zero size
+// can't be checked
+func truncatedUploadErr(declared, received int64) error {
+	if declared <= 0 || received >= declared {
+		return nil
+	}
+	return fmt.Errorf("incomplete upload: received %d of %d bytes", received, declared)
+}
+
+// removeTruncatedUpload deletes the partial file committed at path when the body fell short
+// of the declared size, the returned error is the one to respond with
+func removeTruncatedUpload(ctx context.Context, path string, declared int64, body *countingReader) error {
+	err := truncatedUploadErr(declared, body.n)
+	if err == nil {
+		return nil
+	}
+	if rmErr := fs.Remove(ctx, path); rmErr != nil {
+		log.Errorf("failed to remove truncated upload %s: %+v", path, rmErr)
+	}
+	return err
+}
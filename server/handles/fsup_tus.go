@@ -0,0 +1,729 @@
+package handles
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/cron"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// tus resumable upload protocol 1.0.0, see https://tus.io/protocols/resumable-upload
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+)
+
+// TusUpload is persisted next to the partial data, so uploads survive restarts
+type TusUpload struct {
+	ID        string            `json:"id"`
+	Path      string            `json:"path"`
+	Size      int64             `json:"size"`
+	Username  string            `json:"username"`
+	Overwrite bool              `json:"overwrite"`
+	Hashes    map[string]string `json:"hashes"`
+	Modified  time.Time         `json:"modified"`
+	Created   time.Time         `json:"created"`
+	// LastActivity is refreshed by every chunk and keepalive, the upload expires conf.TusUploadTTL after it
+	LastActivity time.Time `json:"last_activity"`
+	// ChunkSize is negotiated at creation, 0 means unrestricted. MaxParallelism is 1,
+	// the PATCHes of an upload are applied one after another
+	ChunkSize      int64 `json:"chunk_size"`
+	MaxParallelism int   `json:"max_parallelism"`
+	// Offset is how much of the data is synced to disk, data past it wasn't acknowledged
+	Offset int64 `json:"offset"`
+}
+
+// TusCreateResp is the body of a created upload, the same values are sent as headers
+type TusCreateResp struct {
+	ID             string `json:"id"`
+	Location       string `json:"location"`
+	ChunkSize      int64  `json:"chunk_size"`
+	MaxParallelism int    `json:"max_parallelism"`
+	// Expires is when the upload is purged unless resumed, absent when uploads never expire
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// tusIDPattern accepts our uuids as well as ids assigned by clients with Upload-Id,
+// the id becomes part of a file name, so nothing else is allowed
+var tusIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,64}$`)
+
+// errTusCorrupt is returned for an upload whose data doesn't match its size or hashes,
+// it is discarded and has to be uploaded again
+var errTusCorrupt = errors.New("upload data is corrupt")
+
+// tusMutex serializes the requests of a single upload, refs counts its holder and waiters
+type tusMutex struct {
+	sync.Mutex
+	refs int
+}
+
+var (
+	// tusLocks holds the locks in use, one is dropped once nobody holds or waits for it,
+	// so all requests of an id share the same lock
+	tusLocks     = make(map[string]*tusMutex)
+	tusLocksLock sync.Mutex
+)
+
+var tusCleanerStart sync.Once
+
+func tusLock(id string) func() {
+	tusLocksLock.Lock()
+	l, ok := tusLocks[id]
+	if !ok {
+		l = &tusMutex{}
+		tusLocks[id] = l
+	}
+	l.refs++
+	tusLocksLock.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		tusLocksLock.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(tusLocks, id)
+		}
+		tusLocksLock.Unlock()
+	}
+}
+
+func tusDir() string {
+	return filepath.Join(conf.Conf.TempDir, "tus")
+}
+
+func tusInfoPath(id string) string {
+	return filepath.Join(tusDir(), id+".json")
+}
+
+func tusDataPath(id string) string {
+	return filepath.Join(tusDir(), id+".part")
+}
+
+func tusMaxSize() int64 {
+	return int64(setting.GetInt(conf.MaxUploadSize, 0))
+}
+
+func tusTTL() time.Duration {
+	return time.Duration(setting.GetInt(conf.TusUploadTTL, 86400)) * time.Second
+}
+
+// expires returns when the upload is purged, zero when uploads never expire
+func (u *TusUpload) expires() time.Time {
+	ttl := tusTTL()
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	last := u.LastActivity
+	if last.IsZero() {
+		last = u.Created
+	}
+	return last.Add(ttl)
+}
+
+func (u *TusUpload) expired(now time.Time) bool {
+	expires := u.expires()
+	return !expires.IsZero() && now.After(expires)
+}
+
+// touchTusUpload records activity on the upload, pushing back its expiry
+func touchTusUpload(upload *TusUpload) error {
+	upload.LastActivity = time.Now()
+	return saveTusUpload(upload)
+}
+
+// setTusExpires sends the expiry of the upload in Upload-Expires, as the expiration extension defines
+func setTusExpires(c *gin.Context, upload *TusUpload) {
+	if expires := upload.expires(); !expires.IsZero() {
+		c.Header("Upload-Expires", expires.UTC().Format(http.TimeFormat))
+	}
+}
+
+func setTusHeaders(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	if max := tusMaxSize(); max > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(max, 10))
+	}
+}
+
+// parseTusMetadata decodes "key base64value,key2 base64value2"
+func parseTusMetadata(s string) (map[string]string, error) {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, " ")
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata %s: %w", key, err)
+		}
+		meta[key] = string(decoded)
+	}
+	return meta, nil
+}
+
+// loadTusUpload reads the upload of the id param under its lock, the returned func releases it.
+// It responds 404 when the upload is missing or of another user and 410 when it expired
+func loadTusUpload(c *gin.Context) (*TusUpload, func(), bool) {
+	id := c.Param("id")
+	if !tusIDPattern.MatchString(id) {
+		c.Status(404)
+		return nil, nil, false
+	}
+	user, ok := uploadUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+	unlock := tusLock(id)
+	upload, err := readTusUpload(id)
+	if err != nil || upload.Username != user.Username {
+		unlock()
+		c.Status(404)
+		return nil, nil, false
+	}
+	if upload.expired(time.Now()) {
+		removeTusUpload(id)
+		unlock()
+		c.Status(410)
+		return nil, nil, false
+	}
+	return upload, unlock, true
+}
+
+func readTusUpload(id string) (*TusUpload, error) {
+	data, err := os.ReadFile(tusInfoPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var upload TusUpload
+	if err = utils.Json.Unmarshal(data, &upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// cleanExpiredTusUploads removes the uploads idle for longer than conf.TusUploadTTL
+func cleanExpiredTusUploads() {
+	if tusTTL() <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(tusDir())
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !tusIDPattern.MatchString(id) {
+			continue
+		}
+		unlock := tusLock(id)
+		// read again under the lock, a chunk may have arrived meanwhile
+		if upload, err := readTusUpload(id); err == nil && upload.expired(now) {
+			log.Infof("tus upload %s of %s expired", id, upload.Path)
+			removeTusUpload(id)
+		}
+		unlock()
+	}
+}
+
+// recoverTusUploads checks the uploads left by the previous run against their synced offset:
+// data written past it is cut off, uploads missing synced data are discarded
+func recoverTusUploads() {
+	entries, err := os.ReadDir(tusDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		// metadata being replaced when the previous run stopped
+		if strings.Contains(entry.Name(), ".json.") {
+			_ = os.Remove(filepath.Join(tusDir(), entry.Name()))
+			continue
+		}
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !tusIDPattern.MatchString(id) {
+			continue
+		}
+		unlock := tusLock(id)
+		if err = recoverTusUpload(id); err != nil {
+			log.Warnf("discard tus upload %s: %+v", id, err)
+			removeTusUpload(id)
+		}
+		unlock()
+	}
+}
+
+func recoverTusUpload(id string) error {
+	upload, err := readTusUpload(id)
+	if err != nil {
+		return err
+	}
+	offset, err := tusOffset(id)
+	if err != nil {
+		return err
+	}
+	if offset < upload.Offset {
+		return fmt.Errorf("%w: %d bytes on disk, %d synced", errTusCorrupt, offset, upload.Offset)
+	}
+	if offset > upload.Offset {
+		return os.Truncate(tusDataPath(id), upload.Offset)
+	}
+	return nil
+}
+
+// InitTusCleaner validates the uploads left by the previous run and starts purging the expired ones
+func InitTusCleaner() {
+	tusCleanerStart.Do(func() {
+		recoverTusUploads()
+		cron.NewCron(10 * time.Minute).Do(cleanExpiredTusUploads)
+	})
+}
+
+// saveTusUpload replaces the metadata atomically, a crash leaves either the old or the new one
+func saveTusUpload(upload *TusUpload) error {
+	data, err := utils.Json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(tusDir(), upload.ID+".json.*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), tusInfoPath(upload.ID))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+func removeTusUpload(id string) {
+	_ = os.Remove(tusDataPath(id))
+	_ = os.Remove(tusInfoPath(id))
+}
+
+func tusOffset(id string) (int64, error) {
+	info, err := os.Stat(tusDataPath(id))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func FsTusOptions(c *gin.Context) {
+	setTusHeaders(c)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Status(204)
+}
+
+// negotiateTusChunkSize takes the Upload-Chunk-Size requested by the client,
+// or the preferred conf.TusChunkSize when it's absent
+func negotiateTusChunkSize(c *gin.Context) (int64, error) {
+	requested := c.GetHeader("Upload-Chunk-Size")
+	if requested == "" {
+		return int64(setting.GetInt(conf.TusChunkSize, 0)), nil
+	}
+	size, err := strconv.ParseInt(requested, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid Upload-Chunk-Size")
+	}
+	return size, nil
+}
+
+// FsTusCreate creates an upload, the destination comes from the metadata "filepath",
+// or "filename" in the dir of the File-Path header. The id is generated unless
+// the client assigns one with Upload-Id
+func FsTusCreate(c *gin.Context) {
+	setTusHeaders(c)
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		common.ErrorStrResp(c, "invalid Upload-Length", 400)
+		return
+	}
+	if max := tusMaxSize(); max > 0 && size > max {
+		common.ErrorStrResp(c, "upload exceeds Tus-Max-Size", 413)
+		return
+	}
+	meta, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	path := meta["filepath"]
+	if path == "" {
+		if meta["filename"] == "" {
+			common.ErrorStrResp(c, "metadata filepath or filename is required", 400)
+			return
+		}
+		path = stdpath.Join(c.GetHeader("File-Path"), meta["filename"])
+	}
+	user, ok := uploadUser(c)
+	if !ok {
+		return
+	}
+	path, err = user.JoinUploadPath(normalizeUploadPath(path))
+	if err != nil {
+		common.PathErrorResp(c, err, 403)
+		return
+	}
+	if err = checkUploadPermission(c, user, path); err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	if shouldIgnoreSystemFile(stdpath.Base(path)) {
+		common.ErrorStrResp(c, errs.IgnoredSystemFile.Error(), 403)
+		return
+	}
+	if storage, err := fs.GetPutStorage(c.Request.Context(), path); err == nil && !checkFreeSpace(c, storage, size) {
+		return
+	}
+	id := c.GetHeader("Upload-Id")
+	if id == "" {
+		id = uuid.NewString()
+	} else if !tusIDPattern.MatchString(id) {
+		common.ErrorStrResp(c, "Upload-Id must be 16-64 chars of A-Z, a-z, 0-9, _ and -", 400)
+		return
+	}
+	chunkSize, err := negotiateTusChunkSize(c)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	overwrite := resolveOverwrite(meta["overwrite"])
+	if !overwrite {
+		if exist := probeExistence(c.Request.Context(), path); exist != nil {
+			common.ErrorStrResp(c, "file exists", 403)
+			return
+		}
+	}
+	upload := &TusUpload{
+		ID:        id,
+		Path:      path,
+		Size:      size,
+		Username:  user.Username,
+		Overwrite: overwrite,
+		Hashes:    make(map[string]string),
+		Modified:  getLastModified(c),
+		Created:   time.Now(),
+		ChunkSize: chunkSize,
+		// offsets of a single upload are strictly sequential
+		MaxParallelism: 1,
+	}
+	for _, ht := range []*utils.HashType{utils.MD5, utils.SHA1, utils.SHA256} {
+		v := meta[ht.Name]
+		if v == "" {
+			continue
+		}
+		normalized, err := utils.NormalizeHash(ht, v)
+		if err != nil {
+			if setting.GetBool(conf.IgnoreInvalidHash) {
+				log.Warnf("ignore %s of tus upload: %v", ht.Name, err)
+				continue
+			}
+			common.ErrorResp(c, err, 400)
+			return
+		}
+		upload.Hashes[ht.Name] = normalized
+	}
+	if ms, err := strconv.ParseInt(meta["lastmodified"], 10, 64); err == nil {
+		upload.Modified = time.UnixMilli(ms)
+	}
+	upload.LastActivity = upload.Created
+	if err = os.MkdirAll(tusDir(), 0o700); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	// the data file claims the id, a client assigned one may be sent twice at once
+	unlock := tusLock(upload.ID)
+	defer unlock()
+	f, err := os.OpenFile(tusDataPath(upload.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if os.IsExist(err) {
+		common.ErrorStrResp(c, "Upload-Id already exists", 409)
+		return
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if err = saveTusUpload(upload); err != nil {
+		removeTusUpload(upload.ID)
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	location := common.GetApiUrl(c) + "/api/fs/tus/" + upload.ID
+	c.Header("Location", location)
+	c.Header("Upload-Id", upload.ID)
+	c.Header("Upload-Chunk-Size", strconv.FormatInt(upload.ChunkSize, 10))
+	c.Header("Upload-Max-Parallelism", strconv.Itoa(upload.MaxParallelism))
+	setTusExpires(c, upload)
+	resp := TusCreateResp{
+		ID:             upload.ID,
+		Location:       location,
+		ChunkSize:      upload.ChunkSize,
+		MaxParallelism: upload.MaxParallelism,
+	}
+	if expires := upload.expires(); !expires.IsZero() {
+		resp.Expires = &expires
+	}
+	c.JSON(201, resp)
+}
+
+func FsTusHead(c *gin.Context) {
+	setTusHeaders(c)
+	c.Header("Cache-Control", "no-store")
+	upload, unlock, ok := loadTusUpload(c)
+	if !ok {
+		return
+	}
+	defer unlock()
+	offset, err := tusOffset(upload.ID)
+	if err != nil {
+		c.Status(404)
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	c.Header("Upload-Chunk-Size", strconv.FormatInt(upload.ChunkSize, 10))
+	c.Header("Upload-Max-Parallelism", strconv.Itoa(upload.MaxParallelism))
+	setTusExpires(c, upload)
+	c.Status(200)
+}
+
+// FsTusKeepalive refreshes the expiry of an upload without sending data,
+// for clients pausing longer than conf.TusUploadTTL between chunks
+func FsTusKeepalive(c *gin.Context) {
+	setTusHeaders(c)
+	upload, unlock, ok := loadTusUpload(c)
+	if !ok {
+		return
+	}
+	defer unlock()
+	if err := touchTusUpload(upload); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	setTusExpires(c, upload)
+	c.Status(204)
+}
+
+// FsTusPatch appends a chunk at Upload-Offset, the upload is put into
+// the storage once all of its data is received
+func FsTusPatch(c *gin.Context) {
+	setTusHeaders(c)
+	if c.ContentType() != "application/offset+octet-stream" {
+		common.ErrorStrResp(c, "Content-Type must be application/offset+octet-stream", 415)
+		return
+	}
+	upload, unlock, ok := loadTusUpload(c)
+	if !ok {
+		return
+	}
+	defer unlock()
+	release, ok := acquireUploadSlot(c, upload.Path)
+	if !ok {
+		return
+	}
+	defer release()
+	offset, err := tusOffset(upload.ID)
+	if err != nil {
+		c.Status(404)
+		return
+	}
+	if c.GetHeader("Upload-Offset") != strconv.FormatInt(offset, 10) {
+		common.ErrorStrResp(c, "Upload-Offset mismatch", 409)
+		return
+	}
+	// a slow chunk keeps the upload alive while it's received
+	if err = touchTusUpload(upload); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if err = checkTusChunkSize(upload, offset, c.Request.ContentLength); err != nil {
+		c.Header("Upload-Chunk-Size", strconv.FormatInt(upload.ChunkSize, 10))
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	f, err := os.OpenFile(tusDataPath(upload.ID), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	n, err := utils.CopyWithBuffer(f, io.LimitReader(c.Request.Body, upload.Size-offset))
+	// the received part is synced before its offset is recorded, even when the body broke off
+	if syncErr := f.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	offset += n
+	upload.Offset = offset
+	if touchErr := touchTusUpload(upload); touchErr != nil {
+		log.Warnf("failed to refresh tus upload %s: %+v", upload.ID, touchErr)
+		if err == nil {
+			err = touchErr
+		}
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	if offset < upload.Size {
+		setTusExpires(c, upload)
+	}
+	if err != nil {
+		// the received part is kept, the client resumes from the new offset
+		log.Warnf("tus upload %s interrupted at %d: %+v", upload.ID, offset, err)
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if offset == upload.Size {
+		if err = finishTusUpload(c, upload); errors.Is(err, errTusCorrupt) {
+			common.ErrorResp(c, err, 460)
+			return
+		} else if err != nil {
+			common.ErrorResp(c, err, 500)
+			return
+		}
+	}
+	c.Status(204)
+}
+
+// checkTusChunkSize requires every chunk but the last to be exactly the negotiated size,
+// the last one carries the rest of the upload
+func checkTusChunkSize(upload *TusUpload, offset, length int64) error {
+	if upload.ChunkSize <= 0 {
+		return nil
+	}
+	expected := min(upload.ChunkSize, upload.Size-offset)
+	if length != expected {
+		return fmt.Errorf("chunk size %d doesn't match the negotiated Upload-Chunk-Size %d, expected %d bytes at offset %d",
+			length, upload.ChunkSize, expected, offset)
+	}
+	return nil
+}
+
+// finishTusUpload puts the assembled upload into the storage. It's discarded once put or when it can't be,
+// after a failed put it's kept so the client retries with an empty PATCH at the final offset
+func finishTusUpload(c *gin.Context, upload *TusUpload) (err error) {
+	defer func() {
+		if err == nil || errors.Is(err, errTusCorrupt) || errors.Is(err, errs.ObjectAlreadyExists) {
+			removeTusUpload(upload.ID)
+		}
+	}()
+	if !upload.Overwrite {
+		if exist := probeExistence(c.Request.Context(), upload.Path); exist != nil {
+			return errs.ObjectAlreadyExists
+		}
+	}
+	f, err := os.Open(tusDataPath(upload.ID))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := make(map[*utils.HashType]string)
+	for _, ht := range []*utils.HashType{utils.MD5, utils.SHA1, utils.SHA256} {
+		if v := upload.Hashes[ht.Name]; v != "" {
+			h[ht] = v
+		}
+	}
+	if err = verifyTusData(f, upload.Size, h); err != nil {
+		return err
+	}
+	dir, name := stdpath.Split(upload.Path)
+	mimetype := utils.GetMimeType(name)
+	// uploads requiring moderation are held in quarantine like those of FsStream and FsForm
+	quarantine, err := holdUpload(c, upload.Path, mimetype, upload.Overwrite, upload.Username)
+	if err != nil {
+		return err
+	}
+	if quarantine != nil {
+		dir = stdpath.Dir(quarantine.QuarantinePath)
+	}
+	s := &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     upload.Size,
+			Modified: upload.Modified,
+			HashInfo: utils.NewHashInfoByMap(h),
+		},
+		Reader:   f,
+		Mimetype: mimetype,
+	}
+	if err = fs.PutDirectly(c.Request.Context(), dir, s); err != nil {
+		if quarantine != nil {
+			discardQuarantineItem(quarantine)
+		}
+		return err
+	}
+	if quarantine != nil {
+		startModeration(quarantine, s, nil, common.GetApiUrl(c))
+		return nil
+	}
+	applyUploadFileMode(c.Request.Context(), upload.Path)
+	return nil
+}
+
+// verifyTusData checks the assembled data against the size and hashes of the upload
+// before it's put into the storage, f is left at its start
+func verifyTusData(f *os.File, size int64, hashes map[*utils.HashType]string) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != size {
+		return fmt.Errorf("%w: size %d, expected %d", errTusCorrupt, info.Size(), size)
+	}
+	for ht, expected := range hashes {
+		actual, err := utils.HashFile(ht, f)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(actual, expected) {
+			return fmt.Errorf("%w: %s mismatch", errTusCorrupt, ht.Name)
+		}
+	}
+	return nil
+}
+
+func FsTusDelete(c *gin.Context) {
+	setTusHeaders(c)
+	upload, unlock, ok := loadTusUpload(c)
+	if !ok {
+		return
+	}
+	removeTusUpload(upload.ID)
+	unlock()
+	c.Status(204)
+}
@@ -0,0 +1,193 @@
+package handles
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckTusChunkSize(t *testing.T) {
+	upload := &TusUpload{Size: 25, ChunkSize: 10}
+	tests := []struct {
+		offset, length int64
+		ok             bool
+	}{
+		{0, 10, true},
+		{0, 9, false},
+		{10, 11, false},
+		{20, 5, true},
+		{20, 10, false},
+	}
+	for _, tt := range tests {
+		err := checkTusChunkSize(upload, tt.offset, tt.length)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkTusChunkSize(%d, %d) = %v, want ok=%v", tt.offset, tt.length, err, tt.ok)
+		}
+	}
+	if err := checkTusChunkSize(&TusUpload{Size: 25}, 0, 3); err != nil {
+		t.Errorf("unrestricted chunk size rejected: %v", err)
+	}
+}
+
+func TestCleanExpiredTusUploads(t *testing.T) {
+	tempDir := conf.Conf.TempDir
+	conf.Conf.TempDir = t.TempDir()
+	t.Cleanup(func() { conf.Conf.TempDir = tempDir })
+	if err := os.MkdirAll(tusDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	uploads := map[string]*TusUpload{
+		"idle-upload-0000000": {ID: "idle-upload-0000000", Created: now.Add(-72 * time.Hour), LastActivity: now.Add(-48 * time.Hour)},
+		"slow-upload-0000000": {ID: "slow-upload-0000000", Created: now.Add(-72 * time.Hour), LastActivity: now.Add(-time.Hour)},
+		"new-upload-00000000": {ID: "new-upload-00000000", Created: now},
+	}
+	for id, upload := range uploads {
+		if err := os.WriteFile(tusDataPath(id), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := saveTusUpload(upload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cleanExpiredTusUploads()
+	for id, kept := range map[string]bool{"idle-upload-0000000": false, "slow-upload-0000000": true, "new-upload-00000000": true} {
+		if _, err := os.Stat(tusInfoPath(id)); (err == nil) != kept {
+			t.Errorf("%s: expected kept=%v, got %v", id, kept, err)
+		}
+		if _, err := os.Stat(tusDataPath(id)); (err == nil) != kept {
+			t.Errorf("%s data: expected kept=%v, got %v", id, kept, err)
+		}
+	}
+	if expires := uploads["slow-upload-0000000"].expires(); !expires.Equal(now.Add(-time.Hour).Add(24 * time.Hour)) {
+		t.Errorf("unexpected expiry %s", expires)
+	}
+}
+
+func TestRecoverTruncatedTusUpload(t *testing.T) {
+	tempDir := conf.Conf.TempDir
+	conf.Conf.TempDir = t.TempDir()
+	t.Cleanup(func() { conf.Conf.TempDir = tempDir })
+	if err := os.MkdirAll(tusDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	uploads := map[string]struct {
+		synced int64
+		data   string
+	}{
+		"truncated-upload-00": {synced: 10, data: "01234"},     // crashed before the data reached the disk
+		"unacked-upload-0000": {synced: 5, data: "0123456789"}, // crashed before the offset was recorded
+		"intact-upload-00000": {synced: 10, data: "0123456789"},
+	}
+	for id, u := range uploads {
+		if err := os.WriteFile(tusDataPath(id), []byte(u.data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := saveTusUpload(&TusUpload{ID: id, Size: 10, Offset: u.synced, Created: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recoverTusUploads()
+	if _, err := os.Stat(tusInfoPath("truncated-upload-00")); !os.IsNotExist(err) {
+		t.Errorf("truncated upload kept: %v", err)
+	}
+	for id, want := range map[string]int64{"unacked-upload-0000": 5, "intact-upload-00000": 10} {
+		if offset, err := tusOffset(id); err != nil || offset != want {
+			t.Errorf("%s: offset %d, %v, want %d", id, offset, err, want)
+		}
+	}
+
+	f, err := os.Open(tusDataPath("intact-upload-00000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	md5 := utils.HashData(utils.MD5, []byte("0123456789"))
+	if err = verifyTusData(f, 10, map[*utils.HashType]string{utils.MD5: md5}); err != nil {
+		t.Errorf("intact data rejected: %v", err)
+	}
+	if err = verifyTusData(f, 10, map[*utils.HashType]string{utils.MD5: utils.HashData(utils.MD5, []byte("x"))}); !errors.Is(err, errTusCorrupt) {
+		t.Errorf("hash mismatch not detected: %v", err)
+	}
+	if err = verifyTusData(f, 11, nil); !errors.Is(err, errTusCorrupt) {
+		t.Errorf("size mismatch not detected: %v", err)
+	}
+}
+
+func TestFinishTusUploadKeepsRetryable(t *testing.T) {
+	tempDir := conf.Conf.TempDir
+	conf.Conf.TempDir = t.TempDir()
+	t.Cleanup(func() { conf.Conf.TempDir = tempDir })
+	if err := os.MkdirAll(tusDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	finish := func(id string, hashes map[string]string) error {
+		if err := os.WriteFile(tusDataPath(id), []byte("0123456789"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		upload := &TusUpload{ID: id, Path: "/missing-storage/a.txt", Size: 10, Offset: 10, Overwrite: true, Hashes: hashes, Created: time.Now()}
+		if err := saveTusUpload(upload); err != nil {
+			t.Fatal(err)
+		}
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPatch, "/api/fs/tus/"+id, nil)
+		return finishTusUpload(c, upload)
+	}
+
+	// the put fails but may succeed when retried
+	if err := finish("failed-put-0000000", nil); err == nil || errors.Is(err, errTusCorrupt) {
+		t.Fatalf("got %v, want a failed put", err)
+	}
+	if offset, err := tusOffset("failed-put-0000000"); err != nil || offset != 10 {
+		t.Errorf("upload of a failed put discarded: %d, %v", offset, err)
+	}
+	if _, err := readTusUpload("failed-put-0000000"); err != nil {
+		t.Errorf("metadata of a failed put discarded: %v", err)
+	}
+
+	if err := finish("corrupt-upload-000", map[string]string{"md5": utils.HashData(utils.MD5, []byte("x"))}); !errors.Is(err, errTusCorrupt) {
+		t.Fatalf("got %v, want errTusCorrupt", err)
+	}
+	if _, err := os.Stat(tusInfoPath("corrupt-upload-000")); !os.IsNotExist(err) {
+		t.Errorf("corrupt upload kept: %v", err)
+	}
+}
+
+func TestFsTusCreateDuplicateID(t *testing.T) {
+	tempDir := conf.Conf.TempDir
+	conf.Conf.TempDir = t.TempDir()
+	t.Cleanup(func() { conf.Conf.TempDir = tempDir })
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": t.TempDir()})
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: "Local", MountPath: "/tus-create", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	create := func() *httptest.ResponseRecorder {
+		c, w := newUploadContext(t, nil, "")
+		c.Request.Method = http.MethodPost
+		c.Request.Header.Set("Upload-Length", "5")
+		c.Request.Header.Set("Upload-Metadata", "filepath "+base64.StdEncoding.EncodeToString([]byte("/tus-create/a.txt")))
+		c.Request.Header.Set("Upload-Id", "client-assigned-id-01")
+		FsTusCreate(c)
+		return w
+	}
+	if w := create(); w.Code != 201 {
+		t.Fatalf("got status %d, want 201: %s", w.Code, w.Body.String())
+	}
+	if code := respCode(t, create()); code != 409 {
+		t.Errorf("got code %d, want 409 for a taken Upload-Id", code)
+	}
+	if upload, err := readTusUpload("client-assigned-id-01"); err != nil || upload.MaxParallelism != 1 {
+		t.Errorf("upload of the first create lost: %+v, %v", upload, err)
+	}
+}
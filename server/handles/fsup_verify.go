@@ -0,0 +1,138 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"io"
+	stdpath "path"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// uploadErrCode is 400 for a body failing the hash verification, code otherwise
+func uploadErrCode(err error, code int) int {
+	var mismatch *utils.HashMismatchError
+	if errors.As(err, &mismatch) {
+		return 400
+	}
+	return code
+}
+
+// verifyHashRequested reports whether the body of the upload is checked against its hash headers,
+// "Verify-Hash: true" or "false" overrides conf.VerifyUploadHash
+func verifyHashRequested(c *gin.Context) bool {
+	switch c.GetHeader("Verify-Hash") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return setting.GetBool(conf.VerifyUploadHash)
+}
+
+func hashTypes(h map[*utils.HashType]string) []*utils.HashType {
+	types := make([]*utils.HashType, 0, len(h))
+	for ht := range h {
+		types = append(types, ht)
+	}
+	return types
+}
+
+// hashVerifier hashes the body of FsStream while it's read and fails the read completing it,
+// at the declared size or at EOF, when it doesn't match the hash headers. So the storage
+// sees an error instead of the end of a corrupted body
+type hashVerifier struct {
+	r       io.Reader
+	h       map[*utils.HashType]string
+	hasher  *utils.MultiHasher
+	size    int64
+	n       int64
+	checked bool
+	err     error
+}
+
+// newHashVerifier returns nil unless the verification is requested and a hash header is sent.
+// A task verifies its cached body itself, see verifyUploadTask
+func newHashVerifier(c *gin.Context, body io.Reader, size int64, h map[*utils.HashType]string, asTask bool) *hashVerifier {
+	if asTask || len(h) == 0 || !verifyHashRequested(c) {
+		return nil
+	}
+	return &hashVerifier{r: body, h: h, hasher: utils.NewMultiHasher(hashTypes(h)), size: size}
+}
+
+func (v *hashVerifier) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.r.Read(p)
+	_, _ = v.hasher.Write(p[:n])
+	v.n += int64(n)
+	if errors.Is(err, io.EOF) || v.size > 0 && v.n >= v.size {
+		v.checked = true
+		if v.err = v.hasher.Verify(v.h); v.err != nil {
+			return n, v.err
+		}
+	}
+	return n, err
+}
+
+// finish checks a body the storage didn't read to its end, e.g. when it found the content by its hash
+func (v *hashVerifier) finish() error {
+	if !v.checked {
+		_, _ = utils.CopyWithBuffer(io.Discard, v)
+	}
+	if !v.checked {
+		v.checked = true
+		v.err = v.hasher.Verify(v.h)
+	}
+	return v.err
+}
+
+// verifyUploadFile checks the file of FsForm against the hash headers before it's put,
+// rewinds it and returns the computed hashes. It returns h unless the verification is requested
+func verifyUploadFile(c *gin.Context, f io.ReadSeeker, h map[*utils.HashType]string) (map[*utils.HashType]string, error) {
+	if len(h) == 0 || !verifyHashRequested(c) {
+		return h, nil
+	}
+	hasher := utils.NewMultiHasher(hashTypes(h))
+	if _, err := utils.CopyWithBuffer(hasher, f); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := hasher.Verify(h); err != nil {
+		return nil, err
+	}
+	return hasher.GetHashInfo().Export(), nil
+}
+
+// verifyUploadTask makes the task of the upload check its cached body against the hash headers
+// when the verification is requested, a mismatch then fails the task
+func verifyUploadTask(c *gin.Context, ctx context.Context, h map[*utils.HashType]string) context.Context {
+	if len(h) == 0 || !verifyHashRequested(c) {
+		return ctx
+	}
+	return context.WithValue(ctx, conf.VerifyHashKey, true)
+}
+
+// removeUnverifiedUpload deletes what the put of a body failing the verification left at path.
+// An overwritten file is restored from its kept version, without one it's already lost
+func removeUnverifiedUpload(ctx context.Context, path, version string) {
+	if obj, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true}); err == nil && !obj.IsDir() {
+		if err = fs.Remove(ctx, path); err != nil {
+			log.Errorf("failed to remove unverified upload %s: %+v", path, err)
+			return
+		}
+	}
+	if version != "" {
+		if err := fs.Rename(ctx, stdpath.Join(stdpath.Dir(path), version), stdpath.Base(path)); err != nil {
+			log.Errorf("failed to restore %s from its version %s: %+v", path, version, err)
+		}
+	}
+}
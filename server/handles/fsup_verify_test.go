@@ -0,0 +1,90 @@
+package handles
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+func TestHashVerifier(t *testing.T) {
+	h := map[*utils.HashType]string{utils.MD5: "00000000000000000000000000000000"}
+	// a storage reading exactly the declared size never sees EOF
+	v := &hashVerifier{r: strings.NewReader("hello"), h: h, hasher: utils.NewMultiHasher(hashTypes(h)), size: 5}
+	if _, err := io.ReadFull(v, make([]byte, 5)); uploadErrCode(err, 500) != 400 {
+		t.Errorf("mismatch not reported by the last read: %v", err)
+	}
+	h = map[*utils.HashType]string{utils.SHA1: "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"}
+	v = &hashVerifier{r: strings.NewReader("hello"), h: h, hasher: utils.NewMultiHasher(hashTypes(h)), size: -1}
+	if err := v.finish(); err != nil {
+		t.Errorf("unread body failed the verification: %v", err)
+	}
+}
+
+func TestVerifyUploadHash(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/verify", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	upload := func(name, md5, verify string) (int, string) {
+		c, w := newUploadContext(t, strings.NewReader("hello"), "text/plain")
+		c.Request.Header.Set("File-Path", "/verify/"+name)
+		c.Request.Header.Set("X-File-Md5", md5)
+		c.Request.Header.Set("Verify-Hash", verify)
+		c.Request.Header.Set("Overwrite", "true")
+		FsStream(c)
+		return respCode(t, w), w.Body.String()
+	}
+
+	if code, body := upload("bad.txt", "00000000000000000000000000000000", "true"); code != 400 || !strings.Contains(body, "md5 mismatch") {
+		t.Errorf("got code %d, want 400 reporting the md5 mismatch: %s", code, body)
+	}
+	if _, err := os.Stat(filepath.Join(root, "bad.txt")); !os.IsNotExist(err) {
+		t.Errorf("unverified upload kept: %v", err)
+	}
+	if code, body := upload("good.txt", "5d41402abc4b2a76b9719d911017c592", "true"); code != 200 {
+		t.Errorf("got code %d, want 200: %s", code, body)
+	}
+
+	// without a kept version the overwritten file is already lost, the upload is removed anyway
+	if err := os.WriteFile(filepath.Join(root, "lost.txt"), []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := upload("lost.txt", "00000000000000000000000000000000", "true"); code != 400 {
+		t.Errorf("got code %d, want 400: %s", code, body)
+	}
+	if _, err := os.Stat(filepath.Join(root, "lost.txt")); !os.IsNotExist(err) {
+		t.Errorf("unverified upload over a file which wasn't kept left in place: %v", err)
+	}
+
+	// an overwritten file is restored from its kept version
+	setKeep := func(value string) {
+		if err := op.SaveSettingItem(&model.SettingItem{Key: conf.KeepPreviousVersions, Value: value, Type: conf.TypeBool, Group: model.UPLOAD, Flag: model.PRIVATE}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setKeep("true")
+	t.Cleanup(func() { setKeep("false") })
+	if err := os.WriteFile(filepath.Join(root, "kept.txt"), []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := upload("kept.txt", "00000000000000000000000000000000", "true"); code != 400 {
+		t.Errorf("got code %d, want 400: %s", code, body)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "kept.txt")); err != nil || string(data) != "previous" {
+		t.Errorf("previous file not restored: %q, %v", data, err)
+	}
+	// verification is opt-in
+	if code, body := upload("unchecked.txt", "00000000000000000000000000000000", "false"); code != 200 {
+		t.Errorf("got code %d, want 200: %s", code, body)
+	}
+}
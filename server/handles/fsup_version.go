@@ -0,0 +1,41 @@
+package handles
+
+import (
+	"context"
+	stdpath "path"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+// defaultVersionNameTemplate names versions like "a.txt.bak.1"
+const defaultVersionNameTemplate = "{name}{ext}.bak.{seq}"
+
+// keepPreviousVersion renames the file at path by conf.VersionNameTemplate before it's overwritten,
+// it returns the name of the version, empty when there was no file to keep.
+// Nothing is done unless conf.KeepPreviousVersions is enabled
+func keepPreviousVersion(ctx context.Context, path string) (string, error) {
+	if !setting.GetBool(conf.KeepPreviousVersions) {
+		return "", nil
+	}
+	exist, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+	if err != nil || exist.IsDir() {
+		return "", nil
+	}
+	dir, name := stdpath.Split(path)
+	template := setting.GetStr(conf.VersionNameTemplate, defaultVersionNameTemplate)
+	versionName, err := utils.NextVersionName(template, name, time.Now(), func(versionName string) bool {
+		obj, _ := fs.Get(ctx, stdpath.Join(dir, versionName), &fs.GetArgs{NoLog: true})
+		return obj != nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if err = fs.Rename(ctx, path, versionName); err != nil {
+		return "", err
+	}
+	return versionName, nil
+}
@@ -0,0 +1,135 @@
+package handles
+
+import (
+	"context"
+	stdpath "path"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// the extensions of the halves of a live photo, paired when they share their directory and name,
+// e.g. IMG_0001.HEIC and IMG_0001.MOV
+var (
+	livePhotoStillExts  = []string{".heic", ".heif", ".jpg", ".jpeg"}
+	livePhotoMotionExts = []string{".mov"}
+)
+
+// LivePhoto links the still and the motion of a live photo, by their names in the directory
+type LivePhoto struct {
+	Still  string `json:"still"`
+	Motion string `json:"motion"`
+}
+
+// livePhotoPart returns the name without the extension and which half of a live photo the file may be
+func livePhotoPart(name string) (base string, still, motion bool) {
+	ext := stdpath.Ext(name)
+	lower := strings.ToLower(ext)
+	return strings.TrimSuffix(name, ext), utils.SliceContains(livePhotoStillExts, lower), utils.SliceContains(livePhotoMotionExts, lower)
+}
+
+// findLivePhotoPair looks for the other half of the live photo at filePath, its extension in either case
+func findLivePhotoPair(ctx context.Context, filePath string) *LivePhoto {
+	dir, name := stdpath.Split(filePath)
+	base, still, motion := livePhotoPart(name)
+	var candidates []string
+	switch {
+	case still:
+		candidates = livePhotoMotionExts
+	case motion:
+		candidates = livePhotoStillExts
+	default:
+		return nil
+	}
+	for _, ext := range candidates {
+		for _, e := range []string{ext, strings.ToUpper(ext)} {
+			obj, err := fs.Get(ctx, stdpath.Join(dir, base+e), &fs.GetArgs{NoLog: true})
+			if err != nil || obj.IsDir() {
+				continue
+			}
+			if still {
+				return &LivePhoto{Still: name, Motion: obj.GetName()}
+			}
+			return &LivePhoto{Still: obj.GetName(), Motion: name}
+		}
+	}
+	return nil
+}
+
+// pairLivePhoto records the live photo in the sidecars of both halves once the second one is uploaded,
+// when conf.LivePhotos is enabled
+func pairLivePhoto(ctx context.Context, filePath string) {
+	if !setting.GetBool(conf.LivePhotos) {
+		return
+	}
+	pair := findLivePhotoPair(ctx, filePath)
+	if pair == nil {
+		return
+	}
+	dir := stdpath.Dir(filePath)
+	for _, name := range []string{pair.Still, pair.Motion} {
+		path := stdpath.Join(dir, name)
+		sidecar, err := readMediaSidecar(ctx, path)
+		if err != nil {
+			sidecar = &MediaSidecar{}
+		}
+		sidecar.LivePhoto = pair
+		if err = writeMediaSidecar(ctx, path, sidecar); err != nil {
+			logrus.Printf("保存实况照片配对失败: %v", err)
+		}
+	}
+}
+
+// livePhotoStill returns the path of the still of the live photo whose motion is filePath.
+// The motion gets no thumbnail of its own, the one of the still is served for it
+func livePhotoStill(ctx context.Context, filePath string) (string, bool) {
+	if !setting.GetBool(conf.LivePhotos) {
+		return "", false
+	}
+	if _, _, motion := livePhotoPart(stdpath.Base(filePath)); !motion {
+		return "", false
+	}
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil || sidecar.LivePhoto == nil || sidecar.LivePhoto.Motion != stdpath.Base(filePath) {
+		return "", false
+	}
+	return stdpath.Join(stdpath.Dir(filePath), sidecar.LivePhoto.Still), true
+}
+
+// livePhotos sets the live photo of the stills and motions of a listing, when conf.LivePhotos is enabled
+func livePhotos(content []ObjResp) {
+	if setting.GetBool(conf.LivePhotos) {
+		pairLivePhotos(content)
+	}
+}
+
+// pairLivePhotos pairs the halves of the live photos of a listing by name, so no sidecar is read.
+// A pair split across the pages of a paginated listing is missed
+func pairLivePhotos(content []ObjResp) {
+	stills := make(map[string]int)
+	motions := make(map[string]int)
+	for i, obj := range content {
+		if obj.IsDir {
+			continue
+		}
+		base, still, motion := livePhotoPart(obj.Name)
+		key := strings.ToLower(base)
+		if _, ok := stills[key]; still && !ok {
+			stills[key] = i
+		} else if _, ok := motions[key]; motion && !ok {
+			motions[key] = i
+		}
+	}
+	for key, m := range motions {
+		s, ok := stills[key]
+		if !ok {
+			continue
+		}
+		pair := &LivePhoto{Still: content[s].Name, Motion: content[m].Name}
+		content[s].LivePhoto, content[m].LivePhoto = pair, pair
+	}
+}
@@ -0,0 +1,29 @@
+package handles
+
+import "testing"
+
+func TestPairLivePhotos(t *testing.T) {
+	content := []ObjResp{
+		{Name: "IMG_0001.HEIC"},
+		{Name: "IMG_0001.MOV"},
+		{Name: "IMG_0002.jpg"},
+		{Name: "IMG_0003.mov"},
+		{Name: "IMG_0004.mp4"},
+		{Name: "IMG_0004.heic"},
+		{Name: "IMG_0005.mov", IsDir: true},
+		{Name: "IMG_0005.heic"},
+	}
+	pairLivePhotos(content)
+	pair := content[0].LivePhoto
+	if pair == nil || pair.Still != "IMG_0001.HEIC" || pair.Motion != "IMG_0001.MOV" {
+		t.Fatalf("IMG_0001 pair = %+v", pair)
+	}
+	if content[1].LivePhoto != pair {
+		t.Errorf("motion of IMG_0001 isn't paired with its still")
+	}
+	for _, obj := range content[2:] {
+		if obj.LivePhoto != nil {
+			t.Errorf("%s paired with %+v, want none", obj.Name, obj.LivePhoto)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package handles
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultTempPurgeAge is the age temp files must reach to be purged when older_than isn't given,
+// long enough for files of running uploads and thumbnail generations to be kept
+const defaultTempPurgeAge = 24 * time.Hour
+
+// tempFilePattern is a kind of temp file written by uploads and thumbnail generations,
+// left behind when the process crashed while using it
+type tempFilePattern struct {
+	dir     func() string
+	pattern string
+}
+
+func confTempDir() string {
+	return conf.Conf.TempDir
+}
+
+// tempFilePatterns doesn't list tus and archive upload sessions, they expire by themselves
+var tempFilePatterns = []tempFilePattern{
+	{os.TempDir, "video_thumb_*"},
+	{os.TempDir, "image_thumb_*"},
+	{os.TempDir, "cover_thumb_*"},
+	{os.TempDir, "video_source_*"},
+	{os.TempDir, "video_head_*"},
+	{os.TempDir, "video_sub_*"},
+	{os.TempDir, "video_verify_*"},
+	{os.TempDir, "filmstrip_*"},
+	{os.TempDir, "poster_*"},
+	{confTempDir, "file-*"},
+	{confTempDir, "durable_upload_*"},
+	{confTempDir, "mirror_upload_*"},
+	{confTempDir, "archive_entry_*"},
+}
+
+type TempFileResp struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Age      int64     `json:"age"` // seconds
+}
+
+// listTempFiles returns the leftover temp files matching the known patterns
+func listTempFiles() []TempFileResp {
+	now := time.Now()
+	files := make([]TempFileResp, 0)
+	seen := make(map[string]struct{})
+	for _, p := range tempFilePatterns {
+		matches, err := filepath.Glob(filepath.Join(p.dir(), p.pattern))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			if _, ok := seen[match]; ok {
+				continue
+			}
+			seen[match] = struct{}{}
+			info, err := os.Lstat(match)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			files = append(files, TempFileResp{
+				Path:     match,
+				Size:     info.Size(),
+				Modified: info.ModTime(),
+				Age:      int64(now.Sub(info.ModTime()).Seconds()),
+			})
+		}
+	}
+	return files
+}
+
+// ListTempFiles lists the temp files left by failed uploads and thumbnail generations
+func ListTempFiles(c *gin.Context) {
+	common.SuccessResp(c, listTempFiles())
+}
+
+// olderThanQuery parses the older_than query, in seconds, responding 400 when it's invalid
+func olderThanQuery(c *gin.Context, def time.Duration) (time.Duration, bool) {
+	olderThan := c.Query("older_than")
+	if olderThan == "" {
+		return def, true
+	}
+	seconds, err := strconv.ParseInt(olderThan, 10, 64)
+	if err != nil || seconds < 0 {
+		common.ErrorStrResp(c, "older_than must be a non-negative number of seconds", 400)
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// PurgeTempFiles removes the leftover temp files older than the older_than query, in seconds
+func PurgeTempFiles(c *gin.Context) {
+	age, ok := olderThanQuery(c, defaultTempPurgeAge)
+	if !ok {
+		return
+	}
+	var removed int
+	var freed int64
+	for _, f := range listTempFiles() {
+		if time.Duration(f.Age)*time.Second < age {
+			continue
+		}
+		if err := os.Remove(f.Path); err != nil {
+			log.Warnf("清理临时文件失败: %v", err)
+			continue
+		}
+		removed++
+		freed += f.Size
+	}
+	common.SuccessResp(c, gin.H{"removed": removed, "freed": freed})
+}
+
+// PruneUploadTasks removes now the finished upload tasks which ended more than older_than seconds ago,
+// all of them by default. Running and pending tasks are kept
+func PruneUploadTasks(c *gin.Context) {
+	age, ok := olderThanQuery(c, 0)
+	if !ok {
+		return
+	}
+	common.SuccessResp(c, gin.H{"removed": fs.PruneUploadTasks(time.Now().Add(-age))})
+}
@@ -0,0 +1,56 @@
+package handles
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/gin-gonic/gin"
+)
+
+func TestPurgeTempFiles(t *testing.T) {
+	tempDir := conf.Conf.TempDir
+	conf.Conf.TempDir = t.TempDir()
+	t.Cleanup(func() { conf.Conf.TempDir = tempDir })
+	t.Setenv("TMPDIR", t.TempDir())
+	old := time.Now().Add(-48 * time.Hour)
+	files := map[string]bool{
+		filepath.Join(os.TempDir(), "video_thumb_1.webp"):           false,
+		filepath.Join(conf.Conf.TempDir, "durable_upload_1"):        false,
+		filepath.Join(conf.Conf.TempDir, "mirror_upload_1"):         true, // recent
+		filepath.Join(conf.Conf.TempDir, "unrelated.bin"):           true,
+		filepath.Join(conf.Conf.TempDir, "archive_upload", "a.zip"): true,
+	}
+	for path, kept := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if !kept || filepath.Base(path) == "unrelated.bin" {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if listed := listTempFiles(); len(listed) != 3 {
+		t.Fatalf("expected 3 temp files, got %+v", listed)
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/api/admin/maintenance/temp?older_than=3600", nil)
+	PurgeTempFiles(c)
+	if code := respCode(t, w); code != 200 {
+		t.Fatalf("unexpected response %s", w.Body.String())
+	}
+	for path, kept := range files {
+		if _, err := os.Stat(path); (err == nil) != kept {
+			t.Errorf("%s: expected kept=%v, got %v", path, kept, err)
+		}
+	}
+}
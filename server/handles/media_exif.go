@@ -0,0 +1,184 @@
+package handles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var errMediaUnsupported = errors.New("media info is not supported for this file")
+
+type MediaGPS struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// MediaInfo is the EXIF of an image or the ffprobe tags of a video, normalized
+type MediaInfo struct {
+	Taken    *time.Time `json:"taken,omitempty"`
+	Make     string     `json:"make,omitempty"`
+	Model    string     `json:"model,omitempty"`
+	Width    int        `json:"width,omitempty"`
+	Height   int        `json:"height,omitempty"`
+	Duration float64    `json:"duration,omitempty"` // seconds, videos only
+	GPS      *MediaGPS  `json:"gps,omitempty"`
+}
+
+// MediaInfoCache is the MediaInfo of the file as it was modified at Modified
+type MediaInfoCache struct {
+	Modified time.Time  `json:"modified"`
+	Info     *MediaInfo `json:"info"`
+}
+
+func imageMediaInfo(ctx context.Context, path string) (*MediaInfo, error) {
+	head, err := readFileContent(ctx, path, exifProbeSize)
+	if err != nil {
+		return nil, err
+	}
+	exif, err := utils.ReadExif(bytes.NewReader(head))
+	if err != nil {
+		// no EXIF, nothing is known
+		return &MediaInfo{}, nil
+	}
+	info := &MediaInfo{
+		Make:   exif.Make,
+		Model:  exif.Model,
+		Width:  exif.Width,
+		Height: exif.Height,
+	}
+	if exif.Orientation >= 5 && exif.Orientation <= 8 {
+		// rotated by 90°, the dimensions are those of the displayed image
+		info.Width, info.Height = info.Height, info.Width
+	}
+	if !exif.Date.IsZero() {
+		info.Taken = &exif.Date
+	}
+	if exif.HasGPS {
+		info.GPS = &MediaGPS{Latitude: exif.Latitude, Longitude: exif.Longitude}
+	}
+	return info, nil
+}
+
+// iso6709 matches the leading latitude and longitude of a location tag like "+48.8577+002.2950+035.000/"
+var iso6709 = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)`)
+
+// firstTag returns the first non empty of the tags
+func firstTag(tags map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(tags[key]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (p *ffprobeOutput) toMediaInfo() *MediaInfo {
+	meta := p.toVideoMeta()
+	tags := p.Format.Tags
+	info := &MediaInfo{
+		Make:     firstTag(tags, "com.apple.quicktime.make", "make"),
+		Model:    firstTag(tags, "com.apple.quicktime.model", "model"),
+		Width:    meta.Width,
+		Height:   meta.Height,
+		Duration: meta.Duration,
+	}
+	if taken, err := time.Parse(time.RFC3339Nano, firstTag(tags, "com.apple.quicktime.creationdate", "creation_time")); err == nil {
+		info.Taken = &taken
+	}
+	if m := iso6709.FindStringSubmatch(firstTag(tags, "com.apple.quicktime.location.ISO6709", "location")); m != nil {
+		lat, err1 := strconv.ParseFloat(m[1], 64)
+		lon, err2 := strconv.ParseFloat(m[2], 64)
+		if err1 == nil && err2 == nil {
+			info.GPS = &MediaGPS{Latitude: lat, Longitude: lon}
+		}
+	}
+	return info
+}
+
+func videoMediaInfo(ctx context.Context, path string, obj model.Obj) (*MediaInfo, error) {
+	if !localFilesStorage(path) || obj.GetPath() == "" {
+		return nil, errMediaUnsupported
+	}
+	probe, err := probeVideo(ctx, obj.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	return probe.toMediaInfo(), nil
+}
+
+// mediaInfo returns the cached MediaInfo of the file unless it was modified since, reading and caching it otherwise
+func mediaInfo(ctx context.Context, path string, obj model.Obj) (*MediaInfo, error) {
+	sidecar, err := readMediaSidecar(ctx, path)
+	if err != nil {
+		sidecar = &MediaSidecar{}
+	}
+	if sidecar.Media != nil && sidecar.Media.Info != nil && sidecar.Media.Modified.Equal(obj.ModTime()) {
+		return sidecar.Media.Info, nil
+	}
+	var info *MediaInfo
+	switch utils.GetFileType(obj.GetName()) {
+	case conf.IMAGE:
+		info, err = imageMediaInfo(ctx, path)
+	case conf.VIDEO:
+		info, err = videoMediaInfo(ctx, path, obj)
+	default:
+		err = errMediaUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	sidecar.Media = &MediaInfoCache{Modified: obj.ModTime(), Info: info}
+	if err = writeMediaSidecar(ctx, path, sidecar); err != nil {
+		logrus.Printf("保存媒体元数据失败: %v", err)
+	}
+	return info, nil
+}
+
+func FsMediaExif(c *gin.Context) {
+	var req MediaPathReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := resolveReadablePath(c, req.Path, req.Password)
+	if !ok {
+		return
+	}
+	obj, err := fs.Get(c.Request.Context(), reqPath, &fs.GetArgs{})
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if obj.IsDir() {
+		common.ErrorStrResp(c, "not a file", 400)
+		return
+	}
+	info, err := mediaInfo(c.Request.Context(), reqPath, obj)
+	if errors.Is(err, errMediaUnsupported) {
+		common.ErrorResp(c, err, 415)
+		return
+	}
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if info.GPS != nil && setting.GetBool(conf.StripGPSFromResponse) {
+		stripped := *info
+		stripped.GPS = nil
+		info = &stripped
+	}
+	common.SuccessResp(c, info)
+}
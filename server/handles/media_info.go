@@ -0,0 +1,108 @@
+package handles
+
+import (
+	"context"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// MediaThumbnail is the thumbnail part of FsMediaInfo, see ThumbnailStatus
+type MediaThumbnail struct {
+	Status string `json:"status"`
+	Path   string `json:"path,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// MediaPlaceholder is the blurhash and the #rrggbb dominant color of the thumbnail, as enabled
+type MediaPlaceholder struct {
+	Blurhash      string `json:"blurhash,omitempty"`
+	DominantColor string `json:"dominant_color,omitempty"`
+}
+
+// MediaInfoResp aggregates what FsThumbnailStatus, FsVideoMeta and FsMediaExif return for one file.
+// Sections which aren't known are null, only the cached ones are read, nothing is probed
+type MediaInfoResp struct {
+	Path        string            `json:"path"`
+	Exists      bool              `json:"exists"`
+	Size        int64             `json:"size"`
+	Modified    time.Time         `json:"modified"`
+	Thumbnail   *MediaThumbnail   `json:"thumbnail"`
+	Video       *VideoMeta        `json:"video"`
+	Exif        *MediaInfo        `json:"exif"`
+	Placeholder *MediaPlaceholder `json:"placeholder"`
+}
+
+// mediaThumbnail returns the stored thumbnail of path, or the state of its generation
+func mediaThumbnail(ctx context.Context, user *model.User, path string) *MediaThumbnail {
+	store := getThumbnailStore()
+	if thumb, err := fs.Get(ctx, store.PathFor(path), &fs.GetArgs{NoLog: true}); err == nil && !thumb.IsDir() {
+		return &MediaThumbnail{
+			Status: thumbnailStateExists,
+			Path:   userRelativePath(user, store.PathFor(path)),
+			Size:   thumb.GetSize(),
+		}
+	}
+	return &MediaThumbnail{Status: thumbnailState(path)}
+}
+
+// sidecarMediaInfo fills the sections of resp cached in the sidecar of path, the EXIF
+// only while the file wasn't modified since it was read
+func sidecarMediaInfo(resp *MediaInfoResp, sidecar *MediaSidecar, obj model.Obj) {
+	resp.Video = sidecar.Video
+	if sidecar.Media != nil && sidecar.Media.Info != nil && sidecar.Media.Modified.Equal(obj.ModTime()) {
+		resp.Exif = sidecar.Media.Info
+		if resp.Exif.GPS != nil && setting.GetBool(conf.StripGPSFromResponse) {
+			stripped := *resp.Exif
+			stripped.GPS = nil
+			resp.Exif = &stripped
+		}
+	}
+	var placeholder MediaPlaceholder
+	if setting.GetBool(conf.EnableBlurhash) {
+		placeholder.Blurhash = sidecar.Blurhash
+	}
+	if setting.GetBool(conf.EnableDominantColor) {
+		placeholder.DominantColor = sidecar.DominantColor
+	}
+	if placeholder != (MediaPlaceholder{}) {
+		resp.Placeholder = &placeholder
+	}
+}
+
+// FsMediaInfo returns the object info, the thumbnail, the video meta, the EXIF and the placeholder
+// of a file in one call. A missing file isn't an error, it's returned with exists false
+func FsMediaInfo(c *gin.Context) {
+	var req MediaPathReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := resolveReadablePath(c, req.Path, req.Password)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	resp := MediaInfoResp{Path: req.Path}
+	obj, err := fs.Get(ctx, reqPath, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		common.SuccessResp(c, resp)
+		return
+	}
+	if obj.IsDir() {
+		common.ErrorStrResp(c, "not a file", 400)
+		return
+	}
+	resp.Exists, resp.Size, resp.Modified = true, obj.GetSize(), obj.ModTime()
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	resp.Thumbnail = mediaThumbnail(ctx, user, reqPath)
+	if sidecar, err := readMediaSidecar(ctx, reqPath); err == nil {
+		sidecarMediaInfo(&resp, sidecar, obj)
+	}
+	common.SuccessResp(c, resp)
+}
@@ -0,0 +1,40 @@
+package handles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func TestSidecarMediaInfo(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	obj := &model.Object{Name: "a.mp4", Size: 10, Modified: modified}
+	sidecar := &MediaSidecar{
+		Video: &VideoMeta{Duration: 12.5, Width: 1920, Height: 1080, Codec: "h264"},
+		Media: &MediaInfoCache{Modified: modified, Info: &MediaInfo{Make: "Apple"}},
+	}
+
+	var resp MediaInfoResp
+	sidecarMediaInfo(&resp, sidecar, obj)
+	if resp.Video == nil || resp.Video.Codec != "h264" {
+		t.Errorf("video = %+v", resp.Video)
+	}
+	if resp.Exif == nil || resp.Exif.Make != "Apple" {
+		t.Errorf("exif = %+v", resp.Exif)
+	}
+
+	// the file changed since its EXIF was cached
+	obj.Modified = modified.Add(time.Minute)
+	resp = MediaInfoResp{}
+	sidecarMediaInfo(&resp, sidecar, obj)
+	if resp.Exif != nil {
+		t.Errorf("stale exif returned: %+v", resp.Exif)
+	}
+
+	resp = MediaInfoResp{}
+	sidecarMediaInfo(&resp, &MediaSidecar{}, obj)
+	if resp.Video != nil || resp.Exif != nil || resp.Placeholder != nil {
+		t.Errorf("empty sidecar gave %+v", resp)
+	}
+}
@@ -495,7 +495,7 @@ func AddOfflineDownload(c *gin.Context) {
 	}
 	reqPath, err := user.JoinPath(req.Path)
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	var tasks []task.TaskExtensionInfo
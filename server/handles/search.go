@@ -6,6 +6,7 @@ import (
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/search"
@@ -37,7 +38,7 @@ func Search(c *gin.Context) {
 	user := c.Request.Context().Value(conf.UserKey).(*model.User)
 	req.Parent, err = user.JoinPath(req.Parent)
 	if err != nil {
-		common.ErrorResp(c, err, 400)
+		common.PathErrorResp(c, err, 400)
 		return
 	}
 	if err := req.Validate(); err != nil {
@@ -54,6 +55,10 @@ func Search(c *gin.Context) {
 		if !strings.HasPrefix(node.Parent, user.BasePath) {
 			continue
 		}
+		// 待审核的上传不对普通用户可见
+		if !user.IsAdmin() && fs.InQuarantine(path.Join(node.Parent, node.Name)) {
+			continue
+		}
 		meta, err := op.GetNearestMeta(node.Parent)
 		if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
 			continue
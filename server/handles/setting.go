@@ -1,9 +1,14 @@
 package handles
 
 import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"path/filepath"
 
@@ -32,6 +37,17 @@ func ResetToken(c *gin.Context) {
 	common.SuccessResp(c, token)
 }
 
+// RotateSignKey invalidates all signed links without changing the token
+func RotateSignKey(c *gin.Context) {
+	item := model.SettingItem{Key: conf.SignSecret, Value: random.Token(), Type: conf.TypeString, Group: model.SINGLE, Flag: model.PRIVATE}
+	if err := op.SaveSettingItem(&item); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	// 钩子已替换签名密钥,旧签名的链接不再有效
+	common.SuccessResp(c, "sign key rotated")
+}
+
 func GetSetting(c *gin.Context) {
 	key := c.Query("key")
 	keys := c.Query("keys")
@@ -66,6 +82,105 @@ func SaveSettings(c *gin.Context) {
 	}
 }
 
+type BulkSetSettingReq struct {
+	Pattern string `json:"pattern" binding:"required"`
+	Value   string `json:"value"`
+}
+
+// BulkSetSettings sets the value to every setting whose key matches the glob pattern,
+// e.g. storage_*_enabled, and returns the keys set
+func BulkSetSettings(c *gin.Context) {
+	var req BulkSetSettingReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	keys, err := op.BulkSetSettingItems(req.Pattern, req.Value)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if len(keys) > 0 {
+		static.UpdateIndex()
+	}
+	common.SuccessResp(c, gin.H{"keys": keys})
+}
+
+// listSettingSeparators are the settings whose value is a delimited list
+var listSettingSeparators = map[string]string{
+	conf.VideoTypes:             ",",
+	conf.AudioTypes:             ",",
+	conf.ImageTypes:             ",",
+	conf.TextTypes:              ",",
+	conf.ProxyTypes:             ",",
+	conf.ProxyIgnoreHeaders:     ",",
+	conf.IgnoreDirectLinkParams: ",",
+	conf.IgnorePaths:            "\n",
+	conf.PrivacyRegs:            "\n",
+}
+
+// settingListLock serializes the read-modify-write of list settings
+var settingListLock sync.Mutex
+
+type SettingListReq struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+func mergeSettingList(value, sep string, add, remove []string) string {
+	removed := make(map[string]struct{}, len(remove))
+	for _, v := range remove {
+		removed[strings.TrimSpace(v)] = struct{}{}
+	}
+	seen := make(map[string]struct{})
+	var values []string
+	for _, v := range append(strings.Split(value, sep), add...) {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if _, ok := removed[v]; ok {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		values = append(values, v)
+	}
+	return strings.Join(values, sep)
+}
+
+// UpdateSettingList adds and removes entries of a list setting atomically
+func UpdateSettingList(c *gin.Context) {
+	key := c.Param("key")
+	sep, ok := listSettingSeparators[key]
+	if !ok {
+		common.ErrorStrResp(c, "setting is not a list", 400)
+		return
+	}
+	var req SettingListReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	settingListLock.Lock()
+	defer settingListLock.Unlock()
+	item, err := op.GetSettingItemByKey(key)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	// the cached item must not be changed before it's saved
+	updated := *item
+	updated.Value = mergeSettingList(item.Value, sep, req.Add, req.Remove)
+	if err = op.SaveSettingItem(&updated); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c, updated)
+}
+
 func ListSettings(c *gin.Context) {
 	groupStr := c.Query("group")
 	groupsStr := c.Query("groups")
@@ -98,6 +213,11 @@ func ListSettings(c *gin.Context) {
 	common.SuccessResp(c, settings)
 }
 
+// ListSettingGroups returns the id, name and label of every setting group
+func ListSettingGroups(c *gin.Context) {
+	common.SuccessResp(c, model.SettingGroups)
+}
+
 func DefaultSettings(c *gin.Context) {
 	groupStr := c.Query("group")
 	groupsStr := c.Query("groups")
@@ -147,6 +267,66 @@ func DeleteSetting(c *gin.Context) {
 	common.SuccessResp(c)
 }
 
+// DeleteSettingGroup deletes all settings of ?group=N, or those of ?keys=a,b in one call,
+// settings which aren't deprecated need ?force=true
+func DeleteSettingGroup(c *gin.Context) {
+	force := c.Query("force") == "true"
+	var deleted []model.SettingItem
+	var err error
+	if keys := c.Query("keys"); keys != "" {
+		deleted, err = op.DeleteSettingItemsByKeys(strings.Split(keys, ","), force)
+	} else {
+		group, convErr := strconv.Atoi(c.Query("group"))
+		if convErr != nil {
+			common.ErrorStrResp(c, "group or keys is required", 400)
+			return
+		}
+		deleted, err = op.DeleteSettingItemsByGroup(group, force)
+	}
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	// the index embeds the site and style settings
+	if slices.ContainsFunc(deleted, func(item model.SettingItem) bool {
+		return item.Group == model.SITE || item.Group == model.STYLE
+	}) {
+		static.UpdateIndex()
+	}
+	common.SuccessResp(c, gin.H{"deleted": len(deleted)})
+}
+
+type PreviewPublicReq struct {
+	Key   string `json:"key" binding:"required"`
+	Value string `json:"value"`
+}
+
+type PreviewPublicResp struct {
+	Public   bool              `json:"public"`
+	Changed  bool              `json:"changed"`
+	Settings map[string]string `json:"settings"`
+}
+
+// PreviewPublicSettings returns the public settings as PublicSettings would after saving the value,
+// without saving it
+func PreviewPublicSettings(c *gin.Context) {
+	var req PreviewPublicReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	settings, public, err := op.PreviewPublicSettingsMap(req.Key, req.Value)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	common.SuccessResp(c, PreviewPublicResp{
+		Public:   public,
+		Changed:  public && !maps.Equal(settings, op.GetPublicSettingsMap()),
+		Settings: settings,
+	})
+}
+
 func PublicSettings(c *gin.Context) {
 	common.SuccessResp(c, op.GetPublicSettingsMap())
 }
@@ -162,6 +342,14 @@ func SetWebDAV(c *gin.Context) {
 		return
 	}
 
+	// 先试绑定新的监听地址，避免保存无法使用的地址；未改变的地址已被当前服务占用，不再检测
+	if req.Enable && req.Listen != "" && !(conf.Conf.WebDAV.Enable && req.Listen == conf.Conf.WebDAV.Listen) {
+		if err := testListen(webdavListenAddr(req.Listen)); err != nil {
+			common.ErrorStrResp(c, fmt.Sprintf("failed to listen on %s: %v", req.Listen, err), 400)
+			return
+		}
+	}
+
 	// 保存WebDAV设置
 	webdavEnabledItem := model.SettingItem{Key: "webdav_enabled", Value: strconv.FormatBool(req.Enable), Type: conf.TypeBool, Group: model.WEBDAV, Flag: model.PUBLIC}
 	webdavListenItem := model.SettingItem{Key: "webdav_listen", Value: req.Listen, Type: conf.TypeString, Group: model.WEBDAV, Flag: model.PRIVATE}
@@ -185,6 +373,23 @@ func SetWebDAV(c *gin.Context) {
 	common.SuccessResp(c)
 }
 
+// webdavListenAddr 将webdav_listen的值（地址或端口）转换为监听地址
+func webdavListenAddr(listen string) string {
+	if !strings.Contains(listen, ":") {
+		return ":" + listen
+	}
+	return listen
+}
+
+// testListen 绑定地址后立即关闭，返回端口占用、权限不足等绑定错误
+func testListen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
 // GetWebDAV 获取WebDAV服务设置状态
 func GetWebDAV(c *gin.Context) {
 	// 从设置中获取WebDAV配置
@@ -44,8 +44,7 @@ func makeStorageResp(ctx *gin.Context, storages []model.Storage) []*StorageResp
 		if err != nil {
 			continue
 		}
-		_, ok := d.(driver.WithDetails)
-		if !ok {
+		if !driver.GetCapabilities(d).FreeSpace {
 			continue
 		}
 		workerCount++
@@ -208,3 +207,23 @@ func LoadAllStorages(c *gin.Context) {
 	}(storages)
 	common.SuccessResp(c)
 }
+
+// GetStorageCapabilities returns the driver.StorageCapabilities of the loaded storage with id
+func GetStorageCapabilities(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	storage, err := db.GetStorageById(uint(id))
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	storageDriver, err := op.GetStorageByMountPath(storage.MountPath)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	common.SuccessResp(c, driver.GetCapabilities(storageDriver))
+}
@@ -0,0 +1,142 @@
+package handles
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// FsThumbnail serves the thumbnail of the file from the thumbnail store
+func FsThumbnail(c *gin.Context) {
+	var req MediaPathReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := resolveReadablePath(c, req.Path, req.Password)
+	if !ok {
+		return
+	}
+	// 实况照片的视频使用照片的缩略图
+	thumbPath := reqPath
+	if still, ok := livePhotoStill(c.Request.Context(), reqPath); ok {
+		thumbPath = still
+	}
+	data, err := getThumbnailStore().Get(c.Request.Context(), thumbPath, maxThumbnailSize)
+	if err == nil {
+		data = negotiateThumbnail(c, thumbPath, data)
+	} else if setting.GetBool(conf.FolderThumbnails) {
+		data, err = folderThumbnail(c.Request.Context(), reqPath)
+	}
+	if err != nil {
+		if !serveDefaultThumbnail(c, reqPath) {
+			common.ErrorStrResp(c, "thumbnail not found", 404)
+		}
+		return
+	}
+	etag := `"` + utils.HashData(utils.MD5, data) + `"`
+	setThumbnailCacheHeaders(c, etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(200, thumbnailMimetype(data), data)
+}
+
+// setThumbnailCacheHeaders applies conf.ThumbnailCacheControl, with an Expires derived from its max-age.
+// Thumbnails are served at a single size, the response only varies by Accept when conf.ThumbnailFormats has variants.
+func setThumbnailCacheHeaders(c *gin.Context, etag string) {
+	c.Header("ETag", etag)
+	cacheControl := strings.TrimSpace(setting.GetStr(conf.ThumbnailCacheControl))
+	if cacheControl == "" {
+		return
+	}
+	c.Header("Cache-Control", cacheControl)
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		if seconds, err := strconv.Atoi(value); err == nil {
+			c.Header("Expires", time.Now().Add(time.Duration(seconds)*time.Second).UTC().Format(http.TimeFormat))
+		}
+	}
+}
+
+// etagMatches implements the weak comparison of If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// FsThumbnailDelete removes the thumbnail of the file from the thumbnail store
+func FsThumbnailDelete(c *gin.Context) {
+	var req MediaPathReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if !user.CanRemove() {
+		common.ErrorResp(c, errs.PermissionDenied, 403)
+		return
+	}
+	reqPath, ok := resolveReadablePath(c, req.Path, req.Password)
+	if !ok {
+		return
+	}
+	store := getThumbnailStore()
+	if err := store.Delete(c.Request.Context(), reqPath); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	deleteThumbnailVariants(c.Request.Context(), store, reqPath)
+	deleteFilmstrip(c.Request.Context(), store, reqPath)
+	common.SuccessResp(c)
+}
+
+// maxInlineThumbnailSize caps conf.InlineThumbnailMaxSize, so listings stay small
+const maxInlineThumbnailSize = 64 * 1024
+
+// inlineThumbnails embeds the existing thumbnails of the images and videos in the listing
+// as data URIs, skipping those larger than conf.InlineThumbnailMaxSize.
+// It only reads thumbnails, missing ones aren't generated.
+func inlineThumbnails(ctx context.Context, parent string, content []ObjResp) {
+	limit := int64(setting.GetInt(conf.InlineThumbnailMaxSize, 16*1024))
+	if limit <= 0 {
+		return
+	}
+	if limit > maxInlineThumbnailSize {
+		limit = maxInlineThumbnailSize
+	}
+	store := getThumbnailStore()
+	for i := range content {
+		obj := &content[i]
+		if obj.IsDir || (obj.Type != conf.VIDEO && obj.Type != conf.IMAGE) {
+			continue
+		}
+		// read one more byte to tell thumbnails over the limit
+		data, err := store.Get(ctx, stdpath.Join(parent, obj.Name), limit+1)
+		if err != nil || len(data) == 0 || int64(len(data)) > limit {
+			continue
+		}
+		obj.InlineThumb = "data:" + thumbnailMimetype(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+}
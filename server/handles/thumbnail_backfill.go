@@ -0,0 +1,332 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ThumbnailBackfillRunning   = "running"
+	ThumbnailBackfillCanceled  = "canceled"
+	ThumbnailBackfillCompleted = "completed"
+)
+
+// ThumbnailBackfill generates the missing thumbnails of every image and video beneath Root,
+// one at a time through thumbnailSlots and only inside the thumbnail_schedule window.
+// Its progress is kept in the data dir, a running backfill resumes where it stopped after a restart
+type ThumbnailBackfill struct {
+	Root     string `json:"root"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
+	// Total counts the images and videos found, each is then done, failed or skipped
+	// because it already had a thumbnail or its storage can't have one
+	Total   int `json:"total"`
+	Done    int `json:"done"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+	// Dirs are the directories left to walk, the entries of Dirs[0] before Offset are walked
+	Dirs     []string   `json:"dirs"`
+	Offset   int        `json:"offset"`
+	Started  time.Time  `json:"started"`
+	Updated  time.Time  `json:"updated"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+var (
+	// thumbnailBackfillLock guards thumbnailBackfill and its persisted copy
+	thumbnailBackfillLock   sync.Mutex
+	thumbnailBackfill       *ThumbnailBackfill
+	thumbnailBackfillCancel context.CancelFunc
+	thumbnailBackfillStart  sync.Once
+)
+
+func thumbnailBackfillPath() string {
+	return filepath.Join(flags.DataDir, "thumbnail_backfill.json")
+}
+
+func loadThumbnailBackfill() (*ThumbnailBackfill, error) {
+	data, err := os.ReadFile(thumbnailBackfillPath())
+	if err != nil {
+		return nil, err
+	}
+	var b ThumbnailBackfill
+	if err = utils.Json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// saveThumbnailBackfill persists b, it must be called with thumbnailBackfillLock held
+func saveThumbnailBackfill(b *ThumbnailBackfill) {
+	b.Updated = time.Now()
+	data, err := utils.Json.Marshal(b)
+	if err == nil {
+		err = os.MkdirAll(flags.DataDir, 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(thumbnailBackfillPath(), data, 0o600)
+	}
+	if err != nil {
+		log.Errorf("save thumbnail backfill error: %+v", err)
+	}
+}
+
+// startThumbnailBackfill runs b in the background once the storages are loaded,
+// it must be called with thumbnailBackfillLock held
+func startThumbnailBackfill(b *ThumbnailBackfill) {
+	ctx, cancel := context.WithCancel(context.Background())
+	thumbnailBackfill, thumbnailBackfillCancel = b, cancel
+	go func() {
+		select {
+		case <-conf.StoragesLoadSignal():
+			runThumbnailBackfill(ctx, b)
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// backfillSkipsDir tells whether the walk leaves out the dir, which holds thumbnails or held uploads
+func backfillSkipsDir(path string) bool {
+	name := stdpath.Base(path)
+	if name == setting.ThumbnailDirName() || name == fs.QuarantineDirName || name == stagingDirName {
+		return true
+	}
+	return setting.GetStr(conf.ThumbnailStoreMode, ThumbnailStoreFolder) != ThumbnailStoreFolder &&
+		path == utils.FixAndCleanPath(setting.GetStr(conf.ThumbnailStorePath, "/.thumbnails"))
+}
+
+// waitThumbnailWindow blocks until the thumbnail_schedule window opens, false when ctx is done first
+func waitThumbnailWindow(ctx context.Context) bool {
+	for !thumbnailWindowOpen() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(time.Minute):
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// runThumbnailBackfill walks the dirs of b breadth first, persisting the progress after every file
+// so a restart resumes at the next one. A canceled walk leaves the current file to be retried
+func runThumbnailBackfill(ctx context.Context, b *ThumbnailBackfill) {
+	user, err := op.GetUserByName(b.Username)
+	if err != nil {
+		log.Warnf("user %s of the thumbnail backfill not found: %+v", b.Username, err)
+	}
+	store := getThumbnailStore()
+	for {
+		thumbnailBackfillLock.Lock()
+		// a canceled walk stops before changing b, which may be resumed meanwhile
+		if ctx.Err() != nil {
+			thumbnailBackfillLock.Unlock()
+			return
+		}
+		if len(b.Dirs) == 0 {
+			finished := time.Now()
+			b.Status, b.Finished = ThumbnailBackfillCompleted, &finished
+			saveThumbnailBackfill(b)
+			thumbnailBackfillLock.Unlock()
+			log.Infof("thumbnail backfill of %s completed: %d done, %d failed, %d skipped", b.Root, b.Done, b.Failed, b.Skipped)
+			return
+		}
+		dir, offset := b.Dirs[0], b.Offset
+		thumbnailBackfillLock.Unlock()
+
+		objs, err := fs.List(ctx, dir, &fs.ListArgs{NoLog: true})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warnf("list %s for the thumbnail backfill error: %+v", dir, err)
+		}
+		var subdirs []string
+		for i, obj := range objs {
+			path := stdpath.Join(dir, obj.GetName())
+			if obj.IsDir() {
+				if !backfillSkipsDir(path) {
+					subdirs = append(subdirs, path)
+				}
+				continue
+			}
+			if i < offset {
+				continue
+			}
+			resp := toObjsResp([]model.Obj{obj}, dir, false)[0]
+			outcome := ""
+			if wantsThumbnail(&resp) {
+				if outcome = backfillThumbnail(ctx, store, path, user); ctx.Err() != nil {
+					return
+				}
+			}
+			thumbnailBackfillLock.Lock()
+			if ctx.Err() != nil {
+				thumbnailBackfillLock.Unlock()
+				return
+			}
+			b.Offset = i + 1
+			switch outcome {
+			case thumbnailStateExists:
+				b.Total++
+				b.Done++
+			case thumbnailStateFailed:
+				b.Total++
+				b.Failed++
+			case thumbnailStateUnsupported:
+				b.Total++
+				b.Skipped++
+			}
+			saveThumbnailBackfill(b)
+			thumbnailBackfillLock.Unlock()
+		}
+		thumbnailBackfillLock.Lock()
+		if ctx.Err() != nil {
+			thumbnailBackfillLock.Unlock()
+			return
+		}
+		b.Dirs = append(b.Dirs[1:], subdirs...)
+		b.Offset = 0
+		saveThumbnailBackfill(b)
+		thumbnailBackfillLock.Unlock()
+	}
+}
+
+// backfillThumbnail generates the thumbnail of path unless it has one. It returns thumbnailStateExists
+// when it was generated, thumbnailStateFailed when that failed and thumbnailStateUnsupported when skipped
+func backfillThumbnail(ctx context.Context, store ThumbnailStore, path string, user *model.User) string {
+	if exists, err := store.Exists(ctx, path); err == nil && exists {
+		return thumbnailStateUnsupported
+	}
+	if !thumbnailStorageSupported(path) {
+		return thumbnailStateUnsupported
+	}
+	if !waitThumbnailWindow(ctx) {
+		return ""
+	}
+	generateThumbnail(ctx, path, user)
+	if exists, err := store.Exists(ctx, path); err == nil && exists {
+		return thumbnailStateExists
+	}
+	return thumbnailStateFailed
+}
+
+// InitThumbnailBackfill resumes the backfill which was running when the server stopped
+func InitThumbnailBackfill() {
+	thumbnailBackfillStart.Do(func() {
+		b, err := loadThumbnailBackfill()
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Errorf("load thumbnail backfill error: %+v", err)
+			}
+			return
+		}
+		thumbnailBackfillLock.Lock()
+		defer thumbnailBackfillLock.Unlock()
+		if b.Status == ThumbnailBackfillRunning {
+			log.Infof("resume the thumbnail backfill of %s", b.Root)
+			startThumbnailBackfill(b)
+			return
+		}
+		thumbnailBackfill = b
+	})
+}
+
+type ThumbnailBackfillReq struct {
+	// Root defaults to / which walks all storages
+	Root string `json:"root" form:"root"`
+	// Resume continues the canceled backfill from where it stopped instead of starting over
+	Resume bool `json:"resume" form:"resume"`
+}
+
+// StartThumbnailBackfill starts generating the missing thumbnails beneath root,
+// only one backfill runs at a time
+func StartThumbnailBackfill(c *gin.Context) {
+	var req ThumbnailBackfillReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if !ffmpegAvailable() {
+		common.ErrorStrResp(c, "ffmpeg is unavailable", 400)
+		return
+	}
+	thumbnailBackfillLock.Lock()
+	defer thumbnailBackfillLock.Unlock()
+	if thumbnailBackfill != nil && thumbnailBackfill.Status == ThumbnailBackfillRunning {
+		common.ErrorStrResp(c, "a thumbnail backfill is already running", 409)
+		return
+	}
+	if req.Resume {
+		if thumbnailBackfill == nil || thumbnailBackfill.Status != ThumbnailBackfillCanceled {
+			common.ErrorStrResp(c, "no canceled thumbnail backfill to resume", 404)
+			return
+		}
+		thumbnailBackfill.Status = ThumbnailBackfillRunning
+		saveThumbnailBackfill(thumbnailBackfill)
+		startThumbnailBackfill(thumbnailBackfill)
+		common.SuccessResp(c, *thumbnailBackfill)
+		return
+	}
+	root := utils.FixAndCleanPath(req.Root)
+	obj, err := fs.Get(c.Request.Context(), root, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if !obj.IsDir() {
+		common.ErrorStrResp(c, "root is not a directory", 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	b := &ThumbnailBackfill{
+		Root:     root,
+		Username: user.Username,
+		Status:   ThumbnailBackfillRunning,
+		Dirs:     []string{root},
+		Started:  time.Now(),
+	}
+	saveThumbnailBackfill(b)
+	startThumbnailBackfill(b)
+	common.SuccessResp(c, *b)
+}
+
+// GetThumbnailBackfill returns the progress of the last backfill, null when none ran
+func GetThumbnailBackfill(c *gin.Context) {
+	thumbnailBackfillLock.Lock()
+	defer thumbnailBackfillLock.Unlock()
+	if thumbnailBackfill == nil {
+		common.SuccessResp(c, nil)
+		return
+	}
+	common.SuccessResp(c, *thumbnailBackfill)
+}
+
+// CancelThumbnailBackfill stops the running backfill, it can be resumed later
+func CancelThumbnailBackfill(c *gin.Context) {
+	thumbnailBackfillLock.Lock()
+	defer thumbnailBackfillLock.Unlock()
+	if thumbnailBackfill == nil || thumbnailBackfill.Status != ThumbnailBackfillRunning {
+		common.ErrorStrResp(c, "no thumbnail backfill is running", 404)
+		return
+	}
+	thumbnailBackfillCancel()
+	thumbnailBackfill.Status = ThumbnailBackfillCanceled
+	saveThumbnailBackfill(thumbnailBackfill)
+	common.SuccessResp(c, *thumbnailBackfill)
+}
@@ -0,0 +1,60 @@
+package handles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+func TestRunThumbnailBackfill(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	dataDir := flags.DataDir
+	flags.DataDir = t.TempDir()
+	t.Cleanup(func() { flags.DataDir = dataDir })
+	for _, name := range []string{"a.mp4", ".thumbnails/a.webp", "notes.txt", "sub/b.mp4", "sub/.thumbnails/b.webp"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/backfill", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+
+	b := &ThumbnailBackfill{Root: "/backfill", Status: ThumbnailBackfillRunning, Dirs: []string{"/backfill"}}
+	runThumbnailBackfill(ctx, b)
+	if b.Status != ThumbnailBackfillCompleted || b.Total != 2 || b.Skipped != 2 || b.Done != 0 || b.Failed != 0 {
+		t.Errorf("got %s with total %d, skipped %d, done %d, failed %d, want completed with 2 skipped",
+			b.Status, b.Total, b.Skipped, b.Done, b.Failed)
+	}
+	saved, err := loadThumbnailBackfill()
+	if err != nil || saved.Status != ThumbnailBackfillCompleted || saved.Total != 2 {
+		t.Errorf("progress not persisted: %+v, %v", saved, err)
+	}
+
+	// a resumed walk skips the files before the offset but still descends into the dirs
+	resumed := &ThumbnailBackfill{Root: "/backfill", Status: ThumbnailBackfillRunning, Dirs: []string{"/backfill"}, Offset: 10}
+	runThumbnailBackfill(ctx, resumed)
+	if resumed.Total != 1 || resumed.Skipped != 1 {
+		t.Errorf("resumed walk counted %d, skipped %d, want 1 and 1", resumed.Total, resumed.Skipped)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	stopped := &ThumbnailBackfill{Root: "/backfill", Status: ThumbnailBackfillRunning, Dirs: []string{"/backfill"}}
+	runThumbnailBackfill(canceled, stopped)
+	if stopped.Status != ThumbnailBackfillRunning || len(stopped.Dirs) != 1 {
+		t.Errorf("canceled walk changed the backfill: %+v", stopped)
+	}
+}
@@ -0,0 +1,352 @@
+package handles
+
+import (
+	stdlist "container/list"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/cron"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type thumbnailCacheEntry struct {
+	Size       int64     `json:"size"`
+	LastServed time.Time `json:"last_served"`
+	key        string
+	elem       *stdlist.Element
+}
+
+// thumbnailDiskCache keeps served thumbnails on the local disk, keyed by their path in the store,
+// so serving them doesn't read the store again. The least recently served are evicted
+// when the cache outgrows conf.ThumbnailCacheMaxBytes, the index of sizes and access times
+// is kept in the data dir so it survives restarts. The lock only guards the index, the cached
+// files are read and written outside of it
+type thumbnailDiskCache struct {
+	sync.Mutex
+	loaded  bool
+	dirty   bool
+	entries map[string]*thumbnailCacheEntry
+	// lru orders the entries from the most to the least recently served
+	lru       *stdlist.List
+	total     int64
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+var thumbnailCache = &thumbnailDiskCache{}
+
+var thumbnailCacheFlusherStart sync.Once
+
+func thumbnailCacheDir() string {
+	return filepath.Join(flags.DataDir, "thumbnail_cache")
+}
+
+func thumbnailCacheIndexPath() string {
+	return filepath.Join(thumbnailCacheDir(), "index.json")
+}
+
+// thumbnailCacheMaxBytes is the size limit of the cache, 0 disables it
+func thumbnailCacheMaxBytes() int64 {
+	return max(int64(setting.GetInt(conf.ThumbnailCacheMaxBytes, 0)), 0)
+}
+
+func thumbnailCacheFile(key string) string {
+	sum := sha1.Sum([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+	return filepath.Join(thumbnailCacheDir(), hash[:2], hash)
+}
+
+func init() {
+	// 关闭缓存时丢弃缓存和索引，再次开启时不会返回期间已变化的缩略图
+	op.RegisterSettingItemHook(conf.ThumbnailCacheMaxBytes, func(item *model.SettingItem) error {
+		if maxBytes, err := strconv.ParseInt(item.Value, 10, 64); err == nil && maxBytes > 0 {
+			return nil
+		}
+		thumbnailCache.clear()
+		return nil
+	})
+}
+
+// load reads the index once, entries whose file is gone or changed size are dropped.
+// It must be called with the lock held.
+func (tc *thumbnailDiskCache) load() {
+	if tc.loaded {
+		return
+	}
+	tc.loaded = true
+	tc.entries = make(map[string]*thumbnailCacheEntry)
+	tc.lru = stdlist.New()
+	data, err := os.ReadFile(thumbnailCacheIndexPath())
+	if err != nil {
+		return
+	}
+	var entries map[string]*thumbnailCacheEntry
+	if err = utils.Json.Unmarshal(data, &entries); err != nil {
+		logrus.Warnf("读取缩略图缓存索引失败: %v", err)
+		return
+	}
+	loaded := make([]*thumbnailCacheEntry, 0, len(entries))
+	for key, entry := range entries {
+		info, err := os.Stat(thumbnailCacheFile(key))
+		if err != nil || info.Size() != entry.Size {
+			tc.dirty = true
+			continue
+		}
+		entry.key = key
+		loaded = append(loaded, entry)
+	}
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].LastServed.After(loaded[j].LastServed)
+	})
+	for _, entry := range loaded {
+		entry.elem = tc.lru.PushBack(entry)
+		tc.entries[entry.key] = entry
+		tc.total += entry.Size
+	}
+}
+
+// get returns the cached thumbnail stored at key
+func (tc *thumbnailDiskCache) get(key string) ([]byte, bool) {
+	tc.Lock()
+	tc.load()
+	entry, ok := tc.entries[key]
+	if !ok {
+		tc.misses++
+		tc.Unlock()
+		return nil, false
+	}
+	size := entry.Size
+	tc.Unlock()
+
+	data, err := os.ReadFile(thumbnailCacheFile(key))
+
+	tc.Lock()
+	defer tc.Unlock()
+	// the entry may have been replaced or dropped while reading
+	current := tc.entries[key] == entry
+	if err != nil || int64(len(data)) != size {
+		if current {
+			tc.remove(entry)
+		}
+		tc.misses++
+		return nil, false
+	}
+	if current {
+		entry.LastServed = time.Now()
+		tc.lru.MoveToFront(entry.elem)
+		tc.dirty = true
+	}
+	tc.hits++
+	return data, true
+}
+
+// put caches the thumbnail stored at key, evicting the least recently served ones to make room
+func (tc *thumbnailDiskCache) put(key string, data []byte) {
+	maxBytes := thumbnailCacheMaxBytes()
+	if int64(len(data)) > maxBytes {
+		return
+	}
+	path := thumbnailCacheFile(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		logrus.Printf("创建缩略图缓存目录失败: %v", err)
+		return
+	}
+	// 先写入临时文件再重命名，并发读取时不会读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		logrus.Printf("写入缩略图缓存失败: %v", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		logrus.Printf("写入缩略图缓存失败: %v", err)
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	tc.Lock()
+	tc.load()
+	if entry, ok := tc.entries[key]; ok {
+		tc.total -= entry.Size
+		tc.lru.Remove(entry.elem)
+	}
+	entry := &thumbnailCacheEntry{Size: int64(len(data)), LastServed: time.Now(), key: key}
+	entry.elem = tc.lru.PushFront(entry)
+	tc.entries[key] = entry
+	tc.total += entry.Size
+	tc.dirty = true
+	evicted := tc.evict(maxBytes)
+	tc.Unlock()
+	removeThumbnailCacheFiles(evicted)
+}
+
+// invalidate drops the cached thumbnail stored at key, after it changed or was removed from the store
+func (tc *thumbnailDiskCache) invalidate(key string) {
+	tc.Lock()
+	tc.load()
+	entry, ok := tc.entries[key]
+	if ok {
+		tc.remove(entry)
+	}
+	tc.Unlock()
+	if ok {
+		removeThumbnailCacheFiles([]string{key})
+	}
+}
+
+// evict drops the least recently served entries from the index while over maxBytes
+// and returns their keys, whose files are removed once the lock is released.
+// It must be called with the lock held.
+func (tc *thumbnailDiskCache) evict(maxBytes int64) []string {
+	var evicted []string
+	for tc.total > maxBytes {
+		back := tc.lru.Back()
+		if back == nil {
+			break
+		}
+		entry := back.Value.(*thumbnailCacheEntry)
+		tc.remove(entry)
+		tc.evictions++
+		evicted = append(evicted, entry.key)
+	}
+	return evicted
+}
+
+// remove drops entry from the index, it must be called with the lock held
+func (tc *thumbnailDiskCache) remove(entry *thumbnailCacheEntry) {
+	tc.total -= entry.Size
+	tc.lru.Remove(entry.elem)
+	delete(tc.entries, entry.key)
+	tc.dirty = true
+}
+
+// clear drops every cached thumbnail along with the persisted index
+func (tc *thumbnailDiskCache) clear() {
+	tc.Lock()
+	defer tc.Unlock()
+	tc.loaded, tc.dirty = false, false
+	tc.entries, tc.lru, tc.total = nil, nil, 0
+	if err := os.RemoveAll(thumbnailCacheDir()); err != nil {
+		logrus.Printf("清理缩略图缓存失败: %v", err)
+	}
+}
+
+func removeThumbnailCacheFiles(keys []string) {
+	for _, key := range keys {
+		if err := os.Remove(thumbnailCacheFile(key)); err != nil && !os.IsNotExist(err) {
+			logrus.Printf("清理缩略图缓存失败: %v", err)
+		}
+	}
+}
+
+// flush saves the index when it changed since the last flush
+func (tc *thumbnailDiskCache) flush() {
+	tc.Lock()
+	defer tc.Unlock()
+	if !tc.loaded || !tc.dirty {
+		return
+	}
+	data, err := utils.Json.Marshal(tc.entries)
+	if err == nil {
+		err = os.MkdirAll(thumbnailCacheDir(), 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(thumbnailCacheIndexPath(), data, 0o600)
+	}
+	if err != nil {
+		logrus.Printf("保存缩略图缓存索引失败: %v", err)
+		return
+	}
+	tc.dirty = false
+}
+
+// InitThumbnailCache flushes the index of the thumbnail cache every minute
+func InitThumbnailCache() {
+	thumbnailCacheFlusherStart.Do(func() {
+		cron.NewCron(time.Minute).Do(thumbnailCache.flush)
+	})
+}
+
+// cachedThumbnailStore serves the thumbnails of store from thumbnailCache,
+// writes and removals through it invalidate the cached copy
+type cachedThumbnailStore struct {
+	ThumbnailStore
+}
+
+func (s cachedThumbnailStore) Get(ctx context.Context, filePath string, limit int64) ([]byte, error) {
+	key := s.PathFor(filePath)
+	if data, ok := thumbnailCache.get(key); ok && int64(len(data)) <= limit {
+		return data, nil
+	}
+	data, err := s.ThumbnailStore.Get(ctx, filePath, limit)
+	if err != nil {
+		return nil, err
+	}
+	// data as long as limit may be truncated
+	if len(data) > 0 && int64(len(data)) < limit {
+		thumbnailCache.put(key, data)
+	}
+	return data, nil
+}
+
+func (s cachedThumbnailStore) Put(ctx context.Context, filePath string, r io.Reader, size int64) error {
+	defer thumbnailCache.invalidate(s.PathFor(filePath))
+	return s.ThumbnailStore.Put(ctx, filePath, r, size)
+}
+
+func (s cachedThumbnailStore) Delete(ctx context.Context, filePath string) error {
+	defer thumbnailCache.invalidate(s.PathFor(filePath))
+	return s.ThumbnailStore.Delete(ctx, filePath)
+}
+
+type ThumbnailCacheStatsResp struct {
+	Entries   int     `json:"entries"`
+	Size      int64   `json:"size"`
+	MaxBytes  int64   `json:"max_bytes"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	HitRate   float64 `json:"hit_rate"`
+	Evictions int64   `json:"evictions"`
+}
+
+// GetThumbnailCacheStats returns the usage of the thumbnail cache and its hit rate and evictions since the start
+func GetThumbnailCacheStats(c *gin.Context) {
+	tc := thumbnailCache
+	tc.Lock()
+	tc.load()
+	resp := ThumbnailCacheStatsResp{
+		Entries:   len(tc.entries),
+		Size:      tc.total,
+		MaxBytes:  thumbnailCacheMaxBytes(),
+		Hits:      tc.hits,
+		Misses:    tc.misses,
+		Evictions: tc.evictions,
+	}
+	tc.Unlock()
+	if lookups := resp.Hits + resp.Misses; lookups > 0 {
+		resp.HitRate = float64(resp.Hits) / float64(lookups)
+	}
+	common.SuccessResp(c, resp)
+}
@@ -0,0 +1,69 @@
+package handles
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestThumbnailDiskCache(t *testing.T) {
+	dataDir := flags.DataDir
+	flags.DataDir = t.TempDir()
+	t.Cleanup(func() { flags.DataDir = dataDir })
+	setMaxBytes := func(value string) {
+		if err := op.SaveSettingItem(&model.SettingItem{Key: conf.ThumbnailCacheMaxBytes, Value: value, Type: conf.TypeNumber, Group: model.THUMBNAIL, Flag: model.PRIVATE}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setMaxBytes("10")
+	t.Cleanup(func() { setMaxBytes("0") })
+
+	tc := &thumbnailDiskCache{}
+	tc.put("/a.webp", []byte("aaaa"))
+	tc.put("/b.webp", []byte("bbbb"))
+	time.Sleep(time.Millisecond)
+	if data, ok := tc.get("/a.webp"); !ok || !bytes.Equal(data, []byte("aaaa")) {
+		t.Fatalf("cached thumbnail not served: %q, %v", data, ok)
+	}
+	// /b.webp is the least recently served
+	tc.put("/c.webp", []byte("cccc"))
+	if _, ok := tc.get("/b.webp"); ok {
+		t.Error("least recently served thumbnail not evicted")
+	}
+	if _, ok := tc.get("/a.webp"); !ok {
+		t.Error("recently served thumbnail evicted")
+	}
+	if tc.evictions != 1 || tc.hits != 2 || tc.misses != 1 {
+		t.Errorf("got %d evictions, %d hits, %d misses, want 1, 2, 1", tc.evictions, tc.hits, tc.misses)
+	}
+	tc.put("/large.webp", []byte("larger than the cache"))
+	if _, ok := tc.get("/large.webp"); ok {
+		t.Error("thumbnail larger than the cache was cached")
+	}
+	tc.invalidate("/c.webp")
+	if _, ok := tc.get("/c.webp"); ok {
+		t.Error("invalidated thumbnail still served")
+	}
+
+	tc.flush()
+	restarted := &thumbnailDiskCache{}
+	if data, ok := restarted.get("/a.webp"); !ok || !bytes.Equal(data, []byte("aaaa")) {
+		t.Errorf("index not restored after a restart: %q, %v", data, ok)
+	}
+	if restarted.total != 4 {
+		t.Errorf("restored size %d, want 4", restarted.total)
+	}
+
+	// disabling the cache drops it, so nothing stale is served once enabled again
+	setMaxBytes("0")
+	setMaxBytes("10")
+	reenabled := &thumbnailDiskCache{}
+	if _, ok := reenabled.get("/a.webp"); ok {
+		t.Error("thumbnail cached before the cache was disabled still served")
+	}
+}
@@ -0,0 +1,137 @@
+package handles
+
+import (
+	"context"
+	"fmt"
+	"os"
+	stdpath "path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/sirupsen/logrus"
+)
+
+// VideoCover is a picture attached to the video, e.g. the front or back cover of an MKV
+type VideoCover struct {
+	Index   int    `json:"index"` // index of the stream, as in -map 0:N
+	Label   string `json:"label"`
+	Primary bool   `json:"primary"`
+	// Thumbnail is the path of its thumbnail, only with conf.ThumbnailAttachments
+	Thumbnail string `json:"thumbnail,omitempty"`
+}
+
+var coverLabelInvalid = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// coverLabel names the attached picture after its comment like "Cover (front)",
+// then after its file name, then after its position
+func coverLabel(s ffprobeStream, position int) string {
+	label := s.Tags["comment"]
+	if start, end := strings.Index(label, "("), strings.LastIndex(label, ")"); start >= 0 && end > start {
+		label = label[start+1 : end]
+	}
+	if label == "" {
+		name := s.Tags["filename"]
+		label = strings.TrimSuffix(name, stdpath.Ext(name))
+	}
+	label = strings.Trim(coverLabelInvalid.ReplaceAllString(strings.ToLower(label), "_"), "_-")
+	if label == "" {
+		label = "attachment" + strconv.Itoa(position)
+	}
+	return label
+}
+
+// videoCovers lists the attached pictures with unique labels, the primary one is the
+// front cover, or else the first
+func (p *ffprobeOutput) videoCovers() []VideoCover {
+	var covers []VideoCover
+	seen := make(map[string]int)
+	primary := -1
+	for _, s := range p.Streams {
+		if s.CodecType != "video" || s.Disposition["attached_pic"] == 0 {
+			continue
+		}
+		label := coverLabel(s, len(covers))
+		if n := seen[label]; n > 0 {
+			seen[label]++
+			label += "_" + strconv.Itoa(n)
+		} else {
+			seen[label] = 1
+		}
+		if primary < 0 && (label == "front" || label == "cover") {
+			primary = len(covers)
+		}
+		covers = append(covers, VideoCover{Index: s.Index, Label: label})
+	}
+	if len(covers) > 0 {
+		if primary < 0 {
+			primary = 0
+		}
+		covers[primary].Primary = true
+	}
+	return covers
+}
+
+// coverThumbnailSource is the path the thumbnail of a cover is stored for, the store
+// keeps it next to the thumbnail of the video, e.g. .thumbnails/<base>_front.webp
+func coverThumbnailSource(filePath, label string) string {
+	ext := stdpath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + "_" + label + ext
+}
+
+// 将附加封面直接编码为缩略图
+func encodeVideoCover(ctx context.Context, videoPath string, index int, outputPath string) error {
+	options := thumbnailOptionsFrom(ctx)
+	args := []string{
+		"-i", videoPath,
+		"-map", fmt.Sprintf("0:%d", index), // 选择封面所在的流
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-1", options.width()),
+	}
+	args = append(args, options.encoderArgs()...)
+	args = append(args,
+		"-y", // 覆盖现有文件
+		outputPath)
+	output, err := runFFmpeg(ctx, args...)
+	if err != nil {
+		logrus.Printf("FFmpeg附加封面编码输出: %s", string(output))
+		return err
+	}
+	return nil
+}
+
+// extractVideoCovers stores a thumbnail of every cover attached to the video with conf.ThumbnailAttachments,
+// and encodes the primary one into outputPath as the thumbnail of the video. It returns false when there
+// is no cover or the primary one fails, the thumbnail is then taken from a frame
+func extractVideoCovers(ctx context.Context, store ThumbnailStore, filePath, videoPath string, meta *VideoMeta, outputPath string) bool {
+	if meta == nil || len(meta.Covers) == 0 || !setting.GetBool(conf.ThumbnailAttachments) {
+		return false
+	}
+	primaryDone := false
+	for _, cover := range meta.Covers {
+		target := outputPath
+		if !cover.Primary {
+			tempFile, err := os.CreateTemp(os.TempDir(), "cover_thumb_*"+thumbnailOptionsFrom(ctx).format().Ext)
+			if err != nil {
+				logrus.Printf("创建本地临时文件失败: %v", err)
+				continue
+			}
+			target = tempFile.Name()
+			_ = tempFile.Close()
+			defer os.Remove(target)
+		}
+		if err := encodeVideoCover(ctx, videoPath, cover.Index, target); err != nil {
+			logrus.Printf("提取附加封面%s失败: %v", cover.Label, err)
+			continue
+		}
+		if cover.Primary {
+			primaryDone = true
+		}
+		if err := uploadThumbnail(ctx, store, coverThumbnailSource(filePath, cover.Label), target); err != nil {
+			logrus.Printf("保存附加封面%s失败: %v", cover.Label, err)
+		}
+	}
+	return primaryDone
+}
@@ -0,0 +1,33 @@
+package handles
+
+import "testing"
+
+func TestVideoCovers(t *testing.T) {
+	attached := map[string]int{"attached_pic": 1}
+	probe := &ffprobeOutput{Streams: []ffprobeStream{
+		{Index: 0, CodecType: "video"},
+		{Index: 1, CodecType: "audio"},
+		{Index: 2, CodecType: "video", Disposition: attached, Tags: map[string]string{"filename": "Back.JPG"}},
+		{Index: 3, CodecType: "video", Disposition: attached, Tags: map[string]string{"comment": "Cover (front)"}},
+		{Index: 4, CodecType: "video", Disposition: attached, Tags: map[string]string{"filename": "back.png"}},
+		{Index: 5, CodecType: "video", Disposition: attached},
+	}}
+	want := []VideoCover{
+		{Index: 2, Label: "back"},
+		{Index: 3, Label: "front", Primary: true},
+		{Index: 4, Label: "back_1"},
+		{Index: 5, Label: "attachment3"},
+	}
+	got := probe.videoCovers()
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("cover %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got := coverThumbnailSource("/videos/movie.mkv", "front"); got != "/videos/movie_front.mkv" {
+		t.Errorf("cover thumbnail source = %s", got)
+	}
+}
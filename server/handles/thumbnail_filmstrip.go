@@ -0,0 +1,219 @@
+package handles
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// the tiles have a fixed size, frames of other aspect ratios are padded, so the sprite
+// is at most 1600x2700 pixels with maxFilmstripFrames
+const (
+	filmstripColumns       = 10
+	filmstripTileWidth     = 160
+	filmstripTileHeight    = 90
+	defaultFilmstripFrames = 100
+	maxFilmstripFrames     = 300
+	filmstripLabel         = "filmstrip"
+)
+
+// Filmstrip is the layout of the sprite of evenly spaced frames of a video, recorded in the sidecar
+type Filmstrip struct {
+	Frames     int     `json:"frames"`
+	Columns    int     `json:"columns"`
+	TileWidth  int     `json:"tile_width"`
+	TileHeight int     `json:"tile_height"`
+	Duration   float64 `json:"duration"`
+	// Interval is the seconds between two frames
+	Interval float64 `json:"interval"`
+}
+
+// newFilmstrip returns the layout of the filmstrip of a video lasting duration seconds,
+// frames is bounded by maxFilmstripFrames
+func newFilmstrip(duration float64, frames int) Filmstrip {
+	frames = max(1, min(frames, maxFilmstripFrames))
+	return Filmstrip{
+		Frames:     frames,
+		Columns:    min(frames, filmstripColumns),
+		TileWidth:  filmstripTileWidth,
+		TileHeight: filmstripTileHeight,
+		Duration:   duration,
+		Interval:   duration / float64(frames),
+	}
+}
+
+func (f Filmstrip) rows() int {
+	return (f.Frames + f.Columns - 1) / f.Columns
+}
+
+// vtt returns the WebVTT mapping the time ranges of the video to their tile of the sprite at spriteURL
+func (f Filmstrip) vtt(spriteURL string) []byte {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i := 0; i < f.Frames; i++ {
+		start := float64(i) * f.Interval
+		if start >= f.Duration {
+			break
+		}
+		end := math.Min(start+f.Interval, f.Duration)
+		x, y := i%f.Columns*f.TileWidth, i/f.Columns*f.TileHeight
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			formatTime(start), formatTime(end), spriteURL, x, y, f.TileWidth, f.TileHeight)
+	}
+	return []byte(b.String())
+}
+
+// filmstripSource is the path the sprite is stored for, the store keeps it next to
+// the thumbnail of the video, e.g. .thumbnails/<base>_filmstrip.webp
+func filmstripSource(filePath string) string {
+	return coverThumbnailSource(filePath, filmstripLabel)
+}
+
+// 单次FFmpeg调用按间隔取帧并拼接为精灵图
+func extractFilmstrip(ctx context.Context, videoPath, outputPath string, f Filmstrip) error {
+	filter := fmt.Sprintf("fps=%f,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,tile=%dx%d",
+		1/f.Interval, f.TileWidth, f.TileHeight, f.TileWidth, f.TileHeight, f.Columns, f.rows())
+	args := []string{
+		"-i", videoPath,
+		"-map", "0:v:0",
+		"-vf", filter,
+		"-frames:v", "1",
+	}
+	args = append(args, thumbnailOptionsFrom(ctx).encoderArgs()...)
+	args = append(args, "-y", outputPath)
+	output, err := runFFmpeg(ctx, args...)
+	if err != nil {
+		logrus.Printf("FFmpeg精灵图生成输出: %s", string(output))
+		return err
+	}
+	return nil
+}
+
+// generateFilmstrip stores the sprite of conf.ThumbnailFilmstripFrames evenly spaced frames of the video
+// and records its layout in the sidecar, when the thumbnail options of the file enable the filmstrip.
+// The whole video is read, a partially downloaded one is completed first.
+func generateFilmstrip(ctx context.Context, store ThumbnailStore, filePath string, source *videoSource, meta *VideoMeta) {
+	if !thumbnailOptionsFrom(ctx).filmstrip() {
+		return
+	}
+	if err := source.complete(ctx); err != nil {
+		logrus.Printf("获取完整视频失败，跳过精灵图: %v", err)
+		return
+	}
+	var duration float64
+	if meta != nil {
+		duration = meta.Duration
+	}
+	if duration <= 0 {
+		var err error
+		if duration, err = getVideoDuration(ctx, source.Path); err != nil || duration <= 0 {
+			logrus.Printf("获取视频时长失败，跳过精灵图: %v", err)
+			return
+		}
+	}
+	filmstrip := newFilmstrip(duration, setting.GetInt(conf.ThumbnailFilmstripFrames, defaultFilmstripFrames))
+	tempFile, err := os.CreateTemp(os.TempDir(), "filmstrip_*"+thumbnailOptionsFrom(ctx).format().Ext)
+	if err != nil {
+		logrus.Printf("创建本地临时文件失败: %v", err)
+		return
+	}
+	tempFilePath := tempFile.Name()
+	_ = tempFile.Close()
+	defer func() {
+		if err := os.Remove(tempFilePath); err != nil {
+			logrus.Printf("清理临时文件失败: %v", err)
+		}
+	}()
+	if err = extractFilmstrip(ctx, source.Path, tempFilePath, filmstrip); err != nil {
+		logrus.Printf("生成精灵图失败: %v", err)
+		return
+	}
+	if err = uploadThumbnail(ctx, store, filmstripSource(filePath), tempFilePath); err != nil {
+		logrus.Printf("%v", err)
+		return
+	}
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil {
+		sidecar = &MediaSidecar{}
+	}
+	sidecar.Filmstrip = &filmstrip
+	if err = writeMediaSidecar(ctx, filePath, sidecar); err != nil {
+		logrus.Printf("保存精灵图信息失败: %v", err)
+	}
+}
+
+// deleteFilmstrip removes the sprite of filePath and its layout from the sidecar
+func deleteFilmstrip(ctx context.Context, store ThumbnailStore, filePath string) {
+	source := filmstripSource(filePath)
+	if exists, err := store.Exists(ctx, source); err == nil && exists {
+		if err = store.Delete(ctx, source); err != nil {
+			logrus.Printf("删除精灵图失败: %v", err)
+		}
+	}
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil || sidecar.Filmstrip == nil {
+		return
+	}
+	sidecar.Filmstrip = nil
+	if err = writeMediaSidecar(ctx, filePath, sidecar); err != nil {
+		logrus.Printf("保存精灵图信息失败: %v", err)
+	}
+}
+
+type FilmstripReq struct {
+	MediaPathReq
+	// Format is vtt for the WebVTT of the sprite, the sprite is served otherwise
+	Format string `json:"format" form:"format"`
+}
+
+// FsFilmstrip serves the filmstrip sprite of the video, or with format=vtt the WebVTT
+// mapping its time ranges to the tiles of the sprite, for hover-scrubbing
+func FsFilmstrip(c *gin.Context) {
+	var req FilmstripReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := resolveReadablePath(c, req.Path, req.Password)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if req.Format == "vtt" {
+		sidecar, err := readMediaSidecar(ctx, reqPath)
+		if err != nil || sidecar.Filmstrip == nil {
+			common.ErrorStrResp(c, "filmstrip not found", 404)
+			return
+		}
+		// relative to the URL of the WebVTT itself
+		spriteURL := "filmstrip?path=" + url.QueryEscape(req.Path)
+		if req.Password != "" {
+			spriteURL += "&password=" + url.QueryEscape(req.Password)
+		}
+		c.Data(http.StatusOK, "text/vtt; charset=utf-8", sidecar.Filmstrip.vtt(spriteURL))
+		return
+	}
+	data, err := getThumbnailStore().Get(ctx, filmstripSource(reqPath), maxThumbnailSize)
+	if err != nil {
+		common.ErrorStrResp(c, "filmstrip not found", 404)
+		return
+	}
+	etag := `"` + utils.HashData(utils.MD5, data) + `"`
+	setThumbnailCacheHeaders(c, etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, thumbnailMimetype(data), data)
+}
@@ -0,0 +1,35 @@
+package handles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewFilmstrip(t *testing.T) {
+	f := newFilmstrip(100, 1000)
+	if f.Frames != maxFilmstripFrames || f.rows()*f.TileHeight > 2700 {
+		t.Errorf("frames should be bounded, got %+v", f)
+	}
+	if f = newFilmstrip(10, 4); f.Columns != 4 || f.rows() != 1 || f.Interval != 2.5 {
+		t.Errorf("few frames should fit a single row, got %+v", f)
+	}
+}
+
+func TestFilmstripVTT(t *testing.T) {
+	vtt := string(newFilmstrip(25, 12).vtt("filmstrip?path=%2Fa.mp4"))
+	if !strings.HasPrefix(vtt, "WEBVTT\n") {
+		t.Fatalf("missing header: %q", vtt)
+	}
+	if got := strings.Count(vtt, " --> "); got != 12 {
+		t.Errorf("got %d cues, want 12", got)
+	}
+	first := "\n00:00:00.000 --> 00:00:02.083\nfilmstrip?path=%2Fa.mp4#xywh=0,0,160,90\n"
+	if !strings.Contains(vtt, first) {
+		t.Errorf("missing first cue in %q", vtt)
+	}
+	// the 11th frame starts the second row, the last cue ends with the video
+	last := "\n00:00:22.917 --> 00:00:25.000\nfilmstrip?path=%2Fa.mp4#xywh=160,90,160,90\n"
+	if !strings.HasSuffix(vtt, last) {
+		t.Errorf("unexpected last cue in %q", vtt)
+	}
+}
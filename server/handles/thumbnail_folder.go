@@ -0,0 +1,93 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	stdpath "path"
+	"sort"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// folderPosterPath is the thumbnail of a directory put there by hand
+func folderPosterPath() string {
+	return stdpath.Join(setting.ThumbnailDirName(), "folder.webp")
+}
+
+// folderPosterNames are images in a directory used as its thumbnail, in order of preference
+var folderPosterNames = []string{"poster.webp", "poster.jpg", "poster.png", "folder.jpg"}
+
+var errNoFolderThumbnail = errors.New("directory has no thumbnail")
+
+// resolveFolderThumbnail finds the thumbnail of the directory: its poster, otherwise
+// the stored thumbnail of its first video by name. poster tells whether path is the
+// image itself or the video whose stored thumbnail is used.
+func resolveFolderThumbnail(ctx context.Context, dirPath string) (path string, poster bool, err error) {
+	if exists, _ := checkFileExists(ctx, stdpath.Join(dirPath, folderPosterPath())); exists {
+		return stdpath.Join(dirPath, folderPosterPath()), true, nil
+	}
+	objs, err := fs.List(ctx, dirPath, &fs.ListArgs{NoLog: true})
+	if err != nil {
+		return "", false, err
+	}
+	names := make(map[string]string, len(objs))
+	var videos []string
+	for _, obj := range objs {
+		if obj.IsDir() {
+			continue
+		}
+		names[strings.ToLower(obj.GetName())] = obj.GetName()
+		if utils.GetFileType(obj.GetName()) == conf.VIDEO {
+			videos = append(videos, obj.GetName())
+		}
+	}
+	for _, poster := range folderPosterNames {
+		if name, ok := names[poster]; ok {
+			return stdpath.Join(dirPath, name), true, nil
+		}
+	}
+	sort.Strings(videos)
+	store := getThumbnailStore()
+	for _, video := range videos {
+		if exists, err := store.Exists(ctx, stdpath.Join(dirPath, video)); err == nil && exists {
+			return stdpath.Join(dirPath, video), false, nil
+		}
+	}
+	return "", false, errNoFolderThumbnail
+}
+
+// folderThumbnail reads the thumbnail of the directory
+func folderThumbnail(ctx context.Context, dirPath string) ([]byte, error) {
+	path, poster, err := resolveFolderThumbnail(ctx, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	if poster {
+		return readFileContent(ctx, path, maxThumbnailSize)
+	}
+	return getThumbnailStore().Get(ctx, path, maxThumbnailSize)
+}
+
+// folderThumbnails points the thumbnail of the listed directories at /api/fs/thumbnail when
+// conf.FolderThumbnails is enabled. The directories aren't looked into here, the endpoint
+// resolves the thumbnail when it's requested and answers 404 for those without one.
+// reqParent is parent as requested, without the base path.
+func folderThumbnails(c *gin.Context, reqParent string, content []ObjResp) {
+	if !setting.GetBool(conf.FolderThumbnails) {
+		return
+	}
+	for i := range content {
+		obj := &content[i]
+		if !obj.IsDir || obj.Thumb != "" {
+			continue
+		}
+		obj.Thumb = common.GetApiUrl(c) + "/api/fs/thumbnail?path=" + url.QueryEscape(stdpath.Join("/", reqParent, obj.Name))
+	}
+}
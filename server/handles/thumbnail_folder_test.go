@@ -0,0 +1,60 @@
+package handles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+func TestResolveFolderThumbnail(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	for _, name := range []string{
+		"custom/.thumbnails/folder.webp",
+		"custom/poster.jpg",
+		"poster/Poster.JPG",
+		"poster/ep1.mkv",
+		"videos/ep2.mkv",
+		"videos/ep1.mkv",
+		"videos/ep3.mkv",
+		"videos/.thumbnails/ep2.webp",
+		"videos/.thumbnails/ep3.webp",
+		"none/ep1.mkv",
+		"none/notes.txt",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addition, _ := utils.Json.MarshalToString(map[string]string{"root_folder_path": root})
+	if _, err := op.CreateStorage(ctx, model.Storage{Driver: "Local", MountPath: "/folderthumb", Addition: addition}); err != nil {
+		t.Fatalf("failed to create storage: %+v", err)
+	}
+	tests := []struct {
+		dir    string
+		path   string
+		poster bool
+	}{
+		{"/folderthumb/custom", "/folderthumb/custom/.thumbnails/folder.webp", true},
+		{"/folderthumb/poster", "/folderthumb/poster/Poster.JPG", true},
+		{"/folderthumb/videos", "/folderthumb/videos/ep2.mkv", false},
+	}
+	for _, tt := range tests {
+		path, poster, err := resolveFolderThumbnail(ctx, tt.dir)
+		if err != nil || path != tt.path || poster != tt.poster {
+			t.Errorf("resolveFolderThumbnail(%s) = %s, %v, %v, want %s, %v", tt.dir, path, poster, err, tt.path, tt.poster)
+		}
+	}
+	if _, _, err := resolveFolderThumbnail(ctx, "/folderthumb/none"); err == nil {
+		t.Error("directory without poster or video thumbnail resolved")
+	}
+}
@@ -0,0 +1,143 @@
+package handles
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// thumbnailFormat is the image format thumbnails are encoded in. The stored thumbnail keeps
+// its .webp name whatever the format, so thumbnails are found after the encoder changes,
+// and the served content type is sniffed from the data
+type thumbnailFormat struct {
+	Name     string
+	Encoder  string
+	Ext      string
+	Mimetype string
+	Args     []string
+}
+
+var thumbnailFormats = []thumbnailFormat{
+	{Name: "webp", Encoder: "libwebp", Ext: ".webp", Mimetype: "image/webp", Args: []string{
+		"-c:v", "libwebp", // 使用WebP编码器，质量参数见encoderArgs
+		"-lossless", "0", // 非无损压缩（节省空间）
+		"-compression_level", "6", // 压缩级别（0-9，默认6）
+	}},
+	{Name: "jpeg", Encoder: "mjpeg", Ext: ".jpg", Mimetype: "image/jpeg", Args: []string{
+		"-c:v", "mjpeg",
+		"-q:v", "3", // 质量参数（2-31，越小越好）
+		"-pix_fmt", "yuvj420p",
+	}},
+	{Name: "png", Encoder: "png", Ext: ".png", Mimetype: "image/png", Args: []string{
+		"-c:v", "png",
+	}},
+}
+
+var (
+	detectedThumbnailFormat thumbnailFormat
+	detectedEncoders        map[string]bool
+	thumbnailFormatOnce     sync.Once
+)
+
+func thumbnailFormatByName(name string) *thumbnailFormat {
+	for i := range thumbnailFormats {
+		if thumbnailFormats[i].Name == name {
+			return &thumbnailFormats[i]
+		}
+	}
+	return nil
+}
+
+// thumbnailEncoderAvailable reports whether ffmpeg has the encoder, only libwebp is assumed
+// when the encoders couldn't be listed
+func thumbnailEncoderAvailable(encoder string) bool {
+	getThumbnailFormat()
+	if detectedEncoders == nil {
+		return encoder == thumbnailFormats[0].Encoder
+	}
+	return detectedEncoders[encoder]
+}
+
+// parseFFmpegEncoders returns the encoder names listed by "ffmpeg -encoders",
+// whose lines look like " V....D libwebp    libwebp WebP image (codec webp)"
+func parseFFmpegEncoders(output []byte) map[string]bool {
+	encoders := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	listing := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// the legend above the list ends with " ------"
+		if !listing {
+			listing = line != "" && strings.Trim(line, "-") == ""
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
+
+// getThumbnailFormat returns the first of thumbnailFormats ffmpeg can encode,
+// it's detected once and falls back to WebP when ffmpeg can't be queried
+func getThumbnailFormat() thumbnailFormat {
+	thumbnailFormatOnce.Do(func() {
+		detectedThumbnailFormat = thumbnailFormats[0]
+		output, err := runFFmpeg(context.Background(), "-hide_banner", "-encoders")
+		if err != nil {
+			logrus.Warnf("获取FFmpeg编码器列表失败，缩略图使用WebP格式: %v", err)
+			return
+		}
+		detectedEncoders = parseFFmpegEncoders(output)
+		for _, format := range thumbnailFormats {
+			if detectedEncoders[format.Encoder] {
+				detectedThumbnailFormat = format
+				break
+			}
+		}
+		if detectedThumbnailFormat.Encoder != thumbnailFormats[0].Encoder {
+			logrus.Warnf("FFmpeg不支持libwebp，缩略图改用%s格式", detectedThumbnailFormat.Mimetype)
+		}
+	})
+	return detectedThumbnailFormat
+}
+
+// thumbnailMimetype sniffs the format of a stored thumbnail, those written before a fallback
+// was detected are still WebP
+func thumbnailMimetype(data []byte) string {
+	mimetype := http.DetectContentType(data)
+	if !strings.HasPrefix(mimetype, "image/") {
+		return "image/webp"
+	}
+	return mimetype
+}
+
+// 验证缩略图文件有效性，格式为生成时使用的格式
+func validateThumbnailFile(path string, format thumbnailFormat) error {
+	if format.Mimetype == "image/webp" {
+		return validateWebPFile(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+	_, name, err := image.DecodeConfig(file)
+	if err != nil {
+		return fmt.Errorf("%s文件头无效: %w", format.Mimetype, err)
+	}
+	if "image/"+name != format.Mimetype {
+		return fmt.Errorf("文件格式为%s，应为%s", name, format.Mimetype)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package handles
+
+import "testing"
+
+func TestParseFFmpegEncoders(t *testing.T) {
+	output := []byte(`Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D mjpeg                MJPEG (Motion JPEG)
+ V....D png                  PNG (Portable Network Graphics) image
+ A....D aac                  AAC (Advanced Audio Coding)
+`)
+	encoders := parseFFmpegEncoders(output)
+	for _, name := range []string{"mjpeg", "png", "aac"} {
+		if !encoders[name] {
+			t.Errorf("encoder %s not found", name)
+		}
+	}
+	if encoders["libwebp"] || encoders["="] {
+		t.Errorf("unexpected encoders %v", encoders)
+	}
+}
+
+func TestAcceptedMimetypes(t *testing.T) {
+	got := acceptedMimetypes("image/webp;q=0.8, image/jpeg, image/png;q=0, */*;q=0.1")
+	want := []string{"image/jpeg", "image/webp", "*/*"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if got := acceptedMimetypes(""); len(got) != 0 {
+		t.Errorf("empty Accept gave %v", got)
+	}
+}
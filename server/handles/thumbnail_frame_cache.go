@@ -0,0 +1,142 @@
+package handles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/singleflight"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type cachedFrame struct {
+	path     string
+	size     int64
+	refs     int
+	lastUsed time.Time
+}
+
+// frameCache keeps full resolution frames extracted from videos, keyed by video path and position,
+// so generating several outputs or retrying positions runs ffmpeg once per frame.
+// Frames in use are never evicted, the others expire after conf.ThumbnailFrameCacheTTL
+// or when the cache outgrows conf.ThumbnailFrameCacheSize.
+type frameCache struct {
+	sync.Mutex
+	frames map[string]*cachedFrame
+	total  int64
+	group  singleflight.Group[int64]
+}
+
+var videoFrames = &frameCache{frames: make(map[string]*cachedFrame)}
+
+func frameCacheDir() string {
+	return filepath.Join(conf.Conf.TempDir, "frame_cache")
+}
+
+func frameCacheTTL() time.Duration {
+	return time.Duration(setting.GetInt(conf.ThumbnailFrameCacheTTL, 60)) * time.Second
+}
+
+// frame returns the path of the frame of the video at pos, release must be called when done with it
+func (fc *frameCache) frame(ctx context.Context, videoPath string, pos utils.FramePosition) (string, func(), error) {
+	key := videoPath + "|" + pos.String()
+	path := filepath.Join(frameCacheDir(), utils.HashData(utils.SHA1, []byte(key))+".png")
+	fc.Lock()
+	frame, ok := fc.frames[key]
+	if ok {
+		frame.refs++
+		frame.lastUsed = time.Now()
+		fc.Unlock()
+		return frame.path, fc.releaser(frame), nil
+	}
+	fc.Unlock()
+
+	size, err, _ := fc.group.Do(key, func() (int64, error) {
+		if err := os.MkdirAll(frameCacheDir(), 0o700); err != nil {
+			return 0, err
+		}
+		if err := extractVideoFrame(ctx, videoPath, path, pos); err != nil {
+			_ = os.Remove(path)
+			return 0, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	fc.Lock()
+	defer fc.Unlock()
+	frame, ok = fc.frames[key]
+	if !ok {
+		// callers sharing the extraction insert it once
+		frame = &cachedFrame{path: path, size: size}
+		fc.frames[key] = frame
+		fc.total += size
+	}
+	frame.refs++
+	frame.lastUsed = time.Now()
+	fc.evict()
+	return frame.path, fc.releaser(frame), nil
+}
+
+func (fc *frameCache) releaser(frame *cachedFrame) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			fc.Lock()
+			frame.refs--
+			frame.lastUsed = time.Now()
+			fc.evict()
+			fc.Unlock()
+			// evict again once the frame expired, in case nothing else touches the cache
+			time.AfterFunc(frameCacheTTL()+time.Second, func() {
+				fc.Lock()
+				fc.evict()
+				fc.Unlock()
+			})
+		})
+	}
+}
+
+// evict removes the expired frames, then the least recently used ones while over the size limit.
+// It must be called with the lock held.
+func (fc *frameCache) evict() {
+	ttl := frameCacheTTL()
+	maxSize := int64(setting.GetInt(conf.ThumbnailFrameCacheSize, 64*1024*1024))
+	for key, frame := range fc.frames {
+		if frame.refs == 0 && time.Since(frame.lastUsed) >= ttl {
+			fc.remove(key, frame)
+		}
+	}
+	for fc.total > maxSize {
+		var oldestKey string
+		var oldest *cachedFrame
+		for key, frame := range fc.frames {
+			if frame.refs == 0 && (oldest == nil || frame.lastUsed.Before(oldest.lastUsed)) {
+				oldestKey, oldest = key, frame
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		fc.remove(oldestKey, oldest)
+	}
+}
+
+func (fc *frameCache) remove(key string, frame *cachedFrame) {
+	if err := os.Remove(frame.path); err != nil && !os.IsNotExist(err) {
+		logrus.Printf("清理缓存帧失败: %v", err)
+	}
+	delete(fc.frames, key)
+	fc.total -= frame.size
+}
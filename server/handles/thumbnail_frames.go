@@ -0,0 +1,100 @@
+package handles
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultThumbnailFrames is the order used before conf.ThumbnailFrames existed
+const defaultThumbnailFrames = "cover,3"
+
+var yavgRegexp = regexp.MustCompile(`lavfi\.signalstats\.YAVG=([0-9.]+)`)
+
+func thumbnailFramePositions() []utils.FramePosition {
+	positions, err := utils.ParseFramePositions(setting.GetStr(conf.ThumbnailFrames, defaultThumbnailFrames))
+	if err != nil {
+		logrus.Warnf("invalid %s: %+v", conf.ThumbnailFrames, err)
+		positions, _ = utils.ParseFramePositions(defaultThumbnailFrames)
+	}
+	return positions
+}
+
+// 计算图片的平均亮度（0-255）
+func frameLuminance(ctx context.Context, imagePath string) (float64, error) {
+	output, err := runFFmpeg(ctx,
+		"-v", "error",
+		"-i", imagePath,
+		"-vf", "signalstats,metadata=mode=print:key=lavfi.signalstats.YAVG:file=-",
+		"-f", "null", "-")
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", err, string(output))
+	}
+	match := yavgRegexp.FindSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("亮度信息缺失: %s", string(output))
+	}
+	return strconv.ParseFloat(string(match[1]), 64)
+}
+
+// isBlackFrame reports whether the mean luminance of the image is below conf.ThumbnailBlackThreshold,
+// a threshold of 0 disables the check
+func isBlackFrame(ctx context.Context, imagePath string) bool {
+	threshold := setting.GetInt(conf.ThumbnailBlackThreshold, 16)
+	if threshold <= 0 {
+		return false
+	}
+	luminance, err := frameLuminance(ctx, imagePath)
+	if err != nil {
+		logrus.Printf("检测黑帧失败: %v", err)
+		return false
+	}
+	return luminance < float64(threshold)
+}
+
+// extractVideoThumbnail tries the conf.ThumbnailFrames positions in order until one yields a frame
+// which isn't black. The last position is used as is, and when it fails the first black frame is kept.
+// Frames come from videoFrames, so positions already extracted for the video aren't extracted again.
+// The chosen frame is also encoded into the variants.
+func extractVideoThumbnail(ctx context.Context, videoPath, outputPath string, variants []*thumbnailVariant) error {
+	positions := thumbnailOptionsFrom(ctx).framePositions()
+	fallback := ""
+	var fallbackPos utils.FramePosition
+	var lastErr error
+	for i, pos := range positions {
+		framePath, release, err := videoFrames.frame(ctx, videoPath, pos)
+		if err != nil {
+			logrus.Printf("提取%s处缩略图失败: %v", pos, err)
+			lastErr = err
+			continue
+		}
+		defer release()
+		// the last position is taken as is, any frame beats none
+		if i < len(positions)-1 && isBlackFrame(ctx, framePath) {
+			logrus.Printf("%s处为黑帧，尝试下一个位置", pos)
+			if fallback == "" {
+				fallback, fallbackPos = framePath, pos
+			}
+			continue
+		}
+		return encodeVerifiedThumbnail(ctx, videoPath, framePath, outputPath, pos, variants)
+	}
+	if fallback != "" {
+		return encodeVerifiedThumbnail(ctx, videoPath, fallback, outputPath, fallbackPos, variants)
+	}
+	return lastErr
+}
+
+func encodeThumbnailFrame(ctx context.Context, framePath, outputPath string, variants []*thumbnailVariant) error {
+	if err := encodeThumbnail(ctx, framePath, outputPath); err != nil {
+		return err
+	}
+	encodeThumbnailVariants(ctx, framePath, variants)
+	return nil
+}
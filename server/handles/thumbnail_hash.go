@@ -0,0 +1,197 @@
+package handles
+
+import (
+	"context"
+	"image"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	defaultSimilarThreshold = 10
+	maxSimilarThreshold     = 32
+)
+
+// blurhash components of the placeholders, 4x3 suits the usual landscape thumbnails
+const (
+	blurhashXComponents = 4
+	blurhashYComponents = 3
+)
+
+// recordThumbnailHashes decodes the thumbnail of filePath once and stores the hashes enabled in the settings
+// into its sidecar: the perceptual hash, also indexed for FsSimilar, the blurhash placeholder and the dominant color
+func recordThumbnailHashes(ctx context.Context, filePath, thumbnailPath string) {
+	withPHash, withBlurhash := setting.GetBool(conf.ThumbnailPHash), setting.GetBool(conf.EnableBlurhash)
+	withColor := setting.GetBool(conf.EnableDominantColor)
+	if !withPHash && !withBlurhash && !withColor {
+		return
+	}
+	file, err := os.Open(thumbnailPath)
+	if err != nil {
+		logrus.Printf("打开缩略图失败: %v", err)
+		return
+	}
+	img, _, err := image.Decode(file)
+	_ = file.Close()
+	if err != nil {
+		logrus.Printf("解码缩略图失败: %v", err)
+		return
+	}
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil {
+		sidecar = &MediaSidecar{}
+	}
+	if withPHash {
+		phash := utils.PHash(img)
+		if err = op.SaveMediaHash(filePath, phash); err != nil {
+			logrus.Printf("保存感知哈希失败: %v", err)
+		}
+		sidecar.PHash = strconv.FormatUint(phash, 16)
+	}
+	if withBlurhash {
+		if sidecar.Blurhash, err = utils.Blurhash(img, blurhashXComponents, blurhashYComponents); err != nil {
+			logrus.Printf("计算blurhash失败: %v", err)
+		}
+	}
+	if withColor {
+		sidecar.DominantColor = utils.DominantColor(img)
+	}
+	if withBlurhash || withColor {
+		// 列表从数据库读取占位信息，不逐个读取sidecar
+		if err = op.SaveMediaPlaceholder(filePath, sidecar.Blurhash, sidecar.DominantColor); err != nil {
+			logrus.Printf("保存占位信息失败: %v", err)
+		}
+	}
+	if err = writeMediaSidecar(ctx, filePath, sidecar); err != nil {
+		logrus.Printf("保存缩略图哈希失败: %v", err)
+	}
+}
+
+// storedPlaceholders returns the blurhash and the dominant color of the thumbnail of path
+// enabled in the settings, empty when there are none
+func storedPlaceholders(path string) (blurhash, color string) {
+	withBlurhash, withColor := setting.GetBool(conf.EnableBlurhash), setting.GetBool(conf.EnableDominantColor)
+	if !withBlurhash && !withColor {
+		return "", ""
+	}
+	placeholder, err := op.GetMediaPlaceholder(path)
+	if err != nil {
+		return "", ""
+	}
+	if withBlurhash {
+		blurhash = placeholder.Blurhash
+	}
+	if withColor {
+		color = placeholder.DominantColor
+	}
+	return blurhash, color
+}
+
+// placeholders sets the blurhash and dominant color of the images and videos of a listing,
+// read from the database in a single query rather than from their sidecars
+func placeholders(parent string, content []ObjResp) {
+	withBlurhash, withColor := setting.GetBool(conf.EnableBlurhash), setting.GetBool(conf.EnableDominantColor)
+	if !withBlurhash && !withColor {
+		return
+	}
+	stored, err := op.GetMediaPlaceholders(parent)
+	if err != nil || len(stored) == 0 {
+		return
+	}
+	for i := range content {
+		obj := &content[i]
+		if obj.IsDir || (obj.Type != conf.VIDEO && obj.Type != conf.IMAGE) {
+			continue
+		}
+		placeholder, ok := stored[obj.Name]
+		if !ok {
+			continue
+		}
+		if withBlurhash {
+			obj.Blurhash = placeholder.Blurhash
+		}
+		if withColor {
+			obj.DominantColor = placeholder.DominantColor
+		}
+	}
+}
+
+type SimilarReq struct {
+	MediaPathReq
+	Threshold *int `json:"threshold" form:"threshold"`
+}
+
+type SimilarFile struct {
+	Path     string `json:"path"`
+	Distance int    `json:"distance"`
+}
+
+// FsSimilar lists the files whose thumbnail looks like the one of path, closest first
+func FsSimilar(c *gin.Context) {
+	var req SimilarReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	threshold := defaultSimilarThreshold
+	if req.Threshold != nil {
+		threshold = *req.Threshold
+	}
+	if threshold < 0 || threshold > maxSimilarThreshold {
+		common.ErrorStrResp(c, "threshold must be between 0 and "+strconv.Itoa(maxSimilarThreshold), 400)
+		return
+	}
+	reqPath, ok := resolveReadablePath(c, req.Path, req.Password)
+	if !ok {
+		return
+	}
+	phash, err := op.GetMediaHash(reqPath)
+	if err != nil {
+		common.ErrorStrResp(c, "perceptual hash not found", 404)
+		return
+	}
+	hashes, err := op.GetMediaHashes()
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	similar := make([]SimilarFile, 0)
+	for _, h := range hashes {
+		distance := utils.HammingDistance(phash, uint64(h.PHash))
+		if h.Path == reqPath || distance > threshold || !utils.IsSubPath(user.BasePath, h.Path) {
+			continue
+		}
+		meta, err := op.GetNearestMeta(h.Path)
+		if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+			continue
+		}
+		if !common.CanAccess(user, meta, h.Path, req.Password) {
+			continue
+		}
+		// files deleted since are dropped from the index
+		if _, err := fs.Get(c.Request.Context(), h.Path, &fs.GetArgs{NoLog: true}); errs.IsObjectNotFound(err) {
+			_ = op.DeleteMediaHash(h.Path)
+			continue
+		}
+		similar = append(similar, SimilarFile{Path: h.Path, Distance: distance})
+	}
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].Distance < similar[j].Distance
+	})
+	common.SuccessResp(c, similar)
+}
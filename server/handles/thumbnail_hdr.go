@@ -0,0 +1,61 @@
+package handles
+
+import (
+	"context"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// toneMappingFilter converts PQ/HLG frames to BT.709 SDR, it runs at the original resolution
+// so encodeThumbnail scales the tone-mapped frame
+const toneMappingFilter = "zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709," +
+	"tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p"
+
+// isHDRTransfer reports whether the color_transfer reported by ffprobe is PQ or HLG
+func isHDRTransfer(transfer string) bool {
+	switch strings.TrimSpace(transfer) {
+	case "smpte2084", "arib-std-b67":
+		return true
+	}
+	return false
+}
+
+// 通过ffprobe的色彩元数据判断视频是否为HDR
+func isHDRVideo(ctx context.Context, videoPath string) bool {
+	output, err := runFFprobe(ctx,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=color_transfer",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		videoPath)
+	if err != nil {
+		logrus.Printf("获取视频色彩信息失败: %v", err)
+		return false
+	}
+	return isHDRTransfer(string(output))
+}
+
+// extractVideoFrame extracts the frame at pos at the original resolution. With conf.ThumbnailToneMapping
+// frames of HDR videos are tone-mapped, and extracted again without it when the filter fails,
+// e.g. because ffmpeg lacks zscale
+func extractVideoFrame(ctx context.Context, videoPath, outputPath string, pos utils.FramePosition) error {
+	extract := func(filters ...string) error {
+		if pos.Cover {
+			return extractVideoCover(ctx, videoPath, outputPath, filters...)
+		}
+		return extractVideoFrameAtPercentage(ctx, videoPath, outputPath, pos.Percent, filters...)
+	}
+	if !setting.GetBool(conf.ThumbnailToneMapping) || !isHDRVideo(ctx, videoPath) {
+		return extract()
+	}
+	err := extract("-vf", toneMappingFilter)
+	if err == nil {
+		return nil
+	}
+	logrus.Printf("HDR色调映射失败，改为直接提取: %v", err)
+	return extract()
+}
@@ -0,0 +1,150 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// exifProbeSize bounds how much of an image is read to find its EXIF orientation
+const exifProbeSize = 256 * 1024
+
+// orientationFilters are the ffmpeg filters turning an image with the EXIF orientation upright
+var orientationFilters = map[int]string{
+	2: "hflip",
+	3: "hflip,vflip",
+	4: "vflip",
+	5: "transpose=0",
+	6: "transpose=1",
+	7: "transpose=3",
+	8: "transpose=2",
+}
+
+// generateThumbnail generates the thumbnail of an image or a video
+func generateThumbnail(ctx context.Context, filePath string, user *model.User) {
+	if !ffmpegAvailable() {
+		return
+	}
+	thumbnailsGenerating.Store(filePath, struct{}{})
+	defer thumbnailsGenerating.Delete(filePath)
+	thumbnailSlots.acquire()
+	defer thumbnailSlots.release()
+	ctx = resolveThumbnailOptions(ctx, filePath)
+	ctx, done := startThumbnailLog(ctx, filePath)
+	defer done()
+	if !thumbnailStorageSupported(filePath) {
+		logrus.Printf("%s所在存储不是本地文件，无法生成缩略图", filePath)
+		failThumbnail(ctx, thumbnailStageGet, errors.New("storage has no local files"))
+		return
+	}
+	if strings.HasPrefix(utils.GetMimeType(filePath), "image/") {
+		generateImageThumbnail(ctx, filePath, user)
+		return
+	}
+	generateVideoThumbnail(ctx, filePath, user)
+}
+
+// 生成图片缩略图（WebP格式，不支持时见getThumbnailFormat）
+func generateImageThumbnail(ctx context.Context, filePath string, user *model.User) {
+	fileObj, err := fs.Get(ctx, filePath, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		logrus.Printf("获取图片文件信息失败: %v", err)
+		failThumbnail(ctx, thumbnailStageGet, err)
+		return
+	}
+	imageAbsPath := fileObj.GetPath()
+	if imageAbsPath == "" {
+		logrus.Printf("图片文件绝对路径为空")
+		failThumbnail(ctx, thumbnailStageGet, errors.New("empty local path"))
+		return
+	}
+
+	store := getThumbnailStore()
+	removeStaleThumbnail(ctx, store, filePath, fileObj)
+	if !thumbnailNeeded(ctx, store, filePath) {
+		return
+	}
+
+	tempFile, err := os.CreateTemp(os.TempDir(), "image_thumb_*"+thumbnailOptionsFrom(ctx).format().Ext)
+	if err != nil {
+		logrus.Printf("创建本地临时文件失败: %v", err)
+		failThumbnail(ctx, thumbnailStageTemp, err)
+		return
+	}
+	tempFilePath := tempFile.Name()
+	_ = tempFile.Close()
+	defer func() {
+		if err := os.Remove(tempFilePath); err != nil {
+			logrus.Printf("清理临时文件失败: %v", err)
+		}
+	}()
+
+	if err := extractImageThumbnail(ctx, imageAbsPath, tempFilePath); err != nil {
+		logrus.Printf("生成图片缩略图失败: %v", err)
+		failThumbnail(ctx, thumbnailStageExtract, err)
+		return
+	}
+	variants, cleanupVariants := newThumbnailVariants(ctx, "image_thumb_*")
+	defer cleanupVariants()
+	for _, v := range variants {
+		if err := extractImageThumbnail(withThumbnailFormat(ctx, v.format), imageAbsPath, v.path); err != nil {
+			logrus.Printf("生成%s格式缩略图失败: %v", v.format.Name, err)
+			continue
+		}
+		v.encoded = true
+	}
+	if err := uploadThumbnail(ctx, store, filePath, tempFilePath); err != nil {
+		logrus.Printf("%v", err)
+		failThumbnail(ctx, thumbnailStageUpload, err)
+		return
+	}
+	storeThumbnailVariants(ctx, store, filePath, variants)
+	recordThumbnailSource(ctx, filePath, fileObj)
+	recordThumbnailHashes(ctx, filePath, tempFilePath)
+	logrus.Printf("缩略图生成并上传成功: 临时文件=%s, 目标路径=%s", tempFilePath, store.PathFor(filePath))
+}
+
+// readImageOrientation returns the EXIF orientation of the image, 1 when it has none
+func readImageOrientation(imagePath string) int {
+	f, err := os.Open(imagePath)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+	orientation, err := utils.ReadExifOrientation(io.LimitReader(f, exifProbeSize))
+	if err != nil {
+		return 1
+	}
+	return orientation
+}
+
+// 按EXIF方向摆正图片后生成缩略图
+func extractImageThumbnail(ctx context.Context, imagePath, outputPath string) error {
+	options := thumbnailOptionsFrom(ctx)
+	filters := fmt.Sprintf("scale=%d:-1", options.width())
+	if f, ok := orientationFilters[readImageOrientation(imagePath)]; ok {
+		filters = f + "," + filters
+	}
+	args := []string{
+		"-noautorotate", // 方向由EXIF读取后显式处理，避免重复旋转
+		"-i", imagePath,
+		"-vf", filters,
+		"-frames:v", "1",
+	}
+	args = append(args, options.encoderArgs()...)
+	args = append(args, "-y", outputPath)
+	output, err := runFFmpeg(ctx, args...)
+	if err != nil {
+		logrus.Printf("FFmpeg图片缩略图输出: %s", string(output))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package handles
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	dB, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	conf.Conf = conf.DefaultConfig("data")
+	db.Init(dB)
+}
+
+// rotatedJPEG encodes a landscape jpeg tagged with the EXIF orientation,
+// which is displayed as portrait for the orientations 5-8
+func rotatedJPEG(t *testing.T, width, height int, orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 128, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	// little endian TIFF with a single IFD holding the orientation
+	var tiff bytes.Buffer
+	tiff.WriteString("II")
+	_ = binary.Write(&tiff, binary.LittleEndian, []uint16{42})
+	_ = binary.Write(&tiff, binary.LittleEndian, []uint32{8})
+	_ = binary.Write(&tiff, binary.LittleEndian, []uint16{1, 0x0112, 3})
+	_ = binary.Write(&tiff, binary.LittleEndian, []uint32{1})
+	_ = binary.Write(&tiff, binary.LittleEndian, []uint16{orientation, 0})
+	_ = binary.Write(&tiff, binary.LittleEndian, []uint32{0})
+	app1 := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:2]) // SOI
+	out.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(app1)+2))
+	out.Write(app1)
+	out.Write(encoded.Bytes()[2:])
+	return out.Bytes()
+}
+
+func probeDimensions(t *testing.T, path string) (int, int) {
+	output, err := exec.Command("ffprobe", "-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		"-of", "csv=p=0:s=x", path).Output()
+	if err != nil {
+		t.Fatalf("ffprobe failed: %v", err)
+	}
+	var width, height int
+	if _, err = fmt.Sscanf(strings.TrimSpace(string(output)), "%dx%d", &width, &height); err != nil {
+		t.Fatalf("unexpected ffprobe output %q: %v", output, err)
+	}
+	return width, height
+}
+
+func TestExtractImageThumbnailAutoOrient(t *testing.T) {
+	for _, bin := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not found", bin)
+		}
+	}
+	dir := t.TempDir()
+	testCases := []struct {
+		orientation uint16
+		portrait    bool
+	}{
+		{1, false},
+		{3, false},
+		{6, true},
+		{8, true},
+	}
+	for _, tc := range testCases {
+		src := filepath.Join(dir, fmt.Sprintf("rotated_%d.jpg", tc.orientation))
+		if err := os.WriteFile(src, rotatedJPEG(t, 64, 32, tc.orientation), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := readImageOrientation(src); got != int(tc.orientation) {
+			t.Errorf("orientation %d: read %d", tc.orientation, got)
+		}
+		out := filepath.Join(dir, fmt.Sprintf("rotated_%d", tc.orientation)+getThumbnailFormat().Ext)
+		if err := extractImageThumbnail(context.Background(), src, out); err != nil {
+			t.Fatalf("orientation %d: %v", tc.orientation, err)
+		}
+		if err := validateThumbnailFile(out, getThumbnailFormat()); err != nil {
+			t.Errorf("orientation %d: invalid thumbnail: %v", tc.orientation, err)
+		}
+		width, height := probeDimensions(t, out)
+		if portrait := height > width; portrait != tc.portrait {
+			t.Errorf("orientation %d: expected portrait=%v, got %dx%d", tc.orientation, tc.portrait, width, height)
+		}
+	}
+}
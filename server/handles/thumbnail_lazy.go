@@ -0,0 +1,167 @@
+package handles
+
+import (
+	"context"
+	stdpath "path"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// lazyThumbnailQueueSize bounds the thumbnails waiting for the lazy worker,
+// further ones are dropped and retried on a later listing
+const lazyThumbnailQueueSize = 256
+
+type lazyThumbnail struct {
+	path string
+	user *model.User
+}
+
+var (
+	lazyThumbnailQueue = make(chan lazyThumbnail, lazyThumbnailQueueSize)
+	// lazyThumbnailQueued dedups paths queued or being generated
+	lazyThumbnailQueued sync.Map
+	lazyThumbnailWorker sync.Once
+
+	lazyThumbnailLimiterLock sync.Mutex
+	lazyThumbnailLimiter     *rate.Limiter
+	lazyThumbnailRate        int
+)
+
+// wantsThumbnail reports whether thumbnails are generated for the object at all
+func wantsThumbnail(obj *ObjResp) bool {
+	if obj.IsDir || obj.Thumb != "" {
+		return false
+	}
+	return obj.Type == conf.VIDEO || obj.Type == conf.IMAGE && setting.GetBool(conf.ImageThumbnails)
+}
+
+// allowLazyThumbnail applies conf.LazyThumbnailRate, the limiter is rebuilt when the setting changes
+func allowLazyThumbnail() bool {
+	perMinute := setting.GetInt(conf.LazyThumbnailRate, 60)
+	if perMinute <= 0 {
+		return false
+	}
+	lazyThumbnailLimiterLock.Lock()
+	defer lazyThumbnailLimiterLock.Unlock()
+	if lazyThumbnailLimiter == nil || lazyThumbnailRate != perMinute {
+		lazyThumbnailLimiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute)
+		lazyThumbnailRate = perMinute
+	}
+	return lazyThumbnailLimiter.Allow()
+}
+
+// runLazyThumbnails generates the queued thumbnails one at a time, so browsing
+// never competes with uploads for more than a single ffmpeg
+func runLazyThumbnails() {
+	for item := range lazyThumbnailQueue {
+		if thumbnailWindowOpen() {
+			generateThumbnail(context.Background(), item.path, item.user)
+		} else if err := enqueueThumbnail(item.path, item.user, nil); err != nil {
+			log.Errorf("queue thumbnail of %s error: %+v", item.path, err)
+		}
+		lazyThumbnailQueued.Delete(item.path)
+	}
+}
+
+func enqueueLazyThumbnail(path string, user *model.User) {
+	if _, loaded := lazyThumbnailQueued.LoadOrStore(path, struct{}{}); loaded {
+		return
+	}
+	if !allowLazyThumbnail() {
+		lazyThumbnailQueued.Delete(path)
+		return
+	}
+	lazyThumbnailWorker.Do(func() {
+		go runLazyThumbnails()
+	})
+	select {
+	case lazyThumbnailQueue <- lazyThumbnail{path: path, user: user}:
+	default:
+		lazyThumbnailQueued.Delete(path)
+		log.Debugf("lazy thumbnail queue full, skip %s", path)
+	}
+}
+
+// lazyThumbnails queues the missing thumbnails of the listed objects when
+// conf.LazyThumbnails is enabled. The store is checked in the background, so the
+// listing isn't slowed down.
+func lazyThumbnails(parent string, content []ObjResp, user *model.User) {
+	if !setting.GetBool(conf.LazyThumbnails) {
+		return
+	}
+	var paths []string
+	for i := range content {
+		if wantsThumbnail(&content[i]) {
+			paths = append(paths, stdpath.Join(parent, content[i].Name))
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	go func() {
+		ctx := context.Background()
+		store := getThumbnailStore()
+		for _, path := range paths {
+			if _, queued := lazyThumbnailQueued.Load(path); queued {
+				continue
+			}
+			if exists, err := store.Exists(ctx, path); err == nil && exists {
+				continue
+			}
+			enqueueLazyThumbnail(path, user)
+		}
+	}()
+}
+
+// FsThumbnailGenerate queues the thumbnails of all images and videos directly in the dir,
+// they are generated in the thumbnail_schedule window like deferred upload thumbnails
+func FsThumbnailGenerate(c *gin.Context) {
+	var req MediaPathReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if !user.CanWrite() {
+		common.ErrorResp(c, errs.PermissionDenied, 403)
+		return
+	}
+	reqPath, ok := resolveReadablePath(c, req.Path, req.Password)
+	if !ok {
+		return
+	}
+	objs, err := fs.List(c.Request.Context(), reqPath, &fs.ListArgs{NoLog: true})
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	store := getThumbnailStore()
+	var paths []string
+	for _, obj := range toObjsResp(objs, reqPath, false) {
+		if !wantsThumbnail(&obj) {
+			continue
+		}
+		path := stdpath.Join(reqPath, obj.Name)
+		if exists, err := store.Exists(c.Request.Context(), path); err == nil && exists {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	queued, err := enqueueThumbnails(paths, user)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	go drainThumbnailQueue(false)
+	common.SuccessResp(c, gin.H{"queued": queued})
+}
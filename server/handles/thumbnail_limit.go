@@ -0,0 +1,121 @@
+package handles
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultThumbnailConcurrency = 2
+
+// thumbnailLimiter bounds the concurrent thumbnail generations. Unlike a channel semaphore
+// its limit can change while generations hold a slot: lowering it lets the running ones finish
+// and admits no more until they drop below the new limit
+type thumbnailLimiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int // 0 is unlimited
+	inFlight int
+}
+
+func newThumbnailLimiter(limit int) *thumbnailLimiter {
+	l := &thumbnailLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *thumbnailLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.limit > 0 && l.inFlight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inFlight++
+}
+
+func (l *thumbnailLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.cond.Signal()
+}
+
+func (l *thumbnailLimiter) setLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.cond.Broadcast()
+}
+
+func (l *thumbnailLimiter) stats() (limit, inFlight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.inFlight
+}
+
+var thumbnailSlots = newThumbnailLimiter(defaultThumbnailConcurrency)
+
+func parseThumbnailConcurrency(value string) (int, error) {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid %s: %s, expected a number >= 0", conf.ThumbnailConcurrency, value)
+	}
+	return limit, nil
+}
+
+func init() {
+	op.RegisterSettingItemHook(conf.ThumbnailConcurrency, func(item *model.SettingItem) error {
+		limit, err := parseThumbnailConcurrency(item.Value)
+		if err != nil {
+			return err
+		}
+		thumbnailSlots.setLimit(limit)
+		return nil
+	})
+}
+
+type ThumbnailConcurrencyResp struct {
+	Limit    int `json:"limit"`
+	InFlight int `json:"in_flight"`
+}
+
+// GetThumbnailConcurrency returns the limit of concurrent thumbnail generations and how many run
+func GetThumbnailConcurrency(c *gin.Context) {
+	limit, inFlight := thumbnailSlots.stats()
+	common.SuccessResp(c, ThumbnailConcurrencyResp{Limit: limit, InFlight: inFlight})
+}
+
+type SetThumbnailConcurrencyReq struct {
+	Limit *int `json:"limit" binding:"required"`
+}
+
+// SetThumbnailConcurrency changes the limit live and saves it to conf.ThumbnailConcurrency
+func SetThumbnailConcurrency(c *gin.Context) {
+	var req SetThumbnailConcurrencyReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	item, err := op.GetSettingItemByKey(conf.ThumbnailConcurrency)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	item.Value = strconv.Itoa(*req.Limit)
+	if _, err = parseThumbnailConcurrency(item.Value); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	// the setting hook resizes thumbnailSlots
+	if err = op.SaveSettingItem(item); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	GetThumbnailConcurrency(c)
+}
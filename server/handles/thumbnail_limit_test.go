@@ -0,0 +1,38 @@
+package handles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThumbnailLimiterResize(t *testing.T) {
+	l := newThumbnailLimiter(1)
+	l.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		l.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired beyond the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// raising the limit admits the waiter while the first slot is still held
+	l.setLimit(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiter not admitted after raising the limit")
+	}
+	// lowering it keeps the running ones
+	l.setLimit(1)
+	if limit, inFlight := l.stats(); limit != 1 || inFlight != 2 {
+		t.Errorf("got limit %d, in flight %d, want 1, 2", limit, inFlight)
+	}
+	l.release()
+	l.release()
+	if _, inFlight := l.stats(); inFlight != 0 {
+		t.Errorf("got in flight %d, want 0", inFlight)
+	}
+}
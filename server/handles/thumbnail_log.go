@@ -0,0 +1,150 @@
+package handles
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxThumbnailLogOutput bounds the ffmpeg output kept per attempt, the tail holds the error
+	maxThumbnailLogOutput = 16 << 10
+	maxThumbnailLogs      = 512
+	thumbnailLogTTL       = 24 * time.Hour
+)
+
+// stages of a thumbnail attempt
+const (
+	thumbnailStageGet     = "get"
+	thumbnailStageCheck   = "check"
+	thumbnailStageTemp    = "temp"
+	thumbnailStageExtract = "extract"
+	thumbnailStageUpload  = "upload"
+)
+
+// ThumbnailLog is the last thumbnail attempt of a file, Stage is the stage which failed
+type ThumbnailLog struct {
+	Path    string    `json:"path"`
+	Success bool      `json:"success"`
+	Stage   string    `json:"stage,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Output  string    `json:"output"`
+	Time    time.Time `json:"time"`
+}
+
+// thumbnailAttempt collects the ffmpeg output of a generation, see runFFmpeg
+type thumbnailAttempt struct {
+	mu      sync.Mutex
+	log     ThumbnailLog
+	skipped bool
+}
+
+type thumbnailAttemptKey struct{}
+
+func thumbnailAttemptFrom(ctx context.Context) *thumbnailAttempt {
+	attempt, _ := ctx.Value(thumbnailAttemptKey{}).(*thumbnailAttempt)
+	return attempt
+}
+
+// recordFFmpegOutput appends the output of an ffmpeg run to the attempt of ctx, if any
+func recordFFmpegOutput(ctx context.Context, args []string, output []byte) {
+	attempt := thumbnailAttemptFrom(ctx)
+	if attempt == nil {
+		return
+	}
+	attempt.mu.Lock()
+	defer attempt.mu.Unlock()
+	out := attempt.log.Output + "$ ffmpeg " + strings.Join(args, " ") + "\n" + string(output)
+	if len(out) > maxThumbnailLogOutput {
+		out = out[len(out)-maxThumbnailLogOutput:]
+	}
+	attempt.log.Output = out
+}
+
+// failThumbnail marks the attempt of ctx failed at stage
+func failThumbnail(ctx context.Context, stage string, err error) {
+	attempt := thumbnailAttemptFrom(ctx)
+	if attempt == nil {
+		return
+	}
+	attempt.mu.Lock()
+	defer attempt.mu.Unlock()
+	attempt.log.Stage = stage
+	attempt.log.Error = err.Error()
+}
+
+// skipThumbnailLog keeps the previous log when no thumbnail was needed
+func skipThumbnailLog(ctx context.Context) {
+	if attempt := thumbnailAttemptFrom(ctx); attempt != nil {
+		attempt.skipped = true
+	}
+}
+
+var thumbnailLogs = struct {
+	sync.Mutex
+	m map[string]ThumbnailLog
+}{m: make(map[string]ThumbnailLog)}
+
+// startThumbnailLog returns ctx recording the attempt and the func saving it once done
+func startThumbnailLog(ctx context.Context, filePath string) (context.Context, func()) {
+	attempt := &thumbnailAttempt{log: ThumbnailLog{Path: filePath}}
+	return context.WithValue(ctx, thumbnailAttemptKey{}, attempt), func() {
+		attempt.mu.Lock()
+		defer attempt.mu.Unlock()
+		if attempt.skipped {
+			return
+		}
+		attempt.log.Success = attempt.log.Stage == ""
+		attempt.log.Time = time.Now()
+		saveThumbnailLog(attempt.log)
+	}
+}
+
+func saveThumbnailLog(log ThumbnailLog) {
+	thumbnailLogs.Lock()
+	defer thumbnailLogs.Unlock()
+	for path, l := range thumbnailLogs.m {
+		if time.Since(l.Time) > thumbnailLogTTL {
+			delete(thumbnailLogs.m, path)
+		}
+	}
+	if _, ok := thumbnailLogs.m[log.Path]; !ok && len(thumbnailLogs.m) >= maxThumbnailLogs {
+		oldest := ""
+		for path, l := range thumbnailLogs.m {
+			if oldest == "" || l.Time.Before(thumbnailLogs.m[oldest].Time) {
+				oldest = path
+			}
+		}
+		delete(thumbnailLogs.m, oldest)
+	}
+	thumbnailLogs.m[log.Path] = log
+}
+
+func getThumbnailLog(path string) (ThumbnailLog, bool) {
+	thumbnailLogs.Lock()
+	defer thumbnailLogs.Unlock()
+	log, ok := thumbnailLogs.m[path]
+	if !ok || time.Since(log.Time) > thumbnailLogTTL {
+		return ThumbnailLog{}, false
+	}
+	return log, true
+}
+
+// ThumbnailLogGet returns the last thumbnail attempt of ?path=, with the ffmpeg output
+func ThumbnailLogGet(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		common.ErrorStrResp(c, "path is required", 400)
+		return
+	}
+	log, ok := getThumbnailLog(path)
+	if !ok {
+		common.ErrorStrResp(c, "no thumbnail attempt recorded", 404)
+		return
+	}
+	common.SuccessResp(c, log)
+}
@@ -0,0 +1,274 @@
+package handles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// thumbnailConfigName is the per-directory config applying to the files of its tree
+	thumbnailConfigName = ".thumbnail.json"
+	// maxThumbnailConfigSize bounds how much of a config is read
+	maxThumbnailConfigSize = 64 << 10
+	defaultThumbnailWidth  = 320
+	// defaultThumbnailQuality is the -q:v of libwebp, 0-100
+	defaultThumbnailQuality = 80
+)
+
+// ThumbnailOptions override the thumbnail settings, the fields left empty keep the value
+// of the lower precedence: Thumbnail-* headers > .thumbnail.json > global settings
+type ThumbnailOptions struct {
+	Width  int    `json:"width,omitempty"`
+	Frames string `json:"frames,omitempty"`
+	// Format is webp, jpeg or png
+	Format string `json:"format,omitempty"`
+	// Preset is the libwebp preset, see webpPresets
+	Preset string `json:"preset,omitempty"`
+	// Filmstrip also generates the sprite of evenly spaced frames of videos, see generateFilmstrip
+	Filmstrip *bool `json:"filmstrip,omitempty"`
+}
+
+// webpPresets are the values of the -preset of libwebp
+var webpPresets = []string{"default", "photo", "picture", "drawing", "icon", "text"}
+
+func (o *ThumbnailOptions) validate() error {
+	if o.Width != 0 && (o.Width < 16 || o.Width > 4096) {
+		return fmt.Errorf("width %d out of [16, 4096]", o.Width)
+	}
+	if o.Frames != "" {
+		if _, err := utils.ParseFramePositions(o.Frames); err != nil {
+			return err
+		}
+	}
+	if o.Format != "" && thumbnailFormatByName(o.Format) == nil {
+		return fmt.Errorf("unknown format %s", o.Format)
+	}
+	if o.Preset != "" && !utils.SliceContains(webpPresets, o.Preset) {
+		return fmt.Errorf("unknown preset %s", o.Preset)
+	}
+	return nil
+}
+
+// merge returns o with the fields set in over replaced
+func (o ThumbnailOptions) merge(over *ThumbnailOptions) ThumbnailOptions {
+	if over == nil {
+		return o
+	}
+	if over.Width != 0 {
+		o.Width = over.Width
+	}
+	if over.Frames != "" {
+		o.Frames = over.Frames
+	}
+	if over.Format != "" {
+		o.Format = over.Format
+	}
+	if over.Preset != "" {
+		o.Preset = over.Preset
+	}
+	if over.Filmstrip != nil {
+		o.Filmstrip = over.Filmstrip
+	}
+	return o
+}
+
+// width falls back to conf.ThumbnailWidth, and to defaultThumbnailWidth when the setting is out of range
+func (o ThumbnailOptions) width() int {
+	if o.Width != 0 {
+		return o.Width
+	}
+	if width := setting.GetInt(conf.ThumbnailWidth, defaultThumbnailWidth); width >= 16 && width <= 4096 {
+		return width
+	}
+	return defaultThumbnailWidth
+}
+
+// thumbnailQuality is conf.ThumbnailQuality, defaultThumbnailQuality when it's out of range
+func thumbnailQuality() int {
+	if quality := setting.GetInt(conf.ThumbnailQuality, defaultThumbnailQuality); quality >= 0 && quality <= 100 {
+		return quality
+	}
+	return defaultThumbnailQuality
+}
+
+func (o ThumbnailOptions) filmstrip() bool {
+	return o.Filmstrip != nil && *o.Filmstrip
+}
+
+func (o ThumbnailOptions) framePositions() []utils.FramePosition {
+	if o.Frames != "" {
+		if positions, err := utils.ParseFramePositions(o.Frames); err == nil {
+			return positions
+		}
+	}
+	return thumbnailFramePositions()
+}
+
+// format falls back to the detected format when ffmpeg can't encode the requested one
+func (o ThumbnailOptions) format() thumbnailFormat {
+	detected := getThumbnailFormat()
+	if o.Format == "" {
+		return detected
+	}
+	format := thumbnailFormatByName(o.Format)
+	if format == nil || !thumbnailEncoderAvailable(format.Encoder) {
+		logrus.Warnf("缩略图格式%s不可用，使用%s", o.Format, detected.Name)
+		return detected
+	}
+	return *format
+}
+
+// encoderArgs are the ffmpeg output args of the format, with the quality and the preset for libwebp
+func (o ThumbnailOptions) encoderArgs() []string {
+	format := o.format()
+	args := append([]string{}, format.Args...)
+	if format.Encoder == "libwebp" {
+		preset := o.Preset
+		if preset == "" {
+			preset = "default" // 预设：平衡质量和速度
+		}
+		args = append(args,
+			"-q:v", strconv.Itoa(thumbnailQuality()), // 质量参数（0-100），见conf.ThumbnailQuality
+			"-preset", preset)
+	}
+	return args
+}
+
+// thumbnailOptionsFromHeaders reads the Thumbnail-Width, Thumbnail-Frames, Thumbnail-Format, Thumbnail-Preset
+// and Thumbnail-Filmstrip headers of an upload, nil when none is sent. An unknown Thumbnail-Preset is ignored
+func thumbnailOptionsFromHeaders(c *gin.Context) (*ThumbnailOptions, error) {
+	o := &ThumbnailOptions{
+		Frames: c.GetHeader("Thumbnail-Frames"),
+		Format: strings.ToLower(c.GetHeader("Thumbnail-Format")),
+		Preset: strings.ToLower(c.GetHeader("Thumbnail-Preset")),
+	}
+	if o.Preset != "" && !utils.SliceContains(webpPresets, o.Preset) {
+		logrus.Warnf("忽略无效的缩略图预设%s", o.Preset)
+		o.Preset = ""
+	}
+	if width := c.GetHeader("Thumbnail-Width"); width != "" {
+		w, err := strconv.Atoi(width)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Thumbnail-Width: %s", width)
+		}
+		o.Width = w
+	}
+	if filmstrip := c.GetHeader("Thumbnail-Filmstrip"); filmstrip != "" {
+		f, err := strconv.ParseBool(filmstrip)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Thumbnail-Filmstrip: %s", filmstrip)
+		}
+		o.Filmstrip = &f
+	}
+	if *o == (ThumbnailOptions{}) {
+		return nil, nil
+	}
+	if err := o.validate(); err != nil {
+		return nil, fmt.Errorf("invalid thumbnail headers: %w", err)
+	}
+	return o, nil
+}
+
+type thumbnailConfigEntry struct {
+	modified time.Time
+	options  *ThumbnailOptions
+}
+
+// thumbnailConfigs caches the parsed configs by path, an entry is valid while the
+// modification time of the config is unchanged
+var thumbnailConfigs sync.Map
+
+// loadThumbnailConfig returns the options of the .thumbnail.json in dir, nil when there is none
+// or it's malformed
+func loadThumbnailConfig(ctx context.Context, dir string) *ThumbnailOptions {
+	path := stdpath.Join(dir, thumbnailConfigName)
+	obj, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+	if err != nil || obj.IsDir() {
+		thumbnailConfigs.Delete(path)
+		return nil
+	}
+	if v, ok := thumbnailConfigs.Load(path); ok && v.(thumbnailConfigEntry).modified.Equal(obj.ModTime()) {
+		return v.(thumbnailConfigEntry).options
+	}
+	options, err := parseThumbnailConfig(ctx, path)
+	if err != nil {
+		logrus.Warnf("忽略无效的缩略图配置%s: %v", path, err)
+	}
+	thumbnailConfigs.Store(path, thumbnailConfigEntry{modified: obj.ModTime(), options: options})
+	return options
+}
+
+func parseThumbnailConfig(ctx context.Context, path string) (*ThumbnailOptions, error) {
+	data, err := readFileContent(ctx, path, maxThumbnailConfigSize)
+	if err != nil {
+		return nil, err
+	}
+	var options ThumbnailOptions
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&options); err != nil {
+		return nil, err
+	}
+	if err = options.validate(); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// folderThumbnailOptions merges the configs from the root down to the dir of the file,
+// so the nearest config wins
+func folderThumbnailOptions(ctx context.Context, filePath string) ThumbnailOptions {
+	var dirs []string
+	for dir := stdpath.Dir(filePath); ; dir = stdpath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == "/" || dir == "." {
+			break
+		}
+	}
+	var options ThumbnailOptions
+	for i := len(dirs) - 1; i >= 0; i-- {
+		options = options.merge(loadThumbnailConfig(ctx, dirs[i]))
+	}
+	return options
+}
+
+type (
+	thumbnailOverrideKey struct{}
+	thumbnailOptionsKey  struct{}
+)
+
+// withThumbnailOverride carries the options of the request headers to the generation
+func withThumbnailOverride(ctx context.Context, override *ThumbnailOptions) context.Context {
+	if override == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, thumbnailOverrideKey{}, override)
+}
+
+// resolveThumbnailOptions stores the options of the file in the context, see thumbnailOptionsFrom
+func resolveThumbnailOptions(ctx context.Context, filePath string) context.Context {
+	override, _ := ctx.Value(thumbnailOverrideKey{}).(*ThumbnailOptions)
+	options := folderThumbnailOptions(ctx, filePath).merge(override)
+	return context.WithValue(ctx, thumbnailOptionsKey{}, options)
+}
+
+// thumbnailOptionsFrom returns the options resolved for the thumbnail being generated,
+// the global settings when they weren't resolved
+func thumbnailOptionsFrom(ctx context.Context) ThumbnailOptions {
+	options, _ := ctx.Value(thumbnailOptionsKey{}).(ThumbnailOptions)
+	return options
+}
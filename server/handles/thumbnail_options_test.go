@@ -0,0 +1,51 @@
+package handles
+
+import "testing"
+
+func TestThumbnailOptionsValidate(t *testing.T) {
+	valid := []ThumbnailOptions{
+		{},
+		{Width: 640, Frames: "cover,10%", Format: "jpeg"},
+		{Format: "png"},
+		{Preset: "picture"},
+	}
+	for _, o := range valid {
+		if err := o.validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", o, err)
+		}
+	}
+	invalid := []ThumbnailOptions{
+		{Width: 8},
+		{Width: 10000},
+		{Frames: "nowhere"},
+		{Format: "gif"},
+		{Preset: "film"},
+	}
+	for _, o := range invalid {
+		if err := o.validate(); err == nil {
+			t.Errorf("%+v: expected an error", o)
+		}
+	}
+}
+
+func TestThumbnailOptionsMerge(t *testing.T) {
+	folder := ThumbnailOptions{Width: 480, Format: "png"}
+	got := folder.merge(&ThumbnailOptions{Format: "jpeg", Frames: "3"})
+	want := ThumbnailOptions{Width: 480, Frames: "3", Format: "jpeg"}
+	if got != want {
+		t.Errorf("merge = %+v, want %+v", got, want)
+	}
+	if got = folder.merge(nil); got != folder {
+		t.Errorf("merge(nil) = %+v, want %+v", got, folder)
+	}
+	enabled, disabled := true, false
+	if !folder.merge(&ThumbnailOptions{Filmstrip: &enabled}).filmstrip() {
+		t.Error("filmstrip should be enabled by the override")
+	}
+	if (ThumbnailOptions{Filmstrip: &enabled}).merge(&ThumbnailOptions{Filmstrip: &disabled}).filmstrip() {
+		t.Error("filmstrip should be disabled by a nearer config")
+	}
+	if w := (ThumbnailOptions{}).width(); w != defaultThumbnailWidth {
+		t.Errorf("default width = %d", w)
+	}
+}
@@ -0,0 +1,139 @@
+package handles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"os"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxPosterSize bounds the poster field of a form upload
+	maxPosterSize = 5 << 20
+	// maxPosterDimension bounds the width and the height of the poster, so decoding it stays cheap
+	maxPosterDimension = 8192
+)
+
+// uploadPoster reads the poster field of a form upload, a still the client picked as the thumbnail.
+// It's nil when there is none, an oversized or undecodable poster is refused with 400
+func uploadPoster(c *gin.Context) ([]byte, bool) {
+	header, err := c.FormFile("poster")
+	if err != nil {
+		return nil, true
+	}
+	if header.Size > maxPosterSize {
+		common.ErrorStrResp(c, fmt.Sprintf("poster exceeds %d bytes", maxPosterSize), 400)
+		return nil, false
+	}
+	f, err := header.Open()
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return nil, false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxPosterSize+1))
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return nil, false
+	}
+	if err = checkPoster(data); err != nil {
+		common.ErrorResp(c, err, 400)
+		return nil, false
+	}
+	return data, true
+}
+
+// checkPoster validates the poster is a decodable image within maxPosterSize and maxPosterDimension
+func checkPoster(data []byte) error {
+	if len(data) > maxPosterSize {
+		return fmt.Errorf("poster exceeds %d bytes", maxPosterSize)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("poster is not a decodable image: %w", err)
+	}
+	if config.Width == 0 || config.Height == 0 || config.Width > maxPosterDimension || config.Height > maxPosterDimension {
+		return fmt.Errorf("poster is %dx%d, at most %dx%d is allowed", config.Width, config.Height, maxPosterDimension, maxPosterDimension)
+	}
+	if _, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("poster is not a decodable image: %w", err)
+	}
+	return nil
+}
+
+// storePosterThumbnail encodes the poster sent with the upload of filePath as its thumbnail,
+// in the configured format and width, instead of extracting a frame
+func storePosterThumbnail(ctx context.Context, filePath string, poster []byte) {
+	if !ffmpegAvailable() {
+		logrus.Warnf("FFmpeg不可用，无法保存%s的封面缩略图", filePath)
+		return
+	}
+	thumbnailsGenerating.Store(filePath, struct{}{})
+	defer thumbnailsGenerating.Delete(filePath)
+	thumbnailSlots.acquire()
+	defer thumbnailSlots.release()
+	ctx = resolveThumbnailOptions(ctx, filePath)
+	ctx, done := startThumbnailLog(ctx, filePath)
+	defer done()
+
+	posterFile, err := os.CreateTemp(os.TempDir(), "poster_source_*")
+	if err != nil {
+		logrus.Printf("创建本地临时文件失败: %v", err)
+		failThumbnail(ctx, thumbnailStageTemp, err)
+		return
+	}
+	posterPath := posterFile.Name()
+	_, err = posterFile.Write(poster)
+	_ = posterFile.Close()
+	defer func() {
+		if err := os.Remove(posterPath); err != nil {
+			logrus.Printf("清理临时文件失败: %v", err)
+		}
+	}()
+	if err != nil {
+		logrus.Printf("写入本地临时文件失败: %v", err)
+		failThumbnail(ctx, thumbnailStageTemp, err)
+		return
+	}
+	tempFile, err := os.CreateTemp(os.TempDir(), "poster_thumb_*"+thumbnailOptionsFrom(ctx).format().Ext)
+	if err != nil {
+		logrus.Printf("创建本地临时文件失败: %v", err)
+		failThumbnail(ctx, thumbnailStageTemp, err)
+		return
+	}
+	tempFilePath := tempFile.Name()
+	_ = tempFile.Close()
+	defer func() {
+		if err := os.Remove(tempFilePath); err != nil {
+			logrus.Printf("清理临时文件失败: %v", err)
+		}
+	}()
+
+	variants, cleanupVariants := newThumbnailVariants(ctx, "poster_thumb_*")
+	defer cleanupVariants()
+	if err = encodeThumbnailFrame(ctx, posterPath, tempFilePath, variants); err != nil {
+		logrus.Printf("编码封面缩略图失败: %v", err)
+		failThumbnail(ctx, thumbnailStageExtract, err)
+		return
+	}
+	store := getThumbnailStore()
+	if err = uploadThumbnail(ctx, store, filePath, tempFilePath); err != nil {
+		logrus.Printf("%v", err)
+		failThumbnail(ctx, thumbnailStageUpload, err)
+		return
+	}
+	storeThumbnailVariants(ctx, store, filePath, variants)
+	// a background upload may not be put yet, its thumbnail is then kept whatever the file
+	if obj, err := fs.Get(ctx, filePath, &fs.GetArgs{NoLog: true}); err == nil {
+		recordThumbnailSource(ctx, filePath, obj)
+	}
+	recordThumbnailHashes(ctx, filePath, tempFilePath)
+	logrus.Printf("封面缩略图保存成功: 目标路径=%s", store.PathFor(filePath))
+}
@@ -0,0 +1,35 @@
+package handles
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestCheckPoster(t *testing.T) {
+	encode := func(w, h int) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	valid := encode(64, 36)
+	cases := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "png", data: valid},
+		{name: "not an image", data: []byte("hello"), wantErr: true},
+		{name: "truncated", data: valid[:len(valid)/2], wantErr: true},
+		{name: "too wide", data: encode(maxPosterDimension+1, 1), wantErr: true},
+		{name: "too large", data: append(valid, make([]byte, maxPosterSize)...), wantErr: true},
+	}
+	for _, tc := range cases {
+		if err := checkPoster(tc.data); (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// probeVideoStream reports whether ffprobe finds a video stream in the file, a file ffprobe
+// can't read at all, e.g. text uploaded as video/mp4, has none
+func probeVideoStream(ctx context.Context, videoPath string) (bool, error) {
+	output, err := runFFprobe(ctx,
+		"-v", "error",
+		"-select_streams", "v",
+		"-show_entries", "stream=codec_type",
+		"-of", "csv=p=0",
+		videoPath)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+// confirmVideoStream probes the video before any extraction, a file without a video stream is
+// recorded as such in its sidecar and skipped. The file is assumed to be a video when ffprobe
+// can't tell, e.g. it's missing or timed out
+func confirmVideoStream(ctx context.Context, filePath, videoPath string, obj model.Obj) bool {
+	ok, err := probeVideoStream(ctx, videoPath)
+	if err != nil {
+		logrus.Printf("检测视频流失败: %v", err)
+		return true
+	}
+	if ok {
+		return true
+	}
+	logrus.Infof("文件不含可解码的视频流，跳过生成缩略图: %s", filePath)
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil {
+		sidecar = &MediaSidecar{}
+	}
+	sidecar.NoVideoStream = newThumbnailSource(obj)
+	if err = writeMediaSidecar(ctx, filePath, sidecar); err != nil {
+		logrus.Printf("保存视频流检测结果失败: %v", err)
+	}
+	return false
+}
+
+// noVideoStreamRecorded reports whether the file was found without a video stream
+// and hasn't changed since, so it isn't probed again
+func noVideoStreamRecorded(ctx context.Context, filePath string, obj model.Obj) bool {
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	return err == nil && sidecar.NoVideoStream != nil && sidecar.NoVideoStream.matches(obj)
+}
@@ -0,0 +1,33 @@
+package handles
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestProbeVideoStream(t *testing.T) {
+	for _, bin := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not found", bin)
+		}
+	}
+	dir := t.TempDir()
+	text := filepath.Join(dir, "notes.mp4")
+	if err := os.WriteFile(text, []byte("not a video at all\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := probeVideoStream(context.Background(), text); err != nil || ok {
+		t.Errorf("text file: got %v, %v, want no video stream", ok, err)
+	}
+	video := filepath.Join(dir, "video.mp4")
+	if output, err := exec.Command("ffmpeg", "-f", "lavfi", "-i", "testsrc=duration=1:size=64x48",
+		"-c:v", "mpeg4", "-y", video).CombinedOutput(); err != nil {
+		t.Fatalf("create video: %v: %s", err, output)
+	}
+	if ok, err := probeVideoStream(context.Background(), video); err != nil || !ok {
+		t.Errorf("video: got %v, %v, want a video stream", ok, err)
+	}
+}
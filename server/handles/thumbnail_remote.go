@@ -0,0 +1,114 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	stdpath "path"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/sirupsen/logrus"
+)
+
+// remoteFetchSize is how much of the head of a video of a storage without local files is downloaded, 0 when disabled
+func remoteFetchSize() int64 {
+	return int64(setting.GetInt(conf.ThumbnailRemoteFetchSize, 0))
+}
+
+// localFilesStorage reports whether the storage of path has local files ffmpeg can read
+func localFilesStorage(path string) bool {
+	storage, _, err := op.GetStorageAndActualPath(path)
+	return err == nil && driver.GetCapabilities(storage).LocalFiles
+}
+
+// videoSource is the file ffmpeg reads a video from: the video itself on storages with local files,
+// otherwise a local copy of the head of the video which can be completed when ffmpeg needs more
+type videoSource struct {
+	Path     string
+	filePath string
+	partial  bool
+	temp     bool
+}
+
+// openVideoSource returns the source of the video at filePath, Close must be called when done with it
+func openVideoSource(ctx context.Context, filePath string, obj model.Obj) (*videoSource, error) {
+	if localFilesStorage(filePath) {
+		if obj.GetPath() == "" {
+			return nil, errors.New("empty local path")
+		}
+		return &videoSource{Path: obj.GetPath()}, nil
+	}
+	size := remoteFetchSize()
+	if size <= 0 {
+		return nil, errors.New("storage has no local files")
+	}
+	if size >= obj.GetSize() {
+		size = -1
+	}
+	source := &videoSource{filePath: filePath}
+	if err := source.fetch(ctx, size); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// fetch downloads the first size bytes of the video, all of it when size is -1, replacing the previous copy
+func (s *videoSource) fetch(ctx context.Context, size int64) error {
+	link, obj, err := fs.Link(ctx, s.filePath, model.LinkArgs{})
+	if err != nil {
+		return err
+	}
+	ss, err := stream.NewSeekableStream(&stream.FileStream{Obj: obj, Ctx: ctx}, link)
+	if err != nil {
+		_ = link.Close()
+		return err
+	}
+	defer ss.Close()
+	reader, err := ss.RangeRead(http_range.Range{Length: size})
+	if err != nil {
+		return err
+	}
+	// the extension helps ffmpeg detect the format of a truncated file
+	tempFile, err := os.CreateTemp(os.TempDir(), "video_source_*"+stdpath.Ext(s.filePath))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tempFile, reader)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+		return err
+	}
+	s.Close()
+	s.Path, s.partial, s.temp = tempFile.Name(), size >= 0, true
+	return nil
+}
+
+// complete downloads the whole video when only its head was
+func (s *videoSource) complete(ctx context.Context) error {
+	if !s.partial {
+		return nil
+	}
+	logrus.Printf("下载完整视频文件: %s", s.filePath)
+	return s.fetch(ctx, -1)
+}
+
+// Close removes the downloaded copy
+func (s *videoSource) Close() {
+	if !s.temp {
+		return
+	}
+	if err := os.Remove(s.Path); err != nil {
+		logrus.Printf("清理临时文件失败: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/cron"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// PendingThumbnail is a thumbnail deferred until the thumbnail_schedule window opens
+type PendingThumbnail struct {
+	Path     string    `json:"path"`
+	Username string    `json:"username"`
+	Queued   time.Time `json:"queued"`
+	// Options are the Thumbnail-* headers of the upload
+	Options *ThumbnailOptions `json:"options,omitempty"`
+}
+
+// maxPendingThumbnails bounds the persisted queue, thumbnails deferred beyond it are dropped
+const maxPendingThumbnails = 10000
+
+var (
+	// pendingThumbnailsLock guards the read-modify-write of the persisted queue
+	pendingThumbnailsLock   sync.Mutex
+	thumbnailQueueDraining  atomic.Bool
+	thumbnailSchedulerStart sync.Once
+)
+
+func thumbnailWindowOpen() bool {
+	windows, err := utils.ParseTimeWindows(setting.GetStr(conf.ThumbnailSchedule))
+	if err != nil {
+		log.Warnf("invalid %s: %+v", conf.ThumbnailSchedule, err)
+		return true
+	}
+	return utils.InTimeWindows(windows, time.Now())
+}
+
+func pendingThumbnailsPath() string {
+	return filepath.Join(flags.DataDir, "thumbnail_pending.json")
+}
+
+// getPendingThumbnails reads the queue from the data dir
+func getPendingThumbnails() []PendingThumbnail {
+	data, err := os.ReadFile(pendingThumbnailsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		log.Errorf("read pending thumbnails error: %+v", err)
+		return nil
+	}
+	var pending []PendingThumbnail
+	if err = utils.Json.Unmarshal(data, &pending); err != nil {
+		log.Errorf("unmarshal pending thumbnails error: %+v", err)
+	}
+	return pending
+}
+
+// setPendingThumbnails persists the queue, it must be called with pendingThumbnailsLock held
+func setPendingThumbnails(pending []PendingThumbnail) error {
+	if pending == nil {
+		pending = []PendingThumbnail{}
+	}
+	data, err := utils.Json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(flags.DataDir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(pendingThumbnailsPath(), data, 0o600)
+}
+
+func enqueueThumbnail(path string, user *model.User, override *ThumbnailOptions) error {
+	_, err := queueThumbnails([]string{path}, user, override)
+	return err
+}
+
+// enqueueThumbnails persists the paths not queued yet in a single write and returns how many were added,
+// those beyond maxPendingThumbnails are dropped
+func enqueueThumbnails(paths []string, user *model.User) (int, error) {
+	return queueThumbnails(paths, user, nil)
+}
+
+func queueThumbnails(paths []string, user *model.User, override *ThumbnailOptions) (int, error) {
+	pendingThumbnailsLock.Lock()
+	defer pendingThumbnailsLock.Unlock()
+	pending := getPendingThumbnails()
+	queued := make(map[string]struct{}, len(pending))
+	for _, p := range pending {
+		queued[p.Path] = struct{}{}
+	}
+	added := 0
+	for i, path := range paths {
+		if _, ok := queued[path]; ok {
+			continue
+		}
+		if len(pending) >= maxPendingThumbnails {
+			log.Warnf("thumbnail queue is full, %d thumbnails dropped", len(paths)-i)
+			break
+		}
+		queued[path] = struct{}{}
+		pending = append(pending, PendingThumbnail{
+			Path:     path,
+			Username: user.Username,
+			Queued:   time.Now(),
+			Options:  override,
+		})
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	return added, setPendingThumbnails(pending)
+}
+
+func dequeueThumbnail(path string) {
+	pendingThumbnailsLock.Lock()
+	defer pendingThumbnailsLock.Unlock()
+	pending := getPendingThumbnails()
+	remain := pending[:0]
+	for _, p := range pending {
+		if p.Path != path {
+			remain = append(remain, p)
+		}
+	}
+	if err := setPendingThumbnails(remain); err != nil {
+		log.Errorf("save pending thumbnails error: %+v", err)
+	}
+}
+
+// scheduleThumbnail generates the thumbnail right away inside the schedule window,
+// otherwise it's persisted to be generated once the window opens. override holds the
+// Thumbnail-* headers of the upload, it may be nil
+func scheduleThumbnail(path string, user *model.User, override *ThumbnailOptions) {
+	if !ffmpegAvailable() {
+		return
+	}
+	if thumbnailWindowOpen() {
+		// 使用独立上下文，避免HTTP请求结束后取消任务
+		go generateThumbnail(withThumbnailOverride(context.Background(), override), path, user)
+		return
+	}
+	if err := enqueueThumbnail(path, user, override); err != nil {
+		log.Errorf("queue thumbnail of %s error: %+v", path, err)
+		return
+	}
+	log.Debugf("thumbnail of %s deferred to the schedule window", path)
+}
+
+// drainThumbnailQueue generates the pending thumbnails one by one while the window
+// stays open, or until the queue is empty when forced
+func drainThumbnailQueue(force bool) {
+	if !thumbnailQueueDraining.CompareAndSwap(false, true) {
+		return
+	}
+	defer thumbnailQueueDraining.Store(false)
+	for force || thumbnailWindowOpen() {
+		pending := getPendingThumbnails()
+		if len(pending) == 0 {
+			return
+		}
+		item := pending[0]
+		// the thumbnail of a deleted or disabled user is dropped rather than generated without permissions
+		if user, err := op.GetUserByName(item.Username); err != nil || user.Disabled {
+			log.Warnf("drop pending thumbnail %s, user %s is missing or disabled", item.Path, item.Username)
+		} else {
+			generateThumbnail(withThumbnailOverride(context.Background(), item.Options), item.Path, user)
+		}
+		dequeueThumbnail(item.Path)
+	}
+}
+
+// InitThumbnailScheduler starts draining the pending thumbnails during the schedule window
+func InitThumbnailScheduler() {
+	thumbnailSchedulerStart.Do(func() {
+		// detect ffmpeg and its thumbnail encoder before the first thumbnail needs them,
+		// a missing ffmpeg is reported once here rather than by every upload
+		if ffmpegAvailable() {
+			go getThumbnailFormat()
+		}
+		cron.NewCron(time.Minute).Do(func() {
+			drainThumbnailQueue(false)
+		})
+	})
+}
+
+// RunPendingThumbnails generates all pending thumbnails now regardless of the schedule window
+func RunPendingThumbnails(c *gin.Context) {
+	pending := len(getPendingThumbnails())
+	go drainThumbnailQueue(true)
+	common.SuccessResp(c, gin.H{"pending": pending})
+}
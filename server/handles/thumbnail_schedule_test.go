@@ -0,0 +1,39 @@
+package handles
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func TestPendingThumbnailsQueue(t *testing.T) {
+	dataDir := flags.DataDir
+	flags.DataDir = t.TempDir()
+	t.Cleanup(func() { flags.DataDir = dataDir })
+
+	paths := make([]string, maxPendingThumbnails+5)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("/queue/%d.mp4", i)
+	}
+	added, err := enqueueThumbnails(paths, &model.User{Username: "removed-user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != maxPendingThumbnails || len(getPendingThumbnails()) != maxPendingThumbnails {
+		t.Errorf("queued %d of %d, want the queue bounded to %d", added, len(paths), maxPendingThumbnails)
+	}
+
+	// the thumbnails of a user which no longer exists are dropped
+	pendingThumbnailsLock.Lock()
+	err = setPendingThumbnails(getPendingThumbnails()[:3])
+	pendingThumbnailsLock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainThumbnailQueue(true)
+	if pending := getPendingThumbnails(); len(pending) != 0 {
+		t.Errorf("%d thumbnails of a missing user left in the queue", len(pending))
+	}
+}
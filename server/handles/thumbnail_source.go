@@ -0,0 +1,82 @@
+package handles
+
+import (
+	"context"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/sirupsen/logrus"
+)
+
+// ThumbnailSource is the state of the file a thumbnail was generated from, recorded in the sidecar
+type ThumbnailSource struct {
+	Modified time.Time         `json:"modified"`
+	Size     int64             `json:"size"`
+	Hashes   map[string]string `json:"hashes,omitempty"`
+}
+
+func newThumbnailSource(obj model.Obj) *ThumbnailSource {
+	source := &ThumbnailSource{
+		Modified: obj.ModTime(),
+		Size:     obj.GetSize(),
+	}
+	for hashType, value := range obj.GetHash().All() {
+		if source.Hashes == nil {
+			source.Hashes = make(map[string]string)
+		}
+		source.Hashes[hashType.Name] = value
+	}
+	return source
+}
+
+// matches reports whether obj is still the file the thumbnail was generated from,
+// only the hashes known on both sides are compared
+func (s *ThumbnailSource) matches(obj model.Obj) bool {
+	if !s.Modified.Equal(obj.ModTime()) || s.Size != obj.GetSize() {
+		return false
+	}
+	for hashType, value := range obj.GetHash().All() {
+		if recorded, ok := s.Hashes[hashType.Name]; ok && recorded != value {
+			return false
+		}
+	}
+	return true
+}
+
+// removeStaleThumbnail deletes the thumbnail of filePath when the file changed since it was generated,
+// so it's generated again. Thumbnails without a recorded source are kept
+func removeStaleThumbnail(ctx context.Context, store ThumbnailStore, filePath string, obj model.Obj) {
+	if !setting.GetBool(conf.RegenerateThumbnailOnOverwrite) {
+		return
+	}
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil || sidecar.Thumbnail == nil || sidecar.Thumbnail.matches(obj) {
+		return
+	}
+	if exists, err := store.Exists(ctx, filePath); err != nil || !exists {
+		return
+	}
+	logrus.Printf("源文件已修改，重新生成缩略图: %s", filePath)
+	if err = store.Delete(ctx, filePath); err != nil {
+		logrus.Printf("删除旧缩略图失败: %v", err)
+	}
+	deleteThumbnailVariants(ctx, store, filePath)
+	deleteFilmstrip(ctx, store, filePath)
+}
+
+// recordThumbnailSource records the state of the file the thumbnail was just generated from
+func recordThumbnailSource(ctx context.Context, filePath string, obj model.Obj) {
+	if !setting.GetBool(conf.RegenerateThumbnailOnOverwrite) {
+		return
+	}
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil {
+		sidecar = &MediaSidecar{}
+	}
+	sidecar.Thumbnail = newThumbnailSource(obj)
+	if err = writeMediaSidecar(ctx, filePath, sidecar); err != nil {
+		logrus.Printf("保存缩略图源文件信息失败: %v", err)
+	}
+}
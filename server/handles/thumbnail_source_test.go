@@ -0,0 +1,32 @@
+package handles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+func TestThumbnailSourceMatches(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	obj := &model.Object{Size: 10, Modified: modified, HashInfo: utils.NewHashInfo(utils.MD5, "aaa")}
+	source := newThumbnailSource(obj)
+	if !source.matches(obj) {
+		t.Fatal("source should match the object it was recorded from")
+	}
+	cases := map[string]*model.Object{
+		"modified": {Size: 10, Modified: modified.Add(time.Second), HashInfo: obj.HashInfo},
+		"size":     {Size: 11, Modified: modified, HashInfo: obj.HashInfo},
+		"hash":     {Size: 10, Modified: modified, HashInfo: utils.NewHashInfo(utils.MD5, "bbb")},
+	}
+	for name, changed := range cases {
+		if source.matches(changed) {
+			t.Errorf("changed %s should not match", name)
+		}
+	}
+	// a hash unknown on one side is not compared
+	if !source.matches(&model.Object{Size: 10, Modified: modified}) {
+		t.Error("object without hash should match")
+	}
+}
@@ -0,0 +1,75 @@
+package handles
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// states of a file without thumbnail, sent as X-Thumbnail-State. thumbnailStateExists
+// is only reported by FsThumbnailStatus
+const (
+	thumbnailStateExists      = "exists"
+	thumbnailStateGenerating  = "generating"
+	thumbnailStateFailed      = "failed"
+	thumbnailStateUnsupported = "unsupported"
+	thumbnailStateMissing     = "missing"
+)
+
+// thumbnailsGenerating holds the paths whose thumbnail is being generated
+var thumbnailsGenerating sync.Map
+
+// thumbnailStorageSupported reports whether ffmpeg can read the file at path, from the local disk or,
+// for videos, from a download of their head when conf.ThumbnailRemoteFetchSize is set
+func thumbnailStorageSupported(path string) bool {
+	if localFilesStorage(path) {
+		return true
+	}
+	return remoteFetchSize() > 0 && strings.HasPrefix(utils.GetMimeType(path), "video/")
+}
+
+// thumbnailState tells why the file at path has no thumbnail
+func thumbnailState(path string) string {
+	mimetype := utils.GetMimeType(path)
+	if !strings.HasPrefix(mimetype, "video/") && !(strings.HasPrefix(mimetype, "image/") && setting.GetBool(conf.ImageThumbnails)) {
+		return thumbnailStateUnsupported
+	}
+	if !thumbnailStorageSupported(path) || !ffmpegAvailable() {
+		return thumbnailStateUnsupported
+	}
+	if _, ok := thumbnailsGenerating.Load(path); ok {
+		return thumbnailStateGenerating
+	}
+	for _, p := range getPendingThumbnails() {
+		if p.Path == path {
+			return thumbnailStateGenerating
+		}
+	}
+	if log, ok := getThumbnailLog(path); ok && !log.Success {
+		return thumbnailStateFailed
+	}
+	return thumbnailStateMissing
+}
+
+// serveDefaultThumbnail answers a missing thumbnail with conf.DefaultThumbnailPath, the state is
+// sent in X-Thumbnail-State so clients can pick their own placeholder. It returns false when no
+// placeholder is configured or it can't be read
+func serveDefaultThumbnail(c *gin.Context, path string) bool {
+	c.Header("X-Thumbnail-State", thumbnailState(path))
+	placeholder := setting.GetStr(conf.DefaultThumbnailPath)
+	if placeholder == "" {
+		return false
+	}
+	data, err := readFileContent(c.Request.Context(), placeholder, maxThumbnailSize)
+	if err != nil {
+		return false
+	}
+	// the state changes once generated, so the placeholder mustn't be cached
+	c.Header("Cache-Control", "no-store")
+	c.Data(200, thumbnailMimetype(data), data)
+	return true
+}
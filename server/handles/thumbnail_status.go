@@ -0,0 +1,98 @@
+package handles
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// maxThumbnailStatusPaths bounds the paths queried in one request
+const maxThumbnailStatusPaths = 500
+
+type ThumbnailStatusReq struct {
+	Paths    []string `json:"paths" binding:"required"`
+	Password string   `json:"password"`
+}
+
+// ThumbnailStatus is exists with the path and size of the thumbnail, otherwise the state of
+// X-Thumbnail-State, or error when the file can't be read
+type ThumbnailStatus struct {
+	Path      string `json:"path"`
+	Status    string `json:"status"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	Blurhash  string `json:"blurhash,omitempty"`
+	// DominantColor is the #rrggbb color of the thumbnail
+	DominantColor string `json:"dominant_color,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// FsThumbnailStatus tells for each path whether its thumbnail exists, is being generated,
+// failed or isn't supported
+func FsThumbnailStatus(c *gin.Context) {
+	var req ThumbnailStatusReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if len(req.Paths) > maxThumbnailStatusPaths {
+		common.ErrorStrResp(c, fmt.Sprintf("at most %d paths per request", maxThumbnailStatusPaths), 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	store := getThumbnailStore()
+	statuses := make([]ThumbnailStatus, 0, len(req.Paths))
+	for _, rawPath := range req.Paths {
+		status := ThumbnailStatus{Path: rawPath}
+		reqPath, err := thumbnailStatusPath(user, rawPath, req.Password)
+		if err != nil {
+			status.Status = "error"
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			continue
+		}
+		if thumb, err := fs.Get(c.Request.Context(), store.PathFor(reqPath), &fs.GetArgs{NoLog: true}); err == nil && !thumb.IsDir() {
+			status.Status = thumbnailStateExists
+			status.Thumbnail = userRelativePath(user, store.PathFor(reqPath))
+			status.Size = thumb.GetSize()
+			status.Blurhash, status.DominantColor = storedPlaceholders(reqPath)
+		} else {
+			status.Status = thumbnailState(reqPath)
+		}
+		statuses = append(statuses, status)
+	}
+	common.SuccessResp(c, statuses)
+}
+
+// thumbnailStatusPath is resolveReadablePath reporting the error instead of responding with it
+func thumbnailStatusPath(user *model.User, rawPath, password string) (string, error) {
+	reqPath, err := user.JoinPath(rawPath)
+	if err != nil {
+		return "", err
+	}
+	meta, err := op.GetNearestMeta(reqPath)
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		return "", err
+	}
+	if !common.CanAccess(user, meta, reqPath, password) {
+		return "", errors.New("password is incorrect or you have no permission")
+	}
+	return reqPath, nil
+}
+
+// userRelativePath turns an absolute path into one under the base path of the user
+func userRelativePath(user *model.User, path string) string {
+	if user.BasePath == "/" || user.BasePath == "" {
+		return path
+	}
+	return utils.FixAndCleanPath(strings.TrimPrefix(path, user.BasePath))
+}
@@ -0,0 +1,137 @@
+package handles
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	stdpath "path"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+)
+
+// maxThumbnailSize bounds how much of a thumbnail is read back
+const maxThumbnailSize = 10 << 20
+
+const (
+	ThumbnailStoreFolder  = "folder"
+	ThumbnailStoreCentral = "central"
+	ThumbnailStoreStorage = "storage"
+)
+
+// ThumbnailStore decides where the thumbnail of a file lives and how it's accessed,
+// all paths are paths of the original files
+type ThumbnailStore interface {
+	// PathFor returns the path of the thumbnail of the file
+	PathFor(filePath string) string
+	Exists(ctx context.Context, filePath string) (bool, error)
+	Put(ctx context.Context, filePath string, r io.Reader, size int64) error
+	// Get reads at most limit bytes of the thumbnail
+	Get(ctx context.Context, filePath string, limit int64) ([]byte, error)
+	Delete(ctx context.Context, filePath string) error
+}
+
+// fsThumbnailStore keeps the thumbnails in the virtual file system,
+// the store modes only differ in the path of the thumbnail
+type fsThumbnailStore struct {
+	pathFor func(filePath string) string
+}
+
+func (s fsThumbnailStore) PathFor(filePath string) string {
+	return s.pathFor(filePath)
+}
+
+func (s fsThumbnailStore) Exists(ctx context.Context, filePath string) (bool, error) {
+	return checkFileExists(ctx, s.PathFor(filePath))
+}
+
+func (s fsThumbnailStore) Put(ctx context.Context, filePath string, r io.Reader, size int64) error {
+	dir, name := stdpath.Split(s.PathFor(filePath))
+	if err := MakeDir(ctx, dir, true); err != nil {
+		return err
+	}
+	return fs.PutDirectly(ctx, dir, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     size,
+			Modified: time.Now(),
+		},
+		Reader:   r,
+		Mimetype: thumbnailOptionsFrom(ctx).format().Mimetype,
+	}, true)
+}
+
+func (s fsThumbnailStore) Get(ctx context.Context, filePath string, limit int64) ([]byte, error) {
+	return readFileContent(ctx, s.PathFor(filePath), limit)
+}
+
+func (s fsThumbnailStore) Delete(ctx context.Context, filePath string) error {
+	return fs.Remove(ctx, s.PathFor(filePath))
+}
+
+// thumbnailBase returns the directory of the file and its name without the extension,
+// a name made of the extension only, e.g. .mp4, is kept whole
+func thumbnailBase(filePath string) (dir, base string) {
+	dir, name := stdpath.Split(stdpath.Clean(filePath))
+	base = strings.TrimSuffix(name, stdpath.Ext(name))
+	if base == "" {
+		base = name
+	}
+	return dir, base
+}
+
+// resolveThumbnailTarget returns the setting.ThumbnailDirName directory next to the file and the path of its thumbnail in it,
+// the layout of ThumbnailStoreFolder which the sidecars follow in every mode
+func resolveThumbnailTarget(filePath string) (string, string) {
+	dir, base := thumbnailBase(filePath)
+	thumbDir := stdpath.Join(dir, setting.ThumbnailDirName())
+	return thumbDir, stdpath.Join(thumbDir, base+".webp")
+}
+
+// folderThumbnailPath keeps the thumbnail in setting.ThumbnailDirName next to the file
+func folderThumbnailPath(filePath string) string {
+	_, path := resolveThumbnailTarget(filePath)
+	return path
+}
+
+// centralThumbnailPath keeps all thumbnails in root, named by the hash of the file path
+func centralThumbnailPath(root string) func(string) string {
+	return func(filePath string) string {
+		sum := sha1.Sum([]byte(filePath))
+		hash := hex.EncodeToString(sum[:])
+		return stdpath.Join(root, hash[:2], hash+".webp")
+	}
+}
+
+// storageThumbnailPath mirrors the tree of the files beneath root, the mount path of a dedicated storage
+func storageThumbnailPath(root string) func(string) string {
+	return func(filePath string) string {
+		dir, base := thumbnailBase(filePath)
+		return stdpath.Join(root, dir, base+".webp")
+	}
+}
+
+// getThumbnailStore returns the store selected by conf.ThumbnailStoreMode,
+// served through thumbnailCache when conf.ThumbnailCacheMaxBytes enables it
+func getThumbnailStore() ThumbnailStore {
+	root := setting.GetStr(conf.ThumbnailStorePath, "/.thumbnails")
+	var store ThumbnailStore
+	switch setting.GetStr(conf.ThumbnailStoreMode, ThumbnailStoreFolder) {
+	case ThumbnailStoreCentral:
+		store = fsThumbnailStore{pathFor: centralThumbnailPath(root)}
+	case ThumbnailStoreStorage:
+		store = fsThumbnailStore{pathFor: storageThumbnailPath(root)}
+	default:
+		store = fsThumbnailStore{pathFor: folderThumbnailPath}
+	}
+	if thumbnailCacheMaxBytes() > 0 {
+		return cachedThumbnailStore{store}
+	}
+	return store
+}
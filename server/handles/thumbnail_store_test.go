@@ -0,0 +1,181 @@
+package handles
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+// 1x1 lossless webp encoded by libwebp
+const testWebP = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+type mockThumbnailStore struct {
+	thumbnails map[string][]byte
+	existsErr  error
+}
+
+func newMockThumbnailStore() *mockThumbnailStore {
+	return &mockThumbnailStore{thumbnails: make(map[string][]byte)}
+}
+
+func (m *mockThumbnailStore) PathFor(filePath string) string {
+	return "/mock" + filePath + ".webp"
+}
+
+func (m *mockThumbnailStore) Exists(ctx context.Context, filePath string) (bool, error) {
+	if m.existsErr != nil {
+		return false, m.existsErr
+	}
+	_, ok := m.thumbnails[filePath]
+	return ok, nil
+}
+
+func (m *mockThumbnailStore) Put(ctx context.Context, filePath string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return errors.New("size mismatch")
+	}
+	m.thumbnails[filePath] = data
+	return nil
+}
+
+func (m *mockThumbnailStore) Get(ctx context.Context, filePath string, limit int64) ([]byte, error) {
+	data, ok := m.thumbnails[filePath]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	if int64(len(data)) > limit {
+		data = data[:limit]
+	}
+	return data, nil
+}
+
+func (m *mockThumbnailStore) Delete(ctx context.Context, filePath string) error {
+	delete(m.thumbnails, filePath)
+	return nil
+}
+
+func writeTempFile(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "thumb.webp")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUploadThumbnail(t *testing.T) {
+	webp, _ := base64.StdEncoding.DecodeString(testWebP)
+	store := newMockThumbnailStore()
+	ctx := context.Background()
+
+	if !thumbnailNeeded(ctx, store, "/videos/a.mp4") {
+		t.Fatal("thumbnail should be needed before upload")
+	}
+	if err := uploadThumbnail(ctx, store, "/videos/a.mp4", writeTempFile(t, webp)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := store.Get(ctx, "/videos/a.mp4", maxThumbnailSize)
+	if err != nil || string(data) != string(webp) {
+		t.Fatalf("stored thumbnail mismatch: %v", err)
+	}
+	if thumbnailNeeded(ctx, store, "/videos/a.mp4") {
+		t.Error("thumbnail shouldn't be needed after upload")
+	}
+
+	_ = store.Delete(ctx, "/videos/a.mp4")
+	if !thumbnailNeeded(ctx, store, "/videos/a.mp4") {
+		t.Error("thumbnail should be needed after delete")
+	}
+}
+
+func TestUploadThumbnailInvalid(t *testing.T) {
+	store := newMockThumbnailStore()
+	err := uploadThumbnail(context.Background(), store, "/videos/a.mp4", writeTempFile(t, []byte("not a webp image")))
+	if err == nil {
+		t.Fatal("expected error for invalid webp")
+	}
+	if len(store.thumbnails) != 0 {
+		t.Error("invalid thumbnail shouldn't be stored")
+	}
+}
+
+func TestThumbnailNeededExistsError(t *testing.T) {
+	store := newMockThumbnailStore()
+	store.existsErr = errors.New("storage unavailable")
+	if thumbnailNeeded(context.Background(), store, "/videos/a.mp4") {
+		t.Error("thumbnail shouldn't be generated when its existence is unknown")
+	}
+}
+
+func TestThumbnailStorePathFor(t *testing.T) {
+	folder := fsThumbnailStore{pathFor: folderThumbnailPath}
+	if got := folder.PathFor("/videos/movie.mkv"); got != "/videos/.thumbnails/movie.webp" {
+		t.Errorf("folder: got %s", got)
+	}
+	central := fsThumbnailStore{pathFor: centralThumbnailPath("/thumbs")}
+	a, b := central.PathFor("/videos/movie.mkv"), central.PathFor("/other/movie.mkv")
+	if a == b || !strings.HasPrefix(a, "/thumbs/") || !strings.HasSuffix(a, ".webp") {
+		t.Errorf("central: got %s and %s", a, b)
+	}
+	if a != central.PathFor("/videos/movie.mkv") {
+		t.Error("central: path isn't stable")
+	}
+	storage := fsThumbnailStore{pathFor: storageThumbnailPath("/thumbs")}
+	if got := storage.PathFor("/videos/movie.mkv"); got != "/thumbs/videos/movie.webp" {
+		t.Errorf("storage: got %s", got)
+	}
+}
+
+func TestResolveThumbnailTarget(t *testing.T) {
+	cases := []struct {
+		filePath, dir, path string
+	}{
+		{"/videos/movie.mkv", "/videos/.thumbnails", "/videos/.thumbnails/movie.webp"},
+		{"/videos/movie", "/videos/.thumbnails", "/videos/.thumbnails/movie.webp"},
+		{"/videos/show.s01.e02.mp4", "/videos/.thumbnails", "/videos/.thumbnails/show.s01.e02.webp"},
+		{"/视频/电影 1.mp4", "/视频/.thumbnails", "/视频/.thumbnails/电影 1.webp"},
+		{"/videos/movie.mkv/", "/videos/.thumbnails", "/videos/.thumbnails/movie.webp"},
+		{"/videos/.mp4", "/videos/.thumbnails", "/videos/.thumbnails/.mp4.webp"},
+		{"/movie.mkv", "/.thumbnails", "/.thumbnails/movie.webp"},
+	}
+	for _, c := range cases {
+		dir, path := resolveThumbnailTarget(c.filePath)
+		if dir != c.dir || path != c.path {
+			t.Errorf("%s: got %s and %s, want %s and %s", c.filePath, dir, path, c.dir, c.path)
+		}
+	}
+}
+
+func TestThumbnailDirName(t *testing.T) {
+	setDirName := func(value string) {
+		if err := op.SaveSettingItem(&model.SettingItem{Key: conf.ThumbnailDirName, Value: value, Type: conf.TypeString, Group: model.THUMBNAIL, Flag: model.PRIVATE}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { setDirName(".thumbnails") })
+	cases := map[string]string{
+		"@eaDir":     "/videos/@eaDir/movie.webp",
+		"":           "/videos/.thumbnails/movie.webp",
+		"..":         "/videos/.thumbnails/movie.webp",
+		"a/b":        "/videos/.thumbnails/movie.webp",
+		`thumbs\old`: "/videos/.thumbnails/movie.webp",
+	}
+	for name, want := range cases {
+		setDirName(name)
+		if _, path := resolveThumbnailTarget("/videos/movie.mkv"); path != want {
+			t.Errorf("%q: got %s, want %s", name, path, want)
+		}
+	}
+}
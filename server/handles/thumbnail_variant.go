@@ -0,0 +1,185 @@
+package handles
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// thumbnailVariant is the thumbnail encoded in another of conf.ThumbnailFormats,
+// from the same frame as the main thumbnail
+type thumbnailVariant struct {
+	format  thumbnailFormat
+	path    string
+	encoded bool
+}
+
+// configuredThumbnailFormats returns the known formats of conf.ThumbnailFormats
+func configuredThumbnailFormats() []thumbnailFormat {
+	var formats []thumbnailFormat
+	for _, name := range strings.Split(setting.GetStr(conf.ThumbnailFormats), ",") {
+		format := thumbnailFormatByName(strings.ToLower(strings.TrimSpace(name)))
+		if format == nil {
+			continue
+		}
+		duplicate := false
+		for _, f := range formats {
+			duplicate = duplicate || f.Name == format.Name
+		}
+		if !duplicate {
+			formats = append(formats, *format)
+		}
+	}
+	return formats
+}
+
+// thumbnailVariantSource is the path the variant is stored for, the store keeps it next to
+// the main thumbnail, e.g. .thumbnails/<base>_jpeg.webp, sniffed like the main one when served
+func thumbnailVariantSource(filePath string, format thumbnailFormat) string {
+	return coverThumbnailSource(filePath, format.Name)
+}
+
+// withThumbnailFormat returns ctx with the thumbnail options encoding in format
+func withThumbnailFormat(ctx context.Context, format thumbnailFormat) context.Context {
+	options := thumbnailOptionsFrom(ctx)
+	options.Format = format.Name
+	return context.WithValue(ctx, thumbnailOptionsKey{}, options)
+}
+
+// newThumbnailVariants creates the temp files of the variants besides the main thumbnail,
+// formats ffmpeg can't encode are skipped with a warning. cleanup removes the temp files
+func newThumbnailVariants(ctx context.Context, pattern string) ([]*thumbnailVariant, func()) {
+	main := thumbnailOptionsFrom(ctx).format()
+	var variants []*thumbnailVariant
+	cleanup := func() {
+		for _, v := range variants {
+			if err := os.Remove(v.path); err != nil {
+				logrus.Printf("清理临时文件失败: %v", err)
+			}
+		}
+	}
+	for _, format := range configuredThumbnailFormats() {
+		if format.Name == main.Name {
+			continue
+		}
+		if !thumbnailEncoderAvailable(format.Encoder) {
+			logrus.Warnf("FFmpeg不支持%s编码器，跳过%s格式缩略图", format.Encoder, format.Name)
+			continue
+		}
+		tempFile, err := os.CreateTemp(os.TempDir(), pattern+format.Ext)
+		if err != nil {
+			logrus.Printf("创建本地临时文件失败: %v", err)
+			continue
+		}
+		_ = tempFile.Close()
+		variants = append(variants, &thumbnailVariant{format: format, path: tempFile.Name()})
+	}
+	return variants, cleanup
+}
+
+// encodeThumbnailVariants encodes the frame the main thumbnail was made of into every variant,
+// a failing variant is logged and left out
+func encodeThumbnailVariants(ctx context.Context, framePath string, variants []*thumbnailVariant) {
+	for _, v := range variants {
+		if err := encodeThumbnail(withThumbnailFormat(ctx, v.format), framePath, v.path); err != nil {
+			logrus.Printf("生成%s格式缩略图失败: %v", v.format.Name, err)
+			continue
+		}
+		v.encoded = true
+	}
+}
+
+// storeThumbnailVariants saves the encoded variants of the thumbnail of filePath
+func storeThumbnailVariants(ctx context.Context, store ThumbnailStore, filePath string, variants []*thumbnailVariant) {
+	for _, v := range variants {
+		if !v.encoded {
+			continue
+		}
+		if err := uploadThumbnail(withThumbnailFormat(ctx, v.format), store, thumbnailVariantSource(filePath, v.format), v.path); err != nil {
+			logrus.Printf("保存%s格式缩略图失败: %v", v.format.Name, err)
+		}
+	}
+}
+
+// deleteThumbnailVariants removes the variants of the thumbnail of filePath in every configured format
+func deleteThumbnailVariants(ctx context.Context, store ThumbnailStore, filePath string) {
+	for _, format := range configuredThumbnailFormats() {
+		source := thumbnailVariantSource(filePath, format)
+		if exists, err := store.Exists(ctx, source); err == nil && exists {
+			if err = store.Delete(ctx, source); err != nil {
+				logrus.Printf("删除%s格式缩略图失败: %v", format.Name, err)
+			}
+		}
+	}
+}
+
+// acceptedMimetypes returns the media ranges of an Accept header by descending quality,
+// those with q=0 are left out
+func acceptedMimetypes(accept string) []string {
+	type mediaRange struct {
+		mimetype string
+		q        float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mimetype, params, _ := strings.Cut(part, ";")
+		mimetype = strings.ToLower(strings.TrimSpace(mimetype))
+		if mimetype == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mimetype, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	mimetypes := make([]string, len(ranges))
+	for i, r := range ranges {
+		mimetypes[i] = r.mimetype
+	}
+	return mimetypes
+}
+
+// negotiateThumbnail returns the stored thumbnail of filePath in the format the Accept header
+// prefers, data is the main thumbnail and is kept when no variant is preferred over it
+func negotiateThumbnail(c *gin.Context, filePath string, data []byte) []byte {
+	formats := configuredThumbnailFormats()
+	if len(formats) == 0 {
+		return data
+	}
+	c.Header("Vary", "Accept")
+	mainType := thumbnailMimetype(data)
+	store := getThumbnailStore()
+	for _, mimetype := range acceptedMimetypes(c.GetHeader("Accept")) {
+		if mimetype == mainType || mimetype == "*/*" || mimetype == "image/*" {
+			return data
+		}
+		for _, format := range formats {
+			if format.Mimetype != mimetype {
+				continue
+			}
+			variant, err := store.Get(c.Request.Context(), thumbnailVariantSource(filePath, format), maxThumbnailSize)
+			if err == nil && len(variant) > 0 {
+				return variant
+			}
+		}
+	}
+	return data
+}
@@ -0,0 +1,106 @@
+package handles
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// verifyFrameWidth is the width of the frame extracted again, enough for the perceptual hash
+	verifyFrameWidth = 64
+	// maxVerifyDistance is the largest hamming distance between the hashes of a thumbnail and its frame,
+	// scaling and lossy encoding move a few bits, another frame moves about half of them
+	maxVerifyDistance = 12
+)
+
+// encodeVerifiedThumbnail encodes the frame of the video at pos into the thumbnail and its variants.
+// With conf.ThumbnailVerify, a small frame is extracted again and compared with the thumbnail, and when
+// they differ, e.g. the frame came from a stale temp file, the frame is extracted once more without the
+// frame cache and encoded again.
+func encodeVerifiedThumbnail(ctx context.Context, videoPath, framePath, outputPath string, pos utils.FramePosition, variants []*thumbnailVariant) error {
+	if err := encodeThumbnailFrame(ctx, framePath, outputPath, variants); err != nil {
+		return err
+	}
+	if !setting.GetBool(conf.ThumbnailVerify) {
+		return nil
+	}
+	match, err := thumbnailMatchesVideo(ctx, videoPath, outputPath, pos)
+	if err != nil {
+		logrus.Printf("校验缩略图失败: %v", err)
+		return nil
+	}
+	if match {
+		return nil
+	}
+	logrus.Warnf("缩略图与视频%s处的帧不符，重新生成: %s", pos, videoPath)
+	tempFile, err := os.CreateTemp(os.TempDir(), "video_verify_*.png")
+	if err != nil {
+		return fmt.Errorf("创建本地临时文件失败: %w", err)
+	}
+	tempFilePath := tempFile.Name()
+	_ = tempFile.Close()
+	defer func() {
+		if err := os.Remove(tempFilePath); err != nil {
+			logrus.Printf("清理临时文件失败: %v", err)
+		}
+	}()
+	if err = extractVideoFrame(ctx, videoPath, tempFilePath, pos); err != nil {
+		return err
+	}
+	return encodeThumbnailFrame(ctx, tempFilePath, outputPath, variants)
+}
+
+// thumbnailMatchesVideo reports whether the thumbnail looks like the frame of the video at pos
+func thumbnailMatchesVideo(ctx context.Context, videoPath, thumbnailPath string, pos utils.FramePosition) (bool, error) {
+	thumbnail, err := decodeImageFile(thumbnailPath)
+	if err != nil {
+		return false, err
+	}
+	tempFile, err := os.CreateTemp(os.TempDir(), "video_verify_*.png")
+	if err != nil {
+		return false, err
+	}
+	tempFilePath := tempFile.Name()
+	_ = tempFile.Close()
+	defer func() {
+		if err := os.Remove(tempFilePath); err != nil {
+			logrus.Printf("清理临时文件失败: %v", err)
+		}
+	}()
+	scale := []string{"-vf", fmt.Sprintf("scale=%d:-1", verifyFrameWidth)}
+	if pos.Cover {
+		err = extractVideoCover(ctx, videoPath, tempFilePath, scale...)
+	} else {
+		err = extractVideoFrameAtPercentage(ctx, videoPath, tempFilePath, pos.Percent, scale...)
+	}
+	if err != nil {
+		return false, err
+	}
+	frame, err := decodeImageFile(tempFilePath)
+	if err != nil {
+		return false, err
+	}
+	return framesMatch(thumbnail, frame), nil
+}
+
+// framesMatch reports whether the perceptual hashes of both images are within maxVerifyDistance
+func framesMatch(a, b image.Image) bool {
+	return utils.HammingDistance(utils.PHash(a), utils.PHash(b)) <= maxVerifyDistance
+}
+
+func decodeImageFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
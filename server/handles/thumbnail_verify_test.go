@@ -0,0 +1,36 @@
+package handles
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testFrame draws a bright block over a dark background, in the top left quarter or the bottom right one
+func testFrame(w, h int, bottomRight bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(40)
+			inside := x < w/2 && y < h/2
+			if bottomRight {
+				inside = x >= w/2 && y >= h/2
+			}
+			if inside {
+				v = 220
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestFramesMatch(t *testing.T) {
+	thumbnail := testFrame(320, 180, false)
+	if !framesMatch(thumbnail, testFrame(64, 36, false)) {
+		t.Error("a frame should match its thumbnail at another size")
+	}
+	if framesMatch(thumbnail, testFrame(64, 36, true)) {
+		t.Error("another frame should not match the thumbnail")
+	}
+}
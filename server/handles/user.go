@@ -39,6 +39,10 @@ func CreateUser(c *gin.Context) {
 		common.ErrorStrResp(c, "admin or guest user can not be created", 400, true)
 		return
 	}
+	if err := req.FixPaths(); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
 	req.SetPassword(req.Password)
 	req.Password = ""
 	req.Authn = "[]"
@@ -78,6 +82,10 @@ func UpdateUser(c *gin.Context) {
 		common.ErrorStrResp(c, "admin user can not be disabled", 400)
 		return
 	}
+	if err := req.FixPaths(); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
 	if err := op.UpdateUser(&req); err != nil {
 		common.ErrorResp(c, err, 500)
 	} else {
@@ -0,0 +1,361 @@
+package handles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	stdpath "path"
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// maxSidecarSize bounds how much of a sidecar file is read back
+const maxSidecarSize = 1 << 20
+
+type VideoChapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Title string  `json:"title,omitempty"`
+}
+
+type SubtitleTrack struct {
+	Index    int    `json:"index"` // index among the subtitle streams, as in -map 0:s:N
+	Codec    string `json:"codec"`
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Default  bool   `json:"default"`
+	Forced   bool   `json:"forced"`
+	File     string `json:"file,omitempty"` // path of the extracted sidecar subtitle
+}
+
+type VideoMeta struct {
+	Duration  float64         `json:"duration"`
+	Width     int             `json:"width"`
+	Height    int             `json:"height"`
+	Codec     string          `json:"codec"`
+	Chapters  []VideoChapter  `json:"chapters"`
+	Subtitles []SubtitleTrack `json:"subtitles"`
+	Covers    []VideoCover    `json:"covers,omitempty"`
+}
+
+// MediaSidecar is stored as .thumbnails/<base>.json next to the media file
+type MediaSidecar struct {
+	Video *VideoMeta `json:"video,omitempty"`
+	// Charset declared in the Content-Type of a text upload
+	Charset string `json:"charset,omitempty"`
+	// Metadata sent with the upload in X-Metadata
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Thumbnail is the source the thumbnail was generated from
+	Thumbnail *ThumbnailSource `json:"thumbnail,omitempty"`
+	// LivePhoto pairs the file with the other half of its live photo, see pairLivePhoto
+	LivePhoto *LivePhoto `json:"live_photo,omitempty"`
+	// Filmstrip is the layout of the sprite of the video, see generateFilmstrip
+	Filmstrip *Filmstrip `json:"filmstrip,omitempty"`
+	// NoVideoStream is the file ffprobe found without a video stream, see confirmVideoStream
+	NoVideoStream *ThumbnailSource `json:"no_video_stream,omitempty"`
+	// Media is the EXIF or ffprobe metadata returned by FsMediaExif
+	Media *MediaInfoCache `json:"media,omitempty"`
+	// PHash is the hex perceptual hash of the thumbnail, see FsSimilar
+	PHash string `json:"phash,omitempty"`
+	// Blurhash is the placeholder of the thumbnail, see conf.EnableBlurhash
+	Blurhash string `json:"blurhash,omitempty"`
+	// DominantColor is the #rrggbb color of the thumbnail, see conf.EnableDominantColor
+	DominantColor string    `json:"dominant_color,omitempty"`
+	Updated       time.Time `json:"updated"`
+}
+
+type ffprobeStream struct {
+	Index       int               `json:"index"`
+	CodecType   string            `json:"codec_type"`
+	CodecName   string            `json:"codec_name"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	Tags        map[string]string `json:"tags"`
+	Disposition map[string]int    `json:"disposition"`
+}
+
+type ffprobeChapter struct {
+	StartTime string            `json:"start_time"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags"`
+}
+
+type ffprobeOutput struct {
+	Format struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+	Streams  []ffprobeStream  `json:"streams"`
+	Chapters []ffprobeChapter `json:"chapters"`
+}
+
+// text based subtitle codecs which can be converted to srt
+var textSubtitleCodecs = []string{"subrip", "srt", "ass", "ssa", "webvtt", "mov_text", "text"}
+
+// probeVideo runs a single ffprobe pass collecting format, streams and chapters
+func probeVideo(ctx context.Context, filePath string) (*ffprobeOutput, error) {
+	output, err := runFFprobe(ctx,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		"-show_chapters",
+		filePath)
+	if err != nil {
+		return nil, err
+	}
+	var probe ffprobeOutput
+	if err = json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("解析ffprobe输出失败: %w", err)
+	}
+	return &probe, nil
+}
+
+func (p *ffprobeOutput) toVideoMeta() *VideoMeta {
+	meta := &VideoMeta{
+		Chapters:  []VideoChapter{},
+		Subtitles: []SubtitleTrack{},
+	}
+	meta.Duration, _ = strconv.ParseFloat(p.Format.Duration, 64)
+	subIndex := 0
+	for _, s := range p.Streams {
+		switch s.CodecType {
+		case "video":
+			if meta.Codec == "" && s.Disposition["attached_pic"] == 0 {
+				meta.Codec = s.CodecName
+				meta.Width = s.Width
+				meta.Height = s.Height
+			}
+		case "subtitle":
+			meta.Subtitles = append(meta.Subtitles, SubtitleTrack{
+				Index:    subIndex,
+				Codec:    s.CodecName,
+				Language: s.Tags["language"],
+				Title:    s.Tags["title"],
+				Default:  s.Disposition["default"] == 1,
+				Forced:   s.Disposition["forced"] == 1,
+			})
+			subIndex++
+		}
+	}
+	meta.Covers = p.videoCovers()
+	for _, c := range p.Chapters {
+		start, _ := strconv.ParseFloat(c.StartTime, 64)
+		end, _ := strconv.ParseFloat(c.EndTime, 64)
+		meta.Chapters = append(meta.Chapters, VideoChapter{
+			Start: start,
+			End:   end,
+			Title: c.Tags["title"],
+		})
+	}
+	return meta
+}
+
+func sidecarPath(filePath string) (string, string) {
+	thumbDir, _ := resolveThumbnailTarget(filePath)
+	_, base := thumbnailBase(filePath)
+	return thumbDir, base + ".json"
+}
+
+func readMediaSidecar(ctx context.Context, filePath string) (*MediaSidecar, error) {
+	dir, name := sidecarPath(filePath)
+	data, err := readFileContent(ctx, stdpath.Join(dir, name), maxSidecarSize)
+	if err != nil {
+		return nil, err
+	}
+	var sidecar MediaSidecar
+	if err = json.Unmarshal(data, &sidecar); err != nil {
+		return nil, err
+	}
+	return &sidecar, nil
+}
+
+func writeMediaSidecar(ctx context.Context, filePath string, sidecar *MediaSidecar) error {
+	dir, name := sidecarPath(filePath)
+	sidecar.Updated = time.Now()
+	data, err := json.Marshal(sidecar)
+	if err != nil {
+		return err
+	}
+	if err = MakeDir(ctx, dir, true); err != nil {
+		return err
+	}
+	return writeFileContent(ctx, dir, name, data, "application/json")
+}
+
+// readFileContent reads at most limit bytes of the object at path
+func readFileContent(ctx context.Context, path string, limit int64) ([]byte, error) {
+	link, obj, err := fs.Link(ctx, path, model.LinkArgs{})
+	if err != nil {
+		return nil, err
+	}
+	ss, err := stream.NewSeekableStream(&stream.FileStream{Obj: obj, Ctx: ctx}, link)
+	if err != nil {
+		_ = link.Close()
+		return nil, err
+	}
+	defer ss.Close()
+	reader, err := ss.RangeRead(http_range.Range{Length: -1})
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(reader, limit))
+}
+
+func writeFileContent(ctx context.Context, dir, name string, data []byte, mimetype string) error {
+	return fs.PutDirectly(ctx, dir, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     int64(len(data)),
+			Modified: time.Now(),
+		},
+		Reader:   bytes.NewReader(data),
+		Mimetype: mimetype,
+	}, true)
+}
+
+// probeAndStoreVideoMeta records chapters, subtitle tracks and attached covers of the video into its
+// sidecar, and optionally extracts the text subtitles next to the video. It returns nil when probing fails
+func probeAndStoreVideoMeta(ctx context.Context, filePath, videoAbsPath string) *VideoMeta {
+	probe, err := probeVideo(ctx, videoAbsPath)
+	if err != nil {
+		logrus.Printf("获取视频元数据失败: %v", err)
+		return nil
+	}
+	meta := probe.toVideoMeta()
+	if setting.GetBool(conf.ExtractSubtitles) {
+		extractSubtitles(ctx, filePath, videoAbsPath, meta.Subtitles)
+	}
+	if setting.GetBool(conf.ThumbnailAttachments) {
+		store := getThumbnailStore()
+		for i := range meta.Covers {
+			meta.Covers[i].Thumbnail = store.PathFor(coverThumbnailSource(filePath, meta.Covers[i].Label))
+		}
+	}
+	sidecar, err := readMediaSidecar(ctx, filePath)
+	if err != nil {
+		sidecar = &MediaSidecar{}
+	}
+	sidecar.Video = meta
+	if err = writeMediaSidecar(ctx, filePath, sidecar); err != nil {
+		logrus.Printf("保存视频元数据失败: %v", err)
+	}
+	return meta
+}
+
+func extractSubtitles(ctx context.Context, filePath, videoAbsPath string, tracks []SubtitleTrack) {
+	dir, baseName := thumbnailBase(filePath)
+	for i := range tracks {
+		track := &tracks[i]
+		if !isTextSubtitle(track.Codec) {
+			continue
+		}
+		suffix := strconv.Itoa(track.Index)
+		if track.Language != "" {
+			suffix = track.Language + "." + suffix
+		}
+		subName := fmt.Sprintf("%s.%s.srt", baseName, suffix)
+		if err := extractSubtitle(ctx, videoAbsPath, dir, subName, track.Index); err != nil {
+			logrus.Printf("提取字幕失败: %s: %v", subName, err)
+			continue
+		}
+		track.File = stdpath.Join(dir, subName)
+	}
+}
+
+func isTextSubtitle(codec string) bool {
+	for _, c := range textSubtitleCodecs {
+		if c == codec {
+			return true
+		}
+	}
+	return false
+}
+
+func extractSubtitle(ctx context.Context, videoAbsPath, dir, subName string, index int) error {
+	tempFile, err := os.CreateTemp(os.TempDir(), "video_sub_*.srt")
+	if err != nil {
+		return err
+	}
+	tempFilePath := tempFile.Name()
+	_ = tempFile.Close()
+	defer os.Remove(tempFilePath)
+
+	output, err := runFFmpeg(ctx,
+		"-i", videoAbsPath,
+		"-map", fmt.Sprintf("0:s:%d", index),
+		"-f", "srt",
+		"-y",
+		tempFilePath)
+	if err != nil {
+		logrus.Printf("FFmpeg字幕提取输出: %s", string(output))
+		return err
+	}
+	data, err := os.ReadFile(tempFilePath)
+	if err != nil {
+		return err
+	}
+	return writeFileContent(ctx, dir, subName, data, "application/x-subrip")
+}
+
+type MediaPathReq struct {
+	Path     string `json:"path" form:"path"`
+	Password string `json:"password" form:"password"`
+}
+
+// resolveReadablePath joins the requested path with the user's base path
+// and checks the user can read it, writing the error response otherwise
+func resolveReadablePath(c *gin.Context, rawPath, password string) (string, bool) {
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	reqPath, err := user.JoinPath(rawPath)
+	if err != nil {
+		common.PathErrorResp(c, err, 403)
+		return "", false
+	}
+	meta, err := op.GetNearestMeta(reqPath)
+	if err != nil {
+		if !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+			common.ErrorResp(c, err, 500)
+			return "", false
+		}
+	}
+	if !common.CanAccess(user, meta, reqPath, password) {
+		common.ErrorStrResp(c, "password is incorrect or you have no permission", 403)
+		return "", false
+	}
+	return reqPath, true
+}
+
+func FsVideoMeta(c *gin.Context) {
+	var req MediaPathReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := resolveReadablePath(c, req.Path, req.Password)
+	if !ok {
+		return
+	}
+	sidecar, err := readMediaSidecar(c.Request.Context(), reqPath)
+	if err != nil || sidecar.Video == nil {
+		common.ErrorStrResp(c, "video meta not found", 404)
+		return
+	}
+	common.SuccessResp(c, sidecar.Video)
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/OpenListTeam/OpenList/v4/server/common"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -22,10 +24,15 @@ func FsUp(c *gin.Context) {
 		c.Abort()
 		return
 	}
-	user := c.Request.Context().Value(conf.UserKey).(*model.User)
-	path, err = user.JoinPath(path)
+	user, ok := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if !ok || user == nil {
+		common.ErrorStrResp(c, "no user in the request, login please", 401)
+		return
+	}
+	// checked on the path the handler uploads to
+	path, err = user.JoinUploadPath(utils.NormalizeUnicodePath(path, setting.GetStr(conf.PathUnicodeNormalization)))
 	if err != nil {
-		common.ErrorResp(c, err, 403)
+		common.PathErrorResp(c, err, 403)
 		return
 	}
 	meta, err := op.GetNearestMeta(stdpath.Dir(path))
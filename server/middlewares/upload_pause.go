@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// uploadsPausedRetryAfter is the Retry-After of the uploads rejected while paused, in seconds
+const uploadsPausedRetryAfter = 300
+
+const defaultUploadsPausedMessage = "uploads are paused for maintenance, please retry later"
+
+// UploadsPaused reports whether the uploads of the user are rejected by conf.UploadsPaused, admins are never paused
+func UploadsPaused(user *model.User) bool {
+	return setting.GetBool(conf.UploadsPaused) && (user == nil || !user.IsAdmin())
+}
+
+// UploadsPausedMessage is the message of the uploads rejected while paused
+func UploadsPausedMessage() string {
+	if message := setting.GetStr(conf.UploadsPausedMessage); message != "" {
+		return message
+	}
+	return defaultUploadsPausedMessage
+}
+
+// SetUploadsPausedRetryAfter tells clients when to retry the uploads rejected while paused
+func SetUploadsPausedRetryAfter(header http.Header) {
+	header.Set("Retry-After", strconv.Itoa(uploadsPausedRetryAfter))
+}
+
+// RejectPausedUploads rejects the upload with 503 while uploads are paused
+func RejectPausedUploads(c *gin.Context) {
+	user, _ := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if !UploadsPaused(user) {
+		c.Next()
+		return
+	}
+	SetUploadsPausedRetryAfter(c.Writer.Header())
+	c.JSON(http.StatusServiceUnavailable, common.Resp[any]{
+		Code:    http.StatusServiceUnavailable,
+		Message: UploadsPausedMessage(),
+	})
+	c.Abort()
+}
@@ -35,6 +35,11 @@ func Init(e *gin.Engine) {
 	g.GET("/manifest.json", static.ManifestJSON)
 	g.GET("/i/:link_name", handles.Plist)
 	common.SecretKey = []byte(conf.Conf.JwtSecret)
+	handles.InitThumbnailScheduler()
+	handles.InitTusCleaner()
+	handles.InitStagingCleaner()
+	handles.InitThumbnailCache()
+	handles.InitThumbnailBackfill()
 	g.Use(middlewares.StoragesLoaded)
 	if conf.Conf.MaxConnections > 0 {
 		g.Use(middlewares.MaxAllowed(conf.Conf.MaxConnections))
@@ -100,6 +105,7 @@ func Init(e *gin.Engine) {
 	public.Any("/settings", handles.PublicSettings)
 	public.Any("/offline_download_tools", handles.OfflineDownloadTools)
 	public.Any("/archive_extensions", handles.ArchiveExtensions)
+	public.PUT("/upload", handles.SignedUploadAuth, middlewares.RejectPausedUploads, middlewares.FsUp, middlewares.UploadRateLimiter(stream.ClientUploadLimit), handles.FsStream)
 
 	_fs(auth.Group("/fs"))
 	fsAndShare(api.Group("/fs", middlewares.Auth(true)))
@@ -136,6 +142,7 @@ func admin(g *gin.RouterGroup) {
 	storage := g.Group("/storage")
 	storage.GET("/list", handles.ListStorages)
 	storage.GET("/get", handles.GetStorage)
+	storage.GET("/:id/capabilities", handles.GetStorageCapabilities)
 	storage.POST("/create", handles.CreateStorage)
 	storage.POST("/update", handles.UpdateStorage)
 	storage.POST("/delete", handles.DeleteStorage)
@@ -151,8 +158,13 @@ func admin(g *gin.RouterGroup) {
 	setting := g.Group("/setting")
 	setting.GET("/get", handles.GetSetting)
 	setting.GET("/list", handles.ListSettings)
+	setting.GET("/groups", handles.ListSettingGroups)
 	setting.POST("/save", handles.SaveSettings)
+	setting.POST("/bulk_set", handles.BulkSetSettings)
 	setting.POST("/delete", handles.DeleteSetting)
+	setting.DELETE("/group", handles.DeleteSettingGroup)
+	setting.POST("/preview_public", handles.PreviewPublicSettings)
+	setting.POST("/:key/list", handles.UpdateSettingList)
 	setting.POST("/default", handles.DefaultSettings)
 	setting.POST("/reset_token", handles.ResetToken)
 	setting.POST("/set_aria2", handles.SetAria2)
@@ -168,9 +180,30 @@ func admin(g *gin.RouterGroup) {
 	setting.POST("/set_thunder_browser", handles.SetThunderBrowser)
 	setting.POST("/set_webdav", handles.SetWebDAV) // 添加WebDAV设置路由
 	setting.GET("/get_webdav", handles.GetWebDAV)
+
+	g.GET("/config/effective", handles.GetEffectiveConfig)
+	g.POST("/sign/rotate", handles.RotateSignKey)
+	g.POST("/thumbnail/run_now", handles.RunPendingThumbnails)
+	g.GET("/thumbnail/log", handles.ThumbnailLogGet)
+	g.GET("/thumbnail/concurrency", handles.GetThumbnailConcurrency)
+	g.PUT("/thumbnail/concurrency", handles.SetThumbnailConcurrency)
+	g.GET("/thumbnail/cache", handles.GetThumbnailCacheStats)
+	g.GET("/thumbnail/backfill", handles.GetThumbnailBackfill)
+	g.POST("/thumbnail/backfill", handles.StartThumbnailBackfill)
+	g.POST("/thumbnail/backfill/cancel", handles.CancelThumbnailBackfill)
+	g.GET("/upload/in_flight", handles.UploadsInFlight)
+	g.POST("/upload/sign", handles.SignUpload)
+	g.GET("/maintenance/temp", handles.ListTempFiles)
+	g.DELETE("/maintenance/temp", handles.PurgeTempFiles)
+	g.POST("/maintenance/upload_tasks/prune", handles.PruneUploadTasks)
 	// retain /admin/task API to ensure compatibility with legacy automation scripts
 	_task(g.Group("/task"))
 
+	quarantine := g.Group("/quarantine")
+	quarantine.GET("/list", handles.ListQuarantine)
+	quarantine.POST("/approve", handles.ApproveQuarantine)
+	quarantine.POST("/reject", handles.RejectQuarantine)
+
 	ms := g.Group("/message")
 	ms.POST("/get", message.HttpInstance.GetHandle)
 	ms.POST("/send", message.HttpInstance.SendHandle)
@@ -210,16 +243,45 @@ func _fs(g *gin.RouterGroup) {
 	g.POST("/remove", handles.FsRemove)
 	g.POST("/remove_empty_directory", handles.FsRemoveEmptyDirectory)
 	uploadLimiter := middlewares.UploadRateLimiter(stream.ClientUploadLimit)
-	g.PUT("/put", middlewares.FsUp, uploadLimiter, handles.FsStream)
-	g.PUT("/form", middlewares.FsUp, uploadLimiter, handles.FsForm)
+	g.PUT("/put", middlewares.RejectPausedUploads, middlewares.FsUp, uploadLimiter, handles.FsStream)
+	g.PUT("/form", middlewares.RejectPausedUploads, middlewares.FsUp, uploadLimiter, handles.FsForm)
+	g.PUT("/upload", middlewares.RejectPausedUploads, handles.UploadQueryHeaders, middlewares.FsUp, uploadLimiter, handles.FsStream)
+	g.POST("/upload", middlewares.RejectPausedUploads, handles.UploadQueryHeaders, middlewares.FsUp, uploadLimiter, handles.FsStream)
+	g.POST("/touch", handles.FsTouch)
+	g.OPTIONS("/tus", handles.FsTusOptions)
+	g.POST("/tus", middlewares.RejectPausedUploads, handles.FsTusCreate)
+	g.HEAD("/tus/:id", handles.FsTusHead)
+	g.PATCH("/tus/:id", middlewares.RejectPausedUploads, uploadLimiter, handles.FsTusPatch)
+	g.DELETE("/tus/:id", handles.FsTusDelete)
+	g.POST("/tus/:id/keepalive", handles.FsTusKeepalive)
+	g.GET("/upload/capabilities", handles.FsUploadCapabilities)
+	g.GET("/staged/list", handles.ListStagedUploads)
+	g.POST("/staged/commit", handles.CommitStagedUpload)
+	g.POST("/staged/abort", handles.AbortStagedUpload)
+	g.GET("/dead_letter", handles.FsDeadLetters)
+	g.POST("/dead_letter/retry", handles.FsDeadLetterRetry)
+	g.POST("/dead_letter/discard", handles.FsDeadLetterDiscard)
+	g.Any("/video/meta", handles.FsVideoMeta)
+	g.Any("/media/exif", handles.FsMediaExif)
+	g.GET("/media/info", handles.FsMediaInfo)
+	g.Any("/similar", handles.FsSimilar)
+	g.Any("/thumbnail", handles.FsThumbnail)
+	g.Any("/thumbnail/filmstrip", handles.FsFilmstrip)
+	g.POST("/thumbnail/delete", handles.FsThumbnailDelete)
+	g.POST("/thumbnail/generate", handles.FsThumbnailGenerate)
+	g.POST("/thumbnail/status", handles.FsThumbnailStatus)
 	g.POST("/link", middlewares.AuthAdmin, handles.Link)
 	// g.POST("/add_aria2", handles.AddOfflineDownload)
 	// g.POST("/add_qbit", handles.AddQbittorrent)
 	// g.POST("/add_transmission", handles.SetTransmission)
 	g.POST("/add_offline_download", handles.AddOfflineDownload)
 	g.POST("/archive/decompress", handles.FsArchiveDecompress)
+	g.POST("/archive/upload", middlewares.RejectPausedUploads, handles.FsArchiveUploadOpen)
+	g.PUT("/archive/upload/:id", middlewares.RejectPausedUploads, uploadLimiter, handles.FsArchiveUploadAppend)
+	g.POST("/archive/upload/:id/finalize", middlewares.RejectPausedUploads, handles.FsArchiveUploadFinalize)
+	g.DELETE("/archive/upload/:id", handles.FsArchiveUploadAbort)
 	// Direct upload (client-side upload to storage)
-	g.POST("/get_direct_upload_info", middlewares.FsUp, handles.FsGetDirectUploadInfo)
+	g.POST("/get_direct_upload_info", middlewares.RejectPausedUploads, middlewares.FsUp, handles.FsGetDirectUploadInfo)
 }
 
 func _task(g *gin.RouterGroup) {
@@ -90,6 +90,14 @@ func InitWebDAVServer() *gin.Engine {
 }
 
 func ServeWebDAV(c *gin.Context) {
+	if c.Request.Method == http.MethodPut {
+		user, _ := c.Request.Context().Value(conf.UserKey).(*model.User)
+		if middlewares.UploadsPaused(user) {
+			middlewares.SetUploadsPausedRetryAfter(c.Writer.Header())
+			c.String(http.StatusServiceUnavailable, middlewares.UploadsPausedMessage())
+			return
+		}
+	}
 	handler.ServeHTTP(c.Writer, c.Request)
 }
 